@@ -0,0 +1,59 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"blazing/internal/session"
+)
+
+// keygen generates a new session secret and prints the environment for
+// rotating to it from the current SESSION_SECRET and
+// SESSION_PREVIOUS_SECRETS. Rotation takes two deploys so that no
+// instance sees a cookie signed with a key it does not know yet: first
+// every instance learns the new key, then they all start signing with it.
+func keygen(args []string) error {
+	flags := flag.NewFlagSet("keygen", flag.ContinueOnError)
+	keep := flags.Int("keep", 2, "how many previous secrets to keep accepting")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *keep < 1 {
+		return fmt.Errorf("-keep must be at least 1, or the current secret would stop working")
+	}
+
+	secret, err := session.GenerateSecret()
+	if err != nil {
+		return fmt.Errorf("failed to generate secret: %w", err)
+	}
+
+	current := os.Getenv("SESSION_SECRET")
+	previous := session.ParseSecrets(os.Getenv("SESSION_PREVIOUS_SECRETS"))
+	if current == "" {
+		fmt.Printf("# No SESSION_SECRET is set, so there is nothing to rotate from.\n")
+		fmt.Printf("SESSION_SECRET=%s\n", secret)
+		fmt.Printf("# key ID %s\n", session.KeyID(secret))
+		return nil
+	}
+
+	fmt.Printf("# Step 1: stage the new key (ID %s) so every instance accepts it.\n", session.KeyID(secret))
+	fmt.Printf("SESSION_SECRET=%s\n", current)
+	fmt.Printf("SESSION_PREVIOUS_SECRETS=%s\n", joinSecrets(secret, previous, *keep+1))
+	fmt.Println()
+	fmt.Printf("# Step 2: once step 1 is everywhere, sign with the new key. Cookies\n")
+	fmt.Printf("# signed with %s are re-signed as they come in.\n", session.KeyID(current))
+	fmt.Printf("SESSION_SECRET=%s\n", secret)
+	fmt.Printf("SESSION_PREVIOUS_SECRETS=%s\n", joinSecrets(current, previous, *keep))
+	return nil
+}
+
+// joinSecrets puts first in front of previous and keeps at most limit.
+func joinSecrets(first string, previous []string, limit int) string {
+	secrets := append([]string{first}, previous...)
+	if len(secrets) > limit {
+		secrets = secrets[:limit]
+	}
+	return strings.Join(secrets, ",")
+}
@@ -6,8 +6,12 @@ import (
 	"log"
 	"log/slog"
 	"net/http"
+	"net/url"
 	"os"
 	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
 	"syscall"
 	"time"
 
@@ -15,11 +19,21 @@ import (
 	"github.com/go-chi/chi/v5/middleware"
 
 	"blazing/internal/app"
+	"blazing/internal/attachments"
 	"blazing/internal/db"
 	"blazing/internal/handlers"
+	"blazing/internal/session"
 )
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "keygen" {
+		if err := keygen(os.Args[2:]); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	slog.Info("Starting chat server")
 	if err := run(); err != nil {
 		slog.Error("Server failed to run", "error", err)
@@ -40,12 +54,29 @@ func run() error {
 	}
 	defer database.Close()
 
-	application, err := app.New(database, os.Getenv("SESSION_SECRET"))
+	application, err := app.New(database, os.Getenv("SESSION_SECRET"),
+		session.ParseSecrets(os.Getenv("SESSION_PREVIOUS_SECRETS"))...)
 	if err != nil {
 		slog.Error("Failed to create application", "error", err)
 		return fmt.Errorf("failed to create app: %w", err)
 	}
 
+	application.Attachments, err = openAttachmentStore()
+	if err != nil {
+		slog.Error("Failed to open attachment store", "error", err)
+		return fmt.Errorf("failed to initialize attachments: %w", err)
+	}
+
+	if err := configureSessions(application); err != nil {
+		slog.Error("Failed to configure sessions", "error", err)
+		return fmt.Errorf("failed to configure sessions: %w", err)
+	}
+	application.Admins, err = parseAdmins(os.Getenv("ADMIN_GITHUB_IDS"))
+	if err != nil {
+		slog.Error("Invalid ADMIN_GITHUB_IDS", "error", err)
+		return fmt.Errorf("configuration error: %w", err)
+	}
+
 	h, err := handlers.New(application)
 	if err != nil {
 		slog.Error("Failed to create handlers", "error", err)
@@ -56,22 +87,73 @@ func run() error {
 	r.Use(middleware.Logger)
 	r.Use(middleware.Recoverer)
 	r.Use(middleware.RealIP)
-	r.Use(middleware.Timeout(15 * time.Second))
 
-	// Public routes
-	r.Get("/", h.Dashboard)
-	r.Get("/auth/github", h.GitHubAuth)
-	r.Get("/auth/github/callback", h.GitHubCallback)
-	r.Get("/logout", h.Logout)
+	r.Group(func(r chi.Router) {
+		r.Use(middleware.Timeout(15 * time.Second))
 
-	// Authenticated routes
-	r.Route("/rooms", func(r chi.Router) {
-		r.Use(h.RequireAuth)
-		r.Get("/{roomID}", h.Room)
-		r.Post("/", h.CreateRoom)
+		// Public routes
+		r.Get("/", h.Dashboard)
+		r.Get("/auth/github", h.GitHubAuth)
+		r.Get("/auth/github/callback", h.GitHubCallback)
+		r.Get("/logout", h.Logout)
+		r.Get("/invite/{token}", h.AcceptInvite)
+
+		// Authenticated routes
+		r.Route("/rooms", func(r chi.Router) {
+			r.Use(h.RequireAuth)
+			r.Get("/{roomID}", h.Room)
+			r.Post("/", h.CreateRoom)
+			r.Get("/{roomID}/messages", h.RoomMessages)
+			r.Post("/{roomID}/messages", h.CreateMessage)
+			r.Post("/{roomID}/read", h.MarkRead)
+			r.Post("/{roomID}/attachments", h.UploadAttachment)
+			r.Get("/{roomID}/attachments/{attachmentID}", h.Attachment)
+			r.Get("/{roomID}/attachments/{attachmentID}/thumbnail", h.AttachmentThumbnail)
+			r.Get("/{roomID}/messages/{messageID}", h.Message)
+			r.Put("/{roomID}/messages/{messageID}", h.UpdateMessage)
+			r.Delete("/{roomID}/messages/{messageID}", h.DeleteMessage)
+			r.Get("/{roomID}/messages/{messageID}/edit", h.EditMessageForm)
+			r.Get("/{roomID}/messages/{messageID}/history", h.MessageHistory)
+			r.Post("/{roomID}/messages/{messageID}/reactions", h.ToggleReaction)
+			r.Put("/{roomID}/messages/{messageID}/pin", h.PinMessage)
+			r.Delete("/{roomID}/messages/{messageID}/pin", h.UnpinMessage)
+			r.Put("/{roomID}/messages/{messageID}/saved", h.SaveMessage)
+			r.Delete("/{roomID}/messages/{messageID}/saved", h.UnsaveMessage)
+			r.Get("/{roomID}/threads/{messageID}", h.Thread)
+			r.Post("/{roomID}/threads/{messageID}", h.CreateReply)
+			r.Get("/{roomID}/members", h.RoomMembers)
+			r.Post("/{roomID}/members", h.InviteMember)
+			r.Delete("/{roomID}/members/{userID}", h.RemoveMember)
+			r.Put("/{roomID}/members/{userID}/role", h.UpdateMemberRole)
+			r.Post("/{roomID}/invites", h.CreateInvite)
+			r.Delete("/{roomID}/invites/{inviteID}", h.RevokeInvite)
+			r.Post("/{roomID}/owner", h.TransferOwnership)
+			r.Put("/{roomID}/notifications", h.UpdateNotifications)
+			r.Put("/{roomID}/policies", h.UpdateRoomPolicies)
+			r.Put("/{roomID}/name", h.RenameRoom)
+			r.Put("/{roomID}/topic", h.UpdateRoomTopic)
+			r.Put("/{roomID}/archive", h.ArchiveRoom)
+			r.Delete("/{roomID}/archive", h.UnarchiveRoom)
+			r.Delete("/{roomID}", h.DeleteRoom)
+		})
+		r.With(h.RequireAuth).Post("/dms", h.CreateDirectMessage)
+		r.With(h.RequireAuth).Get("/search", h.Search)
+		r.With(h.RequireAuth).Get("/saved", h.SavedMessages)
+		r.Route("/sessions", func(r chi.Router) {
+			r.Use(h.RequireAuth)
+			r.Get("/", h.Sessions)
+			r.Delete("/", h.RevokeOtherSessions)
+			r.Delete("/{sessionID}", h.RevokeSession)
+		})
+		r.With(h.RequireAuth).Post("/admin/sessions/revoke", h.RevokeUserSessions)
+		r.With(h.RequireAuth).Post("/admin/users/disable", h.DisableUser)
+		r.With(h.RequireAuth).Post("/admin/users/enable", h.EnableUser)
 	})
+
+	// WebSockets are long-lived, so they sit outside the request timeout
 	r.Route("/ws", func(r chi.Router) {
 		r.Use(h.RequireAuth)
+		r.Get("/", h.DashboardWebSocket)
 		r.Get("/{roomID}", h.WebSocket)
 	})
 
@@ -119,6 +201,86 @@ func run() error {
 	return nil
 }
 
+// openAttachmentStore picks where uploads live from ATTACHMENT_STORAGE:
+// "local" (the default) keeps them in an attachments directory next to the
+// database, "s3" in a bucket on S3 or a compatible service.
+func openAttachmentStore() (attachments.Store, error) {
+	switch storage := os.Getenv("ATTACHMENT_STORAGE"); storage {
+	case "", "local":
+		dir := filepath.Join(filepath.Dir(db.Path()), "attachments")
+		slog.Info("Storing attachments on local disk", "dir", dir)
+		return attachments.NewLocal(dir)
+	case "s3":
+		cfg := attachments.S3Config{
+			Endpoint:  os.Getenv("S3_ENDPOINT"),
+			Region:    os.Getenv("S3_REGION"),
+			Bucket:    os.Getenv("S3_BUCKET"),
+			AccessKey: os.Getenv("S3_ACCESS_KEY_ID"),
+			SecretKey: os.Getenv("S3_SECRET_ACCESS_KEY"),
+			Prefix:    os.Getenv("S3_PREFIX"),
+		}
+		slog.Info("Storing attachments in S3", "endpoint", cfg.Endpoint, "bucket", cfg.Bucket, "prefix", cfg.Prefix)
+		return attachments.NewS3(cfg)
+	default:
+		return nil, fmt.Errorf("unknown ATTACHMENT_STORAGE %q, expected local or s3", storage)
+	}
+}
+
+// configureSessions picks where sessions live from SESSION_STORE: "cookie"
+// (the default) keeps everything in the encrypted cookie, "database" keeps
+// them server-side so they can be listed and revoked. SESSION_LIFETIME and
+// SESSION_IDLE_TIMEOUT set how long they last.
+func configureSessions(application *app.App) error {
+	expiry, err := session.ParseExpiry(os.Getenv("SESSION_LIFETIME"), os.Getenv("SESSION_IDLE_TIMEOUT"))
+	if err != nil {
+		return err
+	}
+	if err := application.Session.UseExpiry(expiry); err != nil {
+		return err
+	}
+	slog.Info("Session expiry configured", "lifetime", expiry.Lifetime, "idle_timeout", expiry.IdleTimeout)
+
+	if raw := os.Getenv("SESSION_LEGACY_COOKIES_UNTIL"); raw != "" {
+		until, err := session.ParseLegacyUntil(raw)
+		if err != nil {
+			return err
+		}
+		if err := application.Session.AcceptLegacyUntil(until); err != nil {
+			return fmt.Errorf("invalid SESSION_LEGACY_COOKIES_UNTIL: %w", err)
+		}
+		slog.Info("Legacy session cookies accepted until", "until", until)
+	}
+
+	switch storage := os.Getenv("SESSION_STORE"); storage {
+	case "", "cookie":
+		slog.Info("Keeping sessions in cookies")
+	case "database":
+		slog.Info("Keeping sessions in the database")
+		application.Session.UseStore(session.NewDBStore(application.DB, expiry))
+	default:
+		return fmt.Errorf("unknown SESSION_STORE %q, expected cookie or database", storage)
+	}
+	return nil
+}
+
+// parseAdmins reads ADMIN_GITHUB_IDS, a comma-separated list of numeric
+// GitHub user IDs.
+func parseAdmins(raw string) (map[int64]bool, error) {
+	admins := make(map[int64]bool)
+	for _, field := range strings.Split(raw, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		id, err := strconv.ParseInt(field, 10, 64)
+		if err != nil || id <= 0 {
+			return nil, fmt.Errorf("ADMIN_GITHUB_IDS entry %q is not a GitHub user ID", field)
+		}
+		admins[id] = true
+	}
+	return admins, nil
+}
+
 // SESSION_SECRET and GITHUB_CLIENT_ID/GITHUB_CLIENT_SECRET are always required
 func validateConfig() error {
 	sessionSecret := os.Getenv("SESSION_SECRET")
@@ -131,7 +293,14 @@ func validateConfig() error {
 			"required_min", 32)
 		return fmt.Errorf("SESSION_SECRET must be at least 32 characters")
 	}
-	slog.Info("SESSION_SECRET validated", "length", len(sessionSecret))
+	slog.Info("SESSION_SECRET validated", "length", len(sessionSecret), "key_id", session.KeyID(sessionSecret))
+
+	for _, secret := range session.ParseSecrets(os.Getenv("SESSION_PREVIOUS_SECRETS")) {
+		if len(secret) < 32 {
+			return fmt.Errorf("every SESSION_PREVIOUS_SECRETS entry must be at least 32 characters")
+		}
+		slog.Info("Previous session secret accepted", "key_id", session.KeyID(secret))
+	}
 
 	if os.Getenv("GITHUB_CLIENT_ID") == "" {
 		slog.Error("GITHUB_CLIENT_ID missing - create a GitHub OAuth app and set this environment variable")
@@ -143,5 +312,16 @@ func validateConfig() error {
 	}
 	slog.Info("GitHub OAuth credentials validated")
 
+	if os.Getenv("ADMIN_LOGINS") != "" {
+		return fmt.Errorf("ADMIN_LOGINS is no longer read, since logins can change hands; list GitHub user IDs in ADMIN_GITHUB_IDS instead")
+	}
+
+	if baseURL := os.Getenv("BASE_URL"); baseURL != "" {
+		u, err := url.Parse(baseURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("BASE_URL must be an absolute http or https URL, such as https://chat.example.com")
+		}
+	}
+
 	return nil
 }
@@ -6,4 +6,5 @@ require (
 	github.com/go-chi/chi/v5 v5.0.11
 	github.com/mattn/go-sqlite3 v1.14.22
 	golang.org/x/oauth2 v0.30.0
+	nhooyr.io/websocket v1.8.17
 )
@@ -1,30 +1,87 @@
 package app
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 
+	"blazing/internal/attachments"
 	"blazing/internal/db"
+	"blazing/internal/hub"
+	"blazing/internal/presence"
 	"blazing/internal/session"
 )
 
 type App struct {
-	DB      *db.Queries
-	Session *session.Manager
+	DB       *db.Queries
+	Session  *session.Manager
+	Hub      *hub.Hub
+	Presence *presence.Tracker
+
+	// Attachments holds uploaded files; main picks the backend
+	Attachments attachments.Store
+
+	// Admins are the GitHub user IDs allowed to act on other people's
+	// accounts, such as signing them out everywhere. IDs are used rather
+	// than logins because a login can be renamed and then claimed by
+	// someone else.
+	Admins map[int64]bool
+
+	// SearchEnabled is false when SQLite was built without FTS5
+	SearchEnabled bool
+
+	sqlDB *sql.DB
 }
 
-func New(database *sql.DB, sessionSecret string) (*App, error) {
+// New builds the app. Sessions are signed with sessionSecret; anything
+// signed with one of previousSecrets is still accepted, so the secret can
+// be rotated without signing everyone out.
+func New(database *sql.DB, sessionSecret string, previousSecrets ...string) (*App, error) {
 	if database == nil {
 		return nil, fmt.Errorf("database is required")
 	}
 
-	sessionManager, err := session.NewManager(sessionSecret)
+	sessionManager, err := session.NewManager(sessionSecret, previousSecrets...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create session manager: %w", err)
 	}
 
+	queries := db.New(database)
+	upgraded, err := queries.GetSessionUpgrade(context.Background())
+	if err != nil {
+		return nil, fmt.Errorf("failed to read session upgrade time: %w", err)
+	}
+	if upgraded.Valid {
+		sessionManager.AcceptLegacyFrom(upgraded.Time)
+	}
+
 	return &App{
-		DB:      db.New(database),
-		Session: sessionManager,
+		DB:       queries,
+		Session:  sessionManager,
+		Hub:      hub.New(),
+		Presence: presence.New(presence.DefaultGrace),
+
+		SearchEnabled: db.FTS5Available(database),
+
+		sqlDB: database,
 	}, nil
 }
+
+// WithTx runs fn inside a transaction, committing if fn returns nil and
+// rolling back otherwise.
+func (a *App) WithTx(ctx context.Context, fn func(q *db.Queries) error) error {
+	tx, err := a.sqlDB.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+
+	if err := fn(a.DB.WithTx(tx)); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
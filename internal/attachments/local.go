@@ -0,0 +1,91 @@
+package attachments
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+var _ Store = (*Local)(nil)
+
+// Local keeps files in a directory on disk.
+type Local struct {
+	dir string
+}
+
+// NewLocal keeps files under dir, creating it if needed.
+func NewLocal(dir string) (*Local, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create attachment directory: %w", err)
+	}
+	return &Local{dir: dir}, nil
+}
+
+// Put copies r into the store and returns its key and size. Content is
+// written to a temporary file first and only moved into place once it is
+// complete, so readers never see a partial file.
+func (s *Local) Put(ctx context.Context, r io.Reader) (string, int64, error) {
+	tmp, err := os.CreateTemp(s.dir, ".upload-*")
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to create temporary file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	hash := sha256.New()
+	size, err := io.Copy(io.MultiWriter(tmp, hash), r)
+	if err != nil {
+		tmp.Close()
+		return "", 0, fmt.Errorf("failed to write upload: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return "", 0, fmt.Errorf("failed to write upload: %w", err)
+	}
+
+	key := hex.EncodeToString(hash.Sum(nil))
+	path := s.path(key)
+	if _, err := os.Stat(path); err == nil {
+		return key, size, nil
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return "", 0, fmt.Errorf("failed to create attachment directory: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return "", 0, fmt.Errorf("failed to store upload: %w", err)
+	}
+	return key, size, nil
+}
+
+// Open returns the file stored under key.
+func (s *Local) Open(ctx context.Context, key string) (io.ReadCloser, error) {
+	if !validKey(key) {
+		return nil, ErrInvalidKey
+	}
+	f, err := os.Open(s.path(key))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	return f, err
+}
+
+// Delete removes the file stored under key.
+func (s *Local) Delete(ctx context.Context, key string) error {
+	if !validKey(key) {
+		return ErrInvalidKey
+	}
+	if err := os.Remove(s.path(key)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("failed to delete attachment: %w", err)
+	}
+	return nil
+}
+
+// path fans files out over subdirectories named for the first byte of the
+// key, keeping directories small.
+func (s *Local) path(key string) string {
+	return filepath.Join(s.dir, key[:2], key)
+}
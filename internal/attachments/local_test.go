@@ -0,0 +1,70 @@
+package attachments
+
+import (
+	"context"
+	"io"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLocal(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	store, err := NewLocal(dir)
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+
+	key, size, err := store.Put(ctx, strings.NewReader("hello"))
+	if err != nil {
+		t.Fatalf("Failed to put: %v", err)
+	}
+	// SHA-256 of "hello"
+	if key != "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824" || size != 5 {
+		t.Errorf("Expected the content hash and size, got %s (%d)", key, size)
+	}
+
+	again, _, err := store.Put(ctx, strings.NewReader("hello"))
+	if err != nil || again != key {
+		t.Errorf("Expected the same key for the same content, got %s (%v)", again, err)
+	}
+
+	f, err := store.Open(ctx, key)
+	if err != nil {
+		t.Fatalf("Failed to open: %v", err)
+	}
+	defer f.Close()
+	if data, _ := io.ReadAll(f); string(data) != "hello" {
+		t.Errorf("Expected the stored content, got %q", data)
+	}
+
+	entries, err := filepath.Glob(filepath.Join(dir, ".upload-*"))
+	if err != nil || len(entries) != 0 {
+		t.Errorf("Expected no temporary files left behind, got %v", entries)
+	}
+
+	for _, bad := range []string{"", "../../etc/passwd", strings.Repeat("z", 64)} {
+		if _, err := store.Open(ctx, bad); err != ErrInvalidKey {
+			t.Errorf("Open(%q) = %v, want ErrInvalidKey", bad, err)
+		}
+	}
+
+	missing := strings.Repeat("0", 64)
+	if _, err := store.Open(ctx, missing); err != ErrNotFound {
+		t.Errorf("Expected a missing file, got %v", err)
+	}
+
+	if err := store.Delete(ctx, key); err != nil {
+		t.Fatalf("Failed to delete: %v", err)
+	}
+	if _, err := store.Open(ctx, key); err != ErrNotFound {
+		t.Errorf("Expected the file to be gone, got %v", err)
+	}
+	if err := store.Delete(ctx, key); err != nil {
+		t.Errorf("Expected deleting again to succeed, got %v", err)
+	}
+	if err := store.Delete(ctx, "../../etc/passwd"); err != ErrInvalidKey {
+		t.Errorf("Expected ErrInvalidKey, got %v", err)
+	}
+}
@@ -0,0 +1,41 @@
+// Package attachments keeps the content of uploaded files. Files are
+// addressed by the SHA-256 of their content, so the same upload is only
+// stored once however often it is shared.
+package attachments
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+)
+
+var (
+	ErrInvalidKey = errors.New("invalid attachment key")
+	ErrNotFound   = errors.New("attachment not found")
+)
+
+// Store is a place to keep file content: the local disk, or an
+// S3-compatible bucket.
+type Store interface {
+	// Put stores everything read from r and returns its key and size.
+	Put(ctx context.Context, r io.Reader) (string, int64, error)
+
+	// Open returns the content stored under key, or ErrNotFound. Stores
+	// that can seek return an io.ReadSeekCloser, so downloads can resume.
+	Open(ctx context.Context, key string) (io.ReadCloser, error)
+
+	// Delete removes the content stored under key. Deleting content that
+	// is already gone is not an error. Keys are shared by identical
+	// uploads, so callers check nothing else refers to the key first.
+	Delete(ctx context.Context, key string) error
+}
+
+func validKey(key string) bool {
+	if len(key) != sha256.Size*2 {
+		return false
+	}
+	_, err := hex.DecodeString(key)
+	return err == nil
+}
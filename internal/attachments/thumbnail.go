@@ -0,0 +1,113 @@
+package attachments
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/draw"
+	_ "image/gif"
+	"image/jpeg"
+	_ "image/png"
+	"io"
+)
+
+const (
+	// ThumbnailSize bounds both sides of a thumbnail, in pixels
+	ThumbnailSize = 320
+
+	// Larger images are stored but not decoded, so a small file that
+	// claims huge dimensions cannot exhaust memory. Decoding takes up to
+	// 8 bytes a pixel, which keeps each upload to about 128 MB.
+	maxThumbnailPixels = 16_000_000
+)
+
+var ErrImageTooLarge = errors.New("image too large to thumbnail")
+
+// Thumbnail decodes a GIF, JPEG or PNG image and returns a JPEG copy scaled
+// to fit within size×size, together with the original's dimensions.
+// Transparent areas become white.
+func Thumbnail(r io.ReadSeeker, size int) (thumb []byte, width, height int, err error) {
+	config, _, err := image.DecodeConfig(r)
+	if err != nil {
+		return nil, 0, 0, fmt.Errorf("failed to read image header: %w", err)
+	}
+	if config.Width*config.Height > maxThumbnailPixels {
+		return nil, 0, 0, ErrImageTooLarge
+	}
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return nil, 0, 0, err
+	}
+
+	src, _, err := image.Decode(r)
+	if err != nil {
+		return nil, 0, 0, fmt.Errorf("failed to decode image: %w", err)
+	}
+	bounds := src.Bounds()
+	width, height = bounds.Dx(), bounds.Dy()
+	if width == 0 || height == 0 {
+		return nil, 0, 0, errors.New("image is empty")
+	}
+
+	rgba := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(rgba, rgba.Bounds(), src, bounds.Min, draw.Src)
+
+	dst := downscale(rgba, fit(width, height, size))
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, dst, &jpeg.Options{Quality: 80}); err != nil {
+		return nil, 0, 0, fmt.Errorf("failed to encode thumbnail: %w", err)
+	}
+	return buf.Bytes(), width, height, nil
+}
+
+// fit scales width×height down to fit within size×size, keeping the aspect
+// ratio. Images that already fit keep their size.
+func fit(width, height, size int) image.Rectangle {
+	w, h := width, height
+	if w > size || h > size {
+		if w >= h {
+			w, h = size, max(1, height*size/width)
+		} else {
+			w, h = max(1, width*size/height), size
+		}
+	}
+	return image.Rect(0, 0, w, h)
+}
+
+// downscale averages every source pixel covered by each destination pixel
+// (a box filter), flattening transparency onto white as it goes.
+func downscale(src *image.RGBA, rect image.Rectangle) *image.RGBA {
+	sw, sh := src.Rect.Dx(), src.Rect.Dy()
+	dw, dh := rect.Dx(), rect.Dy()
+	dst := image.NewRGBA(rect)
+
+	for dy := 0; dy < dh; dy++ {
+		y0, y1 := dy*sh/dh, max((dy+1)*sh/dh, dy*sh/dh+1)
+		for dx := 0; dx < dw; dx++ {
+			x0, x1 := dx*sw/dw, max((dx+1)*sw/dw, dx*sw/dw+1)
+
+			var r, g, b, a, n int
+			for y := y0; y < y1; y++ {
+				row := src.Pix[y*src.Stride:]
+				for x := x0; x < x1; x++ {
+					p := row[x*4 : x*4+4]
+					r += int(p[0])
+					g += int(p[1])
+					b += int(p[2])
+					a += int(p[3])
+					n++
+				}
+			}
+
+			// RGBA is premultiplied, so white shows through by 255-a
+			white := 255 - a/n
+			i := dy*dst.Stride + dx*4
+			dst.Pix[i+0] = uint8(r/n + white)
+			dst.Pix[i+1] = uint8(g/n + white)
+			dst.Pix[i+2] = uint8(b/n + white)
+			dst.Pix[i+3] = 255
+		}
+	}
+	return dst
+}
@@ -0,0 +1,92 @@
+package attachments
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"strings"
+	"testing"
+)
+
+func TestThumbnail(t *testing.T) {
+	src := image.NewNRGBA(image.Rect(0, 0, 800, 400))
+	for y := 0; y < 400; y++ {
+		for x := 0; x < 800; x++ {
+			// Left half opaque red, right half fully transparent
+			if x < 400 {
+				src.Set(x, y, color.NRGBA{R: 255, A: 255})
+			}
+		}
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, src); err != nil {
+		t.Fatalf("Failed to encode test image: %v", err)
+	}
+
+	thumb, width, height, err := Thumbnail(bytes.NewReader(buf.Bytes()), ThumbnailSize)
+	if err != nil {
+		t.Fatalf("Failed to thumbnail: %v", err)
+	}
+	if width != 800 || height != 400 {
+		t.Errorf("Expected the original dimensions, got %dx%d", width, height)
+	}
+
+	img, err := jpeg.Decode(bytes.NewReader(thumb))
+	if err != nil {
+		t.Fatalf("Expected a JPEG thumbnail: %v", err)
+	}
+	if b := img.Bounds(); b.Dx() != ThumbnailSize || b.Dy() != ThumbnailSize/2 {
+		t.Errorf("Expected %dx%d, got %dx%d", ThumbnailSize, ThumbnailSize/2, b.Dx(), b.Dy())
+	}
+
+	r, g, _, _ := img.At(10, 10).RGBA()
+	if r>>8 < 200 || g>>8 > 60 {
+		t.Errorf("Expected red on the left, got r=%d g=%d", r>>8, g>>8)
+	}
+	r, g, _, _ = img.At(ThumbnailSize-10, 10).RGBA()
+	if r>>8 < 200 || g>>8 < 200 {
+		t.Errorf("Expected transparency to become white, got r=%d g=%d", r>>8, g>>8)
+	}
+}
+
+func TestThumbnailRejects(t *testing.T) {
+	if _, _, _, err := Thumbnail(strings.NewReader("not an image"), ThumbnailSize); err == nil {
+		t.Error("Expected an error for non-image data")
+	}
+
+	// A valid header claiming far more pixels than we are willing to decode
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewGray(image.Rect(0, 0, 1, 1))); err != nil {
+		t.Fatalf("Failed to encode test image: %v", err)
+	}
+	data := buf.Bytes()
+	// IHDR width and height are the big-endian words at offsets 16 and 20,
+	// followed by the chunk's type and data checksum
+	binary.BigEndian.PutUint32(data[16:], 5000)
+	binary.BigEndian.PutUint32(data[20:], 4000)
+	binary.BigEndian.PutUint32(data[29:], crc32.ChecksumIEEE(data[12:29]))
+	if _, _, _, err := Thumbnail(bytes.NewReader(data), ThumbnailSize); err != ErrImageTooLarge {
+		t.Errorf("Expected ErrImageTooLarge, got %v", err)
+	}
+}
+
+func TestFit(t *testing.T) {
+	tests := []struct {
+		w, h, size int
+		want       image.Point
+	}{
+		{100, 50, 320, image.Pt(100, 50)},
+		{640, 480, 320, image.Pt(320, 240)},
+		{480, 640, 320, image.Pt(240, 320)},
+		{10000, 1, 320, image.Pt(320, 1)},
+	}
+	for _, tt := range tests {
+		if got := fit(tt.w, tt.h, tt.size).Size(); got != tt.want {
+			t.Errorf("fit(%d, %d, %d) = %v, want %v", tt.w, tt.h, tt.size, got, tt.want)
+		}
+	}
+}
@@ -22,9 +22,11 @@ func RunMigrations(db *sql.DB) error {
 		return fmt.Errorf("failed to get migration files: %w", err)
 	}
 
+	pending := 0
 	for _, filename := range migrationFiles {
 		if _, applied := appliedMigrations[filename]; !applied {
 			log.Printf("Running migration: %s", filename)
+			pending++
 
 			if err := runMigration(db, filename); err != nil {
 				return fmt.Errorf("failed to run migration %s: %w", filename, err)
@@ -38,8 +40,8 @@ func RunMigrations(db *sql.DB) error {
 		}
 	}
 
-	if len(migrationFiles) > len(appliedMigrations) {
-		log.Printf("All migrations completed (%d applied)", len(migrationFiles)-len(appliedMigrations))
+	if pending > 0 {
+		log.Printf("All migrations completed (%d applied)", pending)
 	}
 
 	return nil
@@ -0,0 +1,2 @@
+-- History is paged by message id within a room (before/after cursors)
+CREATE INDEX idx_messages_room_id_id ON messages(room_id, id);
@@ -0,0 +1,32 @@
+-- Teammates can be invited by GitHub login before they have ever signed in,
+-- so github_uid stays NULL until their first OAuth callback claims the row.
+-- SQLite cannot relax NOT NULL in place, so the table is rebuilt.
+PRAGMA foreign_keys = OFF;
+
+CREATE TABLE users_new (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    github_uid INTEGER UNIQUE,
+    login TEXT NOT NULL UNIQUE COLLATE NOCASE,
+    avatar_url TEXT,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+INSERT INTO users_new (id, github_uid, login, avatar_url, created_at, updated_at)
+SELECT id, github_uid, login, avatar_url, created_at, updated_at FROM users;
+
+DROP TABLE users;
+ALTER TABLE users_new RENAME TO users;
+
+CREATE INDEX idx_users_github_uid ON users(github_uid);
+CREATE INDEX idx_users_login ON users(login);
+
+CREATE TRIGGER update_users_updated_at 
+    AFTER UPDATE ON users 
+    FOR EACH ROW 
+    WHEN NEW.updated_at <= OLD.updated_at
+BEGIN
+    UPDATE users SET updated_at = CURRENT_TIMESTAMP WHERE id = NEW.id;
+END;
+
+PRAGMA foreign_keys = ON;
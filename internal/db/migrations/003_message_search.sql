@@ -0,0 +1,5 @@
+-- Message search used to be created here. It needs SQLite built with FTS5,
+-- which not every binary has, so the index now lives in search.sql and is
+-- set up by OpenSQLite instead; databases that ran the old version keep
+-- their index.
+SELECT 1;
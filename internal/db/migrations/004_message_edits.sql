@@ -0,0 +1,15 @@
+-- Messages can be corrected or withdrawn. Deletes are soft so history
+-- pagination keeps its shape; the UI renders a placeholder instead.
+ALTER TABLE messages ADD COLUMN edited_at DATETIME;
+ALTER TABLE messages ADD COLUMN deleted_at DATETIME;
+
+-- Previous bodies, one row per edit
+CREATE TABLE message_edits (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    message_id INTEGER NOT NULL REFERENCES messages(id) ON DELETE CASCADE,
+    editor_id INTEGER NOT NULL REFERENCES users(id),
+    body TEXT NOT NULL,
+    edited_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_message_edits_message_id ON message_edits(message_id);
@@ -0,0 +1,22 @@
+-- Replies hang off a top-level message and stay out of the room timeline.
+-- The parent carries a denormalized count and last reply time so history
+-- pages can show thread summaries without aggregating.
+ALTER TABLE messages ADD COLUMN parent_message_id INTEGER REFERENCES messages(id);
+ALTER TABLE messages ADD COLUMN reply_count INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE messages ADD COLUMN last_reply_at DATETIME;
+
+CREATE INDEX idx_messages_parent_id ON messages(parent_message_id, id);
+
+CREATE TRIGGER messages_reply_created AFTER INSERT ON messages
+WHEN NEW.parent_message_id IS NOT NULL
+BEGIN
+    UPDATE messages SET reply_count = reply_count + 1, last_reply_at = NEW.created_at
+    WHERE id = NEW.parent_message_id;
+END;
+
+CREATE TRIGGER messages_reply_deleted AFTER UPDATE OF deleted_at ON messages
+WHEN NEW.parent_message_id IS NOT NULL AND OLD.deleted_at IS NULL AND NEW.deleted_at IS NOT NULL
+BEGIN
+    UPDATE messages SET reply_count = reply_count - 1
+    WHERE id = NEW.parent_message_id;
+END;
@@ -0,0 +1,8 @@
+-- Each member's position in the room timeline. Messages after it count as
+-- unread on the dashboard.
+ALTER TABLE room_memberships ADD COLUMN last_read_message_id INTEGER NOT NULL DEFAULT 0;
+
+-- Existing members start caught up rather than with their whole history unread
+UPDATE room_memberships SET last_read_message_id = COALESCE(
+    (SELECT MAX(id) FROM messages WHERE messages.room_id = room_memberships.room_id), 0
+);
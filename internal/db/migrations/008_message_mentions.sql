@@ -0,0 +1,10 @@
+-- Who each message notifies. kind records why: a direct @login, or an
+-- @room/@here broadcast expanded to the members it reached at send time.
+CREATE TABLE message_mentions (
+    message_id INTEGER NOT NULL REFERENCES messages(id) ON DELETE CASCADE,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    kind TEXT NOT NULL CHECK (kind IN ('user', 'room', 'here')),
+    PRIMARY KEY (message_id, user_id, kind)
+);
+
+CREATE INDEX idx_message_mentions_user_id ON message_mentions(user_id);
@@ -0,0 +1,7 @@
+-- Direct conversations are rooms with a fixed set of members. dm_key is
+-- their sorted, comma-separated user ids, so the same people always land
+-- in the same conversation.
+ALTER TABLE rooms ADD COLUMN kind TEXT NOT NULL DEFAULT 'room' CHECK (kind IN ('room', 'dm'));
+ALTER TABLE rooms ADD COLUMN dm_key TEXT;
+
+CREATE UNIQUE INDEX idx_rooms_dm_key ON rooms(dm_key) WHERE dm_key IS NOT NULL;
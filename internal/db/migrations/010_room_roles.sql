@@ -0,0 +1,20 @@
+-- Authority in a room comes from each member's role rather than from who
+-- created it. Existing creators become owners; direct conversations have
+-- no owner.
+ALTER TABLE room_memberships ADD COLUMN role TEXT NOT NULL DEFAULT 'member'
+    CHECK (role IN ('owner', 'admin', 'member', 'readonly'));
+
+UPDATE room_memberships SET role = 'owner'
+WHERE EXISTS (
+    SELECT 1 FROM rooms r
+    WHERE r.id = room_memberships.room_id
+        AND r.creator_id = room_memberships.user_id
+        AND r.kind = 'room'
+);
+
+-- Who may post and invite: any member who is not read-only, or admins only,
+-- as in announcement rooms
+ALTER TABLE rooms ADD COLUMN post_policy TEXT NOT NULL DEFAULT 'members'
+    CHECK (post_policy IN ('members', 'admins'));
+ALTER TABLE rooms ADD COLUMN invite_policy TEXT NOT NULL DEFAULT 'members'
+    CHECK (invite_policy IN ('members', 'admins'));
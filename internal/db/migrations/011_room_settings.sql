@@ -0,0 +1,9 @@
+-- Rooms can carry a topic, and be archived: kept readable but frozen and
+-- out of the way on the dashboard.
+ALTER TABLE rooms ADD COLUMN topic TEXT NOT NULL DEFAULT '';
+ALTER TABLE rooms ADD COLUMN archived_at DATETIME;
+
+-- System messages record changes to the room itself in its timeline. Their
+-- user_id is whoever made the change.
+ALTER TABLE messages ADD COLUMN kind TEXT NOT NULL DEFAULT 'message'
+    CHECK (kind IN ('message', 'system'));
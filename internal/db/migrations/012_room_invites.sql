@@ -0,0 +1,15 @@
+-- Shareable invite links. The link itself carries the invite's id signed
+-- with the session key; this table holds what can change after it has been
+-- handed out: expiry, how often it may be used, and whether it was revoked.
+CREATE TABLE room_invites (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    room_id INTEGER NOT NULL REFERENCES rooms(id) ON DELETE CASCADE,
+    creator_id INTEGER NOT NULL REFERENCES users(id),
+    expires_at DATETIME,
+    max_uses INTEGER CHECK (max_uses > 0),
+    use_count INTEGER NOT NULL DEFAULT 0,
+    revoked_at DATETIME,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_room_invites_room_id ON room_invites(room_id);
@@ -0,0 +1,18 @@
+-- Files uploaded with a message. The content lives outside the database,
+-- under storage_key, the SHA-256 of the file; images also get a thumbnail.
+-- width and height are the original image's, and zero for other files.
+CREATE TABLE attachments (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    message_id INTEGER NOT NULL REFERENCES messages(id) ON DELETE CASCADE,
+    user_id INTEGER NOT NULL REFERENCES users(id),
+    storage_key TEXT NOT NULL,
+    thumbnail_key TEXT,
+    filename TEXT NOT NULL,
+    content_type TEXT NOT NULL,
+    size INTEGER NOT NULL,
+    width INTEGER NOT NULL DEFAULT 0,
+    height INTEGER NOT NULL DEFAULT 0,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_attachments_message_id ON attachments(message_id);
@@ -0,0 +1,5 @@
+-- How loudly each room reaches a member: every message, only mentions of
+-- them, or nothing. muted_until silences the room entirely until it passes.
+ALTER TABLE room_memberships ADD COLUMN notify_level TEXT NOT NULL DEFAULT 'all'
+    CHECK (notify_level IN ('all', 'mentions', 'none'));
+ALTER TABLE room_memberships ADD COLUMN muted_until DATETIME;
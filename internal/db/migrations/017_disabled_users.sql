@@ -0,0 +1,3 @@
+-- Lets admins disable an account. A disabled user cannot sign in, and
+-- sessions they already hold stop working on their next request.
+ALTER TABLE users ADD COLUMN disabled_at DATETIME;
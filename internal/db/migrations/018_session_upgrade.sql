@@ -0,0 +1,10 @@
+-- Records when the server first ran with session cookies that carry their
+-- issue time. Older cookies cannot say how old they are, so they are
+-- accepted for a week from here; keeping the instant in the database stops
+-- each restart from granting them another week.
+CREATE TABLE session_upgrade (
+    id INTEGER PRIMARY KEY CHECK (id = 1),
+    upgraded_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+INSERT INTO session_upgrade (id) VALUES (1);
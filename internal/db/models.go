@@ -8,11 +8,56 @@ import (
 	"database/sql"
 )
 
+type Attachment struct {
+	ID           int64
+	MessageID    int64
+	UserID       int64
+	StorageKey   string
+	ThumbnailKey sql.NullString
+	Filename     string
+	ContentType  string
+	Size         int64
+	Width        int64
+	Height       int64
+	CreatedAt    sql.NullTime
+}
+
 type Message struct {
+	ID              int64
+	RoomID          int64
+	UserID          int64
+	Body            string
+	CreatedAt       sql.NullTime
+	EditedAt        sql.NullTime
+	DeletedAt       sql.NullTime
+	ParentMessageID sql.NullInt64
+	ReplyCount      int64
+	LastReplyAt     sql.NullTime
+	Kind            string
+}
+
+type MessageEdit struct {
 	ID        int64
-	RoomID    int64
-	UserID    int64
+	MessageID int64
+	EditorID  int64
 	Body      string
+	EditedAt  sql.NullTime
+}
+
+type MessagesFt struct {
+	Body string
+}
+
+type MessageMention struct {
+	MessageID int64
+	UserID    int64
+	Kind      string
+}
+
+type MessageReaction struct {
+	MessageID int64
+	UserID    int64
+	Emoji     string
 	CreatedAt sql.NullTime
 }
 
@@ -21,25 +66,75 @@ type Migration struct {
 	AppliedAt sql.NullTime
 }
 
+type PinnedMessage struct {
+	RoomID    int64
+	MessageID int64
+	PinnedBy  int64
+	CreatedAt sql.NullTime
+}
+
 type Room struct {
+	ID           int64
+	Name         string
+	CreatorID    int64
+	CreatedAt    sql.NullTime
+	UpdatedAt    sql.NullTime
+	Kind         string
+	DmKey        sql.NullString
+	PostPolicy   string
+	InvitePolicy string
+	Topic        string
+	ArchivedAt   sql.NullTime
+}
+
+type RoomInvite struct {
 	ID        int64
-	Name      string
+	RoomID    int64
 	CreatorID int64
+	ExpiresAt sql.NullTime
+	MaxUses   sql.NullInt64
+	UseCount  int64
+	RevokedAt sql.NullTime
 	CreatedAt sql.NullTime
-	UpdatedAt sql.NullTime
 }
 
 type RoomMembership struct {
-	RoomID   int64
-	UserID   int64
-	JoinedAt sql.NullTime
+	RoomID            int64
+	UserID            int64
+	JoinedAt          sql.NullTime
+	LastReadMessageID int64
+	Role              string
+	NotifyLevel       string
+	MutedUntil        sql.NullTime
 }
 
-type User struct {
-	ID        int64
-	GithubUid int64
-	Login     string
-	AvatarUrl sql.NullString
+type SavedMessage struct {
+	UserID    int64
+	MessageID int64
 	CreatedAt sql.NullTime
-	UpdatedAt sql.NullTime
+}
+
+type Session struct {
+	ID         int64
+	TokenHash  string
+	UserID     int64
+	UserAgent  string
+	IpAddress  string
+	CreatedAt  sql.NullTime
+	LastSeenAt sql.NullTime
+}
+
+type SessionUpgrade struct {
+	ID         int64
+	UpgradedAt sql.NullTime
+}
+
+type User struct {
+	ID         int64
+	GithubUid  sql.NullInt64
+	Login      string
+	AvatarUrl  sql.NullString
+	CreatedAt  sql.NullTime
+	UpdatedAt  sql.NullTime
+	DisabledAt sql.NullTime
 }
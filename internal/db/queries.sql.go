@@ -8,21 +8,1724 @@ package db
 import (
 	"context"
 	"database/sql"
+	"strings"
 )
 
+const archiveRoom = `-- name: ArchiveRoom :exec
+UPDATE rooms SET archived_at = CURRENT_TIMESTAMP WHERE id = ? AND archived_at IS NULL
+`
+
+func (q *Queries) ArchiveRoom(ctx context.Context, id int64) error {
+	_, err := q.db.ExecContext(ctx, archiveRoom, id)
+	return err
+}
+
+const attachmentKeyInUse = `-- name: AttachmentKeyInUse :one
+SELECT EXISTS (
+    SELECT 1 FROM attachments
+    WHERE storage_key = ?1 OR thumbnail_key = ?1
+) AS in_use
+`
+
+func (q *Queries) AttachmentKeyInUse(ctx context.Context, key string) (int64, error) {
+	row := q.db.QueryRowContext(ctx, attachmentKeyInUse, key)
+	var in_use int64
+	err := row.Scan(&in_use)
+	return in_use, err
+}
+
+const claimPendingUser = `-- name: ClaimPendingUser :one
+UPDATE users SET github_uid = ?, login = ?, avatar_url = ?, updated_at = CURRENT_TIMESTAMP
+WHERE id = ? AND github_uid IS NULL
+RETURNING id, github_uid, login, avatar_url, created_at, updated_at, disabled_at
+`
+
+type ClaimPendingUserParams struct {
+	GithubUid sql.NullInt64
+	Login     string
+	AvatarUrl sql.NullString
+	ID        int64
+}
+
+func (q *Queries) ClaimPendingUser(ctx context.Context, arg ClaimPendingUserParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, claimPendingUser,
+		arg.GithubUid,
+		arg.Login,
+		arg.AvatarUrl,
+		arg.ID,
+	)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.GithubUid,
+		&i.Login,
+		&i.AvatarUrl,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.DisabledAt,
+	)
+	return i, err
+}
+
+const createAttachment = `-- name: CreateAttachment :one
+INSERT INTO attachments (message_id, user_id, storage_key, thumbnail_key, filename, content_type, size, width, height)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+RETURNING id, message_id, user_id, storage_key, thumbnail_key, filename, content_type, size, width, height, created_at
+`
+
+type CreateAttachmentParams struct {
+	MessageID    int64
+	UserID       int64
+	StorageKey   string
+	ThumbnailKey sql.NullString
+	Filename     string
+	ContentType  string
+	Size         int64
+	Width        int64
+	Height       int64
+}
+
+func (q *Queries) CreateAttachment(ctx context.Context, arg CreateAttachmentParams) (Attachment, error) {
+	row := q.db.QueryRowContext(ctx, createAttachment,
+		arg.MessageID,
+		arg.UserID,
+		arg.StorageKey,
+		arg.ThumbnailKey,
+		arg.Filename,
+		arg.ContentType,
+		arg.Size,
+		arg.Width,
+		arg.Height,
+	)
+	var i Attachment
+	err := row.Scan(
+		&i.ID,
+		&i.MessageID,
+		&i.UserID,
+		&i.StorageKey,
+		&i.ThumbnailKey,
+		&i.Filename,
+		&i.ContentType,
+		&i.Size,
+		&i.Width,
+		&i.Height,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const createDirectRoom = `-- name: CreateDirectRoom :one
+INSERT INTO rooms (name, creator_id, kind, dm_key) VALUES (?, ?, 'dm', ?)
+ON CONFLICT (dm_key) WHERE dm_key IS NOT NULL DO NOTHING
+RETURNING id, name, creator_id, created_at, updated_at, kind, dm_key, post_policy, invite_policy, topic, archived_at
+`
+
+type CreateDirectRoomParams struct {
+	Name      string
+	CreatorID int64
+	DmKey     sql.NullString
+}
+
+func (q *Queries) CreateDirectRoom(ctx context.Context, arg CreateDirectRoomParams) (Room, error) {
+	row := q.db.QueryRowContext(ctx, createDirectRoom, arg.Name, arg.CreatorID, arg.DmKey)
+	var i Room
+	err := row.Scan(
+		&i.ID,
+		&i.Name,
+		&i.CreatorID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Kind,
+		&i.DmKey,
+		&i.PostPolicy,
+		&i.InvitePolicy,
+		&i.Topic,
+		&i.ArchivedAt,
+	)
+	return i, err
+}
+
+const createMessage = `-- name: CreateMessage :one
+INSERT INTO messages (room_id, user_id, body) VALUES (?, ?, ?)
+RETURNING id, room_id, user_id, body, created_at, edited_at, deleted_at, parent_message_id, reply_count, last_reply_at, kind
+`
+
+type CreateMessageParams struct {
+	RoomID int64
+	UserID int64
+	Body   string
+}
+
+func (q *Queries) CreateMessage(ctx context.Context, arg CreateMessageParams) (Message, error) {
+	row := q.db.QueryRowContext(ctx, createMessage, arg.RoomID, arg.UserID, arg.Body)
+	var i Message
+	err := row.Scan(
+		&i.ID,
+		&i.RoomID,
+		&i.UserID,
+		&i.Body,
+		&i.CreatedAt,
+		&i.EditedAt,
+		&i.DeletedAt,
+		&i.ParentMessageID,
+		&i.ReplyCount,
+		&i.LastReplyAt,
+		&i.Kind,
+	)
+	return i, err
+}
+
+const createMessageEdit = `-- name: CreateMessageEdit :exec
+INSERT INTO message_edits (message_id, editor_id, body) VALUES (?, ?, ?)
+`
+
+type CreateMessageEditParams struct {
+	MessageID int64
+	EditorID  int64
+	Body      string
+}
+
+func (q *Queries) CreateMessageEdit(ctx context.Context, arg CreateMessageEditParams) error {
+	_, err := q.db.ExecContext(ctx, createMessageEdit, arg.MessageID, arg.EditorID, arg.Body)
+	return err
+}
+
+const createMessageMention = `-- name: CreateMessageMention :exec
+INSERT OR IGNORE INTO message_mentions (message_id, user_id, kind) VALUES (?, ?, ?)
+`
+
+type CreateMessageMentionParams struct {
+	MessageID int64
+	UserID    int64
+	Kind      string
+}
+
+func (q *Queries) CreateMessageMention(ctx context.Context, arg CreateMessageMentionParams) error {
+	_, err := q.db.ExecContext(ctx, createMessageMention, arg.MessageID, arg.UserID, arg.Kind)
+	return err
+}
+
+const createMessageReaction = `-- name: CreateMessageReaction :exec
+INSERT OR IGNORE INTO message_reactions (message_id, user_id, emoji) VALUES (?, ?, ?)
+`
+
+type CreateMessageReactionParams struct {
+	MessageID int64
+	UserID    int64
+	Emoji     string
+}
+
+func (q *Queries) CreateMessageReaction(ctx context.Context, arg CreateMessageReactionParams) error {
+	_, err := q.db.ExecContext(ctx, createMessageReaction, arg.MessageID, arg.UserID, arg.Emoji)
+	return err
+}
+
+const createPendingUser = `-- name: CreatePendingUser :one
+INSERT INTO users (login) VALUES (?)
+RETURNING id, github_uid, login, avatar_url, created_at, updated_at, disabled_at
+`
+
+func (q *Queries) CreatePendingUser(ctx context.Context, login string) (User, error) {
+	row := q.db.QueryRowContext(ctx, createPendingUser, login)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.GithubUid,
+		&i.Login,
+		&i.AvatarUrl,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.DisabledAt,
+	)
+	return i, err
+}
+
+const createReply = `-- name: CreateReply :one
+INSERT INTO messages (room_id, user_id, body, parent_message_id) VALUES (?, ?, ?, ?)
+RETURNING id, room_id, user_id, body, created_at, edited_at, deleted_at, parent_message_id, reply_count, last_reply_at, kind
+`
+
+type CreateReplyParams struct {
+	RoomID          int64
+	UserID          int64
+	Body            string
+	ParentMessageID sql.NullInt64
+}
+
+func (q *Queries) CreateReply(ctx context.Context, arg CreateReplyParams) (Message, error) {
+	row := q.db.QueryRowContext(ctx, createReply,
+		arg.RoomID,
+		arg.UserID,
+		arg.Body,
+		arg.ParentMessageID,
+	)
+	var i Message
+	err := row.Scan(
+		&i.ID,
+		&i.RoomID,
+		&i.UserID,
+		&i.Body,
+		&i.CreatedAt,
+		&i.EditedAt,
+		&i.DeletedAt,
+		&i.ParentMessageID,
+		&i.ReplyCount,
+		&i.LastReplyAt,
+		&i.Kind,
+	)
+	return i, err
+}
+
+const createRoom = `-- name: CreateRoom :one
+INSERT INTO rooms (name, creator_id) VALUES (?, ?)
+RETURNING id, name, creator_id, created_at, updated_at, kind, dm_key, post_policy, invite_policy, topic, archived_at
+`
+
+type CreateRoomParams struct {
+	Name      string
+	CreatorID int64
+}
+
+func (q *Queries) CreateRoom(ctx context.Context, arg CreateRoomParams) (Room, error) {
+	row := q.db.QueryRowContext(ctx, createRoom, arg.Name, arg.CreatorID)
+	var i Room
+	err := row.Scan(
+		&i.ID,
+		&i.Name,
+		&i.CreatorID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Kind,
+		&i.DmKey,
+		&i.PostPolicy,
+		&i.InvitePolicy,
+		&i.Topic,
+		&i.ArchivedAt,
+	)
+	return i, err
+}
+
+const createRoomInvite = `-- name: CreateRoomInvite :one
+INSERT INTO room_invites (room_id, creator_id, expires_at, max_uses) VALUES (?, ?, ?, ?)
+RETURNING id, room_id, creator_id, expires_at, max_uses, use_count, revoked_at, created_at
+`
+
+type CreateRoomInviteParams struct {
+	RoomID    int64
+	CreatorID int64
+	ExpiresAt sql.NullTime
+	MaxUses   sql.NullInt64
+}
+
+func (q *Queries) CreateRoomInvite(ctx context.Context, arg CreateRoomInviteParams) (RoomInvite, error) {
+	row := q.db.QueryRowContext(ctx, createRoomInvite,
+		arg.RoomID,
+		arg.CreatorID,
+		arg.ExpiresAt,
+		arg.MaxUses,
+	)
+	var i RoomInvite
+	err := row.Scan(
+		&i.ID,
+		&i.RoomID,
+		&i.CreatorID,
+		&i.ExpiresAt,
+		&i.MaxUses,
+		&i.UseCount,
+		&i.RevokedAt,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const createRoomMembership = `-- name: CreateRoomMembership :exec
+INSERT INTO room_memberships (room_id, user_id, role) VALUES (?, ?, ?)
+`
+
+type CreateRoomMembershipParams struct {
+	RoomID int64
+	UserID int64
+	Role   string
+}
+
+func (q *Queries) CreateRoomMembership(ctx context.Context, arg CreateRoomMembershipParams) error {
+	_, err := q.db.ExecContext(ctx, createRoomMembership, arg.RoomID, arg.UserID, arg.Role)
+	return err
+}
+
+const createSession = `-- name: CreateSession :exec
+INSERT INTO sessions (token_hash, user_id, user_agent, ip_address) VALUES (?, ?, ?, ?)
+`
+
+type CreateSessionParams struct {
+	TokenHash string
+	UserID    int64
+	UserAgent string
+	IpAddress string
+}
+
+func (q *Queries) CreateSession(ctx context.Context, arg CreateSessionParams) error {
+	_, err := q.db.ExecContext(ctx, createSession,
+		arg.TokenHash,
+		arg.UserID,
+		arg.UserAgent,
+		arg.IpAddress,
+	)
+	return err
+}
+
+const createSystemMessage = `-- name: CreateSystemMessage :one
+INSERT INTO messages (room_id, user_id, body, kind) VALUES (?, ?, ?, 'system')
+RETURNING id, room_id, user_id, body, created_at, edited_at, deleted_at, parent_message_id, reply_count, last_reply_at, kind
+`
+
+type CreateSystemMessageParams struct {
+	RoomID int64
+	UserID int64
+	Body   string
+}
+
+func (q *Queries) CreateSystemMessage(ctx context.Context, arg CreateSystemMessageParams) (Message, error) {
+	row := q.db.QueryRowContext(ctx, createSystemMessage, arg.RoomID, arg.UserID, arg.Body)
+	var i Message
+	err := row.Scan(
+		&i.ID,
+		&i.RoomID,
+		&i.UserID,
+		&i.Body,
+		&i.CreatedAt,
+		&i.EditedAt,
+		&i.DeletedAt,
+		&i.ParentMessageID,
+		&i.ReplyCount,
+		&i.LastReplyAt,
+		&i.Kind,
+	)
+	return i, err
+}
+
 const createUser = `-- name: CreateUser :one
 INSERT INTO users (github_uid, login, avatar_url) VALUES (?, ?, ?)
-RETURNING id, github_uid, login, avatar_url, created_at, updated_at
+RETURNING id, github_uid, login, avatar_url, created_at, updated_at, disabled_at
+`
+
+type CreateUserParams struct {
+	GithubUid sql.NullInt64
+	Login     string
+	AvatarUrl sql.NullString
+}
+
+func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, createUser, arg.GithubUid, arg.Login, arg.AvatarUrl)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.GithubUid,
+		&i.Login,
+		&i.AvatarUrl,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.DisabledAt,
+	)
+	return i, err
+}
+
+const deleteExpiredSessions = `-- name: DeleteExpiredSessions :exec
+DELETE FROM sessions WHERE user_id = ? AND (created_at < ? OR last_seen_at < ?)
+`
+
+type DeleteExpiredSessionsParams struct {
+	UserID     int64
+	CreatedAt  sql.NullTime
+	LastSeenAt sql.NullTime
+}
+
+func (q *Queries) DeleteExpiredSessions(ctx context.Context, arg DeleteExpiredSessionsParams) error {
+	_, err := q.db.ExecContext(ctx, deleteExpiredSessions, arg.UserID, arg.CreatedAt, arg.LastSeenAt)
+	return err
+}
+
+const deleteMessageAttachments = `-- name: DeleteMessageAttachments :many
+DELETE FROM attachments WHERE message_id = ?
+RETURNING id, message_id, user_id, storage_key, thumbnail_key, filename, content_type, size, width, height, created_at
+`
+
+func (q *Queries) DeleteMessageAttachments(ctx context.Context, messageID int64) ([]Attachment, error) {
+	rows, err := q.db.QueryContext(ctx, deleteMessageAttachments, messageID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Attachment
+	for rows.Next() {
+		var i Attachment
+		if err := rows.Scan(
+			&i.ID,
+			&i.MessageID,
+			&i.UserID,
+			&i.StorageKey,
+			&i.ThumbnailKey,
+			&i.Filename,
+			&i.ContentType,
+			&i.Size,
+			&i.Width,
+			&i.Height,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const deleteMessageMentions = `-- name: DeleteMessageMentions :exec
+DELETE FROM message_mentions WHERE message_id = ?
+`
+
+func (q *Queries) DeleteMessageMentions(ctx context.Context, messageID int64) error {
+	_, err := q.db.ExecContext(ctx, deleteMessageMentions, messageID)
+	return err
+}
+
+const deleteMessageReaction = `-- name: DeleteMessageReaction :execrows
+DELETE FROM message_reactions WHERE message_id = ? AND user_id = ? AND emoji = ?
+`
+
+type DeleteMessageReactionParams struct {
+	MessageID int64
+	UserID    int64
+	Emoji     string
+}
+
+func (q *Queries) DeleteMessageReaction(ctx context.Context, arg DeleteMessageReactionParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, deleteMessageReaction, arg.MessageID, arg.UserID, arg.Emoji)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const deleteOtherSessions = `-- name: DeleteOtherSessions :execrows
+DELETE FROM sessions WHERE user_id = ? AND id != ?
+`
+
+type DeleteOtherSessionsParams struct {
+	UserID int64
+	ID     int64
+}
+
+func (q *Queries) DeleteOtherSessions(ctx context.Context, arg DeleteOtherSessionsParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, deleteOtherSessions, arg.UserID, arg.ID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const deleteRoom = `-- name: DeleteRoom :exec
+DELETE FROM rooms WHERE id = ?
+`
+
+func (q *Queries) DeleteRoom(ctx context.Context, id int64) error {
+	_, err := q.db.ExecContext(ctx, deleteRoom, id)
+	return err
+}
+
+const deleteRoomMembership = `-- name: DeleteRoomMembership :exec
+DELETE FROM room_memberships WHERE room_id = ? AND user_id = ?
+`
+
+type DeleteRoomMembershipParams struct {
+	RoomID int64
+	UserID int64
+}
+
+func (q *Queries) DeleteRoomMembership(ctx context.Context, arg DeleteRoomMembershipParams) error {
+	_, err := q.db.ExecContext(ctx, deleteRoomMembership, arg.RoomID, arg.UserID)
+	return err
+}
+
+const deleteSession = `-- name: DeleteSession :execrows
+DELETE FROM sessions WHERE id = ? AND user_id = ?
+`
+
+type DeleteSessionParams struct {
+	ID     int64
+	UserID int64
+}
+
+func (q *Queries) DeleteSession(ctx context.Context, arg DeleteSessionParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, deleteSession, arg.ID, arg.UserID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const deleteSessionByToken = `-- name: DeleteSessionByToken :exec
+DELETE FROM sessions WHERE token_hash = ?
+`
+
+func (q *Queries) DeleteSessionByToken(ctx context.Context, tokenHash string) error {
+	_, err := q.db.ExecContext(ctx, deleteSessionByToken, tokenHash)
+	return err
+}
+
+const deleteUserSessions = `-- name: DeleteUserSessions :execrows
+DELETE FROM sessions WHERE user_id = ?
+`
+
+func (q *Queries) DeleteUserSessions(ctx context.Context, userID int64) (int64, error) {
+	result, err := q.db.ExecContext(ctx, deleteUserSessions, userID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const disableUser = `-- name: DisableUser :execrows
+UPDATE users SET disabled_at = CURRENT_TIMESTAMP WHERE id = ? AND disabled_at IS NULL
+`
+
+func (q *Queries) DisableUser(ctx context.Context, id int64) (int64, error) {
+	result, err := q.db.ExecContext(ctx, disableUser, id)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const enableUser = `-- name: EnableUser :execrows
+UPDATE users SET disabled_at = NULL WHERE id = ? AND disabled_at IS NOT NULL
+`
+
+func (q *Queries) EnableUser(ctx context.Context, id int64) (int64, error) {
+	result, err := q.db.ExecContext(ctx, enableUser, id)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const getDirectRoom = `-- name: GetDirectRoom :one
+SELECT id, name, creator_id, created_at, updated_at, kind, dm_key, post_policy, invite_policy, topic, archived_at FROM rooms WHERE dm_key = ? LIMIT 1
+`
+
+func (q *Queries) GetDirectRoom(ctx context.Context, dmKey sql.NullString) (Room, error) {
+	row := q.db.QueryRowContext(ctx, getDirectRoom, dmKey)
+	var i Room
+	err := row.Scan(
+		&i.ID,
+		&i.Name,
+		&i.CreatorID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Kind,
+		&i.DmKey,
+		&i.PostPolicy,
+		&i.InvitePolicy,
+		&i.Topic,
+		&i.ArchivedAt,
+	)
+	return i, err
+}
+
+const getMemberNotificationSettings = `-- name: GetMemberNotificationSettings :many
+SELECT user_id, notify_level, muted_until FROM room_memberships
+WHERE room_id = ? AND user_id IN (/*SLICE:user_ids*/?)
+`
+
+type GetMemberNotificationSettingsParams struct {
+	RoomID  int64
+	UserIds []int64
+}
+
+type GetMemberNotificationSettingsRow struct {
+	UserID      int64
+	NotifyLevel string
+	MutedUntil  sql.NullTime
+}
+
+func (q *Queries) GetMemberNotificationSettings(ctx context.Context, arg GetMemberNotificationSettingsParams) ([]GetMemberNotificationSettingsRow, error) {
+	query := getMemberNotificationSettings
+	var queryParams []interface{}
+	queryParams = append(queryParams, arg.RoomID)
+	if len(arg.UserIds) > 0 {
+		for _, v := range arg.UserIds {
+			queryParams = append(queryParams, v)
+		}
+		query = strings.Replace(query, "/*SLICE:user_ids*/?", strings.Repeat(",?", len(arg.UserIds))[1:], 1)
+	} else {
+		query = strings.Replace(query, "/*SLICE:user_ids*/?", "NULL", 1)
+	}
+	rows, err := q.db.QueryContext(ctx, query, queryParams...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetMemberNotificationSettingsRow
+	for rows.Next() {
+		var i GetMemberNotificationSettingsRow
+		if err := rows.Scan(&i.UserID, &i.NotifyLevel, &i.MutedUntil); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getMessageAttachments = `-- name: GetMessageAttachments :many
+SELECT id, message_id, user_id, storage_key, thumbnail_key, filename, content_type, size, width, height, created_at FROM attachments
+WHERE message_id IN (/*SLICE:message_ids*/?)
+ORDER BY id
+`
+
+func (q *Queries) GetMessageAttachments(ctx context.Context, messageIds []int64) ([]Attachment, error) {
+	query := getMessageAttachments
+	var queryParams []interface{}
+	if len(messageIds) > 0 {
+		for _, v := range messageIds {
+			queryParams = append(queryParams, v)
+		}
+		query = strings.Replace(query, "/*SLICE:message_ids*/?", strings.Repeat(",?", len(messageIds))[1:], 1)
+	} else {
+		query = strings.Replace(query, "/*SLICE:message_ids*/?", "NULL", 1)
+	}
+	rows, err := q.db.QueryContext(ctx, query, queryParams...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Attachment
+	for rows.Next() {
+		var i Attachment
+		if err := rows.Scan(
+			&i.ID,
+			&i.MessageID,
+			&i.UserID,
+			&i.StorageKey,
+			&i.ThumbnailKey,
+			&i.Filename,
+			&i.ContentType,
+			&i.Size,
+			&i.Width,
+			&i.Height,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getMessageEdits = `-- name: GetMessageEdits :many
+SELECT e.id, e.body, e.edited_at, u.login
+FROM message_edits e
+JOIN users u ON u.id = e.editor_id
+WHERE e.message_id = ?
+ORDER BY e.id DESC
+`
+
+type GetMessageEditsRow struct {
+	ID       int64
+	Body     string
+	EditedAt sql.NullTime
+	Login    string
+}
+
+func (q *Queries) GetMessageEdits(ctx context.Context, messageID int64) ([]GetMessageEditsRow, error) {
+	rows, err := q.db.QueryContext(ctx, getMessageEdits, messageID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetMessageEditsRow
+	for rows.Next() {
+		var i GetMessageEditsRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.Body,
+			&i.EditedAt,
+			&i.Login,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getMessageMentions = `-- name: GetMessageMentions :many
+SELECT mm.message_id, mm.kind,
+    CAST(group_concat(mm.user_id, ' ') AS TEXT) AS user_ids,
+    CAST(group_concat(u.login, ' ') AS TEXT) AS logins
+FROM message_mentions mm
+JOIN users u ON u.id = mm.user_id
+WHERE mm.message_id IN (/*SLICE:message_ids*/?)
+GROUP BY mm.message_id, mm.kind
+`
+
+type GetMessageMentionsRow struct {
+	MessageID int64
+	Kind      string
+	UserIds   string
+	Logins    string
+}
+
+func (q *Queries) GetMessageMentions(ctx context.Context, messageIds []int64) ([]GetMessageMentionsRow, error) {
+	query := getMessageMentions
+	var queryParams []interface{}
+	if len(messageIds) > 0 {
+		for _, v := range messageIds {
+			queryParams = append(queryParams, v)
+		}
+		query = strings.Replace(query, "/*SLICE:message_ids*/?", strings.Repeat(",?", len(messageIds))[1:], 1)
+	} else {
+		query = strings.Replace(query, "/*SLICE:message_ids*/?", "NULL", 1)
+	}
+	rows, err := q.db.QueryContext(ctx, query, queryParams...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetMessageMentionsRow
+	for rows.Next() {
+		var i GetMessageMentionsRow
+		if err := rows.Scan(
+			&i.MessageID,
+			&i.Kind,
+			&i.UserIds,
+			&i.Logins,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getMessageReactions = `-- name: GetMessageReactions :many
+SELECT r.message_id, r.emoji, COUNT(*) AS count,
+    CAST(group_concat(r.user_id, ' ') AS TEXT) AS user_ids,
+    CAST(group_concat(u.login, ', ') AS TEXT) AS logins
+FROM message_reactions r
+JOIN users u ON u.id = r.user_id
+WHERE r.message_id IN (/*SLICE:message_ids*/?)
+GROUP BY r.message_id, r.emoji
+ORDER BY r.message_id, MIN(r.created_at), r.emoji
+`
+
+type GetMessageReactionsRow struct {
+	MessageID int64
+	Emoji     string
+	Count     int64
+	UserIds   string
+	Logins    string
+}
+
+func (q *Queries) GetMessageReactions(ctx context.Context, messageIds []int64) ([]GetMessageReactionsRow, error) {
+	query := getMessageReactions
+	var queryParams []interface{}
+	if len(messageIds) > 0 {
+		for _, v := range messageIds {
+			queryParams = append(queryParams, v)
+		}
+		query = strings.Replace(query, "/*SLICE:message_ids*/?", strings.Repeat(",?", len(messageIds))[1:], 1)
+	} else {
+		query = strings.Replace(query, "/*SLICE:message_ids*/?", "NULL", 1)
+	}
+	rows, err := q.db.QueryContext(ctx, query, queryParams...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetMessageReactionsRow
+	for rows.Next() {
+		var i GetMessageReactionsRow
+		if err := rows.Scan(
+			&i.MessageID,
+			&i.Emoji,
+			&i.Count,
+			&i.UserIds,
+			&i.Logins,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getPinnedMessageIDs = `-- name: GetPinnedMessageIDs :many
+SELECT message_id FROM pinned_messages
+WHERE message_id IN (/*SLICE:message_ids*/?)
+`
+
+func (q *Queries) GetPinnedMessageIDs(ctx context.Context, messageIds []int64) ([]int64, error) {
+	query := getPinnedMessageIDs
+	var queryParams []interface{}
+	if len(messageIds) > 0 {
+		for _, v := range messageIds {
+			queryParams = append(queryParams, v)
+		}
+		query = strings.Replace(query, "/*SLICE:message_ids*/?", strings.Repeat(",?", len(messageIds))[1:], 1)
+	} else {
+		query = strings.Replace(query, "/*SLICE:message_ids*/?", "NULL", 1)
+	}
+	rows, err := q.db.QueryContext(ctx, query, queryParams...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []int64
+	for rows.Next() {
+		var message_id int64
+		if err := rows.Scan(&message_id); err != nil {
+			return nil, err
+		}
+		items = append(items, message_id)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getPinnedMessages = `-- name: GetPinnedMessages :many
+SELECT m.id, m.user_id, m.body, m.created_at, u.login, u.avatar_url,
+    p.created_at AS pinned_at, pu.login AS pinned_by_login
+FROM pinned_messages p
+JOIN messages m ON m.id = p.message_id
+JOIN users u ON u.id = m.user_id
+JOIN users pu ON pu.id = p.pinned_by
+WHERE p.room_id = ? AND m.deleted_at IS NULL
+ORDER BY p.created_at DESC, p.message_id DESC
+`
+
+type GetPinnedMessagesRow struct {
+	ID            int64
+	UserID        int64
+	Body          string
+	CreatedAt     sql.NullTime
+	Login         string
+	AvatarUrl     sql.NullString
+	PinnedAt      sql.NullTime
+	PinnedByLogin string
+}
+
+func (q *Queries) GetPinnedMessages(ctx context.Context, roomID int64) ([]GetPinnedMessagesRow, error) {
+	rows, err := q.db.QueryContext(ctx, getPinnedMessages, roomID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetPinnedMessagesRow
+	for rows.Next() {
+		var i GetPinnedMessagesRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.UserID,
+			&i.Body,
+			&i.CreatedAt,
+			&i.Login,
+			&i.AvatarUrl,
+			&i.PinnedAt,
+			&i.PinnedByLogin,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getRoom = `-- name: GetRoom :one
+SELECT id, name, creator_id, created_at, updated_at, kind, dm_key, post_policy, invite_policy, topic, archived_at FROM rooms WHERE id = ? LIMIT 1
+`
+
+func (q *Queries) GetRoom(ctx context.Context, id int64) (Room, error) {
+	row := q.db.QueryRowContext(ctx, getRoom, id)
+	var i Room
+	err := row.Scan(
+		&i.ID,
+		&i.Name,
+		&i.CreatorID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Kind,
+		&i.DmKey,
+		&i.PostPolicy,
+		&i.InvitePolicy,
+		&i.Topic,
+		&i.ArchivedAt,
+	)
+	return i, err
+}
+
+const getRoomAttachment = `-- name: GetRoomAttachment :one
+SELECT a.id, a.message_id, a.user_id, a.storage_key, a.thumbnail_key, a.filename, a.content_type, a.size, a.width, a.height, a.created_at
+FROM attachments a
+JOIN messages m ON m.id = a.message_id
+WHERE a.id = ? AND m.room_id = ? AND m.deleted_at IS NULL
+`
+
+type GetRoomAttachmentParams struct {
+	ID     int64
+	RoomID int64
+}
+
+func (q *Queries) GetRoomAttachment(ctx context.Context, arg GetRoomAttachmentParams) (Attachment, error) {
+	row := q.db.QueryRowContext(ctx, getRoomAttachment, arg.ID, arg.RoomID)
+	var i Attachment
+	err := row.Scan(
+		&i.ID,
+		&i.MessageID,
+		&i.UserID,
+		&i.StorageKey,
+		&i.ThumbnailKey,
+		&i.Filename,
+		&i.ContentType,
+		&i.Size,
+		&i.Width,
+		&i.Height,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const getRoomAttachmentKeys = `-- name: GetRoomAttachmentKeys :many
+SELECT a.storage_key, a.thumbnail_key
+FROM attachments a
+JOIN messages m ON m.id = a.message_id
+WHERE m.room_id = ?
+`
+
+type GetRoomAttachmentKeysRow struct {
+	StorageKey   string
+	ThumbnailKey sql.NullString
+}
+
+func (q *Queries) GetRoomAttachmentKeys(ctx context.Context, roomID int64) ([]GetRoomAttachmentKeysRow, error) {
+	rows, err := q.db.QueryContext(ctx, getRoomAttachmentKeys, roomID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetRoomAttachmentKeysRow
+	for rows.Next() {
+		var i GetRoomAttachmentKeysRow
+		if err := rows.Scan(&i.StorageKey, &i.ThumbnailKey); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getRoomInvite = `-- name: GetRoomInvite :one
+SELECT id, room_id, creator_id, expires_at, max_uses, use_count, revoked_at, created_at FROM room_invites WHERE id = ?
+`
+
+func (q *Queries) GetRoomInvite(ctx context.Context, id int64) (RoomInvite, error) {
+	row := q.db.QueryRowContext(ctx, getRoomInvite, id)
+	var i RoomInvite
+	err := row.Scan(
+		&i.ID,
+		&i.RoomID,
+		&i.CreatorID,
+		&i.ExpiresAt,
+		&i.MaxUses,
+		&i.UseCount,
+		&i.RevokedAt,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const getRoomInvites = `-- name: GetRoomInvites :many
+SELECT i.id, i.room_id, i.creator_id, i.expires_at, i.max_uses, i.use_count, i.revoked_at, i.created_at, u.login AS creator_login
+FROM room_invites i
+JOIN users u ON u.id = i.creator_id
+WHERE i.room_id = ? AND i.revoked_at IS NULL
+ORDER BY i.id DESC
+`
+
+type GetRoomInvitesRow struct {
+	ID           int64
+	RoomID       int64
+	CreatorID    int64
+	ExpiresAt    sql.NullTime
+	MaxUses      sql.NullInt64
+	UseCount     int64
+	RevokedAt    sql.NullTime
+	CreatedAt    sql.NullTime
+	CreatorLogin string
+}
+
+func (q *Queries) GetRoomInvites(ctx context.Context, roomID int64) ([]GetRoomInvitesRow, error) {
+	rows, err := q.db.QueryContext(ctx, getRoomInvites, roomID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetRoomInvitesRow
+	for rows.Next() {
+		var i GetRoomInvitesRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.RoomID,
+			&i.CreatorID,
+			&i.ExpiresAt,
+			&i.MaxUses,
+			&i.UseCount,
+			&i.RevokedAt,
+			&i.CreatedAt,
+			&i.CreatorLogin,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getRoomMembers = `-- name: GetRoomMembers :many
+SELECT u.id, u.github_uid, u.login, u.avatar_url, rm.joined_at, rm.role
+FROM room_memberships rm
+JOIN users u ON u.id = rm.user_id
+WHERE rm.room_id = ?
+ORDER BY u.login
+`
+
+type GetRoomMembersRow struct {
+	ID        int64
+	GithubUid sql.NullInt64
+	Login     string
+	AvatarUrl sql.NullString
+	JoinedAt  sql.NullTime
+	Role      string
+}
+
+func (q *Queries) GetRoomMembers(ctx context.Context, roomID int64) ([]GetRoomMembersRow, error) {
+	rows, err := q.db.QueryContext(ctx, getRoomMembers, roomID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetRoomMembersRow
+	for rows.Next() {
+		var i GetRoomMembersRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.GithubUid,
+			&i.Login,
+			&i.AvatarUrl,
+			&i.JoinedAt,
+			&i.Role,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getRoomMembersByLogin = `-- name: GetRoomMembersByLogin :many
+SELECT u.id, u.login
+FROM room_memberships rm
+JOIN users u ON u.id = rm.user_id
+WHERE rm.room_id = ? AND lower(u.login) IN (/*SLICE:logins*/?)
+`
+
+type GetRoomMembersByLoginParams struct {
+	RoomID int64
+	Logins []string
+}
+
+type GetRoomMembersByLoginRow struct {
+	ID    int64
+	Login string
+}
+
+func (q *Queries) GetRoomMembersByLogin(ctx context.Context, arg GetRoomMembersByLoginParams) ([]GetRoomMembersByLoginRow, error) {
+	query := getRoomMembersByLogin
+	var queryParams []interface{}
+	queryParams = append(queryParams, arg.RoomID)
+	if len(arg.Logins) > 0 {
+		for _, v := range arg.Logins {
+			queryParams = append(queryParams, v)
+		}
+		query = strings.Replace(query, "/*SLICE:logins*/?", strings.Repeat(",?", len(arg.Logins))[1:], 1)
+	} else {
+		query = strings.Replace(query, "/*SLICE:logins*/?", "NULL", 1)
+	}
+	rows, err := q.db.QueryContext(ctx, query, queryParams...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetRoomMembersByLoginRow
+	for rows.Next() {
+		var i GetRoomMembersByLoginRow
+		if err := rows.Scan(&i.ID, &i.Login); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getRoomMembership = `-- name: GetRoomMembership :one
+SELECT room_id, user_id, joined_at, last_read_message_id, role, notify_level, muted_until FROM room_memberships WHERE room_id = ? AND user_id = ? LIMIT 1
+`
+
+type GetRoomMembershipParams struct {
+	RoomID int64
+	UserID int64
+}
+
+func (q *Queries) GetRoomMembership(ctx context.Context, arg GetRoomMembershipParams) (RoomMembership, error) {
+	row := q.db.QueryRowContext(ctx, getRoomMembership, arg.RoomID, arg.UserID)
+	var i RoomMembership
+	err := row.Scan(
+		&i.RoomID,
+		&i.UserID,
+		&i.JoinedAt,
+		&i.LastReadMessageID,
+		&i.Role,
+		&i.NotifyLevel,
+		&i.MutedUntil,
+	)
+	return i, err
+}
+
+const getRoomMessage = `-- name: GetRoomMessage :one
+SELECT m.id, m.room_id, m.user_id, m.body, m.created_at, m.edited_at, m.deleted_at,
+    m.parent_message_id, m.reply_count, m.last_reply_at, m.kind, u.login, u.avatar_url
+FROM messages m
+JOIN users u ON u.id = m.user_id
+WHERE m.id = ? AND m.room_id = ?
+LIMIT 1
+`
+
+type GetRoomMessageParams struct {
+	ID     int64
+	RoomID int64
+}
+
+type GetRoomMessageRow struct {
+	ID              int64
+	RoomID          int64
+	UserID          int64
+	Body            string
+	CreatedAt       sql.NullTime
+	EditedAt        sql.NullTime
+	DeletedAt       sql.NullTime
+	ParentMessageID sql.NullInt64
+	ReplyCount      int64
+	LastReplyAt     sql.NullTime
+	Kind            string
+	Login           string
+	AvatarUrl       sql.NullString
+}
+
+func (q *Queries) GetRoomMessage(ctx context.Context, arg GetRoomMessageParams) (GetRoomMessageRow, error) {
+	row := q.db.QueryRowContext(ctx, getRoomMessage, arg.ID, arg.RoomID)
+	var i GetRoomMessageRow
+	err := row.Scan(
+		&i.ID,
+		&i.RoomID,
+		&i.UserID,
+		&i.Body,
+		&i.CreatedAt,
+		&i.EditedAt,
+		&i.DeletedAt,
+		&i.ParentMessageID,
+		&i.ReplyCount,
+		&i.LastReplyAt,
+		&i.Kind,
+		&i.Login,
+		&i.AvatarUrl,
+	)
+	return i, err
+}
+
+const getRoomMessagesAfter = `-- name: GetRoomMessagesAfter :many
+SELECT m.id, m.room_id, m.user_id, m.body, m.created_at, m.edited_at, m.deleted_at,
+    m.parent_message_id, m.reply_count, m.last_reply_at, m.kind, u.login, u.avatar_url
+FROM messages m
+JOIN users u ON u.id = m.user_id
+WHERE m.room_id = ? AND m.id > ?
+    AND m.parent_message_id IS NULL
+ORDER BY m.id ASC
+LIMIT ?
+`
+
+type GetRoomMessagesAfterParams struct {
+	RoomID  int64
+	AfterID int64
+	Limit   int64
+}
+
+type GetRoomMessagesAfterRow struct {
+	ID              int64
+	RoomID          int64
+	UserID          int64
+	Body            string
+	CreatedAt       sql.NullTime
+	EditedAt        sql.NullTime
+	DeletedAt       sql.NullTime
+	ParentMessageID sql.NullInt64
+	ReplyCount      int64
+	LastReplyAt     sql.NullTime
+	Kind            string
+	Login           string
+	AvatarUrl       sql.NullString
+}
+
+func (q *Queries) GetRoomMessagesAfter(ctx context.Context, arg GetRoomMessagesAfterParams) ([]GetRoomMessagesAfterRow, error) {
+	rows, err := q.db.QueryContext(ctx, getRoomMessagesAfter, arg.RoomID, arg.AfterID, arg.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetRoomMessagesAfterRow
+	for rows.Next() {
+		var i GetRoomMessagesAfterRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.RoomID,
+			&i.UserID,
+			&i.Body,
+			&i.CreatedAt,
+			&i.EditedAt,
+			&i.DeletedAt,
+			&i.ParentMessageID,
+			&i.ReplyCount,
+			&i.LastReplyAt,
+			&i.Kind,
+			&i.Login,
+			&i.AvatarUrl,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getRoomMessagesBefore = `-- name: GetRoomMessagesBefore :many
+SELECT m.id, m.room_id, m.user_id, m.body, m.created_at, m.edited_at, m.deleted_at,
+    m.parent_message_id, m.reply_count, m.last_reply_at, m.kind, u.login, u.avatar_url
+FROM messages m
+JOIN users u ON u.id = m.user_id
+WHERE m.room_id = ? AND m.id < ?
+    AND m.parent_message_id IS NULL
+ORDER BY m.id DESC
+LIMIT ?
+`
+
+type GetRoomMessagesBeforeParams struct {
+	RoomID   int64
+	BeforeID int64
+	Limit    int64
+}
+
+type GetRoomMessagesBeforeRow struct {
+	ID              int64
+	RoomID          int64
+	UserID          int64
+	Body            string
+	CreatedAt       sql.NullTime
+	EditedAt        sql.NullTime
+	DeletedAt       sql.NullTime
+	ParentMessageID sql.NullInt64
+	ReplyCount      int64
+	LastReplyAt     sql.NullTime
+	Kind            string
+	Login           string
+	AvatarUrl       sql.NullString
+}
+
+func (q *Queries) GetRoomMessagesBefore(ctx context.Context, arg GetRoomMessagesBeforeParams) ([]GetRoomMessagesBeforeRow, error) {
+	rows, err := q.db.QueryContext(ctx, getRoomMessagesBefore, arg.RoomID, arg.BeforeID, arg.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetRoomMessagesBeforeRow
+	for rows.Next() {
+		var i GetRoomMessagesBeforeRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.RoomID,
+			&i.UserID,
+			&i.Body,
+			&i.CreatedAt,
+			&i.EditedAt,
+			&i.DeletedAt,
+			&i.ParentMessageID,
+			&i.ReplyCount,
+			&i.LastReplyAt,
+			&i.Kind,
+			&i.Login,
+			&i.AvatarUrl,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getRoomUnread = `-- name: GetRoomUnread :one
+SELECT
+    (SELECT COUNT(*) FROM messages m
+     WHERE m.room_id = rm.room_id AND m.id > rm.last_read_message_id AND m.user_id != rm.user_id
+        AND m.parent_message_id IS NULL AND m.deleted_at IS NULL) AS unread_count,
+    (SELECT COUNT(DISTINCT m.id) FROM messages m
+     JOIN message_mentions mm ON mm.message_id = m.id AND mm.user_id = rm.user_id
+     WHERE m.room_id = rm.room_id AND m.id > rm.last_read_message_id
+        AND m.parent_message_id IS NULL AND m.deleted_at IS NULL) AS mention_count,
+    rm.notify_level, rm.muted_until
+FROM room_memberships rm
+WHERE rm.room_id = ? AND rm.user_id = ?
+`
+
+type GetRoomUnreadParams struct {
+	RoomID int64
+	UserID int64
+}
+
+type GetRoomUnreadRow struct {
+	UnreadCount  int64
+	MentionCount int64
+	NotifyLevel  string
+	MutedUntil   sql.NullTime
+}
+
+func (q *Queries) GetRoomUnread(ctx context.Context, arg GetRoomUnreadParams) (GetRoomUnreadRow, error) {
+	row := q.db.QueryRowContext(ctx, getRoomUnread, arg.RoomID, arg.UserID)
+	var i GetRoomUnreadRow
+	err := row.Scan(
+		&i.UnreadCount,
+		&i.MentionCount,
+		&i.NotifyLevel,
+		&i.MutedUntil,
+	)
+	return i, err
+}
+
+const getSavedMessageIDs = `-- name: GetSavedMessageIDs :many
+SELECT message_id FROM saved_messages
+WHERE user_id = ? AND message_id IN (/*SLICE:message_ids*/?)
+`
+
+type GetSavedMessageIDsParams struct {
+	UserID     int64
+	MessageIds []int64
+}
+
+func (q *Queries) GetSavedMessageIDs(ctx context.Context, arg GetSavedMessageIDsParams) ([]int64, error) {
+	query := getSavedMessageIDs
+	var queryParams []interface{}
+	queryParams = append(queryParams, arg.UserID)
+	if len(arg.MessageIds) > 0 {
+		for _, v := range arg.MessageIds {
+			queryParams = append(queryParams, v)
+		}
+		query = strings.Replace(query, "/*SLICE:message_ids*/?", strings.Repeat(",?", len(arg.MessageIds))[1:], 1)
+	} else {
+		query = strings.Replace(query, "/*SLICE:message_ids*/?", "NULL", 1)
+	}
+	rows, err := q.db.QueryContext(ctx, query, queryParams...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []int64
+	for rows.Next() {
+		var message_id int64
+		if err := rows.Scan(&message_id); err != nil {
+			return nil, err
+		}
+		items = append(items, message_id)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getSavedMessages = `-- name: GetSavedMessages :many
+SELECT m.id, m.room_id, m.user_id, m.body, m.parent_message_id, m.created_at,
+    r.name AS room_name, u.login, u.avatar_url, s.created_at AS saved_at
+FROM saved_messages s
+JOIN messages m ON m.id = s.message_id
+JOIN rooms r ON r.id = m.room_id
+JOIN room_memberships rm ON rm.room_id = m.room_id AND rm.user_id = s.user_id
+JOIN users u ON u.id = m.user_id
+WHERE s.user_id = ? AND m.deleted_at IS NULL
+ORDER BY s.created_at DESC, s.message_id DESC
+LIMIT ?
+`
+
+type GetSavedMessagesParams struct {
+	UserID int64
+	Limit  int64
+}
+
+type GetSavedMessagesRow struct {
+	ID              int64
+	RoomID          int64
+	UserID          int64
+	Body            string
+	ParentMessageID sql.NullInt64
+	CreatedAt       sql.NullTime
+	RoomName        string
+	Login           string
+	AvatarUrl       sql.NullString
+	SavedAt         sql.NullTime
+}
+
+func (q *Queries) GetSavedMessages(ctx context.Context, arg GetSavedMessagesParams) ([]GetSavedMessagesRow, error) {
+	rows, err := q.db.QueryContext(ctx, getSavedMessages, arg.UserID, arg.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetSavedMessagesRow
+	for rows.Next() {
+		var i GetSavedMessagesRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.RoomID,
+			&i.UserID,
+			&i.Body,
+			&i.ParentMessageID,
+			&i.CreatedAt,
+			&i.RoomName,
+			&i.Login,
+			&i.AvatarUrl,
+			&i.SavedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getSessionByToken = `-- name: GetSessionByToken :one
+SELECT s.id, s.user_id, s.created_at, s.last_seen_at, u.github_uid, u.login, u.avatar_url
+FROM sessions s
+JOIN users u ON u.id = s.user_id
+WHERE s.token_hash = ?
+`
+
+type GetSessionByTokenRow struct {
+	ID         int64
+	UserID     int64
+	CreatedAt  sql.NullTime
+	LastSeenAt sql.NullTime
+	GithubUid  sql.NullInt64
+	Login      string
+	AvatarUrl  sql.NullString
+}
+
+func (q *Queries) GetSessionByToken(ctx context.Context, tokenHash string) (GetSessionByTokenRow, error) {
+	row := q.db.QueryRowContext(ctx, getSessionByToken, tokenHash)
+	var i GetSessionByTokenRow
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.CreatedAt,
+		&i.LastSeenAt,
+		&i.GithubUid,
+		&i.Login,
+		&i.AvatarUrl,
+	)
+	return i, err
+}
+
+const getSessionUpgrade = `-- name: GetSessionUpgrade :one
+SELECT upgraded_at FROM session_upgrade WHERE id = 1
+`
+
+func (q *Queries) GetSessionUpgrade(ctx context.Context) (sql.NullTime, error) {
+	row := q.db.QueryRowContext(ctx, getSessionUpgrade)
+	var upgraded_at sql.NullTime
+	err := row.Scan(&upgraded_at)
+	return upgraded_at, err
+}
+
+const getThreadReplies = `-- name: GetThreadReplies :many
+SELECT m.id, m.room_id, m.user_id, m.body, m.created_at, m.edited_at, m.deleted_at,
+    m.parent_message_id, m.reply_count, m.last_reply_at, m.kind, u.login, u.avatar_url
+FROM messages m
+JOIN users u ON u.id = m.user_id
+WHERE m.parent_message_id = ? AND m.id > ?
+ORDER BY m.id ASC
+LIMIT ?
 `
 
-type CreateUserParams struct {
-	GithubUid int64
-	Login     string
-	AvatarUrl sql.NullString
+type GetThreadRepliesParams struct {
+	ParentMessageID sql.NullInt64
+	AfterID         int64
+	Limit           int64
 }
 
-func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (User, error) {
-	row := q.db.QueryRowContext(ctx, createUser, arg.GithubUid, arg.Login, arg.AvatarUrl)
+type GetThreadRepliesRow struct {
+	ID              int64
+	RoomID          int64
+	UserID          int64
+	Body            string
+	CreatedAt       sql.NullTime
+	EditedAt        sql.NullTime
+	DeletedAt       sql.NullTime
+	ParentMessageID sql.NullInt64
+	ReplyCount      int64
+	LastReplyAt     sql.NullTime
+	Kind            string
+	Login           string
+	AvatarUrl       sql.NullString
+}
+
+func (q *Queries) GetThreadReplies(ctx context.Context, arg GetThreadRepliesParams) ([]GetThreadRepliesRow, error) {
+	rows, err := q.db.QueryContext(ctx, getThreadReplies, arg.ParentMessageID, arg.AfterID, arg.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetThreadRepliesRow
+	for rows.Next() {
+		var i GetThreadRepliesRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.RoomID,
+			&i.UserID,
+			&i.Body,
+			&i.CreatedAt,
+			&i.EditedAt,
+			&i.DeletedAt,
+			&i.ParentMessageID,
+			&i.ReplyCount,
+			&i.LastReplyAt,
+			&i.Kind,
+			&i.Login,
+			&i.AvatarUrl,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getUserByGitHubUID = `-- name: GetUserByGitHubUID :one
+SELECT id, github_uid, login, avatar_url, created_at, updated_at, disabled_at FROM users WHERE github_uid = ? LIMIT 1
+`
+
+func (q *Queries) GetUserByGitHubUID(ctx context.Context, githubUid sql.NullInt64) (User, error) {
+	row := q.db.QueryRowContext(ctx, getUserByGitHubUID, githubUid)
 	var i User
 	err := row.Scan(
 		&i.ID,
@@ -31,16 +1734,17 @@ func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (User, e
 		&i.AvatarUrl,
 		&i.CreatedAt,
 		&i.UpdatedAt,
+		&i.DisabledAt,
 	)
 	return i, err
 }
 
-const getUserByGitHubUID = `-- name: GetUserByGitHubUID :one
-SELECT id, github_uid, login, avatar_url, created_at, updated_at FROM users WHERE github_uid = ? LIMIT 1
+const getUserByID = `-- name: GetUserByID :one
+SELECT id, github_uid, login, avatar_url, created_at, updated_at, disabled_at FROM users WHERE id = ? LIMIT 1
 `
 
-func (q *Queries) GetUserByGitHubUID(ctx context.Context, githubUid int64) (User, error) {
-	row := q.db.QueryRowContext(ctx, getUserByGitHubUID, githubUid)
+func (q *Queries) GetUserByID(ctx context.Context, id int64) (User, error) {
+	row := q.db.QueryRowContext(ctx, getUserByID, id)
 	var i User
 	err := row.Scan(
 		&i.ID,
@@ -49,16 +1753,17 @@ func (q *Queries) GetUserByGitHubUID(ctx context.Context, githubUid int64) (User
 		&i.AvatarUrl,
 		&i.CreatedAt,
 		&i.UpdatedAt,
+		&i.DisabledAt,
 	)
 	return i, err
 }
 
-const getUserByID = `-- name: GetUserByID :one
-SELECT id, github_uid, login, avatar_url, created_at, updated_at FROM users WHERE id = ? LIMIT 1
+const getUserByLogin = `-- name: GetUserByLogin :one
+SELECT id, github_uid, login, avatar_url, created_at, updated_at, disabled_at FROM users WHERE login = ? LIMIT 1
 `
 
-func (q *Queries) GetUserByID(ctx context.Context, id int64) (User, error) {
-	row := q.db.QueryRowContext(ctx, getUserByID, id)
+func (q *Queries) GetUserByLogin(ctx context.Context, login string) (User, error) {
+	row := q.db.QueryRowContext(ctx, getUserByLogin, login)
 	var i User
 	err := row.Scan(
 		&i.ID,
@@ -67,12 +1772,48 @@ func (q *Queries) GetUserByID(ctx context.Context, id int64) (User, error) {
 		&i.AvatarUrl,
 		&i.CreatedAt,
 		&i.UpdatedAt,
+		&i.DisabledAt,
 	)
 	return i, err
 }
 
+const getUserRoomMates = `-- name: GetUserRoomMates :many
+SELECT rm.room_id, rm.user_id
+FROM room_memberships rm
+JOIN room_memberships mine ON mine.room_id = rm.room_id
+WHERE mine.user_id = ?1 AND rm.user_id != ?1
+`
+
+type GetUserRoomMatesRow struct {
+	RoomID int64
+	UserID int64
+}
+
+func (q *Queries) GetUserRoomMates(ctx context.Context, userID int64) ([]GetUserRoomMatesRow, error) {
+	rows, err := q.db.QueryContext(ctx, getUserRoomMates, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetUserRoomMatesRow
+	for rows.Next() {
+		var i GetUserRoomMatesRow
+		if err := rows.Scan(&i.RoomID, &i.UserID); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const getUserRooms = `-- name: GetUserRooms :many
-SELECT r.id, r.name, r.creator_id, r.created_at, r.updated_at FROM rooms r
+SELECT r.id, r.name, r.creator_id, r.created_at, r.updated_at, r.kind, r.dm_key, r.post_policy, r.invite_policy, r.topic, r.archived_at FROM rooms r
 JOIN room_memberships rm ON r.id = rm.room_id
 WHERE rm.user_id = ?
 ORDER BY r.created_at DESC
@@ -93,6 +1834,256 @@ func (q *Queries) GetUserRooms(ctx context.Context, userID int64) ([]Room, error
 			&i.CreatorID,
 			&i.CreatedAt,
 			&i.UpdatedAt,
+			&i.Kind,
+			&i.DmKey,
+			&i.PostPolicy,
+			&i.InvitePolicy,
+			&i.Topic,
+			&i.ArchivedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getUserRoomsWithUnread = `-- name: GetUserRoomsWithUnread :many
+SELECT r.id, r.name, r.kind, r.archived_at,
+    CAST(CASE WHEN r.kind = 'dm' THEN COALESCE(
+        (SELECT group_concat(u.login, ', ') FROM room_memberships o
+         JOIN users u ON u.id = o.user_id
+         WHERE o.room_id = r.id AND o.user_id != rm.user_id), '')
+    ELSE '' END AS TEXT) AS other_logins,
+    (SELECT COUNT(*) FROM messages m
+     WHERE m.room_id = r.id AND m.id > rm.last_read_message_id AND m.user_id != rm.user_id
+        AND m.parent_message_id IS NULL AND m.deleted_at IS NULL) AS unread_count,
+    (SELECT COUNT(DISTINCT m.id) FROM messages m
+     JOIN message_mentions mm ON mm.message_id = m.id AND mm.user_id = rm.user_id
+     WHERE m.room_id = r.id AND m.id > rm.last_read_message_id
+        AND m.parent_message_id IS NULL AND m.deleted_at IS NULL) AS mention_count,
+    rm.notify_level, rm.muted_until
+FROM rooms r
+JOIN room_memberships rm ON rm.room_id = r.id
+WHERE rm.user_id = ?
+ORDER BY r.created_at DESC
+`
+
+type GetUserRoomsWithUnreadRow struct {
+	ID           int64
+	Name         string
+	Kind         string
+	ArchivedAt   sql.NullTime
+	OtherLogins  string
+	UnreadCount  int64
+	MentionCount int64
+	NotifyLevel  string
+	MutedUntil   sql.NullTime
+}
+
+func (q *Queries) GetUserRoomsWithUnread(ctx context.Context, userID int64) ([]GetUserRoomsWithUnreadRow, error) {
+	rows, err := q.db.QueryContext(ctx, getUserRoomsWithUnread, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetUserRoomsWithUnreadRow
+	for rows.Next() {
+		var i GetUserRoomsWithUnreadRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.Name,
+			&i.Kind,
+			&i.ArchivedAt,
+			&i.OtherLogins,
+			&i.UnreadCount,
+			&i.MentionCount,
+			&i.NotifyLevel,
+			&i.MutedUntil,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getUserSessions = `-- name: GetUserSessions :many
+SELECT id, token_hash, user_id, user_agent, ip_address, created_at, last_seen_at FROM sessions
+WHERE user_id = ?
+ORDER BY last_seen_at DESC, id DESC
+`
+
+func (q *Queries) GetUserSessions(ctx context.Context, userID int64) ([]Session, error) {
+	rows, err := q.db.QueryContext(ctx, getUserSessions, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Session
+	for rows.Next() {
+		var i Session
+		if err := rows.Scan(
+			&i.ID,
+			&i.TokenHash,
+			&i.UserID,
+			&i.UserAgent,
+			&i.IpAddress,
+			&i.CreatedAt,
+			&i.LastSeenAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const markRoomRead = `-- name: MarkRoomRead :exec
+UPDATE room_memberships
+SET last_read_message_id = MAX(last_read_message_id, MIN(?1,
+    (SELECT COALESCE(MAX(id), 0) FROM messages WHERE messages.room_id = ?2)))
+WHERE room_id = ?2 AND user_id = ?3
+`
+
+type MarkRoomReadParams struct {
+	MessageID int64
+	RoomID    int64
+	UserID    int64
+}
+
+func (q *Queries) MarkRoomRead(ctx context.Context, arg MarkRoomReadParams) error {
+	_, err := q.db.ExecContext(ctx, markRoomRead, arg.MessageID, arg.RoomID, arg.UserID)
+	return err
+}
+
+const pinMessage = `-- name: PinMessage :exec
+INSERT INTO pinned_messages (room_id, message_id, pinned_by)
+VALUES (?, ?, ?)
+ON CONFLICT (room_id, message_id) DO NOTHING
+`
+
+type PinMessageParams struct {
+	RoomID    int64
+	MessageID int64
+	PinnedBy  int64
+}
+
+func (q *Queries) PinMessage(ctx context.Context, arg PinMessageParams) error {
+	_, err := q.db.ExecContext(ctx, pinMessage, arg.RoomID, arg.MessageID, arg.PinnedBy)
+	return err
+}
+
+const revokeRoomInvite = `-- name: RevokeRoomInvite :exec
+UPDATE room_invites SET revoked_at = CURRENT_TIMESTAMP WHERE id = ? AND revoked_at IS NULL
+`
+
+func (q *Queries) RevokeRoomInvite(ctx context.Context, id int64) error {
+	_, err := q.db.ExecContext(ctx, revokeRoomInvite, id)
+	return err
+}
+
+const saveMessage = `-- name: SaveMessage :exec
+INSERT INTO saved_messages (user_id, message_id)
+VALUES (?, ?)
+ON CONFLICT (user_id, message_id) DO NOTHING
+`
+
+type SaveMessageParams struct {
+	UserID    int64
+	MessageID int64
+}
+
+func (q *Queries) SaveMessage(ctx context.Context, arg SaveMessageParams) error {
+	_, err := q.db.ExecContext(ctx, saveMessage, arg.UserID, arg.MessageID)
+	return err
+}
+
+const searchMessages = `-- name: SearchMessages :many
+SELECT m.id, m.room_id, m.user_id, m.parent_message_id, m.created_at, r.name AS room_name, u.login, u.avatar_url,
+    CAST(snippet(messages_fts, 0, char(2), char(3), '…', 16) AS TEXT) AS snippet
+FROM messages_fts
+JOIN messages m ON m.id = messages_fts.rowid
+JOIN rooms r ON r.id = m.room_id
+JOIN users u ON u.id = m.user_id
+JOIN room_memberships rm ON rm.room_id = m.room_id AND rm.user_id = ?1
+WHERE messages_fts MATCH ?2
+    AND m.deleted_at IS NULL AND m.kind = 'message'
+    AND (?3 IS NULL OR m.room_id = ?3)
+    AND (?4 IS NULL OR m.user_id = ?4)
+    AND (?5 IS NULL OR m.created_at >= ?5)
+    AND (?6 IS NULL OR m.created_at < ?6)
+ORDER BY rank
+LIMIT ?7
+`
+
+type SearchMessagesParams struct {
+	UserID   int64
+	Query    string
+	RoomID   sql.NullInt64
+	AuthorID sql.NullInt64
+	Since    sql.NullTime
+	Until    sql.NullTime
+	Limit    int64
+}
+
+type SearchMessagesRow struct {
+	ID              int64
+	RoomID          int64
+	UserID          int64
+	ParentMessageID sql.NullInt64
+	CreatedAt       sql.NullTime
+	RoomName        string
+	Login           string
+	AvatarUrl       sql.NullString
+	Snippet         string
+}
+
+func (q *Queries) SearchMessages(ctx context.Context, arg SearchMessagesParams) ([]SearchMessagesRow, error) {
+	rows, err := q.db.QueryContext(ctx, searchMessages,
+		arg.UserID,
+		arg.Query,
+		arg.RoomID,
+		arg.AuthorID,
+		arg.Since,
+		arg.Until,
+		arg.Limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []SearchMessagesRow
+	for rows.Next() {
+		var i SearchMessagesRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.RoomID,
+			&i.UserID,
+			&i.ParentMessageID,
+			&i.CreatedAt,
+			&i.RoomName,
+			&i.Login,
+			&i.AvatarUrl,
+			&i.Snippet,
 		); err != nil {
 			return nil, err
 		}
@@ -107,6 +2098,166 @@ func (q *Queries) GetUserRooms(ctx context.Context, userID int64) ([]Room, error
 	return items, nil
 }
 
+const softDeleteMessage = `-- name: SoftDeleteMessage :exec
+UPDATE messages SET deleted_at = CURRENT_TIMESTAMP
+WHERE id = ? AND deleted_at IS NULL
+`
+
+func (q *Queries) SoftDeleteMessage(ctx context.Context, id int64) error {
+	_, err := q.db.ExecContext(ctx, softDeleteMessage, id)
+	return err
+}
+
+const touchSession = `-- name: TouchSession :exec
+UPDATE sessions SET last_seen_at = CURRENT_TIMESTAMP, ip_address = ? WHERE id = ?
+`
+
+type TouchSessionParams struct {
+	IpAddress string
+	ID        int64
+}
+
+func (q *Queries) TouchSession(ctx context.Context, arg TouchSessionParams) error {
+	_, err := q.db.ExecContext(ctx, touchSession, arg.IpAddress, arg.ID)
+	return err
+}
+
+const unarchiveRoom = `-- name: UnarchiveRoom :exec
+UPDATE rooms SET archived_at = NULL WHERE id = ?
+`
+
+func (q *Queries) UnarchiveRoom(ctx context.Context, id int64) error {
+	_, err := q.db.ExecContext(ctx, unarchiveRoom, id)
+	return err
+}
+
+const unpinMessage = `-- name: UnpinMessage :execrows
+DELETE FROM pinned_messages WHERE room_id = ? AND message_id = ?
+`
+
+type UnpinMessageParams struct {
+	RoomID    int64
+	MessageID int64
+}
+
+func (q *Queries) UnpinMessage(ctx context.Context, arg UnpinMessageParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, unpinMessage, arg.RoomID, arg.MessageID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const unsaveMessage = `-- name: UnsaveMessage :exec
+DELETE FROM saved_messages WHERE user_id = ? AND message_id = ?
+`
+
+type UnsaveMessageParams struct {
+	UserID    int64
+	MessageID int64
+}
+
+func (q *Queries) UnsaveMessage(ctx context.Context, arg UnsaveMessageParams) error {
+	_, err := q.db.ExecContext(ctx, unsaveMessage, arg.UserID, arg.MessageID)
+	return err
+}
+
+const updateMembershipRole = `-- name: UpdateMembershipRole :exec
+UPDATE room_memberships SET role = ? WHERE room_id = ? AND user_id = ?
+`
+
+type UpdateMembershipRoleParams struct {
+	Role   string
+	RoomID int64
+	UserID int64
+}
+
+func (q *Queries) UpdateMembershipRole(ctx context.Context, arg UpdateMembershipRoleParams) error {
+	_, err := q.db.ExecContext(ctx, updateMembershipRole, arg.Role, arg.RoomID, arg.UserID)
+	return err
+}
+
+const updateMessageBody = `-- name: UpdateMessageBody :exec
+UPDATE messages SET body = ?, edited_at = CURRENT_TIMESTAMP
+WHERE id = ? AND deleted_at IS NULL
+`
+
+type UpdateMessageBodyParams struct {
+	Body string
+	ID   int64
+}
+
+func (q *Queries) UpdateMessageBody(ctx context.Context, arg UpdateMessageBodyParams) error {
+	_, err := q.db.ExecContext(ctx, updateMessageBody, arg.Body, arg.ID)
+	return err
+}
+
+const updateRoomName = `-- name: UpdateRoomName :exec
+UPDATE rooms SET name = ? WHERE id = ?
+`
+
+type UpdateRoomNameParams struct {
+	Name string
+	ID   int64
+}
+
+func (q *Queries) UpdateRoomName(ctx context.Context, arg UpdateRoomNameParams) error {
+	_, err := q.db.ExecContext(ctx, updateRoomName, arg.Name, arg.ID)
+	return err
+}
+
+const updateRoomNotifications = `-- name: UpdateRoomNotifications :exec
+UPDATE room_memberships SET notify_level = ?, muted_until = ?
+WHERE room_id = ? AND user_id = ?
+`
+
+type UpdateRoomNotificationsParams struct {
+	NotifyLevel string
+	MutedUntil  sql.NullTime
+	RoomID      int64
+	UserID      int64
+}
+
+func (q *Queries) UpdateRoomNotifications(ctx context.Context, arg UpdateRoomNotificationsParams) error {
+	_, err := q.db.ExecContext(ctx, updateRoomNotifications,
+		arg.NotifyLevel,
+		arg.MutedUntil,
+		arg.RoomID,
+		arg.UserID,
+	)
+	return err
+}
+
+const updateRoomPolicies = `-- name: UpdateRoomPolicies :exec
+UPDATE rooms SET post_policy = ?, invite_policy = ?, updated_at = CURRENT_TIMESTAMP
+WHERE id = ?
+`
+
+type UpdateRoomPoliciesParams struct {
+	PostPolicy   string
+	InvitePolicy string
+	ID           int64
+}
+
+func (q *Queries) UpdateRoomPolicies(ctx context.Context, arg UpdateRoomPoliciesParams) error {
+	_, err := q.db.ExecContext(ctx, updateRoomPolicies, arg.PostPolicy, arg.InvitePolicy, arg.ID)
+	return err
+}
+
+const updateRoomTopic = `-- name: UpdateRoomTopic :exec
+UPDATE rooms SET topic = ? WHERE id = ?
+`
+
+type UpdateRoomTopicParams struct {
+	Topic string
+	ID    int64
+}
+
+func (q *Queries) UpdateRoomTopic(ctx context.Context, arg UpdateRoomTopicParams) error {
+	_, err := q.db.ExecContext(ctx, updateRoomTopic, arg.Topic, arg.ID)
+	return err
+}
+
 const updateUser = `-- name: UpdateUser :exec
 UPDATE users SET login = ?, avatar_url = ?, updated_at = CURRENT_TIMESTAMP
 WHERE id = ?
@@ -122,3 +2273,16 @@ func (q *Queries) UpdateUser(ctx context.Context, arg UpdateUserParams) error {
 	_, err := q.db.ExecContext(ctx, updateUser, arg.Login, arg.AvatarUrl, arg.ID)
 	return err
 }
+
+const useRoomInvite = `-- name: UseRoomInvite :execrows
+UPDATE room_invites SET use_count = use_count + 1
+WHERE id = ? AND revoked_at IS NULL AND (max_uses IS NULL OR use_count < max_uses)
+`
+
+func (q *Queries) UseRoomInvite(ctx context.Context, id int64) (int64, error) {
+	result, err := q.db.ExecContext(ctx, useRoomInvite, id)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
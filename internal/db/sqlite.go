@@ -2,7 +2,9 @@ package db
 
 import (
 	"database/sql"
+	_ "embed"
 	"fmt"
+	"log"
 	"os"
 	"path/filepath"
 
@@ -11,14 +13,7 @@ import (
 
 func OpenSQLite(dbPath string) (*sql.DB, error) {
 	if dbPath == "" {
-		dbPath = os.Getenv("DB_PATH")
-		if dbPath == "" {
-			if os.Getenv("GO_ENV") == "test" {
-				dbPath = "file::memory:?cache=shared"
-			} else {
-				dbPath = "/data/chat.db"
-			}
-		}
+		dbPath = Path()
 	}
 
 	if dbPath != "file::memory:?cache=shared" {
@@ -45,9 +40,75 @@ func OpenSQLite(dbPath string) (*sql.DB, error) {
 		return nil, fmt.Errorf("failed to run migrations: %w", err)
 	}
 
+	if err := setUpSearch(db); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to set up search: %w", err)
+	}
+
 	return db, nil
 }
 
+// Path is where the database lives when OpenSQLite is given no path:
+// DB_PATH, or a default for the environment. Other data the server keeps
+// on disk, such as attachments, lives next to it.
+func Path() string {
+	if dbPath := os.Getenv("DB_PATH"); dbPath != "" {
+		return dbPath
+	}
+	if os.Getenv("GO_ENV") == "test" {
+		return "file::memory:?cache=shared"
+	}
+	return "/data/chat.db"
+}
+
+// FTS5Available reports whether SQLite was compiled with FTS5, which
+// go-sqlite3 only enables under the sqlite_fts5 build tag.
+func FTS5Available(db *sql.DB) bool {
+	var used bool
+	err := db.QueryRow("SELECT sqlite_compileoption_used('ENABLE_FTS5')").Scan(&used)
+	return err == nil && used
+}
+
+//go:embed search.sql
+var searchSQL string
+
+// searchTriggers keep messages_fts in step with messages.
+var searchTriggers = []string{"messages_fts_insert", "messages_fts_delete", "messages_fts_update"}
+
+// setUpSearch makes the database match the binary. With FTS5 the index and
+// its triggers are created, and the index is rebuilt if the triggers were
+// missing, since messages written meanwhile are not in it. Without FTS5 the
+// triggers are dropped, as they would make every write to messages fail;
+// search is then unavailable until the server runs with FTS5 again.
+func setUpSearch(db *sql.DB) error {
+	var triggers int
+	err := db.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE type = 'trigger' AND name LIKE 'messages_fts_%'").Scan(&triggers)
+	if err != nil {
+		return err
+	}
+
+	if !FTS5Available(db) {
+		for _, name := range searchTriggers {
+			if _, err := db.Exec("DROP TRIGGER IF EXISTS " + name); err != nil {
+				return fmt.Errorf("failed to drop %s: %w", name, err)
+			}
+		}
+		log.Printf("SQLite built without FTS5: message search is disabled")
+		return nil
+	}
+
+	if _, err := db.Exec(searchSQL); err != nil {
+		return fmt.Errorf("failed to create search index: %w", err)
+	}
+	if triggers < len(searchTriggers) {
+		log.Printf("Rebuilding message search index")
+		if _, err := db.Exec("INSERT INTO messages_fts (messages_fts) VALUES ('rebuild')"); err != nil {
+			return fmt.Errorf("failed to rebuild search index: %w", err)
+		}
+	}
+	return nil
+}
+
 func buildDSN(dbPath string) string {
 	if dbPath == "file::memory:?cache=shared" {
 		return dbPath
@@ -0,0 +1,399 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/go-chi/chi/v5"
+
+	"blazing/internal/attachments"
+	"blazing/internal/db"
+)
+
+const (
+	maxAttachmentSize = 10 << 20 // 10 MB
+	maxFilenameLength = 255
+)
+
+// attachmentTypes are the types accepted for upload, judged by sniffing the
+// content rather than trusting the name or the browser. Images are shown
+// inline, so only formats Thumbnail can decode are listed; everything else
+// is offered as a download.
+var attachmentTypes = map[string]bool{
+	"image/png":       true,
+	"image/jpeg":      true,
+	"image/gif":       true,
+	"application/pdf": true,
+	"application/zip": true,
+	"text/plain":      true,
+}
+
+type AttachmentView struct {
+	ID       int64
+	RoomID   int64
+	Filename string
+	Size     int64
+	// Original image dimensions, zero for other files
+	Width, Height int64
+	Thumbnail     bool
+}
+
+// UploadAttachment posts a message carrying a file, with an optional
+// caption in the body field.
+func (h *Handlers) UploadAttachment(w http.ResponseWriter, r *http.Request) {
+	access, ok := h.requireRoomMember(w, r)
+	if !ok {
+		return
+	}
+	if !access.can(permPost) {
+		http.Error(w, "You cannot post in this room", http.StatusForbidden)
+		return
+	}
+	room, user := access.Room, access.User
+
+	// Leave room for the caption and multipart framing around the file
+	r.Body = http.MaxBytesReader(w, r.Body, maxAttachmentSize+1<<20)
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			http.Error(w, "Files can be at most 10 MB", http.StatusRequestEntityTooLarge)
+			return
+		}
+		http.Error(w, "No file uploaded", http.StatusBadRequest)
+		return
+	}
+	defer file.Close()
+	if header.Size > maxAttachmentSize {
+		http.Error(w, "Files can be at most 10 MB", http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	body := strings.TrimSpace(r.FormValue("body"))
+	if utf8.RuneCountInString(body) > maxMessageLength {
+		http.Error(w, "Message is too long", http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	contentType, err := sniffContentType(file)
+	if err != nil {
+		slog.Error("Failed to read upload", "error", err, "room_id", room.ID, "user_id", user.ID)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if mediaType, _, _ := mime.ParseMediaType(contentType); !attachmentTypes[mediaType] {
+		http.Error(w, "This type of file cannot be uploaded", http.StatusUnsupportedMediaType)
+		return
+	}
+
+	params := db.CreateAttachmentParams{
+		UserID:      user.ID,
+		Filename:    cleanFilename(header.Filename),
+		ContentType: contentType,
+	}
+	h.storedKeys.RLock()
+	params.StorageKey, params.Size, err = h.app.Attachments.Put(r.Context(), file)
+	if err != nil {
+		h.storedKeys.RUnlock()
+		slog.Error("Failed to store attachment", "error", err, "room_id", room.ID, "user_id", user.ID)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	if strings.HasPrefix(contentType, "image/") {
+		if err := h.storeThumbnail(r.Context(), file, &params); err != nil {
+			// The upload still works, just without a preview
+			slog.Warn("Failed to make thumbnail", "error", err, "storage_key", params.StorageKey)
+		}
+	}
+
+	var message db.Message
+	var attachment db.Attachment
+	var mentioned []int64
+	err = h.app.WithTx(r.Context(), func(q *db.Queries) error {
+		var err error
+		message, err = q.CreateMessage(r.Context(), db.CreateMessageParams{
+			RoomID: room.ID,
+			UserID: user.ID,
+			Body:   body,
+		})
+		if err != nil {
+			return err
+		}
+		params.MessageID = message.ID
+		attachment, err = q.CreateAttachment(r.Context(), params)
+		if err != nil {
+			return fmt.Errorf("failed to save attachment: %w", err)
+		}
+		mentioned, err = h.saveMentions(r.Context(), q, access, user.ID, message.ID, body)
+		return err
+	})
+	h.storedKeys.RUnlock()
+	if err != nil {
+		// The content may be shared with earlier uploads, so it is only
+		// removed if nothing refers to it
+		h.sweepAttachments(context.WithoutCancel(r.Context()), storedKeys(params.StorageKey, params.ThumbnailKey))
+		slog.Error("Failed to create message with attachment", "error", err, "room_id", room.ID, "user_id", user.ID)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	slog.Info("Attachment uploaded", "room_id", room.ID, "message_id", message.ID, "attachment_id", attachment.ID, "size", attachment.Size, "content_type", contentType)
+
+	views := []MessageView{{
+		ID:          message.ID,
+		RoomID:      message.RoomID,
+		UserID:      message.UserID,
+		Login:       user.Login,
+		AvatarURL:   user.AvatarURL,
+		Body:        message.Body,
+		CreatedAt:   message.CreatedAt.Time,
+		Attachments: []AttachmentView{attachmentView(room.ID, attachment)},
+	}}
+	if err := h.attachMentions(r.Context(), views); err != nil {
+		slog.Error("Failed to load mentions", "error", err, "message_id", message.ID)
+	}
+
+	h.publishMessage(w, eventMessageCreated, views[0], http.StatusCreated)
+	h.notifyUnread(r.Context(), room.ID)
+	h.notifyMentions(r.Context(), room, user.Login, message.ID, 0, body, mentioned)
+}
+
+// Attachment sends an attached file to a member of its room.
+func (h *Handlers) Attachment(w http.ResponseWriter, r *http.Request) {
+	h.serveAttachment(w, r, false)
+}
+
+// AttachmentThumbnail sends the preview of an attached image.
+func (h *Handlers) AttachmentThumbnail(w http.ResponseWriter, r *http.Request) {
+	h.serveAttachment(w, r, true)
+}
+
+func (h *Handlers) serveAttachment(w http.ResponseWriter, r *http.Request, thumbnail bool) {
+	access, ok := h.requireRoomMember(w, r)
+	if !ok {
+		return
+	}
+	room := access.Room
+
+	attachmentID, err := strconv.ParseInt(chi.URLParam(r, "attachmentID"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid attachment ID", http.StatusBadRequest)
+		return
+	}
+
+	// Attachments of deleted messages go with them
+	attachment, err := h.app.DB.GetRoomAttachment(r.Context(), db.GetRoomAttachmentParams{
+		ID:     attachmentID,
+		RoomID: room.ID,
+	})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "Attachment not found", http.StatusNotFound)
+			return
+		}
+		slog.Error("Failed to load attachment", "error", err, "attachment_id", attachmentID)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	key, contentType, disposition := attachment.StorageKey, attachment.ContentType, "attachment"
+	if strings.HasPrefix(contentType, "image/") {
+		disposition = "inline"
+	}
+	if thumbnail {
+		if !attachment.ThumbnailKey.Valid {
+			http.Error(w, "Attachment has no thumbnail", http.StatusNotFound)
+			return
+		}
+		key, contentType, disposition = attachment.ThumbnailKey.String, "image/jpeg", "inline"
+	}
+
+	// The content under a key never changes, but who may see it does, so
+	// only the browser caches it
+	etag := `"` + key + `"`
+	w.Header().Set("Cache-Control", "private, max-age=86400")
+	w.Header().Set("ETag", etag)
+	if r.Header.Get("If-None-Match") == etag {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	content, err := h.app.Attachments.Open(r.Context(), key)
+	if err != nil {
+		slog.Error("Failed to open attachment", "error", err, "attachment_id", attachment.ID, "storage_key", key)
+		if errors.Is(err, attachments.ErrNotFound) {
+			http.Error(w, "Attachment not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	defer content.Close()
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", mime.FormatMediaType(disposition, map[string]string{"filename": attachment.Filename}))
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Content-Security-Policy", "sandbox")
+
+	// Local files support range requests; remote ones are streamed whole
+	if seeker, ok := content.(io.ReadSeeker); ok {
+		http.ServeContent(w, r, "", attachment.CreatedAt.Time, seeker)
+		return
+	}
+	if !thumbnail {
+		w.Header().Set("Content-Length", strconv.FormatInt(attachment.Size, 10))
+	}
+	if _, err := io.Copy(w, content); err != nil {
+		slog.Warn("Failed to send attachment", "error", err, "attachment_id", attachment.ID)
+	}
+}
+
+// attachAttachments fills in the files attached to a batch of messages.
+func (h *Handlers) attachAttachments(ctx context.Context, messages []MessageView) error {
+	ids := make([]int64, 0, len(messages))
+	index := make(map[int64]int, len(messages))
+	for i, m := range messages {
+		if !m.Deleted {
+			ids = append(ids, m.ID)
+			index[m.ID] = i
+		}
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+
+	rows, err := h.app.DB.GetMessageAttachments(ctx, ids)
+	if err != nil {
+		return err
+	}
+
+	for _, row := range rows {
+		i := index[row.MessageID]
+		messages[i].Attachments = append(messages[i].Attachments, attachmentView(messages[i].RoomID, row))
+	}
+	return nil
+}
+
+func attachmentView(roomID int64, a db.Attachment) AttachmentView {
+	return AttachmentView{
+		ID:        a.ID,
+		RoomID:    roomID,
+		Filename:  a.Filename,
+		Size:      a.Size,
+		Width:     a.Width,
+		Height:    a.Height,
+		Thumbnail: a.ThumbnailKey.Valid,
+	}
+}
+
+// storeThumbnail scales the uploaded image down and stores the result
+// alongside it, recording both in params.
+func (h *Handlers) storeThumbnail(ctx context.Context, file io.ReadSeeker, params *db.CreateAttachmentParams) error {
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	thumb, width, height, err := attachments.Thumbnail(file, attachments.ThumbnailSize)
+	if err != nil {
+		return err
+	}
+	key, _, err := h.app.Attachments.Put(ctx, bytes.NewReader(thumb))
+	if err != nil {
+		return err
+	}
+	params.ThumbnailKey = sql.NullString{String: key, Valid: true}
+	params.Width, params.Height = int64(width), int64(height)
+	return nil
+}
+
+// sweepAttachments deletes stored content that no attachment refers to any
+// more. Identical uploads share a key, so each key is checked before its
+// content goes. Failures are logged and leave the content in place.
+func (h *Handlers) sweepAttachments(ctx context.Context, keys []string) {
+	if len(keys) == 0 {
+		return
+	}
+
+	h.storedKeys.Lock()
+	defer h.storedKeys.Unlock()
+
+	for _, key := range keys {
+		inUse, err := h.app.DB.AttachmentKeyInUse(ctx, key)
+		if err != nil {
+			slog.Error("Failed to check attachment references", "error", err, "storage_key", key)
+			continue
+		}
+		if inUse != 0 {
+			continue
+		}
+		if err := h.app.Attachments.Delete(ctx, key); err != nil {
+			slog.Error("Failed to delete attachment content", "error", err, "storage_key", key)
+			continue
+		}
+		slog.Info("Unreferenced attachment content deleted", "storage_key", key)
+	}
+}
+
+// storedKeys lists the content an attachment row refers to.
+func storedKeys(storageKey string, thumbnailKey sql.NullString) []string {
+	keys := []string{storageKey}
+	if thumbnailKey.Valid {
+		keys = append(keys, thumbnailKey.String)
+	}
+	return keys
+}
+
+// sniffContentType detects the type of an upload from its first bytes and
+// rewinds it for storing.
+func sniffContentType(file io.ReadSeeker) (string, error) {
+	head := make([]byte, 512)
+	n, err := io.ReadFull(file, head)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+		return "", err
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+	return http.DetectContentType(head[:n]), nil
+}
+
+// cleanFilename keeps the last element of the name the browser sent,
+// without control characters and at a sensible length.
+func cleanFilename(name string) string {
+	// Some browsers send the full path from the user's machine
+	name = name[strings.LastIndexAny(name, `/\`)+1:]
+	name = strings.TrimSpace(strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) {
+			return -1
+		}
+		return r
+	}, name))
+	if utf8.RuneCountInString(name) > maxFilenameLength {
+		name = string([]rune(name)[:maxFilenameLength])
+	}
+	if name == "" || name == "." || name == ".." {
+		return "file"
+	}
+	return name
+}
+
+// fileSize formats a byte count for people.
+func fileSize(n int64) string {
+	switch {
+	case n >= 1<<20:
+		return fmt.Sprintf("%.1f MB", float64(n)/(1<<20))
+	case n >= 1<<10:
+		return fmt.Sprintf("%d KB", n>>10)
+	}
+	return fmt.Sprintf("%d bytes", n)
+}
@@ -0,0 +1,341 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/png"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+
+	"blazing/internal/attachments"
+	"blazing/internal/db"
+)
+
+var attachmentIDPattern = regexp.MustCompile(`/attachments/(\d+)"`)
+
+func TestAttachments(t *testing.T) {
+	testApp, h := setupTestApp(t)
+	ctx := context.Background()
+
+	alice := createTestUser(t, testApp, 1001, "alice")
+	bob := createTestUser(t, testApp, 1002, "bob")
+	mallory := createTestUser(t, testApp, 1003, "mallory")
+	room := createTestRoom(t, testApp, "general", alice, bob)
+
+	upload := func(user db.User, filename string, content []byte, caption string) *httptest.ResponseRecorder {
+		t.Helper()
+		var buf bytes.Buffer
+		mw := multipart.NewWriter(&buf)
+		if caption != "" {
+			mw.WriteField("body", caption)
+		}
+		part, err := mw.CreateFormFile("file", filename)
+		if err != nil {
+			t.Fatalf("Failed to build upload: %v", err)
+		}
+		part.Write(content)
+		mw.Close()
+
+		req := httptest.NewRequest("POST", "/rooms/"+itoa(room.ID)+"/attachments", &buf)
+		req.Header.Set("Content-Type", mw.FormDataContentType())
+		req = withRoute(req, user, map[string]string{"roomID": itoa(room.ID)})
+		w := httptest.NewRecorder()
+		h.UploadAttachment(w, req)
+		return w
+	}
+
+	download := func(user db.User, id string, suffix string) *httptest.ResponseRecorder {
+		t.Helper()
+		req := httptest.NewRequest("GET", "/rooms/"+itoa(room.ID)+"/attachments/"+id+suffix, nil)
+		req = withRoute(req, user, map[string]string{"roomID": itoa(room.ID), "attachmentID": id})
+		w := httptest.NewRecorder()
+		if suffix == "/thumbnail" {
+			h.AttachmentThumbnail(w, req)
+		} else {
+			h.Attachment(w, req)
+		}
+		return w
+	}
+
+	var img bytes.Buffer
+	if err := png.Encode(&img, image.NewRGBA(image.Rect(0, 0, 640, 480))); err != nil {
+		t.Fatalf("Failed to encode image: %v", err)
+	}
+
+	var imageID string
+	t.Run("image with caption", func(t *testing.T) {
+		client := testApp.Hub.Register(room.ID, bob.ID)
+		defer testApp.Hub.Unregister(client)
+
+		w := upload(alice, "C:\\Users\\alice\\screenshot.png", img.Bytes(), "look @bob")
+		if w.Code != http.StatusCreated {
+			t.Fatalf("Expected status %d, got %d: %s", http.StatusCreated, w.Code, w.Body.String())
+		}
+		body := w.Body.String()
+		if !strings.Contains(body, `alt="screenshot.png"`) || !strings.Contains(body, `width="640"`) || !strings.Contains(body, "/thumbnail") {
+			t.Errorf("Expected a thumbnail of the image, got: %s", body)
+		}
+		if !strings.Contains(body, `class="mention"`) {
+			t.Errorf("Expected the caption's mention to be highlighted, got: %s", body)
+		}
+		imageID = attachmentIDPattern.FindStringSubmatch(body)[1]
+
+		var created messageEvent
+		if typ := nextEvent(t, client, &created); typ != eventMessageCreated || !strings.Contains(created.HTML, "screenshot.png") {
+			t.Errorf("Expected the room to see the upload, got %s %s", typ, created.HTML)
+		}
+	})
+
+	t.Run("members download", func(t *testing.T) {
+		w := download(bob, imageID, "")
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+		}
+		if !bytes.Equal(w.Body.Bytes(), img.Bytes()) {
+			t.Error("Expected the original file")
+		}
+		if ct := w.Header().Get("Content-Type"); ct != "image/png" {
+			t.Errorf("Expected image/png, got %s", ct)
+		}
+		if cd := w.Header().Get("Content-Disposition"); cd != `inline; filename=screenshot.png` {
+			t.Errorf("Expected an inline image, got %s", cd)
+		}
+
+		w = download(bob, imageID, "/thumbnail")
+		if w.Code != http.StatusOK || w.Header().Get("Content-Type") != "image/jpeg" {
+			t.Fatalf("Expected a JPEG thumbnail, got %d %s", w.Code, w.Header().Get("Content-Type"))
+		}
+
+		if w := download(mallory, imageID, ""); w.Code != http.StatusForbidden {
+			t.Errorf("Expected non-members to be refused, got %d", w.Code)
+		}
+	})
+
+	t.Run("cached and streamed", func(t *testing.T) {
+		first := download(bob, imageID, "")
+		etag := first.Header().Get("ETag")
+		req := httptest.NewRequest("GET", "/rooms/"+itoa(room.ID)+"/attachments/"+imageID, nil)
+		req.Header.Set("If-None-Match", etag)
+		req = withRoute(req, bob, map[string]string{"roomID": itoa(room.ID), "attachmentID": imageID})
+		w := httptest.NewRecorder()
+		h.Attachment(w, req)
+		if etag == "" || w.Code != http.StatusNotModified {
+			t.Errorf("Expected a revalidated download to be not modified, got %d (etag %q)", w.Code, etag)
+		}
+
+		local := testApp.Attachments
+		testApp.Attachments = streamingStore{local}
+		defer func() { testApp.Attachments = local }()
+
+		w = download(bob, imageID, "")
+		if w.Code != http.StatusOK || !bytes.Equal(w.Body.Bytes(), img.Bytes()) {
+			t.Fatalf("Expected the original file from a streaming store, got %d", w.Code)
+		}
+		if cl := w.Header().Get("Content-Length"); cl != strconv.Itoa(img.Len()) {
+			t.Errorf("Expected Content-Length %d, got %s", img.Len(), cl)
+		}
+	})
+
+	t.Run("room messages include attachments", func(t *testing.T) {
+		page, err := h.loadMessagesBefore(ctx, room.ID, 0)
+		if err != nil {
+			t.Fatalf("Failed to load messages: %v", err)
+		}
+		last := page.Messages[len(page.Messages)-1]
+		if len(last.Attachments) != 1 || last.Attachments[0].Filename != "screenshot.png" || !last.Attachments[0].Thumbnail {
+			t.Errorf("Expected the image on the message, got %+v", last.Attachments)
+		}
+	})
+
+	t.Run("other files download", func(t *testing.T) {
+		w := upload(bob, "notes.txt", []byte("remember the milk\n"), "")
+		if w.Code != http.StatusCreated {
+			t.Fatalf("Expected status %d, got %d: %s", http.StatusCreated, w.Code, w.Body.String())
+		}
+		if !strings.Contains(w.Body.String(), "notes.txt") || !strings.Contains(w.Body.String(), "18 bytes") {
+			t.Errorf("Expected a file link, got: %s", w.Body.String())
+		}
+		id := attachmentIDPattern.FindStringSubmatch(w.Body.String())[1]
+
+		w = download(alice, id, "")
+		if cd := w.Header().Get("Content-Disposition"); cd != `attachment; filename=notes.txt` {
+			t.Errorf("Expected a download, got %s", cd)
+		}
+		if w := download(alice, id, "/thumbnail"); w.Code != http.StatusNotFound {
+			t.Errorf("Expected no thumbnail for text, got %d", w.Code)
+		}
+	})
+
+	t.Run("limits", func(t *testing.T) {
+		if w := upload(alice, "page.html", []byte("<!DOCTYPE html><script>alert(1)</script>"), ""); w.Code != http.StatusUnsupportedMediaType {
+			t.Errorf("Expected status %d, got %d", http.StatusUnsupportedMediaType, w.Code)
+		}
+		// WebP is sniffed as an image but cannot be decoded for a thumbnail
+		if w := upload(alice, "photo.webp", []byte("RIFF\x24\x00\x00\x00WEBPVP8 \x18\x00\x00\x00"), ""); w.Code != http.StatusUnsupportedMediaType {
+			t.Errorf("Expected status %d for WebP, got %d", http.StatusUnsupportedMediaType, w.Code)
+		}
+		if w := upload(alice, "big.txt", bytes.Repeat([]byte("a"), maxAttachmentSize+1), ""); w.Code != http.StatusRequestEntityTooLarge {
+			t.Errorf("Expected status %d, got %d", http.StatusRequestEntityTooLarge, w.Code)
+		}
+		if w := upload(mallory, "notes.txt", []byte("hi"), ""); w.Code != http.StatusForbidden {
+			t.Errorf("Expected status %d, got %d", http.StatusForbidden, w.Code)
+		}
+	})
+
+	t.Run("deleted messages take their files", func(t *testing.T) {
+		page, err := h.loadMessagesBefore(ctx, room.ID, 0)
+		if err != nil {
+			t.Fatalf("Failed to load messages: %v", err)
+		}
+		messageID := page.Messages[0].ID
+
+		req := httptest.NewRequest("DELETE", "/rooms/"+itoa(room.ID)+"/messages/"+itoa(messageID), nil)
+		req = withRoute(req, alice, map[string]string{"roomID": itoa(room.ID), "messageID": itoa(messageID)})
+		w := httptest.NewRecorder()
+		h.DeleteMessage(w, req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+		}
+
+		if w := download(bob, imageID, ""); w.Code != http.StatusNotFound {
+			t.Errorf("Expected status %d, got %d", http.StatusNotFound, w.Code)
+		}
+	})
+}
+
+func TestAttachmentSweep(t *testing.T) {
+	testApp, h := setupTestApp(t)
+	ctx := context.Background()
+
+	alice := createTestUser(t, testApp, 1001, "alice")
+	general := createTestRoom(t, testApp, "general", alice)
+	scratch := createTestRoom(t, testApp, "scratch", alice)
+
+	// upload posts content to a room and returns the new attachment
+	upload := func(room db.Room, content string) db.Attachment {
+		t.Helper()
+		var buf bytes.Buffer
+		mw := multipart.NewWriter(&buf)
+		part, _ := mw.CreateFormFile("file", "notes.txt")
+		part.Write([]byte(content))
+		mw.Close()
+
+		req := httptest.NewRequest("POST", "/rooms/"+itoa(room.ID)+"/attachments", &buf)
+		req.Header.Set("Content-Type", mw.FormDataContentType())
+		req = withRoute(req, alice, map[string]string{"roomID": itoa(room.ID)})
+		w := httptest.NewRecorder()
+		h.UploadAttachment(w, req)
+		if w.Code != http.StatusCreated {
+			t.Fatalf("Expected status %d, got %d: %s", http.StatusCreated, w.Code, w.Body.String())
+		}
+
+		id, _ := strconv.ParseInt(attachmentIDPattern.FindStringSubmatch(w.Body.String())[1], 10, 64)
+		attachment, err := testApp.DB.GetRoomAttachment(ctx, db.GetRoomAttachmentParams{ID: id, RoomID: room.ID})
+		if err != nil {
+			t.Fatalf("Failed to load attachment: %v", err)
+		}
+		return attachment
+	}
+
+	deleteMessage := func(room db.Room, messageID int64) {
+		t.Helper()
+		req := httptest.NewRequest("DELETE", "/rooms/"+itoa(room.ID)+"/messages/"+itoa(messageID), nil)
+		req = withRoute(req, alice, map[string]string{"roomID": itoa(room.ID), "messageID": itoa(messageID)})
+		w := httptest.NewRecorder()
+		h.DeleteMessage(w, req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+		}
+	}
+
+	stored := func(key string) bool {
+		t.Helper()
+		f, err := testApp.Attachments.Open(ctx, key)
+		if err == attachments.ErrNotFound {
+			return false
+		}
+		if err != nil {
+			t.Fatalf("Failed to open content: %v", err)
+		}
+		f.Close()
+		return true
+	}
+
+	t.Run("shared content outlives one message", func(t *testing.T) {
+		first := upload(general, "same bytes")
+		second := upload(general, "same bytes")
+		if first.StorageKey != second.StorageKey {
+			t.Fatal("Expected identical uploads to share a key")
+		}
+
+		deleteMessage(general, first.MessageID)
+		if !stored(first.StorageKey) {
+			t.Fatal("Expected content still referenced elsewhere to stay")
+		}
+
+		deleteMessage(general, second.MessageID)
+		if stored(first.StorageKey) {
+			t.Error("Expected content to go with its last reference")
+		}
+	})
+
+	t.Run("deleting a room sweeps its files", func(t *testing.T) {
+		kept := upload(general, "kept in general")
+		copied := upload(scratch, "kept in general")
+		gone := upload(scratch, "only in scratch")
+
+		req := withRoute(httptest.NewRequest("DELETE", "/rooms/"+itoa(scratch.ID), nil), alice, map[string]string{"roomID": itoa(scratch.ID)})
+		w := httptest.NewRecorder()
+		h.DeleteRoom(w, req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+		}
+
+		if !stored(kept.StorageKey) || copied.StorageKey != kept.StorageKey {
+			t.Error("Expected content shared with another room to stay")
+		}
+		if stored(gone.StorageKey) {
+			t.Error("Expected the room's own content to be deleted")
+		}
+	})
+}
+
+// streamingStore hides Seek from the files a store opens, the way a remote
+// backend returns a plain response body.
+type streamingStore struct {
+	attachments.Store
+}
+
+func (s streamingStore) Open(ctx context.Context, key string) (io.ReadCloser, error) {
+	f, err := s.Store.Open(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	return struct{ io.ReadCloser }{f}, nil
+}
+
+func TestCleanFilename(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"report.pdf", "report.pdf"},
+		{"/home/alice/report.pdf", "report.pdf"},
+		{`C:\Users\alice\report.pdf`, "report.pdf"},
+		{"re\x00port\n.pdf", "report.pdf"},
+		{"..", "file"},
+		{"", "file"},
+		{strings.Repeat("é", 300), strings.Repeat("é", maxFilenameLength)},
+	}
+	for _, tt := range tests {
+		if got := cleanFilename(tt.in); got != tt.want {
+			t.Errorf("cleanFilename(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
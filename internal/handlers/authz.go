@@ -0,0 +1,164 @@
+package handlers
+
+import (
+	"blazing/internal/db"
+	"blazing/internal/session"
+)
+
+// Membership roles, from most to least authority. The owner is the admin
+// who also manages the other admins and can hand the room over.
+const (
+	roleOwner    = "owner"
+	roleAdmin    = "admin"
+	roleMember   = "member"
+	roleReadOnly = "readonly"
+)
+
+// Room policies restrict posting or inviting to admins, as in announcement
+// rooms.
+const (
+	policyMembers = "members"
+	policyAdmins  = "admins"
+)
+
+type permission int
+
+const (
+	permPost      permission = iota // messages and thread replies
+	permReact                       // allowed for read-only members too
+	permInvite                      // never in direct conversations
+	permModerate                    // others' messages, @room and @here, pins
+	permConfigure                   // room policies
+	permTransfer                    // hand ownership to another member
+	permManage                      // rename, archive or delete the room
+)
+
+// roomAccess is the current user's standing in a room, resolved once per
+// request by requireRoomMember. Every permission check goes through it.
+type roomAccess struct {
+	Room          db.Room
+	User          *session.User
+	Role          string
+	Notifications NotificationSettings
+}
+
+func (a roomAccess) isAdmin() bool {
+	return a.Role == roleOwner || a.Role == roleAdmin
+}
+
+func (a roomAccess) can(p permission) bool {
+	// Archived rooms stay readable but nothing in them changes until the
+	// owner brings them back
+	if a.Room.ArchivedAt.Valid && p != permTransfer && p != permManage {
+		return false
+	}
+
+	switch p {
+	case permPost:
+		if a.Role == roleReadOnly {
+			return false
+		}
+		return a.Room.PostPolicy != policyAdmins || a.isAdmin()
+	case permReact:
+		return true
+	case permInvite:
+		// Direct conversations keep the people they were started with
+		if isDirect(a.Room) || a.Role == roleReadOnly {
+			return false
+		}
+		return a.Room.InvitePolicy != policyAdmins || a.isAdmin()
+	case permModerate, permConfigure:
+		return a.isAdmin()
+	case permTransfer, permManage:
+		return a.Role == roleOwner
+	}
+	return false
+}
+
+// canModifyMessage reports whether the user may edit or delete a message:
+// their own while they may still post, and anyone's if they moderate.
+func (a roomAccess) canModifyMessage(authorID int64) bool {
+	if authorID == a.User.ID && a.can(permPost) {
+		return true
+	}
+	return a.can(permModerate)
+}
+
+// canRevokeInvite reports whether the user may revoke an invite link:
+// their own, and anyone's if they moderate.
+func (a roomAccess) canRevokeInvite(creatorID int64) bool {
+	return creatorID == a.User.ID || a.can(permModerate)
+}
+
+// canRemove reports whether the user may remove a member. Everybody but
+// the owner may leave; otherwise removal needs a higher role, so admins
+// remove members and only the owner removes admins.
+func (a roomAccess) canRemove(memberID int64, memberRole string) bool {
+	if isDirect(a.Room) || memberRole == roleOwner {
+		return false
+	}
+	if memberID == a.User.ID {
+		return true
+	}
+	return a.isAdmin() && roleRank(a.Role) > roleRank(memberRole)
+}
+
+// canSetRole reports whether the user may move another member from one
+// role to another. Admins manage members and read-only members; only the
+// owner grants or revokes admin. Ownership moves by transfer instead.
+func (a roomAccess) canSetRole(memberID int64, from, to string) bool {
+	if memberID == a.User.ID || from == roleOwner || to == roleOwner || !validRole(to) {
+		return false
+	}
+	switch a.Role {
+	case roleOwner:
+		return true
+	case roleAdmin:
+		return from != roleAdmin && to != roleAdmin
+	}
+	return false
+}
+
+// assignableRoles lists the roles the user may give other members.
+func (a roomAccess) assignableRoles() []string {
+	switch a.Role {
+	case roleOwner:
+		return []string{roleAdmin, roleMember, roleReadOnly}
+	case roleAdmin:
+		return []string{roleMember, roleReadOnly}
+	}
+	return nil
+}
+
+func roleLabel(role string) string {
+	switch role {
+	case roleOwner:
+		return "owner"
+	case roleAdmin:
+		return "admin"
+	case roleReadOnly:
+		return "read-only"
+	}
+	return "member"
+}
+
+func roleRank(role string) int {
+	switch role {
+	case roleOwner:
+		return 3
+	case roleAdmin:
+		return 2
+	case roleMember:
+		return 1
+	}
+	return 0
+}
+
+// validRole accepts the roles that can be assigned directly.
+func validRole(role string) bool {
+	return role == roleAdmin || role == roleMember || role == roleReadOnly
+}
+
+func validPolicy(policy string) bool {
+	return policy == policyMembers || policy == policyAdmins
+}
@@ -0,0 +1,97 @@
+package handlers
+
+import (
+	"testing"
+
+	"blazing/internal/db"
+	"blazing/internal/session"
+)
+
+func TestRoomAccessCan(t *testing.T) {
+	open := db.Room{Kind: "room", PostPolicy: policyMembers, InvitePolicy: policyMembers}
+	announce := db.Room{Kind: "room", PostPolicy: policyAdmins, InvitePolicy: policyAdmins}
+	direct := db.Room{Kind: roomKindDirect, PostPolicy: policyMembers, InvitePolicy: policyMembers}
+
+	tests := []struct {
+		name string
+		room db.Room
+		role string
+		perm permission
+		want bool
+	}{
+		{"member posts", open, roleMember, permPost, true},
+		{"read-only cannot post", open, roleReadOnly, permPost, false},
+		{"read-only reacts", open, roleReadOnly, permReact, true},
+		{"member cannot post in announcements", announce, roleMember, permPost, false},
+		{"admin posts in announcements", announce, roleAdmin, permPost, true},
+		{"member invites", open, roleMember, permInvite, true},
+		{"read-only cannot invite", open, roleReadOnly, permInvite, false},
+		{"member cannot invite when restricted", announce, roleMember, permInvite, false},
+		{"owner invites when restricted", announce, roleOwner, permInvite, true},
+		{"member cannot moderate", open, roleMember, permModerate, false},
+		{"admin moderates", open, roleAdmin, permModerate, true},
+		{"admin configures", open, roleAdmin, permConfigure, true},
+		{"admin cannot transfer", open, roleAdmin, permTransfer, false},
+		{"owner transfers", open, roleOwner, permTransfer, true},
+		{"direct members post", direct, roleMember, permPost, true},
+		{"direct members cannot invite", direct, roleMember, permInvite, false},
+		{"direct members cannot moderate", direct, roleMember, permModerate, false},
+	}
+
+	for _, tt := range tests {
+		access := roomAccess{Room: tt.room, User: &session.User{ID: 1}, Role: tt.role}
+		if got := access.can(tt.perm); got != tt.want {
+			t.Errorf("%s: can(%d) = %v, want %v", tt.name, tt.perm, got, tt.want)
+		}
+	}
+}
+
+func TestRoomAccessMembers(t *testing.T) {
+	room := db.Room{Kind: "room", PostPolicy: policyMembers, InvitePolicy: policyMembers}
+	as := func(role string) roomAccess {
+		return roomAccess{Room: room, User: &session.User{ID: 1}, Role: role}
+	}
+
+	removals := []struct {
+		actor, member string
+		memberID      int64
+		want          bool
+	}{
+		{roleMember, roleMember, 1, true},
+		{roleAdmin, roleAdmin, 1, true},
+		{roleOwner, roleOwner, 1, false},
+		{roleMember, roleReadOnly, 2, false},
+		{roleAdmin, roleMember, 2, true},
+		{roleAdmin, roleReadOnly, 2, true},
+		{roleAdmin, roleAdmin, 2, false},
+		{roleOwner, roleAdmin, 2, true},
+		{roleAdmin, roleOwner, 2, false},
+	}
+	for _, tt := range removals {
+		if got := as(tt.actor).canRemove(tt.memberID, tt.member); got != tt.want {
+			t.Errorf("%s removing %s (id %d) = %v, want %v", tt.actor, tt.member, tt.memberID, got, tt.want)
+		}
+	}
+
+	changes := []struct {
+		actor, from, to string
+		memberID        int64
+		want            bool
+	}{
+		{roleOwner, roleMember, roleAdmin, 2, true},
+		{roleOwner, roleAdmin, roleReadOnly, 2, true},
+		{roleOwner, roleMember, roleOwner, 2, false},
+		{roleOwner, roleOwner, roleMember, 1, false},
+		{roleAdmin, roleMember, roleReadOnly, 2, true},
+		{roleAdmin, roleMember, roleAdmin, 2, false},
+		{roleAdmin, roleAdmin, roleMember, 2, false},
+		{roleAdmin, roleAdmin, roleMember, 1, false},
+		{roleMember, roleReadOnly, roleMember, 2, false},
+		{roleOwner, roleMember, "superuser", 2, false},
+	}
+	for _, tt := range changes {
+		if got := as(tt.actor).canSetRole(tt.memberID, tt.from, tt.to); got != tt.want {
+			t.Errorf("%s moving %s to %s (id %d) = %v, want %v", tt.actor, tt.from, tt.to, tt.memberID, got, tt.want)
+		}
+	}
+}
@@ -9,13 +9,16 @@ import (
 )
 
 type DashboardData struct {
-	User *session.User
+	User     *session.User
+	Rooms    []RoomListItem
+	Directs  []RoomListItem
+	Archived []RoomListItem
 }
 
 func (h *Handlers) Dashboard(w http.ResponseWriter, r *http.Request) {
-	user, err := h.app.Session.Get(r)
+	user, err := h.authenticate(w, r)
 	if err != nil {
-		if errors.Is(err, session.ErrNoSession) || errors.Is(err, session.ErrInvalidSession) {
+		if errors.Is(err, session.ErrNoSession) || errors.Is(err, session.ErrInvalidSession) || errors.Is(err, errAccountDisabled) {
 			if err := h.loginTemplate.ExecuteTemplate(w, "login", nil); err != nil {
 				slog.Error("Failed to render login template", "error", err)
 				http.Error(w, "Internal server error", http.StatusInternalServerError)
@@ -26,8 +29,24 @@ func (h *Handlers) Dashboard(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
 		return
 	}
-	data := DashboardData{
-		User: user,
+
+	rooms, err := h.loadRoomList(r.Context(), user.ID)
+	if err != nil {
+		slog.Error("Failed to load user rooms", "error", err, "user_id", user.ID)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	data := DashboardData{User: user}
+	for _, room := range rooms {
+		switch {
+		case room.Archived:
+			data.Archived = append(data.Archived, room)
+		case room.Direct:
+			data.Directs = append(data.Directs, room)
+		default:
+			data.Rooms = append(data.Rooms, room)
+		}
 	}
 
 	if err := h.dashboardTemplate.ExecuteTemplate(w, "dashboard", data); err != nil {
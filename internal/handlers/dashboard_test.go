@@ -29,8 +29,9 @@ func TestDashboard(t *testing.T) {
 	})
 
 	t.Run("shows dashboard when authenticated", func(t *testing.T) {
+		stored := createTestUser(t, testApp, 12345, "testuser")
 		testUser := &session.User{
-			ID:        1,
+			ID:        stored.ID,
 			GitHubUID: 12345,
 			Login:     "testuser",
 			AvatarURL: "https://example.com/avatar.jpg",
@@ -38,7 +39,7 @@ func TestDashboard(t *testing.T) {
 
 		tempW := httptest.NewRecorder()
 
-		if err := testApp.Session.Set(tempW, testUser); err != nil {
+		if err := testApp.Session.Set(tempW, httptest.NewRequest("GET", "/", nil), testUser); err != nil {
 			t.Fatalf("Failed to set test session: %v", err)
 		}
 
@@ -73,4 +74,24 @@ func TestDashboard(t *testing.T) {
 			t.Errorf("Expected dashboard content, got: %s", body)
 		}
 	})
+	t.Run("lists the user's rooms", func(t *testing.T) {
+		alice := createTestUser(t, testApp, 1001, "alice")
+		createTestRoom(t, testApp, "Design reviews", alice)
+
+		req := httptest.NewRequest("GET", "/", nil)
+		for _, c := range sessionCookies(t, testApp, alice) {
+			req.AddCookie(c)
+		}
+		w := httptest.NewRecorder()
+
+		h.Dashboard(w, req)
+
+		body := w.Body.String()
+		if !strings.Contains(body, "Design reviews") {
+			t.Errorf("Expected room in dashboard list, got: %s", body)
+		}
+		if strings.Contains(body, "Your chats will appear here") {
+			t.Error("Expected empty state to be hidden when rooms exist")
+		}
+	})
 }
@@ -0,0 +1,176 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"blazing/internal/db"
+	"blazing/internal/session"
+)
+
+// roomKindDirect marks direct conversations: rooms whose members are fixed
+// when they are started.
+const roomKindDirect = "dm"
+
+// maxDirectParticipants keeps group DMs small; bigger groups want a room.
+const maxDirectParticipants = 8
+
+// CreateDirectMessage opens the conversation between the current user and
+// the given logins, starting it if these people have never talked before.
+func (h *Handlers) CreateDirectMessage(w http.ResponseWriter, r *http.Request) {
+	user, ok := GetUserFromContext(r)
+	if !ok {
+		slog.Error("User not found in context for direct message")
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	logins, err := parseDirectLogins(r.FormValue("logins"), user.Login)
+	if err != nil {
+		http.Error(w, "Invalid recipients: "+err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+
+	room, err := h.openDirectRoom(r.Context(), user, logins)
+	if err != nil {
+		slog.Error("Failed to open direct message", "error", err, "user_id", user.ID)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	url := fmt.Sprintf("/rooms/%d", room.ID)
+	if r.Header.Get("HX-Request") != "" {
+		w.Header().Set("HX-Redirect", url)
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	http.Redirect(w, r, url, http.StatusSeeOther)
+}
+
+// parseDirectLogins splits a comma or space separated list of logins,
+// dropping duplicates and the sender's own login.
+func parseDirectLogins(raw, self string) ([]string, error) {
+	var logins []string
+	seen := map[string]bool{strings.ToLower(self): true}
+	for _, field := range strings.FieldsFunc(raw, func(r rune) bool { return r == ',' || r == ' ' || r == '\n' }) {
+		login := strings.TrimPrefix(strings.TrimSpace(field), "@")
+		if login == "" {
+			continue
+		}
+		if !githubLoginPattern.MatchString(login) {
+			return nil, fmt.Errorf("%q is not a GitHub username", login)
+		}
+		if !seen[strings.ToLower(login)] {
+			seen[strings.ToLower(login)] = true
+			logins = append(logins, login)
+		}
+	}
+
+	if len(logins) == 0 {
+		return nil, errors.New("choose someone to message")
+	}
+	if len(logins) >= maxDirectParticipants {
+		return nil, fmt.Errorf("direct messages are limited to %d people; create a room instead", maxDirectParticipants)
+	}
+	return logins, nil
+}
+
+// openDirectRoom returns the conversation between user and logins. The
+// key over the sorted member ids means each group of people has exactly
+// one; a concurrent start of the same conversation falls back to reading
+// the one that won.
+func (h *Handlers) openDirectRoom(ctx context.Context, user *session.User, logins []string) (db.Room, error) {
+	var room db.Room
+	err := h.app.WithTx(ctx, func(q *db.Queries) error {
+		ids := []int64{user.ID}
+		names := []string{user.Login}
+		for _, login := range logins {
+			member, err := userByLogin(ctx, q, login)
+			if err != nil {
+				return fmt.Errorf("failed to resolve %s: %w", login, err)
+			}
+			if !slices.Contains(ids, member.ID) {
+				ids = append(ids, member.ID)
+				names = append(names, member.Login)
+			}
+		}
+
+		key := directKey(ids)
+		var err error
+		room, err = q.GetDirectRoom(ctx, key)
+		if err == nil {
+			return nil
+		}
+		if !errors.Is(err, sql.ErrNoRows) {
+			return err
+		}
+
+		sort.Slice(names, func(i, j int) bool { return strings.ToLower(names[i]) < strings.ToLower(names[j]) })
+		room, err = q.CreateDirectRoom(ctx, db.CreateDirectRoomParams{
+			Name:      strings.Join(names, ", "),
+			CreatorID: user.ID,
+			DmKey:     key,
+		})
+		if errors.Is(err, sql.ErrNoRows) {
+			room, err = q.GetDirectRoom(ctx, key)
+			return err
+		}
+		if err != nil {
+			return fmt.Errorf("failed to insert direct room: %w", err)
+		}
+
+		for _, id := range ids {
+			if err := q.CreateRoomMembership(ctx, db.CreateRoomMembershipParams{RoomID: room.ID, UserID: id, Role: roleMember}); err != nil {
+				return fmt.Errorf("failed to add member %d: %w", id, err)
+			}
+		}
+		slog.Info("Direct message started", "room_id", room.ID, "user_id", user.ID, "members", len(ids))
+		return nil
+	})
+	return room, err
+}
+
+// directKey identifies a set of people regardless of who started the
+// conversation or the order they were named in.
+func directKey(userIDs []int64) sql.NullString {
+	sorted := slices.Clone(userIDs)
+	slices.Sort(sorted)
+	sorted = slices.Compact(sorted)
+
+	parts := make([]string, len(sorted))
+	for i, id := range sorted {
+		parts[i] = strconv.FormatInt(id, 10)
+	}
+	return sql.NullString{String: strings.Join(parts, ","), Valid: true}
+}
+
+func isDirect(room db.Room) bool {
+	return room.Kind == roomKindDirect
+}
+
+// roomTitle is how the viewer sees a room: direct conversations are named
+// after the other people in them.
+func roomTitle(room db.Room, members []MemberView) string {
+	if !isDirect(room) {
+		return room.Name
+	}
+
+	var others []string
+	for _, member := range members {
+		if !member.IsSelf {
+			others = append(others, member.Login)
+		}
+	}
+	if len(others) == 0 {
+		return room.Name
+	}
+	return strings.Join(others, ", ")
+}
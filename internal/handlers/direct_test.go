@@ -0,0 +1,186 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+
+	"blazing/internal/db"
+	"blazing/internal/session"
+)
+
+func TestParseDirectLogins(t *testing.T) {
+	tests := []struct {
+		raw     string
+		want    []string
+		wantErr bool
+	}{
+		{"bob", []string{"bob"}, false},
+		{"@bob, carol  Bob,alice", []string{"bob", "carol"}, false},
+		{"alice", nil, true},
+		{" , ", nil, true},
+		{"bob, not a/login", nil, true},
+		{"a,b,c,d,e,f,g", []string{"a", "b", "c", "d", "e", "f", "g"}, false},
+		{"a,b,c,d,e,f,g,h", nil, true},
+	}
+
+	for _, tt := range tests {
+		got, err := parseDirectLogins(tt.raw, "Alice")
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseDirectLogins(%q) error = %v, wantErr %v", tt.raw, err, tt.wantErr)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseDirectLogins(%q) = %q, want %q", tt.raw, got, tt.want)
+		}
+	}
+}
+
+func TestCreateDirectMessage(t *testing.T) {
+	testApp, h := setupTestApp(t)
+	ctx := context.Background()
+
+	alice := createTestUser(t, testApp, 1001, "alice")
+	bob := createTestUser(t, testApp, 1002, "bob")
+	createTestUser(t, testApp, 1003, "carol")
+
+	open := func(user db.User, logins string) int64 {
+		t.Helper()
+		form := url.Values{"logins": {logins}}
+		req := httptest.NewRequest("POST", "/dms", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.Header.Set("HX-Request", "true")
+		req = withRoute(req, user, nil)
+		w := httptest.NewRecorder()
+		h.CreateDirectMessage(w, req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+		}
+
+		target := w.Header().Get("HX-Redirect")
+		roomID, ok := strings.CutPrefix(target, "/rooms/")
+		if !ok {
+			t.Fatalf("Expected a redirect to the conversation, got %q", target)
+		}
+		id, err := strconv.ParseInt(roomID, 10, 64)
+		if err != nil {
+			t.Fatalf("Invalid room id in %q", target)
+		}
+		return id
+	}
+
+	var dm int64
+	t.Run("starts a conversation once", func(t *testing.T) {
+		dm = open(alice, "bob")
+		if again := open(alice, "@bob"); again != dm {
+			t.Errorf("Expected the same conversation, got %d and %d", dm, again)
+		}
+		if reverse := open(bob, "alice"); reverse != dm {
+			t.Errorf("Expected bob to reach the same conversation, got %d and %d", dm, reverse)
+		}
+
+		members, err := testApp.DB.GetRoomMembers(ctx, dm)
+		if err != nil {
+			t.Fatalf("Failed to load members: %v", err)
+		}
+		if len(members) != 2 {
+			t.Errorf("Expected two members, got %d", len(members))
+		}
+	})
+
+	t.Run("group conversations ignore order", func(t *testing.T) {
+		group := open(alice, "bob, carol")
+		if group == dm {
+			t.Fatal("Expected a group conversation to be separate from the 1:1")
+		}
+		if again := open(bob, "carol alice"); again != group {
+			t.Errorf("Expected the same group conversation, got %d and %d", group, again)
+		}
+	})
+
+	t.Run("listed separately on the dashboard", func(t *testing.T) {
+		createTestRoom(t, testApp, "general", alice, bob)
+
+		rooms, err := h.loadRoomList(ctx, bob.ID)
+		if err != nil {
+			t.Fatalf("Failed to load rooms: %v", err)
+		}
+		var names []string
+		for _, room := range rooms {
+			if room.Direct {
+				logins := strings.Split(room.Name, ", ")
+				slices.Sort(logins)
+				names = append(names, strings.Join(logins, ", "))
+			}
+		}
+		slices.Sort(names)
+		if want := []string{"alice", "alice, carol"}; !slices.Equal(names, want) {
+			t.Errorf("Expected conversations named after the other people %q, got %q", want, names)
+		}
+
+		req := httptest.NewRequest("GET", "/", nil)
+		for _, c := range sessionCookies(t, testApp, bob) {
+			req.AddCookie(c)
+		}
+		w := httptest.NewRecorder()
+		h.Dashboard(w, req)
+		body := w.Body.String()
+		section := body[strings.Index(body, `id="dm-list"`):]
+		if !strings.Contains(section, `/rooms/`+itoa(dm)) || strings.Contains(section, "general") {
+			t.Errorf("Expected only direct conversations in the DM list, got: %s", section)
+		}
+	})
+
+	t.Run("members are fixed", func(t *testing.T) {
+		form := url.Values{"login": {"carol"}}
+		req := httptest.NewRequest("POST", "/rooms/"+itoa(dm)+"/members", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req = withRoute(req, alice, map[string]string{"roomID": itoa(dm)})
+		w := httptest.NewRecorder()
+		h.InviteMember(w, req)
+		if w.Code != http.StatusConflict {
+			t.Errorf("Expected status %d, got %d", http.StatusConflict, w.Code)
+		}
+
+		req = httptest.NewRequest("DELETE", "/rooms/"+itoa(dm)+"/members/"+itoa(bob.ID), nil)
+		req = withRoute(req, alice, map[string]string{"roomID": itoa(dm), "userID": itoa(bob.ID)})
+		w = httptest.NewRecorder()
+		h.RemoveMember(w, req)
+		if w.Code != http.StatusForbidden {
+			t.Errorf("Expected status %d, got %d", http.StatusForbidden, w.Code)
+		}
+	})
+
+	t.Run("nobody moderates a conversation", func(t *testing.T) {
+		room, err := testApp.DB.GetRoom(ctx, dm)
+		if err != nil {
+			t.Fatalf("Failed to load room: %v", err)
+		}
+		membership, err := testApp.DB.GetRoomMembership(ctx, db.GetRoomMembershipParams{RoomID: dm, UserID: alice.ID})
+		if err != nil {
+			t.Fatalf("Failed to load membership: %v", err)
+		}
+		access := roomAccess{Room: room, User: &session.User{ID: alice.ID}, Role: membership.Role}
+		if access.can(permModerate) || access.can(permInvite) {
+			t.Error("Expected the starter of a conversation not to be its admin")
+		}
+	})
+
+	t.Run("room page is titled after the other person", func(t *testing.T) {
+		req := withRoute(httptest.NewRequest("GET", "/rooms/"+itoa(dm), nil), bob, map[string]string{"roomID": itoa(dm)})
+		w := httptest.NewRecorder()
+		h.Room(w, req)
+		if !strings.Contains(w.Body.String(), `<h2 id="room-title">alice</h2>`) {
+			t.Errorf("Expected conversation titled alice, got: %s", w.Body.String())
+		}
+		if strings.Contains(w.Body.String(), `class="invite"`) {
+			t.Error("Expected no invite form in a direct conversation")
+		}
+	})
+}
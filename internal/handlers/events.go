@@ -0,0 +1,107 @@
+package handlers
+
+import (
+	"log/slog"
+
+	"blazing/internal/hub"
+)
+
+// WebSocket event types pushed to room clients
+const (
+	eventMessageCreated = "message.created"
+	eventMessageUpdated = "message.updated"
+	eventMessageDeleted = "message.deleted"
+
+	eventReactionsUpdated = "reactions.updated"
+
+	eventPinsUpdated = "pins.updated"
+
+	eventThreadReplyCreated = "thread.reply.created"
+
+	eventPermissionsChanged = "room.permissions.changed"
+
+	// Ephemeral, never persisted
+	eventPresenceChanged = "presence.changed"
+	eventTyping          = "typing"
+)
+
+// WebSocket event types pushed to a user's dashboard connections
+const (
+	eventRoomUnread   = "room.unread"
+	eventRoomPresence = "room.presence"
+)
+
+// WebSocket event types pushed to room clients and to each member's
+// dashboard
+const (
+	eventRoomUpdated = "room.updated"
+	eventRoomDeleted = "room.deleted"
+)
+
+// WebSocket event types pushed to every connection a user holds
+const (
+	eventMention = "mention"
+)
+
+type messageEvent struct {
+	ID   int64  `json:"id"`
+	HTML string `json:"html"`
+}
+
+// threadEvent is delivered to the whole room; clients only act on it when
+// they have that thread open.
+type threadEvent struct {
+	ThreadID int64  `json:"thread_id"`
+	ID       int64  `json:"id"`
+	HTML     string `json:"html"`
+}
+
+// mentionEvent tells a user someone mentioned them, wherever they are.
+type mentionEvent struct {
+	RoomID    int64  `json:"room_id"`
+	RoomName  string `json:"room_name"`
+	MessageID int64  `json:"message_id"`
+	ThreadID  int64  `json:"thread_id,omitempty"`
+	Login     string `json:"login"`
+	Excerpt   string `json:"excerpt"`
+}
+
+// roomEvent carries a room's settings after its owner changes them, and
+// who made the change.
+type roomEvent struct {
+	ID       int64  `json:"id"`
+	Name     string `json:"name"`
+	Topic    string `json:"topic"`
+	Archived bool   `json:"archived"`
+	Login    string `json:"login"`
+}
+
+// permissionsEvent lists the members whose role changed, or none when the
+// room's policies did.
+type permissionsEvent struct {
+	UserIDs []int64 `json:"user_ids,omitempty"`
+}
+
+type unreadEvent struct {
+	RoomID   int64 `json:"room_id"`
+	Unread   int64 `json:"unread"`
+	Mentions int64 `json:"mentions"`
+}
+
+func (h *Handlers) broadcast(roomID int64, eventType string, data any) {
+	if err := h.app.Hub.BroadcastEvent(roomID, hub.Event{Type: eventType, Data: data}); err != nil {
+		slog.Error("Failed to broadcast event", "error", err, "type", eventType, "room_id", roomID)
+	}
+}
+
+func (h *Handlers) sendToUser(userID int64, eventType string, data any) {
+	if err := h.app.Hub.SendUserEvent(userID, hub.Event{Type: eventType, Data: data}); err != nil {
+		slog.Error("Failed to send user event", "error", err, "type", eventType, "user_id", userID)
+	}
+}
+
+func (h *Handlers) notify(userID int64, eventType string, data any) {
+	if err := h.app.Hub.NotifyEvent(userID, hub.Event{Type: eventType, Data: data}); err != nil {
+		slog.Error("Failed to send notification", "error", err, "type", eventType, "user_id", userID)
+	}
+}
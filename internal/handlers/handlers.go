@@ -1,8 +1,10 @@
 package handlers
 
 import (
+	"bytes"
 	"embed"
 	"html/template"
+	"sync"
 
 	"blazing/internal/app"
 )
@@ -10,10 +12,29 @@ import (
 //go:embed templates/*.html
 var templateFS embed.FS
 
+var roomFuncs = template.FuncMap{
+	"reactionPalette": func() []string { return reactionPalette },
+	"renderBody":      renderBody,
+	"roleLabel":       roleLabel,
+	"fileSize":        fileSize,
+}
+
 type Handlers struct {
 	app               *app.App
 	loginTemplate     *template.Template
 	dashboardTemplate *template.Template
+	roomTemplate      *template.Template
+	searchTemplate    *template.Template
+	savedTemplate     *template.Template
+	sessionsTemplate  *template.Template
+
+	// users caches accounts for the auth middleware
+	users *userCache
+
+	// storedKeys is held for reading from storing an upload until its row
+	// is committed, and for writing while unreferenced content is deleted,
+	// so a sweep cannot remove content an upload is about to refer to
+	storedKeys sync.RWMutex
 }
 
 func New(app *app.App) (*Handlers, error) {
@@ -27,9 +48,47 @@ func New(app *app.App) (*Handlers, error) {
 		return nil, err
 	}
 
-	return &Handlers{
+	roomTmpl, err := template.New("room").Funcs(roomFuncs).ParseFS(templateFS, "templates/base.html", "templates/room.html", "templates/message.html", "templates/thread.html", "templates/members.html")
+	if err != nil {
+		return nil, err
+	}
+
+	searchTmpl, err := template.New("search").ParseFS(templateFS, "templates/base.html", "templates/search.html")
+	if err != nil {
+		return nil, err
+	}
+
+	savedTmpl, err := template.New("saved").Funcs(roomFuncs).ParseFS(templateFS, "templates/base.html", "templates/saved.html")
+	if err != nil {
+		return nil, err
+	}
+
+	sessionsTmpl, err := template.New("sessions").ParseFS(templateFS, "templates/base.html", "templates/sessions.html")
+	if err != nil {
+		return nil, err
+	}
+
+	h := &Handlers{
 		app:               app,
 		loginTemplate:     loginTmpl,
 		dashboardTemplate: dashboardTmpl,
-	}, nil
+		roomTemplate:      roomTmpl,
+		searchTemplate:    searchTmpl,
+		savedTemplate:     savedTmpl,
+		sessionsTemplate:  sessionsTmpl,
+		users:             newUserCache(userCacheTTL),
+	}
+	app.Presence.OnChange(h.presenceChanged)
+
+	return h, nil
+}
+
+// renderFragment executes a partial from the room template set, for HTML
+// that is pushed over WebSockets rather than written to a response.
+func (h *Handlers) renderFragment(name string, data any) (string, error) {
+	var buf bytes.Buffer
+	if err := h.roomTemplate.ExecuteTemplate(&buf, name, data); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
 }
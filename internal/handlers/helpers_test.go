@@ -0,0 +1,105 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"blazing/internal/app"
+	"blazing/internal/db"
+	"blazing/internal/session"
+)
+
+func createTestUser(t *testing.T, testApp *app.App, githubUID int64, login string) db.User {
+	t.Helper()
+	user, err := testApp.DB.CreateUser(context.Background(), db.CreateUserParams{
+		GithubUid: sql.NullInt64{Int64: githubUID, Valid: true},
+		Login:     login,
+		AvatarUrl: sql.NullString{String: "https://example.com/" + login + ".jpg", Valid: true},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create test user: %v", err)
+	}
+	return user
+}
+
+func createTestRoom(t *testing.T, testApp *app.App, name string, members ...db.User) db.Room {
+	t.Helper()
+	ctx := context.Background()
+	room, err := testApp.DB.CreateRoom(ctx, db.CreateRoomParams{Name: name, CreatorID: members[0].ID})
+	if err != nil {
+		t.Fatalf("Failed to create test room: %v", err)
+	}
+	for i, m := range members {
+		role := roleMember
+		if i == 0 {
+			role = roleOwner
+		}
+		if err := testApp.DB.CreateRoomMembership(ctx, db.CreateRoomMembershipParams{RoomID: room.ID, UserID: m.ID, Role: role}); err != nil {
+			t.Fatalf("Failed to add room member: %v", err)
+		}
+	}
+	return room
+}
+
+func sessionCookies(t *testing.T, testApp *app.App, user db.User) []*http.Cookie {
+	t.Helper()
+	w := httptest.NewRecorder()
+	err := testApp.Session.Set(w, httptest.NewRequest("GET", "/", nil), &session.User{
+		ID:        user.ID,
+		GitHubUID: user.GithubUid.Int64,
+		Login:     user.Login,
+		AvatarURL: user.AvatarUrl.String,
+	})
+	if err != nil {
+		t.Fatalf("Failed to set test session: %v", err)
+	}
+	return w.Result().Cookies()
+}
+
+func cookieHeader(cookies []*http.Cookie) http.Header {
+	var parts []string
+	for _, c := range cookies {
+		parts = append(parts, c.Name+"="+c.Value)
+	}
+	return http.Header{"Cookie": []string{strings.Join(parts, "; ")}}
+}
+
+// withRoute attaches chi URL params and the authenticated user so handlers
+// can be called directly without a router.
+func withRoute(r *http.Request, user db.User, params map[string]string) *http.Request {
+	rctx := chi.NewRouteContext()
+	for k, v := range params {
+		rctx.URLParams.Add(k, v)
+	}
+	ctx := context.WithValue(r.Context(), chi.RouteCtxKey, rctx)
+	ctx = context.WithValue(ctx, userContextKey{}, &session.User{
+		ID:        user.ID,
+		GitHubUID: user.GithubUid.Int64,
+		Login:     user.Login,
+		AvatarURL: user.AvatarUrl.String,
+	})
+	return r.WithContext(ctx)
+}
+
+func itoa(id int64) string {
+	return strconv.FormatInt(id, 10)
+}
+
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("Timed out waiting for condition")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -0,0 +1,322 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"blazing/internal/db"
+	"blazing/internal/session"
+)
+
+const (
+	// Purpose the invite id is signed for, so no other signed value works
+	// as an invite link
+	invitePurpose = "invite"
+
+	maxInviteExpiry = 30 * 24 * time.Hour
+	maxInviteUses   = 1000
+)
+
+var errInviteUsedUp = errors.New("invite used up")
+
+type InviteView struct {
+	ID      int64
+	RoomID  int64
+	URL     string
+	Creator string
+	// Zero when the link never expires
+	ExpiresAt time.Time
+	// Zero when the link can be used any number of times
+	MaxUses   int64
+	Uses      int64
+	CanRevoke bool
+}
+
+// CreateInvite makes a shareable link that adds whoever opens it to the
+// room, optionally expiring after a while or a number of uses.
+func (h *Handlers) CreateInvite(w http.ResponseWriter, r *http.Request) {
+	access, ok := h.requireRoomMember(w, r)
+	if !ok {
+		return
+	}
+	room, user := access.Room, access.User
+	if !access.can(permInvite) {
+		http.Error(w, "You cannot invite people to this room", http.StatusForbidden)
+		return
+	}
+
+	params := db.CreateRoomInviteParams{RoomID: room.ID, CreatorID: user.ID}
+
+	if raw := r.FormValue("expires_in"); raw != "" {
+		expiresIn, err := time.ParseDuration(raw)
+		if err != nil || expiresIn <= 0 || expiresIn > maxInviteExpiry {
+			http.Error(w, "Invalid expiry", http.StatusUnprocessableEntity)
+			return
+		}
+		params.ExpiresAt = sql.NullTime{Time: time.Now().UTC().Add(expiresIn), Valid: true}
+	}
+
+	if raw := strings.TrimSpace(r.FormValue("max_uses")); raw != "" {
+		maxUses, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil || maxUses < 1 || maxUses > maxInviteUses {
+			http.Error(w, fmt.Sprintf("Maximum uses must be between 1 and %d", maxInviteUses), http.StatusUnprocessableEntity)
+			return
+		}
+		params.MaxUses = sql.NullInt64{Int64: maxUses, Valid: true}
+	}
+
+	invite, err := h.app.DB.CreateRoomInvite(r.Context(), params)
+	if err != nil {
+		slog.Error("Failed to create invite", "error", err, "room_id", room.ID, "user_id", user.ID)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	slog.Info("Invite link created", "room_id", room.ID, "invite_id", invite.ID, "created_by", user.ID)
+
+	view := h.inviteView(access, invite, user.Login)
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(http.StatusCreated)
+	if err := h.roomTemplate.ExecuteTemplate(w, "invite_item", view); err != nil {
+		slog.Error("Failed to render invite", "error", err, "room_id", room.ID)
+	}
+}
+
+// RevokeInvite stops a link from working. Its creator and the room's
+// admins may revoke it.
+func (h *Handlers) RevokeInvite(w http.ResponseWriter, r *http.Request) {
+	access, ok := h.requireRoomMember(w, r)
+	if !ok {
+		return
+	}
+	room, user := access.Room, access.User
+
+	inviteID, err := strconv.ParseInt(chi.URLParam(r, "inviteID"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid invite ID", http.StatusBadRequest)
+		return
+	}
+
+	invite, err := h.app.DB.GetRoomInvite(r.Context(), inviteID)
+	if err != nil || invite.RoomID != room.ID {
+		if err == nil || errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "Invite not found", http.StatusNotFound)
+			return
+		}
+		slog.Error("Failed to load invite", "error", err, "invite_id", inviteID)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if !access.canRevokeInvite(invite.CreatorID) {
+		http.Error(w, "You cannot revoke this invite", http.StatusForbidden)
+		return
+	}
+
+	if err := h.app.DB.RevokeRoomInvite(r.Context(), invite.ID); err != nil {
+		slog.Error("Failed to revoke invite", "error", err, "invite_id", invite.ID)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	slog.Info("Invite link revoked", "room_id", room.ID, "invite_id", invite.ID, "revoked_by", user.ID)
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// AcceptInvite is where invite links lead. Signed-in users join the room
+// and land in it; everyone else signs in with GitHub first and is sent
+// back here by GitHubCallback.
+func (h *Handlers) AcceptInvite(w http.ResponseWriter, r *http.Request) {
+	token := chi.URLParam(r, "token")
+	value, ok := h.app.Session.VerifyToken(invitePurpose, token)
+	if !ok {
+		http.Error(w, "This invite link is not valid", http.StatusNotFound)
+		return
+	}
+	inviteID, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		http.Error(w, "This invite link is not valid", http.StatusNotFound)
+		return
+	}
+
+	user, err := h.authenticate(w, r)
+	if err != nil {
+		setReturnTo(w, "/invite/"+token)
+		http.Redirect(w, r, "/auth/github", http.StatusSeeOther)
+		return
+	}
+
+	invite, err := h.app.DB.GetRoomInvite(r.Context(), inviteID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "This invite link is not valid", http.StatusNotFound)
+			return
+		}
+		slog.Error("Failed to load invite", "error", err, "invite_id", inviteID)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	room, err := h.app.DB.GetRoom(r.Context(), invite.RoomID)
+	if err != nil {
+		slog.Error("Failed to load room for invite", "error", err, "invite_id", invite.ID, "room_id", invite.RoomID)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	roomURL := fmt.Sprintf("/rooms/%d", room.ID)
+
+	_, err = h.app.DB.GetRoomMembership(r.Context(), db.GetRoomMembershipParams{RoomID: room.ID, UserID: user.ID})
+	if err == nil {
+		http.Redirect(w, r, roomURL, http.StatusSeeOther)
+		return
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		slog.Error("Failed to check membership", "error", err, "room_id", room.ID, "user_id", user.ID)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	if msg := inviteUnusable(invite, room); msg != "" {
+		http.Error(w, msg, http.StatusGone)
+		return
+	}
+	canInvite, err := h.creatorCanInvite(r.Context(), invite, room)
+	if err != nil {
+		slog.Error("Failed to check invite creator", "error", err, "invite_id", invite.ID, "room_id", room.ID)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if !canInvite {
+		http.Error(w, "This invite link is no longer valid", http.StatusGone)
+		return
+	}
+
+	// The use is counted in the same transaction as the join, and only
+	// while uses remain, so concurrent visits cannot exceed the cap
+	err = h.app.WithTx(r.Context(), func(q *db.Queries) error {
+		n, err := q.UseRoomInvite(r.Context(), invite.ID)
+		if err != nil {
+			return fmt.Errorf("failed to count invite use: %w", err)
+		}
+		if n == 0 {
+			return errInviteUsedUp
+		}
+		return q.CreateRoomMembership(r.Context(), db.CreateRoomMembershipParams{
+			RoomID: room.ID,
+			UserID: user.ID,
+			Role:   roleMember,
+		})
+	})
+	if err != nil {
+		if errors.Is(err, errInviteUsedUp) {
+			http.Error(w, "This invite link is no longer valid", http.StatusGone)
+			return
+		}
+		slog.Error("Failed to join room by invite", "error", err, "invite_id", invite.ID, "user_id", user.ID)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	slog.Info("Member joined by invite link", "room_id", room.ID, "user_id", user.ID, "invite_id", invite.ID)
+
+	http.Redirect(w, r, roomURL, http.StatusSeeOther)
+}
+
+// loadInvites lists the room's links that can still be used.
+func (h *Handlers) loadInvites(r *http.Request, access roomAccess) ([]InviteView, error) {
+	rows, err := h.app.DB.GetRoomInvites(r.Context(), access.Room.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	var invites []InviteView
+	for _, row := range rows {
+		invite := db.RoomInvite{
+			ID:        row.ID,
+			RoomID:    row.RoomID,
+			CreatorID: row.CreatorID,
+			ExpiresAt: row.ExpiresAt,
+			MaxUses:   row.MaxUses,
+			UseCount:  row.UseCount,
+			RevokedAt: row.RevokedAt,
+			CreatedAt: row.CreatedAt,
+		}
+		if inviteUnusable(invite, access.Room) != "" {
+			continue
+		}
+		canInvite, err := h.creatorCanInvite(r.Context(), invite, access.Room)
+		if err != nil {
+			return nil, err
+		}
+		if !canInvite {
+			continue
+		}
+		invites = append(invites, h.inviteView(access, invite, row.CreatorLogin))
+	}
+	return invites, nil
+}
+
+func (h *Handlers) inviteView(access roomAccess, invite db.RoomInvite, creator string) InviteView {
+	view := InviteView{
+		ID:        invite.ID,
+		RoomID:    invite.RoomID,
+		URL:       inviteURL(h.app.Session, invite.ID),
+		Creator:   creator,
+		Uses:      invite.UseCount,
+		CanRevoke: access.canRevokeInvite(invite.CreatorID),
+	}
+	if invite.ExpiresAt.Valid {
+		view.ExpiresAt = invite.ExpiresAt.Time
+	}
+	if invite.MaxUses.Valid {
+		view.MaxUses = invite.MaxUses.Int64
+	}
+	return view
+}
+
+// inviteUnusable says why nobody can join with the invite any more, or
+// returns "" while it still works.
+func inviteUnusable(invite db.RoomInvite, room db.Room) string {
+	switch {
+	case invite.RevokedAt.Valid:
+		return "This invite link has been revoked"
+	case invite.ExpiresAt.Valid && !time.Now().Before(invite.ExpiresAt.Time):
+		return "This invite link has expired"
+	case invite.MaxUses.Valid && invite.UseCount >= invite.MaxUses.Int64:
+		return "This invite link is no longer valid"
+	case room.ArchivedAt.Valid:
+		return "This room is archived"
+	}
+	return ""
+}
+
+// creatorCanInvite reports whether the person who made the link could
+// still make it now. Links stop working when their creator leaves, is
+// made read-only, or the room restricts invites to admins they are not.
+func (h *Handlers) creatorCanInvite(ctx context.Context, invite db.RoomInvite, room db.Room) (bool, error) {
+	membership, err := h.app.DB.GetRoomMembership(ctx, db.GetRoomMembershipParams{
+		RoomID: room.ID,
+		UserID: invite.CreatorID,
+	})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return false, nil
+		}
+		return false, err
+	}
+	return roomAccess{Room: room, Role: membership.Role}.can(permInvite), nil
+}
+
+// inviteURL builds the absolute link to share. The path carries the
+// invite's id signed with the session key, so ids cannot be guessed.
+func inviteURL(sessions *session.Manager, inviteID int64) string {
+	token := sessions.SignToken(invitePurpose, strconv.FormatInt(inviteID, 10))
+	return getBaseURL() + "/invite/" + token
+}
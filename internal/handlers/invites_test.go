@@ -0,0 +1,236 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"blazing/internal/db"
+)
+
+var inviteTokenPattern = regexp.MustCompile(`/invite/([^"]+)"`)
+
+func TestRoomInvites(t *testing.T) {
+	testApp, h := setupTestApp(t)
+	ctx := context.Background()
+
+	alice := createTestUser(t, testApp, 1001, "alice")
+	bob := createTestUser(t, testApp, 1002, "bob")
+	room := createTestRoom(t, testApp, "general", alice, bob)
+
+	create := func(user db.User, form url.Values) *httptest.ResponseRecorder {
+		t.Helper()
+		req := httptest.NewRequest("POST", "/rooms/"+itoa(room.ID)+"/invites", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req = withRoute(req, user, map[string]string{"roomID": itoa(room.ID)})
+		w := httptest.NewRecorder()
+		h.CreateInvite(w, req)
+		return w
+	}
+
+	// accept opens the link, signed in as user unless user is nil
+	accept := func(token string, user *db.User) *httptest.ResponseRecorder {
+		t.Helper()
+		req := httptest.NewRequest("GET", "/invite/"+token, nil)
+		if user != nil {
+			for _, c := range sessionCookies(t, testApp, *user) {
+				req.AddCookie(c)
+			}
+		}
+		rctx := chi.NewRouteContext()
+		rctx.URLParams.Add("token", token)
+		req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+		w := httptest.NewRecorder()
+		h.AcceptInvite(w, req)
+		return w
+	}
+
+	roomPage := func(user db.User) string {
+		t.Helper()
+		req := withRoute(httptest.NewRequest("GET", "/rooms/"+itoa(room.ID), nil), user, map[string]string{"roomID": itoa(room.ID)})
+		w := httptest.NewRecorder()
+		h.Room(w, req)
+		return w.Body.String()
+	}
+
+	isMember := func(user db.User) bool {
+		t.Helper()
+		_, err := testApp.DB.GetRoomMembership(ctx, db.GetRoomMembershipParams{RoomID: room.ID, UserID: user.ID})
+		return err == nil
+	}
+
+	t.Run("member creates a limited link", func(t *testing.T) {
+		w := create(bob, url.Values{"expires_in": {"24h"}, "max_uses": {"1"}})
+		if w.Code != http.StatusCreated {
+			t.Fatalf("Expected status %d, got %d: %s", http.StatusCreated, w.Code, w.Body.String())
+		}
+		body := w.Body.String()
+		if !strings.Contains(body, "http://localhost:8080/invite/") || !strings.Contains(body, "0/1 uses") {
+			t.Fatalf("Expected the new link, got: %s", body)
+		}
+
+		carol := createTestUser(t, testApp, 1003, "carol")
+		dave := createTestUser(t, testApp, 1004, "dave")
+		token := inviteTokenPattern.FindStringSubmatch(body)[1]
+
+		w = accept(token, nil)
+		if w.Code != http.StatusSeeOther || w.Header().Get("Location") != "/auth/github" {
+			t.Fatalf("Expected signed-out visitors to sign in first, got %d %s", w.Code, w.Header().Get("Location"))
+		}
+		var returnTo string
+		for _, c := range w.Result().Cookies() {
+			if c.Name == returnToCookie {
+				returnTo = c.Value
+			}
+		}
+		if returnTo != "/invite/"+token {
+			t.Errorf("Expected to come back to the link after signing in, got %q", returnTo)
+		}
+
+		w = accept(token, &carol)
+		if w.Code != http.StatusSeeOther || w.Header().Get("Location") != "/rooms/"+itoa(room.ID) {
+			t.Fatalf("Expected a redirect into the room, got %d: %s", w.Code, w.Body.String())
+		}
+		if !isMember(carol) {
+			t.Error("Expected carol to have joined")
+		}
+
+		// Members following the link again do not use it up
+		if w := accept(token, &carol); w.Code != http.StatusSeeOther {
+			t.Errorf("Expected members to be sent to the room, got %d", w.Code)
+		}
+
+		if w := accept(token, &dave); w.Code != http.StatusGone {
+			t.Errorf("Expected status %d once used up, got %d", http.StatusGone, w.Code)
+		}
+		if isMember(dave) {
+			t.Error("Expected dave not to have joined")
+		}
+	})
+
+	t.Run("invalid input", func(t *testing.T) {
+		if w := create(bob, url.Values{"expires_in": {"9999h"}}); w.Code != http.StatusUnprocessableEntity {
+			t.Errorf("Expected status %d, got %d", http.StatusUnprocessableEntity, w.Code)
+		}
+		if w := create(bob, url.Values{"max_uses": {"0"}}); w.Code != http.StatusUnprocessableEntity {
+			t.Errorf("Expected status %d, got %d", http.StatusUnprocessableEntity, w.Code)
+		}
+	})
+
+	t.Run("tampered and expired links", func(t *testing.T) {
+		erin := createTestUser(t, testApp, 1005, "erin")
+
+		if w := accept("1.bogus", &erin); w.Code != http.StatusNotFound {
+			t.Errorf("Expected status %d, got %d", http.StatusNotFound, w.Code)
+		}
+
+		invite, err := testApp.DB.CreateRoomInvite(ctx, db.CreateRoomInviteParams{
+			RoomID:    room.ID,
+			CreatorID: alice.ID,
+			ExpiresAt: sql.NullTime{Time: time.Now().Add(-time.Minute), Valid: true},
+		})
+		if err != nil {
+			t.Fatalf("Failed to create invite: %v", err)
+		}
+		token := testApp.Session.SignToken(invitePurpose, itoa(invite.ID))
+		if w := accept(token, &erin); w.Code != http.StatusGone {
+			t.Errorf("Expected status %d, got %d", http.StatusGone, w.Code)
+		}
+		if isMember(erin) {
+			t.Error("Expected erin not to have joined")
+		}
+	})
+
+	t.Run("revoking", func(t *testing.T) {
+		w := create(alice, url.Values{})
+		if w.Code != http.StatusCreated {
+			t.Fatalf("Expected status %d, got %d", http.StatusCreated, w.Code)
+		}
+		token := inviteTokenPattern.FindStringSubmatch(w.Body.String())[1]
+		inviteID, _ := testApp.Session.VerifyToken(invitePurpose, token)
+		if !strings.Contains(roomPage(alice), token) {
+			t.Fatal("Expected the link in the room's list")
+		}
+
+		revoke := func(user db.User) *httptest.ResponseRecorder {
+			req := httptest.NewRequest("DELETE", "/rooms/"+itoa(room.ID)+"/invites/"+inviteID, nil)
+			req = withRoute(req, user, map[string]string{"roomID": itoa(room.ID), "inviteID": inviteID})
+			w := httptest.NewRecorder()
+			h.RevokeInvite(w, req)
+			return w
+		}
+		if w := revoke(bob); w.Code != http.StatusForbidden {
+			t.Errorf("Expected members to be unable to revoke others' links, got %d", w.Code)
+		}
+		if w := revoke(alice); w.Code != http.StatusOK {
+			t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+		}
+
+		frank := createTestUser(t, testApp, 1006, "frank")
+		if w := accept(token, &frank); w.Code != http.StatusGone {
+			t.Errorf("Expected status %d, got %d", http.StatusGone, w.Code)
+		}
+
+		if strings.Contains(roomPage(alice), token) {
+			t.Error("Expected revoked links to leave the list")
+		}
+	})
+
+	t.Run("read-only members cannot create links", func(t *testing.T) {
+		err := testApp.DB.UpdateMembershipRole(ctx, db.UpdateMembershipRoleParams{Role: roleReadOnly, RoomID: room.ID, UserID: bob.ID})
+		if err != nil {
+			t.Fatalf("Failed to update role: %v", err)
+		}
+		if w := create(bob, url.Values{}); w.Code != http.StatusForbidden {
+			t.Errorf("Expected status %d, got %d", http.StatusForbidden, w.Code)
+		}
+	})
+
+	t.Run("links die with their creator's right to invite", func(t *testing.T) {
+		invite, err := testApp.DB.CreateRoomInvite(ctx, db.CreateRoomInviteParams{RoomID: room.ID, CreatorID: bob.ID})
+		if err != nil {
+			t.Fatalf("Failed to create invite: %v", err)
+		}
+		token := testApp.Session.SignToken(invitePurpose, itoa(invite.ID))
+		gina := createTestUser(t, testApp, 1007, "gina")
+
+		// bob was made read-only above
+		if w := accept(token, &gina); w.Code != http.StatusGone {
+			t.Errorf("Expected status %d while the creator is read-only, got %d", http.StatusGone, w.Code)
+		}
+
+		err = testApp.DB.UpdateMembershipRole(ctx, db.UpdateMembershipRoleParams{Role: roleMember, RoomID: room.ID, UserID: bob.ID})
+		if err != nil {
+			t.Fatalf("Failed to update role: %v", err)
+		}
+		err = testApp.DB.UpdateRoomPolicies(ctx, db.UpdateRoomPoliciesParams{PostPolicy: policyMembers, InvitePolicy: policyAdmins, ID: room.ID})
+		if err != nil {
+			t.Fatalf("Failed to update policies: %v", err)
+		}
+		if w := accept(token, &gina); w.Code != http.StatusGone {
+			t.Errorf("Expected status %d once invites are for admins, got %d", http.StatusGone, w.Code)
+		}
+		if strings.Contains(roomPage(alice), token) {
+			t.Error("Expected dead links to leave the list")
+		}
+		if isMember(gina) {
+			t.Error("Expected gina not to have joined")
+		}
+
+		err = testApp.DB.UpdateRoomPolicies(ctx, db.UpdateRoomPoliciesParams{PostPolicy: policyMembers, InvitePolicy: policyMembers, ID: room.ID})
+		if err != nil {
+			t.Fatalf("Failed to update policies: %v", err)
+		}
+		if w := accept(token, &gina); w.Code != http.StatusSeeOther || !isMember(gina) {
+			t.Errorf("Expected the link to work again, got %d", w.Code)
+		}
+	})
+}
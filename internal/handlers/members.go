@@ -0,0 +1,218 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+
+	"blazing/internal/db"
+	"blazing/internal/presence"
+)
+
+// GitHub logins are 1-39 alphanumerics or single hyphens, never leading or
+// trailing with a hyphen.
+var githubLoginPattern = regexp.MustCompile(`^[A-Za-z0-9](?:[A-Za-z0-9]|-[A-Za-z0-9]){0,38}$`)
+
+var errAlreadyMember = errors.New("already a member")
+
+type MemberView struct {
+	RoomID    int64
+	UserID    int64
+	Login     string
+	AvatarURL string
+	Pending   bool
+	Role      string
+	IsSelf    bool
+	CanRemove bool
+	// Roles the viewer may switch this member to; empty hides the control
+	Roles       []string
+	CanTransfer bool
+	Status      presence.Status
+}
+
+func (h *Handlers) RoomMembers(w http.ResponseWriter, r *http.Request) {
+	access, ok := h.requireRoomMember(w, r)
+	if !ok {
+		return
+	}
+	room := access.Room
+
+	members, err := h.loadMembers(r.Context(), access)
+	if err != nil {
+		slog.Error("Failed to load room members", "error", err, "room_id", room.ID)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := h.roomTemplate.ExecuteTemplate(w, "member_list", members); err != nil {
+		slog.Error("Failed to render member list", "error", err, "room_id", room.ID)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+	}
+}
+
+// InviteMember adds a teammate by GitHub login. Logins that have never
+// signed in get a pending user row, which GitHubCallback claims later.
+func (h *Handlers) InviteMember(w http.ResponseWriter, r *http.Request) {
+	access, ok := h.requireRoomMember(w, r)
+	if !ok {
+		return
+	}
+	room, user := access.Room, access.User
+	if isDirect(room) {
+		http.Error(w, "Start a new direct message to talk with more people", http.StatusConflict)
+		return
+	}
+	if !access.can(permInvite) {
+		http.Error(w, "You cannot invite people to this room", http.StatusForbidden)
+		return
+	}
+
+	login := strings.TrimPrefix(strings.TrimSpace(r.FormValue("login")), "@")
+	if !githubLoginPattern.MatchString(login) {
+		http.Error(w, "Invalid GitHub username", http.StatusUnprocessableEntity)
+		return
+	}
+
+	var invitee db.User
+	err := h.app.WithTx(r.Context(), func(q *db.Queries) error {
+		var err error
+		invitee, err = userByLogin(r.Context(), q, login)
+		if err != nil {
+			return fmt.Errorf("failed to resolve invitee: %w", err)
+		}
+
+		_, err = q.GetRoomMembership(r.Context(), db.GetRoomMembershipParams{
+			RoomID: room.ID,
+			UserID: invitee.ID,
+		})
+		if err == nil {
+			return errAlreadyMember
+		}
+		if !errors.Is(err, sql.ErrNoRows) {
+			return err
+		}
+
+		return q.CreateRoomMembership(r.Context(), db.CreateRoomMembershipParams{
+			RoomID: room.ID,
+			UserID: invitee.ID,
+			Role:   roleMember,
+		})
+	})
+	if err != nil {
+		if errors.Is(err, errAlreadyMember) {
+			http.Error(w, login+" is already a member of this room", http.StatusConflict)
+			return
+		}
+		slog.Error("Failed to invite member", "error", err, "room_id", room.ID, "login", login)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	slog.Info("Member invited", "room_id", room.ID, "user_id", invitee.ID, "invited_by", user.ID, "pending", !invitee.GithubUid.Valid)
+
+	member := h.memberView(access, invitee.ID, invitee.Login, invitee.AvatarUrl.String, roleMember, !invitee.GithubUid.Valid)
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(http.StatusCreated)
+	if err := h.roomTemplate.ExecuteTemplate(w, "member_item", member); err != nil {
+		slog.Error("Failed to render member item", "error", err, "room_id", room.ID)
+	}
+}
+
+// RemoveMember removes someone from a room. Admins may remove members
+// below them; everybody but the owner may remove themselves, which is how
+// a member leaves.
+func (h *Handlers) RemoveMember(w http.ResponseWriter, r *http.Request) {
+	access, ok := h.requireRoomMember(w, r)
+	if !ok {
+		return
+	}
+	room, user := access.Room, access.User
+
+	memberID, err := strconv.ParseInt(chi.URLParam(r, "userID"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+
+	membership, ok := h.requireMembership(w, r, room.ID, memberID)
+	if !ok {
+		return
+	}
+
+	if !access.canRemove(memberID, membership.Role) {
+		http.Error(w, "You cannot remove this member", http.StatusForbidden)
+		return
+	}
+
+	err = h.app.DB.DeleteRoomMembership(r.Context(), db.DeleteRoomMembershipParams{
+		RoomID: room.ID,
+		UserID: memberID,
+	})
+	if err != nil {
+		slog.Error("Failed to remove member", "error", err, "room_id", room.ID, "user_id", memberID)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	h.app.Hub.Disconnect(room.ID, memberID)
+	slog.Info("Member removed", "room_id", room.ID, "user_id", memberID, "removed_by", user.ID)
+
+	if memberID == user.ID {
+		w.Header().Set("HX-Redirect", "/")
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+func (h *Handlers) loadMembers(ctx context.Context, access roomAccess) ([]MemberView, error) {
+	rows, err := h.app.DB.GetRoomMembers(ctx, access.Room.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	members := make([]MemberView, len(rows))
+	for i, row := range rows {
+		members[i] = h.memberView(access, row.ID, row.Login, row.AvatarUrl.String, row.Role, !row.GithubUid.Valid)
+	}
+	return members, nil
+}
+
+func (h *Handlers) memberView(access roomAccess, userID int64, login, avatarURL, role string, pending bool) MemberView {
+	member := MemberView{
+		RoomID:      access.Room.ID,
+		UserID:      userID,
+		Login:       login,
+		AvatarURL:   avatarURL,
+		Pending:     pending,
+		Role:        role,
+		IsSelf:      userID == access.User.ID,
+		CanRemove:   access.canRemove(userID, role),
+		CanTransfer: access.can(permTransfer) && userID != access.User.ID && !pending,
+		Status:      h.app.Presence.Status(userID),
+	}
+	for _, to := range access.assignableRoles() {
+		if access.canSetRole(userID, role, to) {
+			member.Roles = append(member.Roles, to)
+		}
+	}
+	return member
+}
+
+// userByLogin finds a user by GitHub login, creating a pending user for
+// logins that have never signed in.
+func userByLogin(ctx context.Context, q *db.Queries, login string) (db.User, error) {
+	user, err := q.GetUserByLogin(ctx, login)
+	if errors.Is(err, sql.ErrNoRows) {
+		return q.CreatePendingUser(ctx, login)
+	}
+	return user, err
+}
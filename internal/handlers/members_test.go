@@ -0,0 +1,170 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"blazing/internal/db"
+)
+
+func TestInviteMember(t *testing.T) {
+	testApp, h := setupTestApp(t)
+
+	alice := createTestUser(t, testApp, 1001, "alice")
+	bob := createTestUser(t, testApp, 1002, "bob")
+	room := createTestRoom(t, testApp, "general", alice)
+
+	invite := func(login string) *httptest.ResponseRecorder {
+		form := url.Values{"login": {login}}
+		req := httptest.NewRequest("POST", "/rooms/"+itoa(room.ID)+"/members", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req = withRoute(req, alice, map[string]string{"roomID": itoa(room.ID)})
+		w := httptest.NewRecorder()
+		h.InviteMember(w, req)
+		return w
+	}
+
+	t.Run("adds an existing user", func(t *testing.T) {
+		w := invite("@bob")
+		if w.Code != http.StatusCreated {
+			t.Fatalf("Expected status %d, got %d: %s", http.StatusCreated, w.Code, w.Body.String())
+		}
+
+		if _, err := testApp.DB.GetRoomMembership(context.Background(), db.GetRoomMembershipParams{
+			RoomID: room.ID, UserID: bob.ID,
+		}); err != nil {
+			t.Errorf("Expected bob to be a member: %v", err)
+		}
+	})
+
+	t.Run("creates a pending user for unknown logins", func(t *testing.T) {
+		w := invite("carol-dev")
+		if w.Code != http.StatusCreated {
+			t.Fatalf("Expected status %d, got %d: %s", http.StatusCreated, w.Code, w.Body.String())
+		}
+		if !strings.Contains(w.Body.String(), "invited") {
+			t.Errorf("Expected pending badge, got: %s", w.Body.String())
+		}
+
+		carol, err := testApp.DB.GetUserByLogin(context.Background(), "carol-dev")
+		if err != nil {
+			t.Fatalf("Expected pending user row: %v", err)
+		}
+		if carol.GithubUid.Valid {
+			t.Error("Expected pending user to have no GitHub UID")
+		}
+	})
+
+	t.Run("rejects duplicates", func(t *testing.T) {
+		w := invite("BOB")
+		if w.Code != http.StatusConflict {
+			t.Errorf("Expected status %d, got %d", http.StatusConflict, w.Code)
+		}
+	})
+
+	t.Run("rejects invalid logins", func(t *testing.T) {
+		for _, login := range []string{"", "-bob", "bob-", "bo--b", "bob smith", strings.Repeat("a", 40)} {
+			w := invite(login)
+			if w.Code != http.StatusUnprocessableEntity {
+				t.Errorf("Expected status %d for %q, got %d", http.StatusUnprocessableEntity, login, w.Code)
+			}
+		}
+	})
+}
+
+func TestInvitedUserClaimsMembershipOnFirstLogin(t *testing.T) {
+	testApp, h := setupTestApp(t)
+	ctx := context.Background()
+
+	alice := createTestUser(t, testApp, 1001, "alice")
+	room := createTestRoom(t, testApp, "general", alice)
+
+	pending, err := testApp.DB.CreatePendingUser(ctx, "carol")
+	if err != nil {
+		t.Fatalf("Failed to create pending user: %v", err)
+	}
+	if err := testApp.DB.CreateRoomMembership(ctx, db.CreateRoomMembershipParams{RoomID: room.ID, UserID: pending.ID, Role: roleMember}); err != nil {
+		t.Fatalf("Failed to add pending member: %v", err)
+	}
+
+	user, err := h.createOrUpdateUser(ctx, &GitHubUser{ID: 3003, Login: "Carol", AvatarURL: "https://example.com/carol.jpg"})
+	if err != nil {
+		t.Fatalf("Failed to sign in invited user: %v", err)
+	}
+
+	if user.ID != pending.ID {
+		t.Errorf("Expected pending row %d to be claimed, got user %d", pending.ID, user.ID)
+	}
+	if user.GithubUid.Int64 != 3003 || user.Login != "Carol" {
+		t.Errorf("Expected claimed row to carry GitHub identity, got %+v", user)
+	}
+
+	rooms, err := testApp.DB.GetUserRooms(ctx, user.ID)
+	if err != nil {
+		t.Fatalf("Failed to load rooms: %v", err)
+	}
+	if len(rooms) != 1 || rooms[0].ID != room.ID {
+		t.Errorf("Expected invited room to be active, got %+v", rooms)
+	}
+}
+
+func TestRemoveMember(t *testing.T) {
+	testApp, h := setupTestApp(t)
+
+	alice := createTestUser(t, testApp, 1001, "alice")
+	bob := createTestUser(t, testApp, 1002, "bob")
+	carol := createTestUser(t, testApp, 1003, "carol")
+	room := createTestRoom(t, testApp, "general", alice, bob, carol)
+
+	remove := func(actor db.User, memberID int64) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("DELETE", "/rooms/"+itoa(room.ID)+"/members/"+itoa(memberID), nil)
+		req = withRoute(req, actor, map[string]string{"roomID": itoa(room.ID), "userID": itoa(memberID)})
+		w := httptest.NewRecorder()
+		h.RemoveMember(w, req)
+		return w
+	}
+
+	t.Run("members cannot remove others", func(t *testing.T) {
+		if w := remove(bob, carol.ID); w.Code != http.StatusForbidden {
+			t.Errorf("Expected status %d, got %d", http.StatusForbidden, w.Code)
+		}
+	})
+
+	t.Run("creator cannot be removed", func(t *testing.T) {
+		if w := remove(alice, alice.ID); w.Code != http.StatusForbidden {
+			t.Errorf("Expected status %d, got %d", http.StatusForbidden, w.Code)
+		}
+	})
+
+	t.Run("creator removes a member and their sockets", func(t *testing.T) {
+		client := testApp.Hub.Register(room.ID, carol.ID)
+
+		if w := remove(alice, carol.ID); w.Code != http.StatusOK {
+			t.Fatalf("Expected status %d, got %d", http.StatusOK, w.Code)
+		}
+
+		select {
+		case <-client.Done():
+		default:
+			t.Error("Expected removed member's WebSocket to be disconnected")
+		}
+
+		if w := remove(alice, carol.ID); w.Code != http.StatusNotFound {
+			t.Errorf("Expected status %d for a second removal, got %d", http.StatusNotFound, w.Code)
+		}
+	})
+
+	t.Run("members can leave", func(t *testing.T) {
+		w := remove(bob, bob.ID)
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected status %d, got %d", http.StatusOK, w.Code)
+		}
+		if w.Header().Get("HX-Redirect") != "/" {
+			t.Error("Expected leaving member to be redirected to the dashboard")
+		}
+	})
+}
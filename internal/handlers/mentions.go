@@ -0,0 +1,301 @@
+package handlers
+
+import (
+	"context"
+	"html"
+	"html/template"
+	"log/slog"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"blazing/internal/db"
+	"blazing/internal/markdown"
+	"blazing/internal/presence"
+)
+
+// maxLoginLength is GitHub's limit on usernames.
+const maxLoginLength = 39
+
+// mentionExcerptLength bounds the message preview sent with a mention.
+const mentionExcerptLength = 140
+
+// Mention kinds, stored alongside each mentioned user. @room reaches every
+// member and @here only those currently online or idle; both are reserved
+// for room moderators.
+const (
+	mentionUser = "user"
+	mentionRoom = "room"
+	mentionHere = "here"
+)
+
+// scanMentions calls fn with the byte range and login of each @login token
+// in body. Tokens glued to a preceding word, such as email addresses, are
+// not mentions.
+func scanMentions(body string, fn func(start, end int, login string)) {
+	for i := 0; i < len(body); i++ {
+		if body[i] != '@' {
+			continue
+		}
+		if i > 0 {
+			prev, _ := utf8.DecodeLastRuneInString(body[:i])
+			if unicode.IsLetter(prev) || unicode.IsDigit(prev) || strings.ContainsRune("_.@/-+", prev) {
+				continue
+			}
+		}
+
+		end := i + 1
+		for end < len(body) && isLoginByte(body[end]) {
+			end++
+		}
+		// Logins cannot end in a hyphen, so "@alice-" is alice
+		for end > i+1 && body[end-1] == '-' {
+			end--
+		}
+
+		login := body[i+1 : end]
+		if login != "" && login[0] != '-' && len(login) <= maxLoginLength {
+			fn(i, end, login)
+		}
+		i = end - 1
+	}
+}
+
+func isLoginByte(b byte) bool {
+	return b == '-' || b >= '0' && b <= '9' || b >= 'A' && b <= 'Z' || b >= 'a' && b <= 'z'
+}
+
+// parseMentions returns the distinct logins mentioned in body, lowercased
+// since GitHub logins are case-insensitive.
+func parseMentions(body string) []string {
+	var logins []string
+	seen := make(map[string]bool)
+	scanMentions(body, func(_, _ int, login string) {
+		login = strings.ToLower(login)
+		if !seen[login] {
+			seen[login] = true
+			logins = append(logins, login)
+		}
+	})
+	return logins
+}
+
+// highlightMentions escapes body and marks up the tokens listed in
+// mentions, which holds the lowercased logins (or "room" and "here") that
+// actually reached someone.
+func highlightMentions(body string, mentions []string) template.HTML {
+	if len(mentions) == 0 {
+		return template.HTML(html.EscapeString(body))
+	}
+
+	highlight := make(map[string]bool, len(mentions))
+	for _, m := range mentions {
+		highlight[m] = true
+	}
+
+	var b strings.Builder
+	last := 0
+	scanMentions(body, func(start, end int, login string) {
+		if !highlight[strings.ToLower(login)] {
+			return
+		}
+		b.WriteString(html.EscapeString(body[last:start]))
+		b.WriteString(`<span class="mention">`)
+		b.WriteString(html.EscapeString(body[start:end]))
+		b.WriteString(`</span>`)
+		last = end
+	})
+	b.WriteString(html.EscapeString(body[last:]))
+	return template.HTML(b.String())
+}
+
+// renderBody renders a message's Markdown source, highlighting the mentions
+// in its text but not those quoted in code or link addresses.
+func renderBody(body string, mentions []string) template.HTML {
+	return markdown.Render(body, func(text string) string {
+		return string(highlightMentions(text, mentions))
+	})
+}
+
+// saveMentions replaces the mentions recorded for a message and returns
+// the users it now mentions that it did not before, who are the ones to
+// notify. Only room members can be mentioned, and never the author.
+func (h *Handlers) saveMentions(ctx context.Context, q *db.Queries, access roomAccess, authorID, messageID int64, body string) ([]int64, error) {
+	prior, err := q.GetMessageMentions(ctx, []int64{messageID})
+	if err != nil {
+		return nil, err
+	}
+	notified := make(map[int64]bool)
+	for _, row := range prior {
+		for _, id := range splitIDs(row.UserIds) {
+			notified[id] = true
+		}
+	}
+
+	if err := q.DeleteMessageMentions(ctx, messageID); err != nil {
+		return nil, err
+	}
+
+	mentions, err := h.resolveMentions(ctx, q, access, authorID, body)
+	if err != nil {
+		return nil, err
+	}
+
+	var fresh []int64
+	for _, m := range mentions {
+		m.MessageID = messageID
+		if err := q.CreateMessageMention(ctx, m); err != nil {
+			return nil, err
+		}
+		if !notified[m.UserID] {
+			notified[m.UserID] = true
+			fresh = append(fresh, m.UserID)
+		}
+	}
+	return fresh, nil
+}
+
+// resolveMentions turns the tokens in body into the members they reach.
+// @room and @here from anyone who cannot moderate the room are left as
+// plain text.
+func (h *Handlers) resolveMentions(ctx context.Context, q *db.Queries, access roomAccess, authorID int64, body string) ([]db.CreateMessageMentionParams, error) {
+	room := access.Room
+	var mentions []db.CreateMessageMentionParams
+	var logins, broadcasts []string
+	for _, login := range parseMentions(body) {
+		switch login {
+		case mentionRoom, mentionHere:
+			if access.can(permModerate) {
+				broadcasts = append(broadcasts, login)
+			}
+		default:
+			logins = append(logins, login)
+		}
+	}
+
+	if len(logins) > 0 {
+		members, err := q.GetRoomMembersByLogin(ctx, db.GetRoomMembersByLoginParams{RoomID: room.ID, Logins: logins})
+		if err != nil {
+			return nil, err
+		}
+		for _, member := range members {
+			if member.ID != authorID {
+				mentions = append(mentions, db.CreateMessageMentionParams{UserID: member.ID, Kind: mentionUser})
+			}
+		}
+	}
+
+	if len(broadcasts) > 0 {
+		members, err := q.GetRoomMembers(ctx, room.ID)
+		if err != nil {
+			return nil, err
+		}
+		for _, kind := range broadcasts {
+			for _, member := range members {
+				if member.ID == authorID {
+					continue
+				}
+				if kind == mentionHere && h.app.Presence.Status(member.ID) == presence.Offline {
+					continue
+				}
+				mentions = append(mentions, db.CreateMessageMentionParams{UserID: member.ID, Kind: kind})
+			}
+		}
+	}
+
+	return mentions, nil
+}
+
+// notifyMentions pushes a mention to every open connection of each user,
+// so they hear about it whichever room they are looking at. Users who have
+// muted the room or turned its notifications off are skipped.
+func (h *Handlers) notifyMentions(ctx context.Context, room db.Room, authorLogin string, messageID, threadID int64, body string, userIDs []int64) {
+	if len(userIDs) == 0 {
+		return
+	}
+
+	userIDs, err := h.mentionRecipients(ctx, room.ID, userIDs)
+	if err != nil {
+		slog.Error("Failed to load notification settings", "error", err, "room_id", room.ID)
+		return
+	}
+
+	event := mentionEvent{
+		RoomID:    room.ID,
+		RoomName:  room.Name,
+		MessageID: messageID,
+		ThreadID:  threadID,
+		Login:     authorLogin,
+		Excerpt:   excerpt(body, mentionExcerptLength),
+	}
+	for _, userID := range userIDs {
+		h.notify(userID, eventMention, event)
+	}
+}
+
+// attachMentions fills in who each message mentions, for highlighting.
+func (h *Handlers) attachMentions(ctx context.Context, messages []MessageView) error {
+	if len(messages) == 0 {
+		return nil
+	}
+
+	ids := make([]int64, len(messages))
+	byID := make(map[int64]*MessageView, len(messages))
+	for i := range messages {
+		ids[i] = messages[i].ID
+		byID[messages[i].ID] = &messages[i]
+	}
+
+	rows, err := h.app.DB.GetMessageMentions(ctx, ids)
+	if err != nil {
+		return err
+	}
+
+	for _, row := range rows {
+		view := byID[row.MessageID]
+		if view == nil || view.Deleted {
+			continue
+		}
+		if row.Kind == mentionUser {
+			view.Mentions = append(view.Mentions, strings.Fields(strings.ToLower(row.Logins))...)
+		} else {
+			view.Mentions = append(view.Mentions, row.Kind)
+		}
+		view.MentionedIDs = strings.TrimSpace(view.MentionedIDs + " " + row.UserIds)
+	}
+	return nil
+}
+
+// decorateMessages loads everything shown alongside message bodies.
+func (h *Handlers) decorateMessages(ctx context.Context, messages []MessageView) error {
+	if err := h.attachReactions(ctx, messages); err != nil {
+		return err
+	}
+	if err := h.attachAttachments(ctx, messages); err != nil {
+		return err
+	}
+	if err := h.attachPins(ctx, messages); err != nil {
+		return err
+	}
+	return h.attachMentions(ctx, messages)
+}
+
+func splitIDs(s string) []int64 {
+	fields := strings.Fields(s)
+	ids := make([]int64, 0, len(fields))
+	for _, f := range fields {
+		if id, err := strconv.ParseInt(f, 10, 64); err == nil {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+func excerpt(s string, limit int) string {
+	if utf8.RuneCountInString(s) <= limit {
+		return s
+	}
+	runes := []rune(s)
+	return strings.TrimSpace(string(runes[:limit])) + "…"
+}
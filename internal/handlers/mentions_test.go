@@ -0,0 +1,205 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+
+	"blazing/internal/db"
+	"blazing/internal/hub"
+)
+
+func TestParseMentions(t *testing.T) {
+	tests := []struct {
+		body string
+		want []string
+	}{
+		{"hey @alice and @Bob", []string{"alice", "bob"}},
+		{"@alice, @ALICE again", []string{"alice"}},
+		{"mail me at bob@example.com", nil},
+		{"(@carol-dev) @dave-.", []string{"carol-dev", "dave"}},
+		{"@-nope @ alone @@double", nil},
+		{"@" + strings.Repeat("a", maxLoginLength+1), nil},
+		{"@room please", []string{"room"}},
+	}
+
+	for _, tt := range tests {
+		if got := parseMentions(tt.body); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseMentions(%q) = %q, want %q", tt.body, got, tt.want)
+		}
+	}
+}
+
+func TestHighlightMentions(t *testing.T) {
+	got := highlightMentions("<b>@Alice</b> ping @nobody", []string{"alice"})
+	want := `&lt;b&gt;<span class="mention">@Alice</span>&lt;/b&gt; ping @nobody`
+	if string(got) != want {
+		t.Errorf("highlightMentions() = %q, want %q", got, want)
+	}
+}
+
+func TestRenderBody(t *testing.T) {
+	got := renderBody("**@alice** see `@alice`", []string{"alice"})
+	want := `<p><strong><span class="mention">@alice</span></strong> see <code>@alice</code></p>`
+	if string(got) != want {
+		t.Errorf("renderBody() = %q, want %q", got, want)
+	}
+}
+
+func TestMentions(t *testing.T) {
+	testApp, h := setupTestApp(t)
+	ctx := context.Background()
+
+	alice := createTestUser(t, testApp, 1001, "alice")
+	bob := createTestUser(t, testApp, 1002, "bob")
+	carol := createTestUser(t, testApp, 1003, "carol")
+	createTestUser(t, testApp, 1004, "outsider")
+	room := createTestRoom(t, testApp, "general", alice, bob, carol)
+	elsewhere := createTestRoom(t, testApp, "elsewhere", bob)
+
+	mentionedIn := func(messageID int64) []int64 {
+		t.Helper()
+		rows, err := testApp.DB.GetMessageMentions(ctx, []int64{messageID})
+		if err != nil {
+			t.Fatalf("Failed to load mentions: %v", err)
+		}
+		var ids []int64
+		for _, row := range rows {
+			ids = append(ids, splitIDs(row.UserIds)...)
+		}
+		return ids
+	}
+
+	post := func(user db.User, body string) int64 {
+		t.Helper()
+		w := postMessage(t, h, user, room.ID, body)
+		if w.Code != http.StatusCreated {
+			t.Fatalf("Expected status %d, got %d: %s", http.StatusCreated, w.Code, w.Body.String())
+		}
+		page, err := h.loadMessagesBefore(ctx, room.ID, 0)
+		if err != nil {
+			t.Fatalf("Failed to load messages: %v", err)
+		}
+		return page.Messages[len(page.Messages)-1].ID
+	}
+
+	drain := func(c *hub.Client) []mentionEvent {
+		var mentions []mentionEvent
+		for len(c.Send()) > 0 {
+			var event struct {
+				Type string       `json:"type"`
+				Data mentionEvent `json:"data"`
+			}
+			if err := json.Unmarshal(<-c.Send(), &event); err != nil {
+				t.Fatalf("Failed to decode event: %v", err)
+			}
+			if event.Type == eventMention {
+				mentions = append(mentions, event.Data)
+			}
+		}
+		return mentions
+	}
+
+	t.Run("notifies members wherever they are", func(t *testing.T) {
+		client := testApp.Hub.Register(elsewhere.ID, bob.ID)
+		defer testApp.Hub.Unregister(client)
+
+		id := post(alice, "@bob @alice @outsider have a look")
+
+		if got := mentionedIn(id); !reflect.DeepEqual(got, []int64{bob.ID}) {
+			t.Errorf("Expected only bob to be mentioned, got %v", got)
+		}
+
+		mentions := drain(client)
+		if len(mentions) != 1 {
+			t.Fatalf("Expected one mention event, got %+v", mentions)
+		}
+		if m := mentions[0]; m.RoomID != room.ID || m.MessageID != id || m.Login != "alice" || m.Excerpt == "" {
+			t.Errorf("Unexpected mention event: %+v", m)
+		}
+	})
+
+	t.Run("highlights mentions", func(t *testing.T) {
+		page, err := h.loadMessagesBefore(ctx, room.ID, 0)
+		if err != nil {
+			t.Fatalf("Failed to load messages: %v", err)
+		}
+		html, err := h.renderFragment("message", page.Messages[len(page.Messages)-1])
+		if err != nil {
+			t.Fatalf("Failed to render message: %v", err)
+		}
+		if !strings.Contains(html, `<span class="mention">@bob</span>`) {
+			t.Errorf("Expected @bob to be highlighted, got: %s", html)
+		}
+		if strings.Contains(html, `<span class="mention">@outsider</span>`) {
+			t.Errorf("Expected non-members to be left alone, got: %s", html)
+		}
+		if !strings.Contains(html, `data-mentioned-ids="`+itoa(bob.ID)+`"`) {
+			t.Errorf("Expected mentioned ids on the message, got: %s", html)
+		}
+	})
+
+	t.Run("only admins can mention everyone", func(t *testing.T) {
+		id := post(bob, "@room lunch?")
+		if got := mentionedIn(id); len(got) != 0 {
+			t.Errorf("Expected @room from a member to be ignored, got %v", got)
+		}
+
+		id = post(alice, "@room standup now")
+		if got := mentionedIn(id); len(got) != 2 {
+			t.Errorf("Expected @room to reach bob and carol, got %v", got)
+		}
+	})
+
+	t.Run("@here skips offline members", func(t *testing.T) {
+		conn := testApp.Presence.Connect(carol.ID)
+		defer conn.Close()
+
+		id := post(alice, "@here quick one")
+		if got := mentionedIn(id); !reflect.DeepEqual(got, []int64{carol.ID}) {
+			t.Errorf("Expected @here to reach only carol, got %v", got)
+		}
+	})
+
+	t.Run("edits notify only new mentions", func(t *testing.T) {
+		id := post(bob, "ping @alice")
+
+		client := testApp.Hub.RegisterUser(carol.ID)
+		defer testApp.Hub.Unregister(client)
+		aliceClient := testApp.Hub.RegisterUser(alice.ID)
+		defer testApp.Hub.Unregister(aliceClient)
+
+		form := url.Values{"body": {"ping @alice and @carol"}}
+		req := httptest.NewRequest("PUT", "/rooms/"+itoa(room.ID)+"/messages/"+itoa(id), strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req = withRoute(req, bob, map[string]string{"roomID": itoa(room.ID), "messageID": itoa(id)})
+		w := httptest.NewRecorder()
+		h.UpdateMessage(w, req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+		}
+
+		if got := drain(client); len(got) != 1 {
+			t.Errorf("Expected carol to be notified once, got %+v", got)
+		}
+		if got := drain(aliceClient); len(got) != 0 {
+			t.Errorf("Expected alice not to be notified again, got %+v", got)
+		}
+	})
+
+	t.Run("counts unread mentions", func(t *testing.T) {
+		row, err := testApp.DB.GetRoomUnread(ctx, db.GetRoomUnreadParams{RoomID: room.ID, UserID: carol.ID})
+		if err != nil {
+			t.Fatalf("Failed to load unread counts: %v", err)
+		}
+		// @room, @here and the edited message
+		if row.MentionCount != 3 {
+			t.Errorf("Expected 3 unread mentions for carol, got %d", row.MentionCount)
+		}
+	})
+}
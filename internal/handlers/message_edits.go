@@ -0,0 +1,274 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"blazing/internal/db"
+)
+
+type MessageEditView struct {
+	Body     string
+	Login    string
+	EditedAt time.Time
+}
+
+type MessageHistoryData struct {
+	MessageID int64
+	Edits     []MessageEditView
+}
+
+// Message renders a single message. The edit form uses it to cancel.
+func (h *Handlers) Message(w http.ResponseWriter, r *http.Request) {
+	_, message, ok := h.requireRoomMessage(w, r)
+	if !ok {
+		return
+	}
+
+	view, err := h.storedMessageView(r.Context(), message)
+	if err != nil {
+		slog.Error("Failed to load message", "error", err, "message_id", message.ID)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	h.renderMessageFragment(w, "message", view)
+}
+
+func (h *Handlers) EditMessageForm(w http.ResponseWriter, r *http.Request) {
+	access, message, ok := h.requireRoomMessage(w, r)
+	if !ok {
+		return
+	}
+	if !h.checkCanModifyMessage(w, access, message) {
+		return
+	}
+
+	h.renderMessageFragment(w, "message_edit", messageViewFromRow(db.GetRoomMessagesBeforeRow(message)))
+}
+
+func (h *Handlers) UpdateMessage(w http.ResponseWriter, r *http.Request) {
+	access, message, ok := h.requireRoomMessage(w, r)
+	if !ok {
+		return
+	}
+	if !h.checkCanModifyMessage(w, access, message) {
+		return
+	}
+
+	body, ok := parseMessageBody(w, r)
+	if !ok {
+		return
+	}
+
+	room, user := access.Room, access.User
+	var mentioned []int64
+	if body != message.Body {
+		err := h.app.WithTx(r.Context(), func(q *db.Queries) error {
+			if err := q.CreateMessageEdit(r.Context(), db.CreateMessageEditParams{
+				MessageID: message.ID,
+				EditorID:  user.ID,
+				Body:      message.Body,
+			}); err != nil {
+				return err
+			}
+			if err := q.UpdateMessageBody(r.Context(), db.UpdateMessageBodyParams{Body: body, ID: message.ID}); err != nil {
+				return err
+			}
+			// Only people newly mentioned by the edit hear about it
+			var err error
+			mentioned, err = h.saveMentions(r.Context(), q, access, message.UserID, message.ID, body)
+			return err
+		})
+		if err != nil {
+			slog.Error("Failed to edit message", "error", err, "message_id", message.ID, "user_id", user.ID)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	h.publishStoredMessage(w, r, room.ID, message.ID, eventMessageUpdated)
+	h.notifyMentions(r.Context(), room, message.Login, message.ID, message.ParentMessageID.Int64, body, mentioned)
+}
+
+func (h *Handlers) DeleteMessage(w http.ResponseWriter, r *http.Request) {
+	access, message, ok := h.requireRoomMessage(w, r)
+	if !ok {
+		return
+	}
+	if !h.checkCanModifyMessage(w, access, message) {
+		return
+	}
+
+	room, user := access.Room, access.User
+
+	// Deleted messages no longer show their files, so the rows go and the
+	// content follows once nothing else refers to it
+	var removed []db.Attachment
+	err := h.app.WithTx(r.Context(), func(q *db.Queries) error {
+		if err := q.SoftDeleteMessage(r.Context(), message.ID); err != nil {
+			return err
+		}
+		var err error
+		removed, err = q.DeleteMessageAttachments(r.Context(), message.ID)
+		return err
+	})
+	if err != nil {
+		slog.Error("Failed to delete message", "error", err, "message_id", message.ID, "user_id", user.ID)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	slog.Info("Message deleted", "room_id", room.ID, "message_id", message.ID, "deleted_by", user.ID)
+
+	var keys []string
+	for _, a := range removed {
+		keys = append(keys, storedKeys(a.StorageKey, a.ThumbnailKey)...)
+	}
+	h.sweepAttachments(context.WithoutCancel(r.Context()), keys)
+
+	if message.ParentMessageID.Valid {
+		h.refreshThreadSummary(r.Context(), room.ID, message.ParentMessageID.Int64)
+	}
+
+	h.publishStoredMessage(w, r, room.ID, message.ID, eventMessageDeleted)
+
+	if !message.ParentMessageID.Valid {
+		h.notifyUnread(r.Context(), room.ID)
+	}
+}
+
+func (h *Handlers) MessageHistory(w http.ResponseWriter, r *http.Request) {
+	_, message, ok := h.requireRoomMessage(w, r)
+	if !ok {
+		return
+	}
+	if message.DeletedAt.Valid {
+		http.Error(w, "Message has been deleted", http.StatusGone)
+		return
+	}
+
+	rows, err := h.app.DB.GetMessageEdits(r.Context(), message.ID)
+	if err != nil {
+		slog.Error("Failed to load message history", "error", err, "message_id", message.ID)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	data := MessageHistoryData{
+		MessageID: message.ID,
+		Edits:     make([]MessageEditView, len(rows)),
+	}
+	for i, row := range rows {
+		data.Edits[i] = MessageEditView{
+			Body:     row.Body,
+			Login:    row.Login,
+			EditedAt: row.EditedAt.Time,
+		}
+	}
+
+	h.renderMessageFragment(w, "message_history", data)
+}
+
+// requireRoomMessage resolves the messageID URL parameter to a message in a
+// room the current user belongs to, writing the error response otherwise.
+func (h *Handlers) requireRoomMessage(w http.ResponseWriter, r *http.Request) (roomAccess, db.GetRoomMessageRow, bool) {
+	access, ok := h.requireRoomMember(w, r)
+	if !ok {
+		return roomAccess{}, db.GetRoomMessageRow{}, false
+	}
+
+	messageID, err := strconv.ParseInt(chi.URLParam(r, "messageID"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid message ID", http.StatusBadRequest)
+		return roomAccess{}, db.GetRoomMessageRow{}, false
+	}
+
+	message, err := h.app.DB.GetRoomMessage(r.Context(), db.GetRoomMessageParams{
+		ID:     messageID,
+		RoomID: access.Room.ID,
+	})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "Message not found", http.StatusNotFound)
+			return roomAccess{}, db.GetRoomMessageRow{}, false
+		}
+		slog.Error("Failed to load message", "error", err, "room_id", access.Room.ID, "message_id", messageID)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return roomAccess{}, db.GetRoomMessageRow{}, false
+	}
+
+	return access, message, true
+}
+
+func (h *Handlers) checkCanModifyMessage(w http.ResponseWriter, access roomAccess, message db.GetRoomMessageRow) bool {
+	if message.Kind == messageKindSystem || !access.canModifyMessage(message.UserID) {
+		http.Error(w, "You cannot change this message", http.StatusForbidden)
+		return false
+	}
+	if message.DeletedAt.Valid {
+		http.Error(w, "Message has been deleted", http.StatusGone)
+		return false
+	}
+	return true
+}
+
+// publishStoredMessage reloads a message after a change so every client
+// renders the stored state, then pushes it to the room.
+func (h *Handlers) publishStoredMessage(w http.ResponseWriter, r *http.Request, roomID, messageID int64, eventType string) {
+	html, err := h.pushStoredMessage(r.Context(), roomID, messageID, eventType)
+	if err != nil {
+		slog.Error("Failed to publish message", "error", err, "message_id", messageID)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write([]byte(html))
+}
+
+// pushStoredMessage broadcasts the stored state of a message to the room
+// and returns the rendered HTML.
+func (h *Handlers) pushStoredMessage(ctx context.Context, roomID, messageID int64, eventType string) (string, error) {
+	message, err := h.app.DB.GetRoomMessage(ctx, db.GetRoomMessageParams{ID: messageID, RoomID: roomID})
+	if err != nil {
+		return "", err
+	}
+
+	view, err := h.storedMessageView(ctx, message)
+	if err != nil {
+		return "", err
+	}
+
+	html, err := h.renderFragment("message", view)
+	if err != nil {
+		return "", err
+	}
+
+	h.broadcast(roomID, eventType, messageEvent{ID: messageID, HTML: html})
+	return html, nil
+}
+
+// storedMessageView renders a loaded message the same way history does,
+// reactions included.
+func (h *Handlers) storedMessageView(ctx context.Context, message db.GetRoomMessageRow) (MessageView, error) {
+	views := []MessageView{messageViewFromRow(db.GetRoomMessagesBeforeRow(message))}
+	if err := h.decorateMessages(ctx, views); err != nil {
+		return MessageView{}, err
+	}
+	return views[0], nil
+}
+
+func (h *Handlers) renderMessageFragment(w http.ResponseWriter, name string, data any) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := h.roomTemplate.ExecuteTemplate(w, name, data); err != nil {
+		slog.Error("Failed to render message fragment", "error", err, "template", name)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,191 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"blazing/internal/db"
+)
+
+func TestUpdateMessage(t *testing.T) {
+	testApp, h := setupTestApp(t)
+	ctx := context.Background()
+
+	alice := createTestUser(t, testApp, 1001, "alice")
+	bob := createTestUser(t, testApp, 1002, "bob")
+	room := createTestRoom(t, testApp, "general", alice, bob)
+
+	message, err := testApp.DB.CreateMessage(ctx, db.CreateMessageParams{RoomID: room.ID, UserID: bob.ID, Body: "teh plan"})
+	if err != nil {
+		t.Fatalf("Failed to create message: %v", err)
+	}
+
+	edit := func(actor db.User, body string) *httptest.ResponseRecorder {
+		form := url.Values{"body": {body}}
+		req := httptest.NewRequest("PUT", "/rooms/"+itoa(room.ID)+"/messages/"+itoa(message.ID), strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req = withRoute(req, actor, map[string]string{"roomID": itoa(room.ID), "messageID": itoa(message.ID)})
+		w := httptest.NewRecorder()
+		h.UpdateMessage(w, req)
+		return w
+	}
+
+	t.Run("author edits and the room is told", func(t *testing.T) {
+		client := testApp.Hub.Register(room.ID, alice.ID)
+		defer testApp.Hub.Unregister(client)
+
+		w := edit(bob, "the plan")
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+		}
+		if !strings.Contains(w.Body.String(), "the plan") || !strings.Contains(w.Body.String(), "(edited)") {
+			t.Errorf("Expected edited message, got: %s", w.Body.String())
+		}
+
+		var event struct {
+			Type string       `json:"type"`
+			Data messageEvent `json:"data"`
+		}
+		if err := json.Unmarshal(<-client.Send(), &event); err != nil {
+			t.Fatalf("Failed to decode event: %v", err)
+		}
+		if event.Type != eventMessageUpdated || event.Data.ID != message.ID {
+			t.Errorf("Expected %s for message %d, got %s for %d", eventMessageUpdated, message.ID, event.Type, event.Data.ID)
+		}
+
+		edits, err := testApp.DB.GetMessageEdits(ctx, message.ID)
+		if err != nil {
+			t.Fatalf("Failed to load edits: %v", err)
+		}
+		if len(edits) != 1 || edits[0].Body != "teh plan" || edits[0].Login != "bob" {
+			t.Errorf("Expected the original body in history, got %+v", edits)
+		}
+	})
+
+	t.Run("room admin can edit", func(t *testing.T) {
+		if w := edit(alice, "the plan, agreed"); w.Code != http.StatusOK {
+			t.Errorf("Expected status %d, got %d", http.StatusOK, w.Code)
+		}
+	})
+
+	t.Run("other members cannot edit", func(t *testing.T) {
+		carol := createTestUser(t, testApp, 1003, "carol")
+		if err := testApp.DB.CreateRoomMembership(ctx, db.CreateRoomMembershipParams{RoomID: room.ID, UserID: carol.ID, Role: roleMember}); err != nil {
+			t.Fatalf("Failed to add member: %v", err)
+		}
+		if w := edit(carol, "hijacked"); w.Code != http.StatusForbidden {
+			t.Errorf("Expected status %d, got %d", http.StatusForbidden, w.Code)
+		}
+	})
+
+	t.Run("rejects empty body", func(t *testing.T) {
+		if w := edit(bob, "  "); w.Code != http.StatusBadRequest {
+			t.Errorf("Expected status %d, got %d", http.StatusBadRequest, w.Code)
+		}
+	})
+}
+
+func TestDeleteMessage(t *testing.T) {
+	testApp, h := setupTestApp(t)
+	ctx := context.Background()
+
+	alice := createTestUser(t, testApp, 1001, "alice")
+	bob := createTestUser(t, testApp, 1002, "bob")
+	room := createTestRoom(t, testApp, "general", alice, bob)
+
+	message, err := testApp.DB.CreateMessage(ctx, db.CreateMessageParams{RoomID: room.ID, UserID: alice.ID, Body: "oops, a password"})
+	if err != nil {
+		t.Fatalf("Failed to create message: %v", err)
+	}
+
+	remove := func(actor db.User) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("DELETE", "/rooms/"+itoa(room.ID)+"/messages/"+itoa(message.ID), nil)
+		req = withRoute(req, actor, map[string]string{"roomID": itoa(room.ID), "messageID": itoa(message.ID)})
+		w := httptest.NewRecorder()
+		h.DeleteMessage(w, req)
+		return w
+	}
+
+	t.Run("members cannot delete others' messages", func(t *testing.T) {
+		if w := remove(bob); w.Code != http.StatusForbidden {
+			t.Errorf("Expected status %d, got %d", http.StatusForbidden, w.Code)
+		}
+	})
+
+	t.Run("author deletes and the room gets a placeholder", func(t *testing.T) {
+		client := testApp.Hub.Register(room.ID, bob.ID)
+		defer testApp.Hub.Unregister(client)
+
+		w := remove(alice)
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+		}
+
+		var event struct {
+			Type string       `json:"type"`
+			Data messageEvent `json:"data"`
+		}
+		if err := json.Unmarshal(<-client.Send(), &event); err != nil {
+			t.Fatalf("Failed to decode event: %v", err)
+		}
+		if event.Type != eventMessageDeleted {
+			t.Errorf("Expected event %q, got %q", eventMessageDeleted, event.Type)
+		}
+		if strings.Contains(event.Data.HTML, "password") || !strings.Contains(event.Data.HTML, "This message was deleted") {
+			t.Errorf("Expected placeholder without the body, got: %s", event.Data.HTML)
+		}
+	})
+
+	t.Run("history shows a placeholder", func(t *testing.T) {
+		page, err := h.loadMessagesBefore(ctx, room.ID, 0)
+		if err != nil {
+			t.Fatalf("Failed to load messages: %v", err)
+		}
+		if len(page.Messages) != 1 || !page.Messages[0].Deleted || page.Messages[0].Body != "" {
+			t.Errorf("Expected one deleted message without a body, got %+v", page.Messages)
+		}
+	})
+
+	t.Run("cannot delete twice", func(t *testing.T) {
+		if w := remove(alice); w.Code != http.StatusGone {
+			t.Errorf("Expected status %d, got %d", http.StatusGone, w.Code)
+		}
+	})
+}
+
+func TestMessageHistory(t *testing.T) {
+	testApp, h := setupTestApp(t)
+	ctx := context.Background()
+
+	alice := createTestUser(t, testApp, 1001, "alice")
+	bob := createTestUser(t, testApp, 1002, "bob")
+	room := createTestRoom(t, testApp, "general", alice)
+
+	message, err := testApp.DB.CreateMessage(ctx, db.CreateMessageParams{RoomID: room.ID, UserID: alice.ID, Body: "v2"})
+	if err != nil {
+		t.Fatalf("Failed to create message: %v", err)
+	}
+	if err := testApp.DB.CreateMessageEdit(ctx, db.CreateMessageEditParams{MessageID: message.ID, EditorID: alice.ID, Body: "v1"}); err != nil {
+		t.Fatalf("Failed to record edit: %v", err)
+	}
+
+	history := func(user db.User) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/rooms/"+itoa(room.ID)+"/messages/"+itoa(message.ID)+"/history", nil)
+		req = withRoute(req, user, map[string]string{"roomID": itoa(room.ID), "messageID": itoa(message.ID)})
+		w := httptest.NewRecorder()
+		h.MessageHistory(w, req)
+		return w
+	}
+
+	if w := history(alice); w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "v1") {
+		t.Errorf("Expected previous version, got %d: %s", w.Code, w.Body.String())
+	}
+	if w := history(bob); w.Code != http.StatusForbidden {
+		t.Errorf("Expected status %d for a non-member, got %d", http.StatusForbidden, w.Code)
+	}
+}
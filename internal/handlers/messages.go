@@ -0,0 +1,291 @@
+package handlers
+
+import (
+	"context"
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"blazing/internal/db"
+)
+
+const (
+	messagePageSize  = 50
+	maxMessageLength = 4000
+)
+
+// messageKindSystem marks messages the server writes when a room changes.
+const messageKindSystem = "system"
+
+type MessageView struct {
+	ID        int64
+	RoomID    int64
+	UserID    int64
+	Login     string
+	AvatarURL string
+	Body      string
+	CreatedAt time.Time
+	Edited    bool
+	Deleted   bool
+	System    bool // a change to the room itself, by the user who made it
+	Pinned    bool
+	Saved     bool // by the viewer, on pages rendered for them alone
+	Reactions []ReactionView
+
+	Attachments []AttachmentView
+
+	// Mentions lists the lowercased tokens to highlight; MentionedIDs is
+	// space-separated, so the page can highlight messages for the viewer
+	Mentions     []string
+	MentionedIDs string
+
+	// ParentID is set on thread replies; ReplyCount and LastReplyAt
+	// summarise the thread on its top-level message.
+	ParentID    int64
+	ReplyCount  int64
+	LastReplyAt time.Time
+}
+
+// MessagePage is a chronological slice of room history. BeforeID is the
+// cursor for the next older page and is zero once the start is reached.
+type MessagePage struct {
+	RoomID   int64
+	Messages []MessageView
+	BeforeID int64
+}
+
+func (h *Handlers) RoomMessages(w http.ResponseWriter, r *http.Request) {
+	access, ok := h.requireRoomMember(w, r)
+	if !ok {
+		return
+	}
+	room := access.Room
+
+	query := r.URL.Query()
+	if after := query.Get("after"); after != "" {
+		afterID, err := strconv.ParseInt(after, 10, 64)
+		if err != nil {
+			http.Error(w, "Invalid after cursor", http.StatusBadRequest)
+			return
+		}
+
+		messages, err := h.loadMessagesAfter(r.Context(), room.ID, afterID)
+		if err == nil {
+			err = h.attachSaved(r.Context(), access.User.ID, messages)
+		}
+		if err != nil {
+			slog.Error("Failed to load newer messages", "error", err, "room_id", room.ID)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		h.renderMessagePage(w, MessagePage{RoomID: room.ID, Messages: messages})
+		return
+	}
+
+	var beforeID int64
+	if before := query.Get("before"); before != "" {
+		var err error
+		beforeID, err = strconv.ParseInt(before, 10, 64)
+		if err != nil {
+			http.Error(w, "Invalid before cursor", http.StatusBadRequest)
+			return
+		}
+	}
+
+	page, err := h.loadMessagesBefore(r.Context(), room.ID, beforeID)
+	if err == nil {
+		err = h.attachSaved(r.Context(), access.User.ID, page.Messages)
+	}
+	if err != nil {
+		slog.Error("Failed to load older messages", "error", err, "room_id", room.ID)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	h.renderMessagePage(w, page)
+}
+
+func (h *Handlers) CreateMessage(w http.ResponseWriter, r *http.Request) {
+	access, ok := h.requireRoomMember(w, r)
+	if !ok {
+		return
+	}
+	if !access.can(permPost) {
+		http.Error(w, "You cannot post in this room", http.StatusForbidden)
+		return
+	}
+
+	body, ok := parseMessageBody(w, r)
+	if !ok {
+		return
+	}
+
+	room, user := access.Room, access.User
+
+	var message db.Message
+	var mentioned []int64
+	err := h.app.WithTx(r.Context(), func(q *db.Queries) error {
+		var err error
+		message, err = q.CreateMessage(r.Context(), db.CreateMessageParams{
+			RoomID: room.ID,
+			UserID: user.ID,
+			Body:   body,
+		})
+		if err != nil {
+			return err
+		}
+		mentioned, err = h.saveMentions(r.Context(), q, access, user.ID, message.ID, body)
+		return err
+	})
+	if err != nil {
+		slog.Error("Failed to create message", "error", err, "room_id", room.ID, "user_id", user.ID)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	view := MessageView{
+		ID:        message.ID,
+		RoomID:    message.RoomID,
+		UserID:    message.UserID,
+		Login:     user.Login,
+		AvatarURL: user.AvatarURL,
+		Body:      message.Body,
+		CreatedAt: message.CreatedAt.Time,
+	}
+	views := []MessageView{view}
+	if err := h.attachMentions(r.Context(), views); err != nil {
+		slog.Error("Failed to load mentions", "error", err, "message_id", message.ID)
+	}
+	view = views[0]
+
+	h.publishMessage(w, eventMessageCreated, view, http.StatusCreated)
+	h.notifyUnread(r.Context(), room.ID)
+	h.notifyMentions(r.Context(), room, user.Login, message.ID, 0, body, mentioned)
+}
+
+// parseMessageBody reads and validates the body form field, writing the
+// error response itself when the body is unusable.
+func parseMessageBody(w http.ResponseWriter, r *http.Request) (string, bool) {
+	body := strings.TrimSpace(r.FormValue("body"))
+	if body == "" {
+		http.Error(w, "Message cannot be empty", http.StatusBadRequest)
+		return "", false
+	}
+	if utf8.RuneCountInString(body) > maxMessageLength {
+		http.Error(w, "Message is too long", http.StatusRequestEntityTooLarge)
+		return "", false
+	}
+	return body, true
+}
+
+// publishMessage renders a message once, pushes it to the room's sockets
+// and returns the same HTML to the client that made the change.
+func (h *Handlers) publishMessage(w http.ResponseWriter, eventType string, view MessageView, status int) {
+	html, err := h.renderFragment("message", view)
+	if err != nil {
+		slog.Error("Failed to render message", "error", err, "message_id", view.ID)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	h.broadcast(view.RoomID, eventType, messageEvent{ID: view.ID, HTML: html})
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
+	w.Write([]byte(html))
+}
+
+// loadMessagesBefore returns the page of messages older than beforeID, or
+// the latest page when beforeID is zero.
+func (h *Handlers) loadMessagesBefore(ctx context.Context, roomID, beforeID int64) (MessagePage, error) {
+	if beforeID <= 0 {
+		beforeID = math.MaxInt64
+	}
+
+	// Fetch one extra row to learn whether an older page exists
+	rows, err := h.app.DB.GetRoomMessagesBefore(ctx, db.GetRoomMessagesBeforeParams{
+		RoomID:   roomID,
+		BeforeID: beforeID,
+		Limit:    messagePageSize + 1,
+	})
+	if err != nil {
+		return MessagePage{}, err
+	}
+
+	page := MessagePage{RoomID: roomID}
+	if len(rows) > messagePageSize {
+		rows = rows[:messagePageSize]
+		page.BeforeID = rows[len(rows)-1].ID
+	}
+
+	page.Messages = make([]MessageView, len(rows))
+	for i, row := range rows {
+		page.Messages[len(rows)-1-i] = messageViewFromRow(row)
+	}
+
+	if err := h.decorateMessages(ctx, page.Messages); err != nil {
+		return MessagePage{}, err
+	}
+
+	return page, nil
+}
+
+func (h *Handlers) loadMessagesAfter(ctx context.Context, roomID, afterID int64) ([]MessageView, error) {
+	rows, err := h.app.DB.GetRoomMessagesAfter(ctx, db.GetRoomMessagesAfterParams{
+		RoomID:  roomID,
+		AfterID: afterID,
+		Limit:   messagePageSize,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	messages := make([]MessageView, len(rows))
+	for i, row := range rows {
+		messages[i] = messageViewFromRow(db.GetRoomMessagesBeforeRow(row))
+	}
+
+	if err := h.decorateMessages(ctx, messages); err != nil {
+		return nil, err
+	}
+
+	return messages, nil
+}
+
+func messageViewFromRow(row db.GetRoomMessagesBeforeRow) MessageView {
+	view := MessageView{
+		ID:        row.ID,
+		RoomID:    row.RoomID,
+		UserID:    row.UserID,
+		Login:     row.Login,
+		AvatarURL: row.AvatarUrl.String,
+		Body:      row.Body,
+		CreatedAt: row.CreatedAt.Time,
+		Edited:    row.EditedAt.Valid,
+		Deleted:   row.DeletedAt.Valid,
+		System:    row.Kind == messageKindSystem,
+
+		ParentID:    row.ParentMessageID.Int64,
+		ReplyCount:  row.ReplyCount,
+		LastReplyAt: row.LastReplyAt.Time,
+	}
+	// Deleted bodies stay in the database for moderation but never reach
+	// the page
+	if view.Deleted {
+		view.Body = ""
+	}
+	return view
+}
+
+func (h *Handlers) renderMessagePage(w http.ResponseWriter, page MessagePage) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := h.roomTemplate.ExecuteTemplate(w, "message_page", page); err != nil {
+		slog.Error("Failed to render message page", "error", err, "room_id", page.RoomID)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,191 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"blazing/internal/db"
+)
+
+func postMessage(t *testing.T, h *Handlers, user db.User, roomID int64, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	form := url.Values{"body": {body}}
+	req := httptest.NewRequest("POST", "/rooms/"+itoa(roomID)+"/messages", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req = withRoute(req, user, map[string]string{"roomID": itoa(roomID)})
+
+	w := httptest.NewRecorder()
+	h.CreateMessage(w, req)
+	return w
+}
+
+func TestCreateMessage(t *testing.T) {
+	testApp, h := setupTestApp(t)
+
+	alice := createTestUser(t, testApp, 1001, "alice")
+	bob := createTestUser(t, testApp, 1002, "bob")
+	room := createTestRoom(t, testApp, "general", alice)
+
+	t.Run("persists and broadcasts", func(t *testing.T) {
+		client := testApp.Hub.Register(room.ID, alice.ID)
+		defer testApp.Hub.Unregister(client)
+
+		w := postMessage(t, h, alice, room.ID, "  hello <world>  ")
+		if w.Code != http.StatusCreated {
+			t.Fatalf("Expected status %d, got %d: %s", http.StatusCreated, w.Code, w.Body.String())
+		}
+
+		if !strings.Contains(w.Body.String(), "hello &lt;world&gt;") {
+			t.Errorf("Expected escaped message body, got: %s", w.Body.String())
+		}
+
+		var event struct {
+			Type string       `json:"type"`
+			Data messageEvent `json:"data"`
+		}
+		if err := json.Unmarshal(<-client.Send(), &event); err != nil {
+			t.Fatalf("Failed to decode event: %v", err)
+		}
+		if event.Type != eventMessageCreated {
+			t.Errorf("Expected event %q, got %q", eventMessageCreated, event.Type)
+		}
+		if !strings.Contains(event.Data.HTML, "alice") {
+			t.Errorf("Expected rendered author in event HTML, got: %s", event.Data.HTML)
+		}
+
+		rows, err := testApp.DB.GetRoomMessagesAfter(context.Background(), db.GetRoomMessagesAfterParams{
+			RoomID: room.ID, AfterID: 0, Limit: 10,
+		})
+		if err != nil {
+			t.Fatalf("Failed to load messages: %v", err)
+		}
+		if len(rows) != 1 || rows[0].Body != "hello <world>" {
+			t.Errorf("Expected one trimmed message, got %+v", rows)
+		}
+	})
+
+	t.Run("renders markdown and stores the source", func(t *testing.T) {
+		source := "**ship it**\n```sh\nrm -rf <dir>\n```\n[click](javascript:alert(1))"
+		w := postMessage(t, h, alice, room.ID, source)
+		if w.Code != http.StatusCreated {
+			t.Fatalf("Expected status %d, got %d: %s", http.StatusCreated, w.Code, w.Body.String())
+		}
+		body := w.Body.String()
+		if !strings.Contains(body, "<strong>ship it</strong>") || !strings.Contains(body, `<code class="language-sh">rm -rf &lt;dir&gt;</code>`) {
+			t.Errorf("Expected rendered markdown, got: %s", body)
+		}
+		if strings.Contains(body, `href="javascript`) {
+			t.Errorf("Expected unsafe links to stay text, got: %s", body)
+		}
+
+		page, err := h.loadMessagesBefore(context.Background(), room.ID, 0)
+		if err != nil {
+			t.Fatalf("Failed to load messages: %v", err)
+		}
+		if last := page.Messages[len(page.Messages)-1]; last.Body != source {
+			t.Errorf("Expected the markdown source to be stored, got %q", last.Body)
+		}
+	})
+
+	t.Run("rejects empty body", func(t *testing.T) {
+		w := postMessage(t, h, alice, room.ID, "   ")
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("Expected status %d, got %d", http.StatusBadRequest, w.Code)
+		}
+	})
+
+	t.Run("rejects non-members", func(t *testing.T) {
+		w := postMessage(t, h, bob, room.ID, "let me in")
+		if w.Code != http.StatusForbidden {
+			t.Errorf("Expected status %d, got %d", http.StatusForbidden, w.Code)
+		}
+	})
+}
+
+func TestRoomMessagesPagination(t *testing.T) {
+	testApp, h := setupTestApp(t)
+
+	alice := createTestUser(t, testApp, 1001, "alice")
+	room := createTestRoom(t, testApp, "general", alice)
+
+	var ids []int64
+	for i := 0; i < messagePageSize+5; i++ {
+		msg, err := testApp.DB.CreateMessage(context.Background(), db.CreateMessageParams{
+			RoomID: room.ID,
+			UserID: alice.ID,
+			Body:   fmt.Sprintf("message %d", i),
+		})
+		if err != nil {
+			t.Fatalf("Failed to create message: %v", err)
+		}
+		ids = append(ids, msg.ID)
+	}
+
+	t.Run("latest page", func(t *testing.T) {
+		page, err := h.loadMessagesBefore(context.Background(), room.ID, 0)
+		if err != nil {
+			t.Fatalf("Failed to load page: %v", err)
+		}
+
+		if len(page.Messages) != messagePageSize {
+			t.Fatalf("Expected %d messages, got %d", messagePageSize, len(page.Messages))
+		}
+		if last := page.Messages[len(page.Messages)-1]; last.ID != ids[len(ids)-1] {
+			t.Errorf("Expected newest message last, got ID %d", last.ID)
+		}
+		if page.BeforeID != ids[5] {
+			t.Errorf("Expected older cursor %d, got %d", ids[5], page.BeforeID)
+		}
+	})
+
+	t.Run("before cursor", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/rooms/"+itoa(room.ID)+"/messages?before="+itoa(ids[5]), nil)
+		req = withRoute(req, alice, map[string]string{"roomID": itoa(room.ID)})
+		w := httptest.NewRecorder()
+
+		h.RoomMessages(w, req)
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected status %d, got %d", http.StatusOK, w.Code)
+		}
+
+		body := w.Body.String()
+		if strings.Count(body, `class="message"`) != 5 {
+			t.Errorf("Expected 5 older messages, got body: %s", body)
+		}
+		if strings.Contains(body, "load-older") {
+			t.Error("Expected no further older page")
+		}
+	})
+
+	t.Run("after cursor", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/rooms/"+itoa(room.ID)+"/messages?after="+itoa(ids[len(ids)-3]), nil)
+		req = withRoute(req, alice, map[string]string{"roomID": itoa(room.ID)})
+		w := httptest.NewRecorder()
+
+		h.RoomMessages(w, req)
+
+		body := w.Body.String()
+		if strings.Count(body, `class="message"`) != 2 {
+			t.Errorf("Expected 2 newer messages, got body: %s", body)
+		}
+	})
+
+	t.Run("invalid cursor", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/rooms/"+itoa(room.ID)+"/messages?before=abc", nil)
+		req = withRoute(req, alice, map[string]string{"roomID": itoa(room.ID)})
+		w := httptest.NewRecorder()
+
+		h.RoomMessages(w, req)
+
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("Expected status %d, got %d", http.StatusBadRequest, w.Code)
+		}
+	})
+}
@@ -13,8 +13,12 @@ type userContextKey struct{}
 
 func (h *Handlers) RequireAuth(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		user, err := h.app.Session.Get(r)
+		user, err := h.authenticate(w, r)
 		if err != nil {
+			if errors.Is(err, errAccountDisabled) {
+				http.Error(w, "This account has been disabled", http.StatusForbidden)
+				return
+			}
 			if errors.Is(err, session.ErrNoSession) || errors.Is(err, session.ErrInvalidSession) {
 				http.Error(w, "Authentication required", http.StatusUnauthorized)
 				return
@@ -36,9 +40,9 @@ func GetUserFromContext(r *http.Request) (*session.User, bool) {
 
 func (h *Handlers) RequireAuthWithRedirect(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		user, err := h.app.Session.Get(r)
+		user, err := h.authenticate(w, r)
 		if err != nil {
-			if errors.Is(err, session.ErrNoSession) || errors.Is(err, session.ErrInvalidSession) {
+			if errors.Is(err, session.ErrNoSession) || errors.Is(err, session.ErrInvalidSession) || errors.Is(err, errAccountDisabled) {
 				http.Redirect(w, r, "/", http.StatusTemporaryRedirect)
 				return
 			}
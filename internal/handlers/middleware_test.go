@@ -4,6 +4,7 @@ import (
 	"context"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 
 	"blazing/internal/session"
@@ -35,10 +36,12 @@ func TestRequireAuth(t *testing.T) {
 		}
 	})
 
+	stored := createTestUser(t, testApp, 12345, "testuser")
+
 	t.Run("allows authenticated requests", func(t *testing.T) {
 		// Create a test user session
 		testUser := &session.User{
-			ID:        1,
+			ID:        stored.ID,
 			GitHubUID: 12345,
 			Login:     "testuser",
 			AvatarURL: "https://example.com/avatar.jpg",
@@ -47,7 +50,7 @@ func TestRequireAuth(t *testing.T) {
 		req := httptest.NewRequest("GET", "/protected", nil)
 		w := httptest.NewRecorder()
 
-		if err := testApp.Session.Set(w, testUser); err != nil {
+		if err := testApp.Session.Set(w, req, testUser); err != nil {
 			t.Fatalf("Failed to set test session: %v", err)
 		}
 
@@ -83,6 +86,38 @@ func TestRequireAuth(t *testing.T) {
 			t.Errorf("Expected status %d, got %d", http.StatusUnauthorized, w.Code)
 		}
 	})
+
+	t.Run("reseals cookies from a rotated key", func(t *testing.T) {
+		rotated, err := session.NewManager("next-secret-key-that-is-long-enough-for-testing", "test-secret-key-that-is-long-enough-for-testing")
+		if err != nil {
+			t.Fatalf("Failed to create session manager: %v", err)
+		}
+
+		req := httptest.NewRequest("GET", "/protected", nil)
+		w := httptest.NewRecorder()
+		if err := testApp.Session.Set(w, req, &session.User{ID: stored.ID, Login: "testuser"}); err != nil {
+			t.Fatalf("Failed to set test session: %v", err)
+		}
+		for _, c := range w.Result().Cookies() {
+			req.AddCookie(c)
+		}
+
+		original := testApp.Session
+		testApp.Session = rotated
+		defer func() { testApp.Session = original }()
+
+		w = httptest.NewRecorder()
+		authHandler.ServeHTTP(w, req)
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected status %d, got %d", http.StatusOK, w.Code)
+		}
+		cookies := w.Result().Cookies()
+		want := "." + session.KeyID("next-secret-key-that-is-long-enough-for-testing") + "."
+		if len(cookies) != 1 || !strings.Contains(cookies[0].Value, want) {
+			t.Errorf("Expected the cookie to be sealed again with the new key, got %+v", cookies)
+		}
+	})
 }
 
 func TestRequireAuthWithRedirect(t *testing.T) {
@@ -112,8 +147,9 @@ func TestRequireAuthWithRedirect(t *testing.T) {
 	})
 
 	t.Run("allows authenticated requests", func(t *testing.T) {
+		stored := createTestUser(t, testApp, 12345, "testuser")
 		testUser := &session.User{
-			ID:        1,
+			ID:        stored.ID,
 			GitHubUID: 12345,
 			Login:     "testuser",
 			AvatarURL: "https://example.com/avatar.jpg",
@@ -122,7 +158,7 @@ func TestRequireAuthWithRedirect(t *testing.T) {
 		req := httptest.NewRequest("GET", "/protected", nil)
 		w := httptest.NewRecorder()
 
-		if err := testApp.Session.Set(w, testUser); err != nil {
+		if err := testApp.Session.Set(w, req, testUser); err != nil {
 			t.Fatalf("Failed to set test session: %v", err)
 		}
 
@@ -0,0 +1,153 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"blazing/internal/db"
+)
+
+// Notification levels, per membership.
+const (
+	notifyLevelAll      = "all"      // unread counts and mentions
+	notifyLevelMentions = "mentions" // only mentions of the member
+	notifyLevelNone     = "none"     // nothing, but the room stays joined
+)
+
+// maxMuteDuration bounds a temporary mute to the longest choice the menus
+// offer; rooms that should stay quiet for good are set to notifyLevelNone
+// instead.
+const maxMuteDuration = 7 * 24 * time.Hour
+
+// NotificationSettings is how loudly one room reaches one member.
+type NotificationSettings struct {
+	RoomID     int64
+	Level      string
+	MutedUntil time.Time // zero unless a mute is in effect
+}
+
+func validNotifyLevel(level string) bool {
+	switch level {
+	case notifyLevelAll, notifyLevelMentions, notifyLevelNone:
+		return true
+	}
+	return false
+}
+
+// notificationSettings builds a member's settings as of now. A mute that
+// has run out is treated as no mute at all.
+func notificationSettings(roomID int64, level string, mutedUntil sql.NullTime, now time.Time) NotificationSettings {
+	s := NotificationSettings{RoomID: roomID, Level: level}
+	if !validNotifyLevel(level) {
+		s.Level = notifyLevelAll
+	}
+	if mutedUntil.Valid && now.Before(mutedUntil.Time) {
+		s.MutedUntil = mutedUntil.Time
+	}
+	return s
+}
+
+// Muted reports whether a temporary mute is in effect.
+func (s NotificationSettings) Muted() bool {
+	return !s.MutedUntil.IsZero()
+}
+
+// Quiet reports whether the room sends the member nothing at all.
+func (s NotificationSettings) Quiet() bool {
+	return s.Muted() || s.Level == notifyLevelNone
+}
+
+// badges filters a room's unread counts down to the ones the member wants
+// to see on the dashboard.
+func (s NotificationSettings) badges(unread, mentions int64) (int64, int64) {
+	switch {
+	case s.Quiet():
+		return 0, 0
+	case s.Level == notifyLevelMentions:
+		return 0, mentions
+	}
+	return unread, mentions
+}
+
+// UpdateNotifications changes how loudly the room reaches the current
+// user. Fields left out of the form keep their value; an empty mute_for
+// unmutes.
+func (h *Handlers) UpdateNotifications(w http.ResponseWriter, r *http.Request) {
+	access, ok := h.requireRoomMember(w, r)
+	if !ok {
+		return
+	}
+	room, user, current := access.Room, access.User, access.Notifications
+
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Invalid form", http.StatusBadRequest)
+		return
+	}
+
+	level := current.Level
+	if _, ok := r.Form["level"]; ok {
+		level = r.FormValue("level")
+		if !validNotifyLevel(level) {
+			http.Error(w, "Invalid notification level", http.StatusUnprocessableEntity)
+			return
+		}
+	}
+
+	now := time.Now().UTC()
+	mutedUntil := sql.NullTime{Time: current.MutedUntil, Valid: current.Muted()}
+	if _, ok := r.Form["mute_for"]; ok {
+		mutedUntil = sql.NullTime{}
+		if raw := r.FormValue("mute_for"); raw != "" {
+			muteFor, err := time.ParseDuration(raw)
+			if err != nil || muteFor <= 0 || muteFor > maxMuteDuration {
+				http.Error(w, "Invalid mute duration", http.StatusUnprocessableEntity)
+				return
+			}
+			mutedUntil = sql.NullTime{Time: now.Add(muteFor), Valid: true}
+		}
+	}
+
+	err := h.app.DB.UpdateRoomNotifications(r.Context(), db.UpdateRoomNotificationsParams{
+		NotifyLevel: level,
+		MutedUntil:  mutedUntil,
+		RoomID:      room.ID,
+		UserID:      user.ID,
+	})
+	if err != nil {
+		slog.Error("Failed to update notification settings", "error", err, "room_id", room.ID, "user_id", user.ID)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	// Dashboards pick up the new badges straight away
+	h.pushUnread(r.Context(), room.ID, user.ID)
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	settings := notificationSettings(room.ID, level, mutedUntil, now)
+	if err := h.roomTemplate.ExecuteTemplate(w, "notification_settings", settings); err != nil {
+		slog.Error("Failed to render notification settings", "error", err, "room_id", room.ID)
+	}
+}
+
+// mentionRecipients drops the mentioned users who have silenced the room.
+func (h *Handlers) mentionRecipients(ctx context.Context, roomID int64, userIDs []int64) ([]int64, error) {
+	rows, err := h.app.DB.GetMemberNotificationSettings(ctx, db.GetMemberNotificationSettingsParams{
+		RoomID:  roomID,
+		UserIds: userIDs,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	recipients := make([]int64, 0, len(rows))
+	for _, row := range rows {
+		if !notificationSettings(roomID, row.NotifyLevel, row.MutedUntil, now).Quiet() {
+			recipients = append(recipients, row.UserID)
+		}
+	}
+	return recipients, nil
+}
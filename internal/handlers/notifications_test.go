@@ -0,0 +1,216 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"blazing/internal/db"
+	"blazing/internal/hub"
+)
+
+func TestNotificationBadges(t *testing.T) {
+	now := time.Now()
+	muted := sql.NullTime{Time: now.Add(time.Hour), Valid: true}
+	expired := sql.NullTime{Time: now.Add(-time.Hour), Valid: true}
+
+	tests := []struct {
+		name             string
+		level            string
+		mutedUntil       sql.NullTime
+		unread, mentions int64
+	}{
+		{"all", notifyLevelAll, sql.NullTime{}, 5, 2},
+		{"mentions only", notifyLevelMentions, sql.NullTime{}, 0, 2},
+		{"none", notifyLevelNone, sql.NullTime{}, 0, 0},
+		{"muted", notifyLevelAll, muted, 0, 0},
+		{"mute expired", notifyLevelAll, expired, 5, 2},
+		{"unknown level", "loud", sql.NullTime{}, 5, 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			settings := notificationSettings(1, tt.level, tt.mutedUntil, now)
+			unread, mentions := settings.badges(5, 2)
+			if unread != tt.unread || mentions != tt.mentions {
+				t.Errorf("Expected %d unread and %d mentions, got %d and %d", tt.unread, tt.mentions, unread, mentions)
+			}
+		})
+	}
+}
+
+func TestUpdateNotifications(t *testing.T) {
+	testApp, h := setupTestApp(t)
+	ctx := context.Background()
+
+	alice := createTestUser(t, testApp, 1001, "alice")
+	bob := createTestUser(t, testApp, 1002, "bob")
+	room := createTestRoom(t, testApp, "general", alice, bob)
+
+	update := func(form url.Values) *httptest.ResponseRecorder {
+		t.Helper()
+		req := httptest.NewRequest("PUT", "/rooms/"+itoa(room.ID)+"/notifications", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req = withRoute(req, alice, map[string]string{"roomID": itoa(room.ID)})
+		w := httptest.NewRecorder()
+		h.UpdateNotifications(w, req)
+		return w
+	}
+
+	roomFor := func() RoomListItem {
+		t.Helper()
+		rooms, err := h.loadRoomList(ctx, alice.ID)
+		if err != nil || len(rooms) != 1 {
+			t.Fatalf("Expected one room, got %+v (%v)", rooms, err)
+		}
+		return rooms[0]
+	}
+
+	markAllRead := func() {
+		t.Helper()
+		if err := h.markRead(ctx, room.ID, alice.ID, 1<<62); err != nil {
+			t.Fatalf("Failed to mark read: %v", err)
+		}
+	}
+
+	// drain collects the mentions and the last unread counts pushed to a
+	// dashboard connection
+	drain := func(c *hub.Client) ([]mentionEvent, *unreadEvent) {
+		var mentions []mentionEvent
+		var unread *unreadEvent
+		for len(c.Send()) > 0 {
+			var event struct {
+				Type string          `json:"type"`
+				Data json.RawMessage `json:"data"`
+			}
+			if err := json.Unmarshal(<-c.Send(), &event); err != nil {
+				t.Fatalf("Failed to decode event: %v", err)
+			}
+			switch event.Type {
+			case eventMention:
+				var m mentionEvent
+				json.Unmarshal(event.Data, &m)
+				mentions = append(mentions, m)
+			case eventRoomUnread:
+				unread = new(unreadEvent)
+				json.Unmarshal(event.Data, unread)
+			}
+		}
+		return mentions, unread
+	}
+
+	t.Run("mentions only", func(t *testing.T) {
+		w := update(url.Values{"level": {notifyLevelMentions}})
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+		}
+		if !strings.Contains(w.Body.String(), `value="mentions" selected`) {
+			t.Errorf("Expected the new level to be selected, got: %s", w.Body.String())
+		}
+
+		postMessage(t, h, bob, room.ID, "Morning all")
+		postMessage(t, h, bob, room.ID, "@alice can you review?")
+		if item := roomFor(); item.Unread != 0 || item.Mentions != 1 || item.Quiet {
+			t.Errorf("Expected only the mention to count, got %+v", item)
+		}
+		markAllRead()
+	})
+
+	t.Run("muted rooms stay silent", func(t *testing.T) {
+		w := update(url.Values{"mute_for": {"1h"}})
+		if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "Muted until") {
+			t.Fatalf("Expected the room to be muted, got %d: %s", w.Code, w.Body.String())
+		}
+
+		client := testApp.Hub.RegisterUser(alice.ID)
+		defer testApp.Hub.Unregister(client)
+
+		postMessage(t, h, bob, room.ID, "@alice ping")
+		mentions, unread := drain(client)
+		if len(mentions) != 0 {
+			t.Errorf("Expected no mention while muted, got %+v", mentions)
+		}
+		if unread == nil || unread.Unread != 0 || unread.Mentions != 0 {
+			t.Errorf("Expected empty badges while muted, got %+v", unread)
+		}
+		if item := roomFor(); !item.Quiet || item.Mentions != 0 {
+			t.Errorf("Expected a quiet room, got %+v", item)
+		}
+	})
+
+	t.Run("changing the level keeps the mute", func(t *testing.T) {
+		w := update(url.Values{"level": {notifyLevelAll}})
+		if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "Muted until") {
+			t.Errorf("Expected the mute to survive, got %d: %s", w.Code, w.Body.String())
+		}
+	})
+
+	t.Run("mutes run out", func(t *testing.T) {
+		err := testApp.DB.UpdateRoomNotifications(ctx, db.UpdateRoomNotificationsParams{
+			NotifyLevel: notifyLevelAll,
+			MutedUntil:  sql.NullTime{Time: time.Now().UTC().Add(-time.Minute), Valid: true},
+			RoomID:      room.ID,
+			UserID:      alice.ID,
+		})
+		if err != nil {
+			t.Fatalf("Failed to update settings: %v", err)
+		}
+
+		client := testApp.Hub.RegisterUser(alice.ID)
+		defer testApp.Hub.Unregister(client)
+
+		postMessage(t, h, bob, room.ID, "@alice back with us?")
+		mentions, unread := drain(client)
+		if len(mentions) != 1 || mentions[0].RoomID != room.ID {
+			t.Errorf("Expected the mention once the mute ran out, got %+v", mentions)
+		}
+		if unread == nil || unread.Unread == 0 || unread.Mentions == 0 {
+			t.Errorf("Expected badges once the mute ran out, got %+v", unread)
+		}
+	})
+
+	t.Run("nothing", func(t *testing.T) {
+		if w := update(url.Values{"level": {notifyLevelNone}, "mute_for": {""}}); w.Code != http.StatusOK {
+			t.Fatalf("Expected status %d, got %d", http.StatusOK, w.Code)
+		}
+
+		client := testApp.Hub.RegisterUser(alice.ID)
+		defer testApp.Hub.Unregister(client)
+
+		postMessage(t, h, bob, room.ID, "@alice still there?")
+		if mentions, _ := drain(client); len(mentions) != 0 {
+			t.Errorf("Expected no mention with notifications off, got %+v", mentions)
+		}
+		if item := roomFor(); !item.Quiet || item.Unread != 0 {
+			t.Errorf("Expected a quiet room, got %+v", item)
+		}
+	})
+
+	t.Run("room page shows the settings", func(t *testing.T) {
+		req := withRoute(httptest.NewRequest("GET", "/rooms/"+itoa(room.ID), nil), alice, map[string]string{"roomID": itoa(room.ID)})
+		w := httptest.NewRecorder()
+		h.Room(w, req)
+		if !strings.Contains(w.Body.String(), `value="none" selected`) {
+			t.Errorf("Expected the room page to show alice's level, got: %s", w.Body.String())
+		}
+	})
+
+	t.Run("invalid settings", func(t *testing.T) {
+		for _, form := range []url.Values{
+			{"level": {"loud"}},
+			{"mute_for": {"soon"}},
+			{"mute_for": {"-1h"}},
+			{"mute_for": {"169h"}},
+		} {
+			if w := update(form); w.Code != http.StatusUnprocessableEntity {
+				t.Errorf("Expected status %d for %v, got %d", http.StatusUnprocessableEntity, form, w.Code)
+			}
+		}
+	})
+}
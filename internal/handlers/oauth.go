@@ -8,7 +8,9 @@ import (
 	"io"
 	"log/slog"
 	"net/http"
+	"net/url"
 	"os"
+	"strings"
 	"time"
 
 	"blazing/internal/db"
@@ -18,6 +20,8 @@ import (
 	"golang.org/x/oauth2/github"
 )
 
+const returnToCookie = "return_to"
+
 type GitHubUser struct {
 	ID        int64  `json:"id"`
 	Login     string `json:"login"`
@@ -42,6 +46,21 @@ func getRedirectURL() string {
 	return redirectURL
 }
 
+// getBaseURL is where the server is reached from outside, for links that
+// leave the site such as invites. BASE_URL sets it; otherwise it is the
+// origin of the OAuth callback, which has to be public already. The Host
+// header is never used, since anyone can send one.
+func getBaseURL() string {
+	if baseURL := os.Getenv("BASE_URL"); baseURL != "" {
+		return strings.TrimRight(baseURL, "/")
+	}
+	u, err := url.Parse(getRedirectURL())
+	if err != nil || u.Host == "" {
+		return "http://localhost:8080"
+	}
+	return u.Scheme + "://" + u.Host
+}
+
 func (h *Handlers) GitHubAuth(w http.ResponseWriter, r *http.Request) {
 	clientID := os.Getenv("GITHUB_CLIENT_ID")
 	clientSecret := os.Getenv("GITHUB_CLIENT_SECRET")
@@ -105,25 +124,32 @@ func (h *Handlers) GitHubCallback(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Failed to process user", http.StatusInternalServerError)
 		return
 	}
+	h.users.forget(user.ID)
+
+	if user.DisabledAt.Valid {
+		slog.Info("Disabled account tried to sign in", "user_id", user.ID)
+		http.Error(w, "This account has been disabled", http.StatusForbidden)
+		return
+	}
 
 	sessionUser := &session.User{
 		ID:        user.ID,
-		GitHubUID: user.GithubUid,
+		GitHubUID: user.GithubUid.Int64,
 		Login:     user.Login,
 		AvatarURL: user.AvatarUrl.String,
 	}
 
-	if err := h.app.Session.Set(w, sessionUser); err != nil {
+	if err := h.app.Session.Set(w, r, sessionUser); err != nil {
 		slog.Error("Failed to set session", "error", err, "user_id", sessionUser.ID)
 		http.Error(w, "Failed to create session", http.StatusInternalServerError)
 		return
 	}
 
-	http.Redirect(w, r, "/", http.StatusTemporaryRedirect)
+	http.Redirect(w, r, takeReturnTo(w, r), http.StatusTemporaryRedirect)
 }
 
 func (h *Handlers) Logout(w http.ResponseWriter, r *http.Request) {
-	h.app.Session.Clear(w)
+	h.app.Session.Clear(w, r)
 	http.Redirect(w, r, "/", http.StatusTemporaryRedirect)
 }
 
@@ -146,6 +172,54 @@ func (h *Handlers) clearStateCookie(w http.ResponseWriter) {
 	})
 }
 
+// setReturnTo remembers where to send the user once they have signed in,
+// such as the invite link they opened while signed out.
+func setReturnTo(w http.ResponseWriter, path string) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     returnToCookie,
+		Value:    path,
+		Path:     "/",
+		MaxAge:   600, // 10 minutes, as long as the OAuth state
+		HttpOnly: true,
+		Secure:   os.Getenv("GO_ENV") == "production",
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// takeReturnTo clears and returns the path saved by setReturnTo, or "/".
+// Only local paths are honoured, so the cookie cannot send users off-site.
+func takeReturnTo(w http.ResponseWriter, r *http.Request) string {
+	cookie, err := r.Cookie(returnToCookie)
+	if err != nil {
+		return "/"
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     returnToCookie,
+		Value:    "",
+		Path:     "/",
+		MaxAge:   -1,
+		HttpOnly: true,
+		Secure:   os.Getenv("GO_ENV") == "production",
+	})
+
+	return localPath(cookie.Value)
+}
+
+// localPath returns path if it can only lead somewhere on this site, and
+// "/" otherwise. Browsers read a backslash as a slash and drop tabs and
+// newlines, so "/\evil.example" or "/\t/evil.example" would go off-site
+// as surely as "//evil.example"; url.Parse refuses control characters.
+func localPath(path string) string {
+	u, err := url.Parse(path)
+	if err != nil || u.Scheme != "" || u.Host != "" {
+		return "/"
+	}
+	if !strings.HasPrefix(path, "/") || strings.HasPrefix(path, "//") || strings.HasPrefix(path, "/\\") {
+		return "/"
+	}
+	return path
+}
+
 func (h *Handlers) getGitHubUser(ctx context.Context, code string) (*GitHubUser, error) {
 	oauthConfig := getOAuthConfig()
 	token, err := oauthConfig.Exchange(ctx, code)
@@ -182,19 +256,13 @@ func (h *Handlers) createOrUpdateUser(ctx context.Context, githubUser *GitHubUse
 	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
 	defer cancel()
 
-	user, err := h.app.DB.GetUserByGitHubUID(ctx, githubUser.ID)
+	githubUID := sql.NullInt64{Int64: githubUser.ID, Valid: true}
+	avatarURL := sql.NullString{String: githubUser.AvatarURL, Valid: githubUser.AvatarURL != ""}
+
+	user, err := h.app.DB.GetUserByGitHubUID(ctx, githubUID)
 	if err != nil {
 		if err == sql.ErrNoRows {
-			newUser, err := h.app.DB.CreateUser(ctx, db.CreateUserParams{
-				GithubUid: githubUser.ID,
-				Login:     githubUser.Login,
-				AvatarUrl: sql.NullString{String: githubUser.AvatarURL, Valid: githubUser.AvatarURL != ""},
-			})
-			if err != nil {
-				slog.Error("Failed to create new user", "error", err, "github_uid", githubUser.ID)
-				return nil, err
-			}
-			return &newUser, nil
+			return h.claimOrCreateUser(ctx, githubUser, githubUID, avatarURL)
 		}
 		return nil, fmt.Errorf("database error: %w", err)
 	}
@@ -202,7 +270,7 @@ func (h *Handlers) createOrUpdateUser(ctx context.Context, githubUser *GitHubUse
 	if user.Login != githubUser.Login || user.AvatarUrl.String != githubUser.AvatarURL {
 		err = h.app.DB.UpdateUser(ctx, db.UpdateUserParams{
 			Login:     githubUser.Login,
-			AvatarUrl: sql.NullString{String: githubUser.AvatarURL, Valid: githubUser.AvatarURL != ""},
+			AvatarUrl: avatarURL,
 			ID:        user.ID,
 		})
 		if err != nil {
@@ -212,3 +280,38 @@ func (h *Handlers) createOrUpdateUser(ctx context.Context, githubUser *GitHubUse
 
 	return &user, nil
 }
+
+// claimOrCreateUser handles a first sign-in. If a teammate already invited
+// this login, the pending row is claimed so its room memberships become
+// active; otherwise a fresh user is created.
+func (h *Handlers) claimOrCreateUser(ctx context.Context, githubUser *GitHubUser, githubUID sql.NullInt64, avatarURL sql.NullString) (*db.User, error) {
+	pending, err := h.app.DB.GetUserByLogin(ctx, githubUser.Login)
+	if err == nil && !pending.GithubUid.Valid {
+		user, err := h.app.DB.ClaimPendingUser(ctx, db.ClaimPendingUserParams{
+			GithubUid: githubUID,
+			Login:     githubUser.Login,
+			AvatarUrl: avatarURL,
+			ID:        pending.ID,
+		})
+		if err != nil {
+			slog.Error("Failed to claim pending user", "error", err, "user_id", pending.ID, "github_uid", githubUser.ID)
+			return nil, err
+		}
+		slog.Info("Invited user signed in for the first time", "user_id", user.ID, "login", user.Login)
+		return &user, nil
+	}
+	if err != nil && err != sql.ErrNoRows {
+		return nil, fmt.Errorf("database error: %w", err)
+	}
+
+	newUser, err := h.app.DB.CreateUser(ctx, db.CreateUserParams{
+		GithubUid: githubUID,
+		Login:     githubUser.Login,
+		AvatarUrl: avatarURL,
+	})
+	if err != nil {
+		slog.Error("Failed to create new user", "error", err, "github_uid", githubUser.ID)
+		return nil, err
+	}
+	return &newUser, nil
+}
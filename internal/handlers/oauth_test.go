@@ -10,6 +10,7 @@ import (
 	"testing"
 
 	"blazing/internal/app"
+	"blazing/internal/attachments"
 	"blazing/internal/db"
 	"blazing/internal/session"
 )
@@ -25,6 +26,10 @@ func setupTestApp(t *testing.T) (*app.App, *Handlers) {
 	if err != nil {
 		t.Fatalf("Failed to create test app: %v", err)
 	}
+	testApp.Attachments, err = attachments.NewLocal(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to create attachment store: %v", err)
+	}
 
 	h, err := New(testApp)
 	if err != nil {
@@ -197,7 +202,7 @@ func TestLogout(t *testing.T) {
 	req := httptest.NewRequest("GET", "/logout", nil)
 	w := httptest.NewRecorder()
 
-	if err := testApp.Session.Set(w, testUser); err != nil {
+	if err := testApp.Session.Set(w, req, testUser); err != nil {
 		t.Fatalf("Failed to set test session: %v", err)
 	}
 
@@ -225,6 +230,56 @@ func TestLogout(t *testing.T) {
 	}
 }
 
+func TestReturnTo(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+		want  string
+	}{
+		{"no cookie", "", "/"},
+		{"local path", "/invite/1.abc", "/invite/1.abc"},
+		{"absolute URL", "https://evil.example/", "/"},
+		{"protocol-relative", "//evil.example/", "/"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/auth/github/callback", nil)
+			if tt.value != "" {
+				req.AddCookie(&http.Cookie{Name: returnToCookie, Value: tt.value})
+			}
+			w := httptest.NewRecorder()
+			if got := takeReturnTo(w, req); got != tt.want {
+				t.Errorf("takeReturnTo() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLocalPath(t *testing.T) {
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"/invite/1.abc", "/invite/1.abc"},
+		{"/rooms/1?thread=2#message-3", "/rooms/1?thread=2#message-3"},
+		{"", "/"},
+		{"rooms/1", "/"},
+		{"https://evil.example/", "/"},
+		{"//evil.example/", "/"},
+		{"/\\evil.example", "/"},
+		{"/\t/evil.example", "/"},
+		{"/\n/evil.example", "/"},
+		{"javascript:alert(1)", "/"},
+	}
+
+	for _, tt := range tests {
+		if got := localPath(tt.path); got != tt.want {
+			t.Errorf("localPath(%q) = %q, want %q", tt.path, got, tt.want)
+		}
+	}
+}
+
 func TestCreateOrUpdateUser(t *testing.T) {
 	testApp, h := setupTestApp(t)
 
@@ -240,8 +295,8 @@ func TestCreateOrUpdateUser(t *testing.T) {
 		t.Fatalf("Failed to create user: %v", err)
 	}
 
-	if user.GithubUid != githubUser.ID {
-		t.Errorf("Expected GitHub UID %d, got %d", githubUser.ID, user.GithubUid)
+	if user.GithubUid.Int64 != githubUser.ID {
+		t.Errorf("Expected GitHub UID %d, got %d", githubUser.ID, user.GithubUid.Int64)
 	}
 
 	if user.Login != githubUser.Login {
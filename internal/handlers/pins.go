@@ -0,0 +1,191 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"blazing/internal/db"
+)
+
+// maxPinnedMessages keeps the pinned panel short enough to scan.
+const maxPinnedMessages = 25
+
+type PinView struct {
+	ID        int64
+	RoomID    int64
+	Login     string
+	AvatarURL string
+	Body      string
+	CreatedAt time.Time
+	PinnedBy  string
+	PinnedAt  time.Time
+}
+
+// PinnedData is the pinned panel of a room page.
+type PinnedData struct {
+	RoomID int64
+	Pins   []PinView
+}
+
+// PinMessage pins a message from the room timeline for everyone in the
+// room. Pinning one that already is changes nothing.
+func (h *Handlers) PinMessage(w http.ResponseWriter, r *http.Request) {
+	access, message, ok := h.requireRoomMessage(w, r)
+	if !ok {
+		return
+	}
+	if !access.can(permModerate) {
+		http.Error(w, "Only admins can pin messages", http.StatusForbidden)
+		return
+	}
+	if message.DeletedAt.Valid {
+		http.Error(w, "Message has been deleted", http.StatusGone)
+		return
+	}
+	// Replies only show inside their thread, so the panel could not link them
+	if message.Kind == messageKindSystem || message.ParentMessageID.Valid {
+		http.Error(w, "Only messages in the room timeline can be pinned", http.StatusUnprocessableEntity)
+		return
+	}
+
+	room, user := access.Room, access.User
+	pinned, err := h.loadPinned(r.Context(), room.ID)
+	if err != nil {
+		slog.Error("Failed to load pinned messages", "error", err, "room_id", room.ID)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if len(pinned.Pins) >= maxPinnedMessages && !pinned.has(message.ID) {
+		http.Error(w, fmt.Sprintf("A room can have at most %d pinned messages", maxPinnedMessages), http.StatusUnprocessableEntity)
+		return
+	}
+
+	err = h.app.DB.PinMessage(r.Context(), db.PinMessageParams{
+		RoomID:    room.ID,
+		MessageID: message.ID,
+		PinnedBy:  user.ID,
+	})
+	if err != nil {
+		slog.Error("Failed to pin message", "error", err, "message_id", message.ID, "user_id", user.ID)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	slog.Info("Message pinned", "room_id", room.ID, "message_id", message.ID, "pinned_by", user.ID)
+
+	h.publishPinned(w, r, room.ID, message.ID)
+}
+
+func (h *Handlers) UnpinMessage(w http.ResponseWriter, r *http.Request) {
+	access, message, ok := h.requireRoomMessage(w, r)
+	if !ok {
+		return
+	}
+	if !access.can(permModerate) {
+		http.Error(w, "Only admins can unpin messages", http.StatusForbidden)
+		return
+	}
+
+	room, user := access.Room, access.User
+	removed, err := h.app.DB.UnpinMessage(r.Context(), db.UnpinMessageParams{
+		RoomID:    room.ID,
+		MessageID: message.ID,
+	})
+	if err != nil {
+		slog.Error("Failed to unpin message", "error", err, "message_id", message.ID, "user_id", user.ID)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if removed > 0 {
+		slog.Info("Message unpinned", "room_id", room.ID, "message_id", message.ID, "unpinned_by", user.ID)
+	}
+
+	h.publishPinned(w, r, room.ID, message.ID)
+}
+
+// publishPinned pushes the message whose pin changed and the room's new
+// pinned panel to the room, and answers with the panel.
+func (h *Handlers) publishPinned(w http.ResponseWriter, r *http.Request, roomID, messageID int64) {
+	if _, err := h.pushStoredMessage(r.Context(), roomID, messageID, eventMessageUpdated); err != nil {
+		slog.Error("Failed to publish pinned message", "error", err, "message_id", messageID)
+	}
+
+	pinned, err := h.loadPinned(r.Context(), roomID)
+	if err != nil {
+		slog.Error("Failed to load pinned messages", "error", err, "room_id", roomID)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	html, err := h.renderFragment("pinned_list", pinned)
+	if err != nil {
+		slog.Error("Failed to render pinned messages", "error", err, "room_id", roomID)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	h.broadcast(roomID, eventPinsUpdated, messageEvent{ID: messageID, HTML: html})
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write([]byte(html))
+}
+
+// loadPinned returns a room's pinned messages, most recently pinned first.
+// Deleted messages drop out of the panel.
+func (h *Handlers) loadPinned(ctx context.Context, roomID int64) (PinnedData, error) {
+	rows, err := h.app.DB.GetPinnedMessages(ctx, roomID)
+	if err != nil {
+		return PinnedData{}, err
+	}
+
+	pinned := PinnedData{RoomID: roomID, Pins: make([]PinView, len(rows))}
+	for i, row := range rows {
+		pinned.Pins[i] = PinView{
+			ID:        row.ID,
+			RoomID:    roomID,
+			Login:     row.Login,
+			AvatarURL: row.AvatarUrl.String,
+			Body:      row.Body,
+			CreatedAt: row.CreatedAt.Time,
+			PinnedBy:  row.PinnedByLogin,
+			PinnedAt:  row.PinnedAt.Time,
+		}
+	}
+	return pinned, nil
+}
+
+func (p PinnedData) has(messageID int64) bool {
+	for _, pin := range p.Pins {
+		if pin.ID == messageID {
+			return true
+		}
+	}
+	return false
+}
+
+// attachPins marks which of a batch of messages are pinned.
+func (h *Handlers) attachPins(ctx context.Context, messages []MessageView) error {
+	ids := make([]int64, 0, len(messages))
+	index := make(map[int64]int, len(messages))
+	for i, m := range messages {
+		if !m.Deleted && m.ParentID == 0 {
+			ids = append(ids, m.ID)
+			index[m.ID] = i
+		}
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+
+	pinned, err := h.app.DB.GetPinnedMessageIDs(ctx, ids)
+	if err != nil {
+		return err
+	}
+
+	for _, id := range pinned {
+		messages[index[id]].Pinned = true
+	}
+	return nil
+}
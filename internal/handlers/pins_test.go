@@ -0,0 +1,133 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"blazing/internal/db"
+)
+
+func TestPinnedMessages(t *testing.T) {
+	testApp, h := setupTestApp(t)
+	ctx := context.Background()
+
+	alice := createTestUser(t, testApp, 1001, "alice")
+	bob := createTestUser(t, testApp, 1002, "bob")
+	mallory := createTestUser(t, testApp, 1003, "mallory")
+	room := createTestRoom(t, testApp, "general", alice, bob)
+
+	message, err := testApp.DB.CreateMessage(ctx, db.CreateMessageParams{RoomID: room.ID, UserID: bob.ID, Body: "runbook: https://example.com/runbook"})
+	if err != nil {
+		t.Fatalf("Failed to create message: %v", err)
+	}
+
+	pin := func(method string, user db.User, messageID int64) *httptest.ResponseRecorder {
+		t.Helper()
+		req := httptest.NewRequest(method, "/rooms/"+itoa(room.ID)+"/messages/"+itoa(messageID)+"/pin", nil)
+		req = withRoute(req, user, map[string]string{"roomID": itoa(room.ID), "messageID": itoa(messageID)})
+		w := httptest.NewRecorder()
+		if method == "PUT" {
+			h.PinMessage(w, req)
+		} else {
+			h.UnpinMessage(w, req)
+		}
+		return w
+	}
+
+	t.Run("admins pin for the room", func(t *testing.T) {
+		client := testApp.Hub.Register(room.ID, bob.ID)
+		defer testApp.Hub.Unregister(client)
+
+		w := pin("PUT", alice, message.ID)
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+		}
+		if !strings.Contains(w.Body.String(), `id="pinned-list"`) || !strings.Contains(w.Body.String(), "Pinned by alice") {
+			t.Errorf("Expected the pinned panel, got: %s", w.Body.String())
+		}
+
+		var updated messageEvent
+		if typ := nextEvent(t, client, &updated); typ != eventMessageUpdated || !strings.Contains(updated.HTML, "message-pinned") {
+			t.Errorf("Expected the message to show as pinned, got %s %s", typ, updated.HTML)
+		}
+		var panel messageEvent
+		if typ := nextEvent(t, client, &panel); typ != eventPinsUpdated || !strings.Contains(panel.HTML, "runbook") {
+			t.Errorf("Expected the room to get the new panel, got %s %s", typ, panel.HTML)
+		}
+
+		// Pinning twice changes nothing
+		if w := pin("PUT", alice, message.ID); w.Code != http.StatusOK {
+			t.Errorf("Expected pinning again to succeed, got %d", w.Code)
+		}
+		pinned, err := h.loadPinned(ctx, room.ID)
+		if err != nil || len(pinned.Pins) != 1 {
+			t.Errorf("Expected one pin, got %+v (%v)", pinned.Pins, err)
+		}
+	})
+
+	t.Run("room page shows pins", func(t *testing.T) {
+		req := withRoute(httptest.NewRequest("GET", "/rooms/"+itoa(room.ID), nil), bob, map[string]string{"roomID": itoa(room.ID)})
+		w := httptest.NewRecorder()
+		h.Room(w, req)
+		if !strings.Contains(w.Body.String(), "Pinned by alice") {
+			t.Errorf("Expected the pinned panel on the room page, got: %s", w.Body.String())
+		}
+	})
+
+	t.Run("only admins pin", func(t *testing.T) {
+		if w := pin("DELETE", bob, message.ID); w.Code != http.StatusForbidden {
+			t.Errorf("Expected status %d for a member, got %d", http.StatusForbidden, w.Code)
+		}
+		if w := pin("PUT", mallory, message.ID); w.Code != http.StatusForbidden {
+			t.Errorf("Expected status %d for a non-member, got %d", http.StatusForbidden, w.Code)
+		}
+	})
+
+	t.Run("only timeline messages", func(t *testing.T) {
+		reply, err := testApp.DB.CreateReply(ctx, db.CreateReplyParams{
+			RoomID:          room.ID,
+			UserID:          bob.ID,
+			Body:            "thanks",
+			ParentMessageID: sql.NullInt64{Int64: message.ID, Valid: true},
+		})
+		if err != nil {
+			t.Fatalf("Failed to create reply: %v", err)
+		}
+		if w := pin("PUT", alice, reply.ID); w.Code != http.StatusUnprocessableEntity {
+			t.Errorf("Expected status %d, got %d", http.StatusUnprocessableEntity, w.Code)
+		}
+	})
+
+	t.Run("limit", func(t *testing.T) {
+		for i := 1; i < maxPinnedMessages; i++ {
+			m, err := testApp.DB.CreateMessage(ctx, db.CreateMessageParams{RoomID: room.ID, UserID: alice.ID, Body: "note"})
+			if err != nil {
+				t.Fatalf("Failed to create message: %v", err)
+			}
+			if w := pin("PUT", alice, m.ID); w.Code != http.StatusOK {
+				t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+			}
+		}
+		extra, err := testApp.DB.CreateMessage(ctx, db.CreateMessageParams{RoomID: room.ID, UserID: alice.ID, Body: "one too many"})
+		if err != nil {
+			t.Fatalf("Failed to create message: %v", err)
+		}
+		if w := pin("PUT", alice, extra.ID); w.Code != http.StatusUnprocessableEntity {
+			t.Errorf("Expected status %d past the limit, got %d", http.StatusUnprocessableEntity, w.Code)
+		}
+	})
+
+	t.Run("unpin", func(t *testing.T) {
+		w := pin("DELETE", alice, message.ID)
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+		}
+		if strings.Contains(w.Body.String(), "runbook") {
+			t.Errorf("Expected the message to leave the panel, got: %s", w.Body.String())
+		}
+	})
+}
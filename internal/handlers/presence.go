@@ -0,0 +1,175 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log/slog"
+	"time"
+
+	"blazing/internal/db"
+	"blazing/internal/hub"
+	"blazing/internal/presence"
+	"blazing/internal/session"
+)
+
+// typingInterval throttles typing events per connection; clients send at
+// most one every few seconds while the user types.
+const typingInterval = 2 * time.Second
+
+// clientEvent is what browsers send over the socket. Only ephemeral signals
+// travel this way; anything persisted goes through HTTP.
+type clientEvent struct {
+	Type     string `json:"type"`
+	ThreadID int64  `json:"thread_id,omitempty"`
+}
+
+const (
+	clientEventIdle   = "presence.idle"
+	clientEventActive = "presence.active"
+	clientEventTyping = "typing"
+)
+
+type presenceEvent struct {
+	UserID int64           `json:"user_id"`
+	Status presence.Status `json:"status"`
+}
+
+type typingEvent struct {
+	UserID   int64  `json:"user_id"`
+	Login    string `json:"login"`
+	ThreadID int64  `json:"thread_id,omitempty"`
+}
+
+type roomPresenceEvent struct {
+	RoomID int64 `json:"room_id"`
+	Online int   `json:"online"`
+}
+
+// socketSession handles the events one connection sends.
+type socketSession struct {
+	h          *Handlers
+	client     *hub.Client
+	user       *session.User
+	presence   *presence.Conn
+	lastTyping time.Time
+}
+
+func (s *socketSession) handle(event clientEvent) {
+	switch event.Type {
+	case clientEventIdle:
+		s.presence.SetIdle(true)
+	case clientEventActive:
+		s.presence.SetIdle(false)
+	case clientEventTyping:
+		// Dashboard connections have no room to type in
+		if s.client.RoomID == 0 || time.Since(s.lastTyping) < typingInterval {
+			return
+		}
+		s.lastTyping = time.Now()
+		if !s.mayType(event.ThreadID) {
+			return
+		}
+		s.h.broadcast(s.client.RoomID, eventTyping, typingEvent{
+			UserID:   s.user.ID,
+			Login:    s.user.Login,
+			ThreadID: event.ThreadID,
+		})
+	}
+}
+
+// mayType reports whether the user could post what they are typing. Roles
+// and archiving change while sockets stay open, so the check is made per
+// event rather than once on connect; the throttle keeps it cheap.
+func (s *socketSession) mayType(threadID int64) bool {
+	ctx := context.Background()
+
+	access, err := s.h.memberAccess(ctx, s.user, s.client.RoomID)
+	if err != nil {
+		if !errors.Is(err, errNotRoomMember) && !errors.Is(err, sql.ErrNoRows) {
+			slog.Error("Failed to resolve room access for typing", "error", err, "room_id", s.client.RoomID, "user_id", s.user.ID)
+		}
+		return false
+	}
+	if !access.can(permPost) {
+		return false
+	}
+	if threadID == 0 {
+		return true
+	}
+
+	// Only live top-level messages of this room have threads to reply in
+	root, err := s.h.app.DB.GetRoomMessage(ctx, db.GetRoomMessageParams{
+		ID:     threadID,
+		RoomID: s.client.RoomID,
+	})
+	if err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			slog.Error("Failed to load thread root for typing", "error", err, "room_id", s.client.RoomID, "message_id", threadID)
+		}
+		return false
+	}
+	return !root.DeletedAt.Valid && !root.ParentMessageID.Valid
+}
+
+// presenceChanged fans a status change out to every room the user shares
+// with someone, and refreshes the online counts on those people's
+// dashboards. Changes are rare thanks to the tracker's grace period.
+func (h *Handlers) presenceChanged(userID int64, status presence.Status) {
+	ctx := context.Background()
+
+	mates, err := h.app.DB.GetUserRoomMates(ctx, userID)
+	if err != nil {
+		slog.Error("Failed to load room mates for presence", "error", err, "user_id", userID)
+		return
+	}
+
+	rooms := make(map[int64][]int64)
+	for _, mate := range mates {
+		rooms[mate.RoomID] = append(rooms[mate.RoomID], mate.UserID)
+	}
+
+	for roomID, members := range rooms {
+		h.broadcast(roomID, eventPresenceChanged, presenceEvent{UserID: userID, Status: status})
+
+		for _, member := range members {
+			if h.app.Hub.UserClientCount(member) == 0 {
+				continue
+			}
+			h.sendToUser(member, eventRoomPresence, roomPresenceEvent{
+				RoomID: roomID,
+				Online: h.app.Presence.CountPresent(othersIn(members, member, userID)),
+			})
+		}
+	}
+}
+
+// othersIn lists a room's members other than viewer, given the members
+// other than changed.
+func othersIn(members []int64, viewer, changed int64) []int64 {
+	others := make([]int64, 0, len(members))
+	for _, id := range members {
+		if id != viewer {
+			others = append(others, id)
+		}
+	}
+	return append(others, changed)
+}
+
+// attachPresence fills in how many other members of each room are around.
+func (h *Handlers) attachPresence(ctx context.Context, userID int64, rooms []RoomListItem) error {
+	mates, err := h.app.DB.GetUserRoomMates(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	members := make(map[int64][]int64)
+	for _, mate := range mates {
+		members[mate.RoomID] = append(members[mate.RoomID], mate.UserID)
+	}
+
+	for i := range rooms {
+		rooms[i].Online = h.app.Presence.CountPresent(members[rooms[i].ID])
+	}
+	return nil
+}
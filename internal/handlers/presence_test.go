@@ -0,0 +1,243 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"nhooyr.io/websocket"
+
+	"blazing/internal/db"
+	"blazing/internal/hub"
+	"blazing/internal/presence"
+	"blazing/internal/session"
+)
+
+// nextEvent decodes the next pending event for a hub client into data.
+func nextEvent(t *testing.T, client *hub.Client, data any) string {
+	t.Helper()
+
+	select {
+	case msg := <-client.Send():
+		var event struct {
+			Type string          `json:"type"`
+			Data json.RawMessage `json:"data"`
+		}
+		if err := json.Unmarshal(msg, &event); err != nil {
+			t.Fatalf("Failed to decode event: %v", err)
+		}
+		if data != nil {
+			if err := json.Unmarshal(event.Data, data); err != nil {
+				t.Fatalf("Failed to decode %s data: %v", event.Type, err)
+			}
+		}
+		return event.Type
+	default:
+		t.Fatal("Expected an event")
+		return ""
+	}
+}
+
+func TestPresenceChanged(t *testing.T) {
+	testApp, h := setupTestApp(t)
+
+	alice := createTestUser(t, testApp, 1001, "alice")
+	bob := createTestUser(t, testApp, 1002, "bob")
+	room := createTestRoom(t, testApp, "general", alice, bob)
+	createTestRoom(t, testApp, "bob-only", bob)
+
+	roomClient := testApp.Hub.Register(room.ID, alice.ID)
+	defer testApp.Hub.Unregister(roomClient)
+	dashboard := testApp.Hub.RegisterUser(alice.ID)
+	defer testApp.Hub.Unregister(dashboard)
+
+	conn := testApp.Presence.Connect(bob.ID)
+	defer conn.Close()
+
+	var changed presenceEvent
+	if typ := nextEvent(t, roomClient, &changed); typ != eventPresenceChanged {
+		t.Fatalf("Expected %s, got %s", eventPresenceChanged, typ)
+	}
+	if changed.UserID != bob.ID || changed.Status != presence.Online {
+		t.Errorf("Expected bob online, got %+v", changed)
+	}
+
+	var online roomPresenceEvent
+	if typ := nextEvent(t, dashboard, &online); typ != eventRoomPresence {
+		t.Fatalf("Expected %s, got %s", eventRoomPresence, typ)
+	}
+	if online.RoomID != room.ID || online.Online != 1 {
+		t.Errorf("Expected one other member online in general, got %+v", online)
+	}
+	if len(dashboard.Send()) != 0 {
+		t.Error("Expected no updates for rooms alice is not in")
+	}
+
+	conn.SetIdle(true)
+	if nextEvent(t, roomClient, &changed); changed.Status != presence.Idle {
+		t.Errorf("Expected bob idle, got %+v", changed)
+	}
+
+	rooms, err := h.loadRoomList(context.Background(), alice.ID)
+	if err != nil {
+		t.Fatalf("Failed to load rooms: %v", err)
+	}
+	if len(rooms) != 1 || rooms[0].Online != 1 {
+		t.Errorf("Expected idle members to count as around, got %+v", rooms)
+	}
+
+	members, err := h.loadMembers(context.Background(), roomAccess{Room: room, User: &session.User{ID: alice.ID, Login: alice.Login}, Role: roleOwner})
+	if err != nil {
+		t.Fatalf("Failed to load members: %v", err)
+	}
+	for _, member := range members {
+		want := presence.Offline
+		if member.UserID == bob.ID {
+			want = presence.Idle
+		}
+		if member.Status != want {
+			t.Errorf("Expected %s to be %s, got %s", member.Login, want, member.Status)
+		}
+	}
+}
+
+func TestTypingIsThrottled(t *testing.T) {
+	testApp, h := setupTestApp(t)
+
+	alice := createTestUser(t, testApp, 1001, "alice")
+	bob := createTestUser(t, testApp, 1002, "bob")
+	room := createTestRoom(t, testApp, "general", alice, bob)
+
+	listener := testApp.Hub.Register(room.ID, alice.ID)
+	defer testApp.Hub.Unregister(listener)
+
+	typist := testApp.Hub.Register(room.ID, bob.ID)
+	defer testApp.Hub.Unregister(typist)
+	conn := testApp.Presence.Connect(bob.ID)
+	defer conn.Close()
+	for len(listener.Send()) > 0 {
+		<-listener.Send()
+	}
+
+	root, err := testApp.DB.CreateMessage(context.Background(), db.CreateMessageParams{RoomID: room.ID, UserID: alice.ID, Body: "root"})
+	if err != nil {
+		t.Fatalf("Failed to create message: %v", err)
+	}
+
+	session := &socketSession{h: h, client: typist, user: &session.User{ID: bob.ID, Login: "bob"}, presence: conn}
+	session.handle(clientEvent{Type: clientEventTyping, ThreadID: root.ID})
+	session.handle(clientEvent{Type: clientEventTyping})
+
+	var typing typingEvent
+	if typ := nextEvent(t, listener, &typing); typ != eventTyping {
+		t.Fatalf("Expected %s, got %s", eventTyping, typ)
+	}
+	if typing.UserID != bob.ID || typing.Login != "bob" || typing.ThreadID != root.ID {
+		t.Errorf("Unexpected typing event: %+v", typing)
+	}
+	if len(listener.Send()) != 0 {
+		t.Error("Expected repeated typing events to be throttled")
+	}
+
+	session.lastTyping = time.Now().Add(-typingInterval)
+	session.handle(clientEvent{Type: clientEventTyping})
+	typing = typingEvent{}
+	if nextEvent(t, listener, &typing); typing.ThreadID != 0 {
+		t.Errorf("Expected a room typing event, got %+v", typing)
+	}
+}
+
+func TestTypingRequiresPost(t *testing.T) {
+	testApp, h := setupTestApp(t)
+	ctx := context.Background()
+
+	alice := createTestUser(t, testApp, 1001, "alice")
+	bob := createTestUser(t, testApp, 1002, "bob")
+	room := createTestRoom(t, testApp, "general", alice, bob)
+	other := createTestRoom(t, testApp, "elsewhere", alice)
+
+	elsewhere, err := testApp.DB.CreateMessage(ctx, db.CreateMessageParams{RoomID: other.ID, UserID: alice.ID, Body: "hidden"})
+	if err != nil {
+		t.Fatalf("Failed to create message: %v", err)
+	}
+
+	listener := testApp.Hub.Register(room.ID, alice.ID)
+	defer testApp.Hub.Unregister(listener)
+	typist := testApp.Hub.Register(room.ID, bob.ID)
+	defer testApp.Hub.Unregister(typist)
+
+	session := &socketSession{h: h, client: typist, user: &session.User{ID: bob.ID, Login: "bob"}}
+	typing := func(threadID int64) {
+		session.lastTyping = time.Time{}
+		session.handle(clientEvent{Type: clientEventTyping, ThreadID: threadID})
+	}
+
+	typing(elsewhere.ID)
+	typing(elsewhere.ID + 1000)
+
+	if err := testApp.DB.UpdateMembershipRole(ctx, db.UpdateMembershipRoleParams{Role: roleReadOnly, RoomID: room.ID, UserID: bob.ID}); err != nil {
+		t.Fatalf("Failed to update role: %v", err)
+	}
+	typing(0)
+
+	if err := testApp.DB.UpdateMembershipRole(ctx, db.UpdateMembershipRoleParams{Role: roleMember, RoomID: room.ID, UserID: bob.ID}); err != nil {
+		t.Fatalf("Failed to update role: %v", err)
+	}
+	if err := testApp.DB.ArchiveRoom(ctx, room.ID); err != nil {
+		t.Fatalf("Failed to archive room: %v", err)
+	}
+	typing(0)
+
+	if n := len(listener.Send()); n != 0 {
+		t.Errorf("Expected no typing events, got %d", n)
+	}
+}
+
+func TestWebSocketClientEvents(t *testing.T) {
+	testApp, h := setupTestApp(t)
+
+	alice := createTestUser(t, testApp, 1001, "alice")
+	bob := createTestUser(t, testApp, 1002, "bob")
+	room := createTestRoom(t, testApp, "general", alice, bob)
+
+	r := chi.NewRouter()
+	r.With(h.RequireAuth).Get("/ws/{roomID}", h.WebSocket)
+	srv := httptest.NewServer(r)
+	t.Cleanup(srv.Close)
+
+	listener := testApp.Hub.Register(room.ID, alice.ID)
+	defer testApp.Hub.Unregister(listener)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	conn, _, err := websocket.Dial(ctx, "ws"+strings.TrimPrefix(srv.URL, "http")+"/ws/"+itoa(room.ID), &websocket.DialOptions{
+		HTTPHeader: cookieHeader(sessionCookies(t, testApp, bob)),
+	})
+	if err != nil {
+		t.Fatalf("Failed to dial: %v", err)
+	}
+	defer conn.CloseNow()
+
+	waitFor(t, func() bool { return testApp.Presence.Status(bob.ID) == presence.Online })
+
+	for _, msg := range []string{`not json`, `{"type":"presence.idle"}`, `{"type":"typing"}`} {
+		if err := conn.Write(ctx, websocket.MessageText, []byte(msg)); err != nil {
+			t.Fatalf("Failed to write: %v", err)
+		}
+	}
+
+	waitFor(t, func() bool { return testApp.Presence.Status(bob.ID) == presence.Idle })
+	waitFor(t, func() bool {
+		for len(listener.Send()) > 0 {
+			if nextEvent(t, listener, nil) == eventTyping {
+				return true
+			}
+		}
+		return false
+	})
+}
@@ -0,0 +1,139 @@
+package handlers
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"blazing/internal/db"
+)
+
+// maxReactionLength allows for multi-codepoint emoji such as flags and
+// ZWJ sequences while keeping arbitrary text out.
+const maxReactionLength = 16
+
+// reactionPalette is offered in the picker; any valid emoji is accepted.
+var reactionPalette = []string{"👍", "❤️", "😂", "🎉", "😮", "🙏", "👀", "🚀"}
+
+type ReactionView struct {
+	Emoji   string
+	Count   int64
+	UserIDs string // space-separated, so the page can style the viewer's own
+	Logins  string
+}
+
+func (h *Handlers) ToggleReaction(w http.ResponseWriter, r *http.Request) {
+	access, message, ok := h.requireRoomMessage(w, r)
+	if !ok {
+		return
+	}
+	if !access.can(permReact) {
+		http.Error(w, "You cannot react in this room", http.StatusForbidden)
+		return
+	}
+	if message.DeletedAt.Valid {
+		http.Error(w, "Message has been deleted", http.StatusGone)
+		return
+	}
+	if message.Kind == messageKindSystem {
+		http.Error(w, "System messages cannot be reacted to", http.StatusUnprocessableEntity)
+		return
+	}
+
+	emoji := strings.TrimSpace(r.FormValue("emoji"))
+	if !validReaction(emoji) {
+		http.Error(w, "Invalid reaction", http.StatusUnprocessableEntity)
+		return
+	}
+
+	room, user := access.Room, access.User
+	removed, err := h.app.DB.DeleteMessageReaction(r.Context(), db.DeleteMessageReactionParams{
+		MessageID: message.ID,
+		UserID:    user.ID,
+		Emoji:     emoji,
+	})
+	if err == nil && removed == 0 {
+		err = h.app.DB.CreateMessageReaction(r.Context(), db.CreateMessageReactionParams{
+			MessageID: message.ID,
+			UserID:    user.ID,
+			Emoji:     emoji,
+		})
+	}
+	if err != nil {
+		slog.Error("Failed to toggle reaction", "error", err, "message_id", message.ID, "user_id", user.ID)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	view, err := h.storedMessageView(r.Context(), message)
+	if err != nil {
+		slog.Error("Failed to load reactions", "error", err, "message_id", message.ID)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	html, err := h.renderFragment("reactions", view)
+	if err != nil {
+		slog.Error("Failed to render reactions", "error", err, "message_id", message.ID)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	h.broadcast(room.ID, eventReactionsUpdated, messageEvent{ID: message.ID, HTML: html})
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write([]byte(html))
+}
+
+// attachReactions fills in the reaction counts for a batch of messages with
+// a single query.
+func (h *Handlers) attachReactions(ctx context.Context, messages []MessageView) error {
+	ids := make([]int64, 0, len(messages))
+	index := make(map[int64]int, len(messages))
+	for i, m := range messages {
+		if !m.Deleted {
+			ids = append(ids, m.ID)
+			index[m.ID] = i
+		}
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+
+	rows, err := h.app.DB.GetMessageReactions(ctx, ids)
+	if err != nil {
+		return err
+	}
+
+	for _, row := range rows {
+		i := index[row.MessageID]
+		messages[i].Reactions = append(messages[i].Reactions, ReactionView{
+			Emoji:   row.Emoji,
+			Count:   row.Count,
+			UserIDs: row.UserIds,
+			Logins:  row.Logins,
+		})
+	}
+	return nil
+}
+
+// validReaction accepts short strings made only of emoji codepoints:
+// symbols plus the modifiers, joiners and variation selectors that
+// combine them.
+func validReaction(emoji string) bool {
+	if emoji == "" || utf8.RuneCountInString(emoji) > maxReactionLength {
+		return false
+	}
+	for _, r := range emoji {
+		switch {
+		case unicode.In(r, unicode.So, unicode.Sk, unicode.Mn, unicode.Me):
+		case r == '\u200d', unicode.In(r, unicode.Variation_Selector):
+		default:
+			return false
+		}
+	}
+	return true
+}
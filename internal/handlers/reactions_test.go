@@ -0,0 +1,136 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"blazing/internal/db"
+)
+
+func TestValidReaction(t *testing.T) {
+	for _, emoji := range []string{"👍", "❤️", "👍🏽", "👩‍💻", "🇬🇧"} {
+		if !validReaction(emoji) {
+			t.Errorf("Expected %q to be a valid reaction", emoji)
+		}
+	}
+	for _, emoji := range []string{"", "ok", "<b>", "👍 ", strings.Repeat("👍", 20)} {
+		if validReaction(emoji) {
+			t.Errorf("Expected %q to be rejected", emoji)
+		}
+	}
+}
+
+func TestToggleReaction(t *testing.T) {
+	testApp, h := setupTestApp(t)
+	ctx := context.Background()
+
+	alice := createTestUser(t, testApp, 1001, "alice")
+	bob := createTestUser(t, testApp, 1002, "bob")
+	room := createTestRoom(t, testApp, "general", alice, bob)
+
+	message, err := testApp.DB.CreateMessage(ctx, db.CreateMessageParams{RoomID: room.ID, UserID: alice.ID, Body: "shipped!"})
+	if err != nil {
+		t.Fatalf("Failed to create message: %v", err)
+	}
+
+	reactTo := func(messageID int64, user db.User, emoji string) *httptest.ResponseRecorder {
+		form := url.Values{"emoji": {emoji}}
+		req := httptest.NewRequest("POST", "/rooms/"+itoa(room.ID)+"/messages/"+itoa(messageID)+"/reactions", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req = withRoute(req, user, map[string]string{"roomID": itoa(room.ID), "messageID": itoa(messageID)})
+		w := httptest.NewRecorder()
+		h.ToggleReaction(w, req)
+		return w
+	}
+	react := func(user db.User, emoji string) *httptest.ResponseRecorder {
+		return reactTo(message.ID, user, emoji)
+	}
+
+	reactionCounts := func() map[string]int64 {
+		rows, err := testApp.DB.GetMessageReactions(ctx, []int64{message.ID})
+		if err != nil {
+			t.Fatalf("Failed to load reactions: %v", err)
+		}
+		counts := make(map[string]int64)
+		for _, row := range rows {
+			counts[row.Emoji] = row.Count
+		}
+		return counts
+	}
+
+	t.Run("adds and broadcasts", func(t *testing.T) {
+		client := testApp.Hub.Register(room.ID, bob.ID)
+		defer testApp.Hub.Unregister(client)
+
+		if w := react(alice, "🎉"); w.Code != http.StatusOK {
+			t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+		}
+		w := react(bob, "🎉")
+		if !strings.Contains(w.Body.String(), `title="alice, bob"`) {
+			t.Errorf("Expected reactors in the fragment, got: %s", w.Body.String())
+		}
+
+		var event struct {
+			Type string       `json:"type"`
+			Data messageEvent `json:"data"`
+		}
+		if err := json.Unmarshal(<-client.Send(), &event); err != nil {
+			t.Fatalf("Failed to decode event: %v", err)
+		}
+		if event.Type != eventReactionsUpdated || event.Data.ID != message.ID {
+			t.Errorf("Expected %s for message %d, got %s for %d", eventReactionsUpdated, message.ID, event.Type, event.Data.ID)
+		}
+
+		if got := reactionCounts()["🎉"]; got != 2 {
+			t.Errorf("Expected 2 reactions, got %d", got)
+		}
+	})
+
+	t.Run("toggles off", func(t *testing.T) {
+		react(bob, "🎉")
+		if got := reactionCounts()["🎉"]; got != 1 {
+			t.Errorf("Expected 1 reaction after toggling off, got %d", got)
+		}
+	})
+
+	t.Run("included in history", func(t *testing.T) {
+		page, err := h.loadMessagesBefore(ctx, room.ID, 0)
+		if err != nil {
+			t.Fatalf("Failed to load messages: %v", err)
+		}
+		reactions := page.Messages[0].Reactions
+		if len(reactions) != 1 || reactions[0].Emoji != "🎉" || reactions[0].UserIDs != itoa(alice.ID) {
+			t.Errorf("Expected alice's reaction on the page, got %+v", reactions)
+		}
+	})
+
+	t.Run("rejects text", func(t *testing.T) {
+		if w := react(alice, "lol"); w.Code != http.StatusUnprocessableEntity {
+			t.Errorf("Expected status %d, got %d", http.StatusUnprocessableEntity, w.Code)
+		}
+	})
+
+	t.Run("rejects system messages", func(t *testing.T) {
+		system, err := testApp.DB.CreateSystemMessage(ctx, db.CreateSystemMessageParams{RoomID: room.ID, UserID: alice.ID, Body: "joined the room"})
+		if err != nil {
+			t.Fatalf("Failed to create system message: %v", err)
+		}
+		if w := reactTo(system.ID, bob, "👍"); w.Code != http.StatusUnprocessableEntity {
+			t.Errorf("Expected status %d, got %d", http.StatusUnprocessableEntity, w.Code)
+		}
+	})
+
+	t.Run("rejects deleted messages", func(t *testing.T) {
+		if err := testApp.DB.SoftDeleteMessage(ctx, message.ID); err != nil {
+			t.Fatalf("Failed to delete message: %v", err)
+		}
+		if w := react(alice, "👍"); w.Code != http.StatusGone {
+			t.Errorf("Expected status %d, got %d", http.StatusGone, w.Code)
+		}
+	})
+}
@@ -0,0 +1,196 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+
+	"blazing/internal/db"
+)
+
+// UpdateMemberRole changes another member's role and returns their
+// refreshed entry in the member list.
+func (h *Handlers) UpdateMemberRole(w http.ResponseWriter, r *http.Request) {
+	access, ok := h.requireRoomMember(w, r)
+	if !ok {
+		return
+	}
+	room, user := access.Room, access.User
+
+	memberID, err := strconv.ParseInt(chi.URLParam(r, "userID"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+
+	role := r.FormValue("role")
+	if !validRole(role) {
+		http.Error(w, "Invalid role", http.StatusUnprocessableEntity)
+		return
+	}
+
+	membership, ok := h.requireMembership(w, r, room.ID, memberID)
+	if !ok {
+		return
+	}
+	if !access.canSetRole(memberID, membership.Role, role) {
+		http.Error(w, "You cannot change this member's role", http.StatusForbidden)
+		return
+	}
+
+	if role != membership.Role {
+		err = h.app.DB.UpdateMembershipRole(r.Context(), db.UpdateMembershipRoleParams{
+			Role:   role,
+			RoomID: room.ID,
+			UserID: memberID,
+		})
+		if err != nil {
+			slog.Error("Failed to update member role", "error", err, "room_id", room.ID, "user_id", memberID)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		slog.Info("Member role changed", "room_id", room.ID, "user_id", memberID, "from", membership.Role, "to", role, "changed_by", user.ID)
+		h.broadcast(room.ID, eventPermissionsChanged, permissionsEvent{UserIDs: []int64{memberID}})
+	}
+
+	members, err := h.loadMembers(r.Context(), access)
+	if err != nil {
+		slog.Error("Failed to load room members", "error", err, "room_id", room.ID)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	for _, member := range members {
+		if member.UserID == memberID {
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			if err := h.roomTemplate.ExecuteTemplate(w, "member_item", member); err != nil {
+				slog.Error("Failed to render member item", "error", err, "room_id", room.ID)
+			}
+			return
+		}
+	}
+	http.Error(w, "Member not found", http.StatusNotFound)
+}
+
+// TransferOwnership hands the room to another signed-in member. The old
+// owner stays on as an admin.
+func (h *Handlers) TransferOwnership(w http.ResponseWriter, r *http.Request) {
+	access, ok := h.requireRoomMember(w, r)
+	if !ok {
+		return
+	}
+	room, user := access.Room, access.User
+	if !access.can(permTransfer) {
+		http.Error(w, "Only the owner can transfer this room", http.StatusForbidden)
+		return
+	}
+
+	memberID, err := strconv.ParseInt(r.FormValue("user_id"), 10, 64)
+	if err != nil || memberID == user.ID {
+		http.Error(w, "Invalid user ID", http.StatusUnprocessableEntity)
+		return
+	}
+
+	if _, ok := h.requireMembership(w, r, room.ID, memberID); !ok {
+		return
+	}
+	member, err := h.app.DB.GetUserByID(r.Context(), memberID)
+	if err != nil {
+		slog.Error("Failed to load user", "error", err, "user_id", memberID)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if !member.GithubUid.Valid {
+		http.Error(w, member.Login+" has not signed in yet", http.StatusConflict)
+		return
+	}
+
+	err = h.app.WithTx(r.Context(), func(q *db.Queries) error {
+		if err := q.UpdateMembershipRole(r.Context(), db.UpdateMembershipRoleParams{
+			Role:   roleAdmin,
+			RoomID: room.ID,
+			UserID: user.ID,
+		}); err != nil {
+			return fmt.Errorf("failed to demote owner: %w", err)
+		}
+		return q.UpdateMembershipRole(r.Context(), db.UpdateMembershipRoleParams{
+			Role:   roleOwner,
+			RoomID: room.ID,
+			UserID: memberID,
+		})
+	})
+	if err != nil {
+		slog.Error("Failed to transfer ownership", "error", err, "room_id", room.ID, "user_id", memberID)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	slog.Info("Room ownership transferred", "room_id", room.ID, "from", user.ID, "to", memberID)
+
+	h.broadcast(room.ID, eventPermissionsChanged, permissionsEvent{UserIDs: []int64{user.ID, memberID}})
+
+	w.Header().Set("HX-Refresh", "true")
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// UpdateRoomPolicies sets who may post and invite, and returns the
+// refreshed settings form.
+func (h *Handlers) UpdateRoomPolicies(w http.ResponseWriter, r *http.Request) {
+	access, ok := h.requireRoomMember(w, r)
+	if !ok {
+		return
+	}
+	room, user := access.Room, access.User
+	if !access.can(permConfigure) {
+		http.Error(w, "Only admins can change room permissions", http.StatusForbidden)
+		return
+	}
+
+	postPolicy, invitePolicy := r.FormValue("post_policy"), r.FormValue("invite_policy")
+	if !validPolicy(postPolicy) || !validPolicy(invitePolicy) {
+		http.Error(w, "Invalid policy", http.StatusUnprocessableEntity)
+		return
+	}
+
+	err := h.app.DB.UpdateRoomPolicies(r.Context(), db.UpdateRoomPoliciesParams{
+		PostPolicy:   postPolicy,
+		InvitePolicy: invitePolicy,
+		ID:           room.ID,
+	})
+	if err != nil {
+		slog.Error("Failed to update room policies", "error", err, "room_id", room.ID)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	slog.Info("Room policies changed", "room_id", room.ID, "post", postPolicy, "invite", invitePolicy, "changed_by", user.ID)
+
+	room.PostPolicy, room.InvitePolicy = postPolicy, invitePolicy
+	h.broadcast(room.ID, eventPermissionsChanged, permissionsEvent{})
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := h.roomTemplate.ExecuteTemplate(w, "room_policies", room); err != nil {
+		slog.Error("Failed to render room policies", "error", err, "room_id", room.ID)
+	}
+}
+
+// requireMembership loads another user's membership of the room, writing
+// the error response if they are not a member.
+func (h *Handlers) requireMembership(w http.ResponseWriter, r *http.Request, roomID, userID int64) (db.RoomMembership, bool) {
+	membership, err := h.app.DB.GetRoomMembership(r.Context(), db.GetRoomMembershipParams{
+		RoomID: roomID,
+		UserID: userID,
+	})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "Member not found", http.StatusNotFound)
+			return db.RoomMembership{}, false
+		}
+		slog.Error("Failed to load membership", "error", err, "room_id", roomID, "user_id", userID)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return db.RoomMembership{}, false
+	}
+	return membership, true
+}
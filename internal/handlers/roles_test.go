@@ -0,0 +1,202 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"blazing/internal/db"
+)
+
+func TestRoomRoles(t *testing.T) {
+	testApp, h := setupTestApp(t)
+	ctx := context.Background()
+
+	alice := createTestUser(t, testApp, 1001, "alice")
+	bob := createTestUser(t, testApp, 1002, "bob")
+	carol := createTestUser(t, testApp, 1003, "carol")
+	room := createTestRoom(t, testApp, "general", alice, bob, carol)
+
+	roleOf := func(user db.User) string {
+		t.Helper()
+		membership, err := testApp.DB.GetRoomMembership(ctx, db.GetRoomMembershipParams{RoomID: room.ID, UserID: user.ID})
+		if err != nil {
+			t.Fatalf("Failed to load membership: %v", err)
+		}
+		return membership.Role
+	}
+
+	setRole := func(actor, member db.User, role string) *httptest.ResponseRecorder {
+		t.Helper()
+		form := url.Values{"role": {role}}
+		req := httptest.NewRequest("PUT", "/rooms/"+itoa(room.ID)+"/members/"+itoa(member.ID)+"/role", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req = withRoute(req, actor, map[string]string{"roomID": itoa(room.ID), "userID": itoa(member.ID)})
+		w := httptest.NewRecorder()
+		h.UpdateMemberRole(w, req)
+		return w
+	}
+
+	setPolicies := func(actor db.User, post, invite string) *httptest.ResponseRecorder {
+		t.Helper()
+		form := url.Values{"post_policy": {post}, "invite_policy": {invite}}
+		req := httptest.NewRequest("PUT", "/rooms/"+itoa(room.ID)+"/policies", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req = withRoute(req, actor, map[string]string{"roomID": itoa(room.ID)})
+		w := httptest.NewRecorder()
+		h.UpdateRoomPolicies(w, req)
+		return w
+	}
+
+	t.Run("creator owns the room", func(t *testing.T) {
+		if role := roleOf(alice); role != roleOwner {
+			t.Errorf("Expected alice to be the owner, got %q", role)
+		}
+	})
+
+	t.Run("owner promotes an admin", func(t *testing.T) {
+		client := testApp.Hub.Register(room.ID, carol.ID)
+		defer testApp.Hub.Unregister(client)
+
+		w := setRole(alice, bob, roleAdmin)
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+		}
+		if !strings.Contains(w.Body.String(), `<span class="badge">admin</span>`) {
+			t.Errorf("Expected the admin badge, got: %s", w.Body.String())
+		}
+		if role := roleOf(bob); role != roleAdmin {
+			t.Errorf("Expected bob to be an admin, got %q", role)
+		}
+
+		var event permissionsEvent
+		if typ := nextEvent(t, client, &event); typ != eventPermissionsChanged || len(event.UserIDs) != 1 || event.UserIDs[0] != bob.ID {
+			t.Errorf("Expected a permissions event for bob, got %s %+v", typ, event)
+		}
+	})
+
+	t.Run("admins cannot manage admins", func(t *testing.T) {
+		if w := setRole(bob, carol, roleAdmin); w.Code != http.StatusForbidden {
+			t.Errorf("Expected status %d, got %d", http.StatusForbidden, w.Code)
+		}
+		if w := setRole(bob, alice, roleMember); w.Code != http.StatusForbidden {
+			t.Errorf("Expected status %d, got %d", http.StatusForbidden, w.Code)
+		}
+		if w := setRole(alice, bob, roleOwner); w.Code != http.StatusUnprocessableEntity {
+			t.Errorf("Expected status %d, got %d", http.StatusUnprocessableEntity, w.Code)
+		}
+	})
+
+	t.Run("read-only members cannot post but can react", func(t *testing.T) {
+		if w := setRole(bob, carol, roleReadOnly); w.Code != http.StatusOK {
+			t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+		}
+
+		if w := postMessage(t, h, carol, room.ID, "hello?"); w.Code != http.StatusForbidden {
+			t.Errorf("Expected status %d, got %d", http.StatusForbidden, w.Code)
+		}
+
+		if w := postMessage(t, h, alice, room.ID, "announcement"); w.Code != http.StatusCreated {
+			t.Fatalf("Expected status %d, got %d", http.StatusCreated, w.Code)
+		}
+		page, err := h.loadMessagesBefore(ctx, room.ID, 0)
+		if err != nil {
+			t.Fatalf("Failed to load messages: %v", err)
+		}
+		messageID := page.Messages[len(page.Messages)-1].ID
+
+		form := url.Values{"emoji": {"👍"}}
+		req := httptest.NewRequest("POST", "/rooms/"+itoa(room.ID)+"/messages/"+itoa(messageID)+"/reactions", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req = withRoute(req, carol, map[string]string{"roomID": itoa(room.ID), "messageID": itoa(messageID)})
+		w := httptest.NewRecorder()
+		h.ToggleReaction(w, req)
+		if w.Code != http.StatusOK {
+			t.Errorf("Expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+		}
+
+		req = withRoute(httptest.NewRequest("GET", "/rooms/"+itoa(room.ID), nil), carol, map[string]string{"roomID": itoa(room.ID)})
+		w = httptest.NewRecorder()
+		h.Room(w, req)
+		if strings.Contains(w.Body.String(), `hx-post="/rooms/`+itoa(room.ID)+`/messages"`) {
+			t.Error("Expected no composer for a read-only member")
+		}
+		if !strings.Contains(w.Body.String(), "read-only access") {
+			t.Errorf("Expected a read-only note, got: %s", w.Body.String())
+		}
+
+		setRole(bob, carol, roleMember)
+	})
+
+	t.Run("announcement rooms", func(t *testing.T) {
+		if w := setPolicies(carol, policyAdmins, policyAdmins); w.Code != http.StatusForbidden {
+			t.Errorf("Expected status %d, got %d", http.StatusForbidden, w.Code)
+		}
+		if w := setPolicies(bob, "everyone", policyMembers); w.Code != http.StatusUnprocessableEntity {
+			t.Errorf("Expected status %d, got %d", http.StatusUnprocessableEntity, w.Code)
+		}
+
+		w := setPolicies(bob, policyAdmins, policyAdmins)
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+		}
+		if !strings.Contains(w.Body.String(), `<option value="admins" selected>`) {
+			t.Errorf("Expected the updated settings form, got: %s", w.Body.String())
+		}
+
+		if w := postMessage(t, h, carol, room.ID, "can I?"); w.Code != http.StatusForbidden {
+			t.Errorf("Expected members to be unable to post, got %d", w.Code)
+		}
+		if w := postMessage(t, h, bob, room.ID, "release is out"); w.Code != http.StatusCreated {
+			t.Errorf("Expected admins to post, got %d", w.Code)
+		}
+
+		form := url.Values{"login": {"dave"}}
+		req := httptest.NewRequest("POST", "/rooms/"+itoa(room.ID)+"/members", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req = withRoute(req, carol, map[string]string{"roomID": itoa(room.ID)})
+		w = httptest.NewRecorder()
+		h.InviteMember(w, req)
+		if w.Code != http.StatusForbidden {
+			t.Errorf("Expected members to be unable to invite, got %d", w.Code)
+		}
+
+		setPolicies(bob, policyMembers, policyMembers)
+	})
+
+	t.Run("owner transfers the room", func(t *testing.T) {
+		transfer := func(actor, member db.User) *httptest.ResponseRecorder {
+			form := url.Values{"user_id": {itoa(member.ID)}}
+			req := httptest.NewRequest("POST", "/rooms/"+itoa(room.ID)+"/owner", strings.NewReader(form.Encode()))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			req = withRoute(req, actor, map[string]string{"roomID": itoa(room.ID)})
+			w := httptest.NewRecorder()
+			h.TransferOwnership(w, req)
+			return w
+		}
+
+		if w := transfer(bob, carol); w.Code != http.StatusForbidden {
+			t.Errorf("Expected admins to be unable to transfer, got %d", w.Code)
+		}
+
+		w := transfer(alice, carol)
+		if w.Code != http.StatusNoContent {
+			t.Fatalf("Expected status %d, got %d: %s", http.StatusNoContent, w.Code, w.Body.String())
+		}
+		if roleOf(carol) != roleOwner || roleOf(alice) != roleAdmin {
+			t.Errorf("Expected carol to own the room and alice to be an admin, got %q and %q", roleOf(carol), roleOf(alice))
+		}
+
+		// The owner cannot leave, but the old owner now can
+		req := httptest.NewRequest("DELETE", "/rooms/"+itoa(room.ID)+"/members/"+itoa(carol.ID), nil)
+		req = withRoute(req, carol, map[string]string{"roomID": itoa(room.ID), "userID": itoa(carol.ID)})
+		w = httptest.NewRecorder()
+		h.RemoveMember(w, req)
+		if w.Code != http.StatusForbidden {
+			t.Errorf("Expected status %d, got %d", http.StatusForbidden, w.Code)
+		}
+	})
+}
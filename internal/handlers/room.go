@@ -1,26 +1,127 @@
 package handlers
 
 import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
 	"log/slog"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
 
 	"github.com/go-chi/chi/v5"
+
+	"blazing/internal/db"
+	"blazing/internal/session"
 )
 
+const maxRoomNameLength = 80
+
+type RoomData struct {
+	User         *session.User
+	Room         db.Room
+	Title        string
+	Direct       bool
+	Archived     bool
+	Role         string
+	Page         MessagePage
+	Members      []MemberView
+	Invites      []InviteView
+	Pinned       PinnedData
+	Notify       NotificationSettings
+	IsAdmin      bool
+	CanPost      bool
+	CanInvite    bool
+	CanConfigure bool
+	CanManage    bool
+	Thread       *ThreadData
+}
+
 func (h *Handlers) Room(w http.ResponseWriter, r *http.Request) {
-	roomID := chi.URLParam(r, "roomID")
-	user, ok := GetUserFromContext(r)
+	access, ok := h.requireRoomMember(w, r)
 	if !ok {
-		slog.Error("User not found in context for room access")
+		return
+	}
+
+	h.renderRoom(w, r, access, nil)
+}
+
+// renderRoom writes the full room page, optionally with a thread open
+// alongside the timeline.
+func (h *Handlers) renderRoom(w http.ResponseWriter, r *http.Request, access roomAccess, thread *ThreadData) {
+	room, user := access.Room, access.User
+	page, err := h.loadMessagesBefore(r.Context(), room.ID, 0)
+	if err != nil {
+		slog.Error("Failed to load room messages", "error", err, "room_id", room.ID)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	if err := h.attachSaved(r.Context(), user.ID, page.Messages); err != nil {
+		slog.Error("Failed to load saved messages", "error", err, "room_id", room.ID, "user_id", user.ID)
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
 		return
 	}
 
-	_ = roomID
-	_ = user
+	pinned, err := h.loadPinned(r.Context(), room.ID)
+	if err != nil {
+		slog.Error("Failed to load pinned messages", "error", err, "room_id", room.ID)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	members, err := h.loadMembers(r.Context(), access)
+	if err != nil {
+		slog.Error("Failed to load room members", "error", err, "room_id", room.ID)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
 
-	// TODO: Verify membership and render chat room with message history
-	http.Error(w, "Not implemented", http.StatusNotImplemented)
+	var invites []InviteView
+	if access.can(permInvite) {
+		invites, err = h.loadInvites(r, access)
+		if err != nil {
+			slog.Error("Failed to load invites", "error", err, "room_id", room.ID)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	// Opening the room shows its latest page, so that much has been read
+	if n := len(page.Messages); n > 0 {
+		if err := h.markRead(r.Context(), room.ID, user.ID, page.Messages[n-1].ID); err != nil {
+			slog.Error("Failed to mark room read", "error", err, "room_id", room.ID, "user_id", user.ID)
+		}
+	}
+
+	data := RoomData{
+		User:         user,
+		Room:         room,
+		Title:        roomTitle(room, members),
+		Direct:       isDirect(room),
+		Archived:     room.ArchivedAt.Valid,
+		Role:         access.Role,
+		Page:         page,
+		Members:      members,
+		Invites:      invites,
+		Pinned:       pinned,
+		Notify:       access.Notifications,
+		IsAdmin:      access.can(permModerate),
+		CanPost:      access.can(permPost),
+		CanInvite:    access.can(permInvite),
+		CanConfigure: access.can(permConfigure),
+		CanManage:    access.can(permManage),
+		Thread:       thread,
+	}
+
+	if err := h.roomTemplate.ExecuteTemplate(w, "room", data); err != nil {
+		slog.Error("Failed to render room template", "error", err, "room_id", room.ID, "user_id", user.ID)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+	}
 }
 
 func (h *Handlers) CreateRoom(w http.ResponseWriter, r *http.Request) {
@@ -31,24 +132,127 @@ func (h *Handlers) CreateRoom(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	_ = user
+	name, err := validateRoomName(r.FormValue("name"))
+	if err != nil {
+		http.Error(w, "Invalid room name: "+err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+
+	var room db.Room
+	err = h.app.WithTx(r.Context(), func(q *db.Queries) error {
+		var err error
+		room, err = q.CreateRoom(r.Context(), db.CreateRoomParams{
+			Name:      name,
+			CreatorID: user.ID,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to insert room: %w", err)
+		}
+
+		return q.CreateRoomMembership(r.Context(), db.CreateRoomMembershipParams{
+			RoomID: room.ID,
+			UserID: user.ID,
+			Role:   roleOwner,
+		})
+	})
+	if err != nil {
+		slog.Error("Failed to create room", "error", err, "user_id", user.ID)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
 
-	// TODO: Create new room with authenticated user as creator and seed member
-	http.Error(w, "Not implemented", http.StatusNotImplemented)
+	// Plain form posts land in the new room; HTMX gets the list item to insert
+	if r.Header.Get("HX-Request") == "" {
+		http.Redirect(w, r, fmt.Sprintf("/rooms/%d", room.ID), http.StatusSeeOther)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(http.StatusCreated)
+	if err := h.dashboardTemplate.ExecuteTemplate(w, "room_created", RoomListItem{ID: room.ID, Name: room.Name}); err != nil {
+		slog.Error("Failed to render room item", "error", err, "room_id", room.ID)
+	}
+}
+
+func validateRoomName(raw string) (string, error) {
+	name := strings.TrimSpace(raw)
+	if name == "" {
+		return "", errors.New("name is required")
+	}
+	if utf8.RuneCountInString(name) > maxRoomNameLength {
+		return "", fmt.Errorf("name must be at most %d characters", maxRoomNameLength)
+	}
+	for _, c := range name {
+		if unicode.IsControl(c) {
+			return "", errors.New("name cannot contain control characters")
+		}
+	}
+	return name, nil
 }
 
-func (h *Handlers) WebSocket(w http.ResponseWriter, r *http.Request) {
-	roomID := chi.URLParam(r, "roomID")
+// requireRoomMember resolves the {roomID} URL parameter and confirms the
+// authenticated user belongs to it, returning their role there. On failure
+// the response has already been written and ok is false.
+func (h *Handlers) requireRoomMember(w http.ResponseWriter, r *http.Request) (roomAccess, bool) {
 	user, ok := GetUserFromContext(r)
 	if !ok {
-		slog.Error("User not found in context for WebSocket")
+		slog.Error("User not found in context for room access")
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
-		return
+		return roomAccess{}, false
+	}
+
+	roomID, err := parseRoomID(r)
+	if err != nil {
+		http.Error(w, "Invalid room ID", http.StatusBadRequest)
+		return roomAccess{}, false
+	}
+
+	access, err := h.memberAccess(r.Context(), user, roomID)
+	if err != nil {
+		switch {
+		case errors.Is(err, errNotRoomMember):
+			http.Error(w, "Not a member of this room", http.StatusForbidden)
+		case errors.Is(err, sql.ErrNoRows):
+			http.Error(w, "Room not found", http.StatusNotFound)
+		default:
+			slog.Error("Failed to resolve room access", "error", err, "room_id", roomID, "user_id", user.ID)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+		}
+		return roomAccess{}, false
 	}
 
-	_ = roomID
-	_ = user
+	return access, true
+}
+
+var errNotRoomMember = errors.New("not a member of this room")
+
+// memberAccess loads the user's standing in a room outside of a request
+// handler, such as for events arriving over a socket.
+func (h *Handlers) memberAccess(ctx context.Context, user *session.User, roomID int64) (roomAccess, error) {
+	membership, err := h.app.DB.GetRoomMembership(ctx, db.GetRoomMembershipParams{
+		RoomID: roomID,
+		UserID: user.ID,
+	})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return roomAccess{}, errNotRoomMember
+		}
+		return roomAccess{}, fmt.Errorf("failed to check room membership: %w", err)
+	}
+
+	room, err := h.app.DB.GetRoom(ctx, roomID)
+	if err != nil {
+		return roomAccess{}, fmt.Errorf("failed to load room: %w", err)
+	}
+
+	return roomAccess{
+		Room:          room,
+		User:          user,
+		Role:          membership.Role,
+		Notifications: notificationSettings(room.ID, membership.NotifyLevel, membership.MutedUntil, time.Now()),
+	}, nil
+}
 
-	// TODO: Upgrade to WebSocket and handle real-time messaging for room members
-	http.Error(w, "Not implemented", http.StatusNotImplemented)
+func parseRoomID(r *http.Request) (int64, error) {
+	return strconv.ParseInt(chi.URLParam(r, "roomID"), 10, 64)
 }
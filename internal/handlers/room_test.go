@@ -0,0 +1,129 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"blazing/internal/db"
+)
+
+func TestRoom(t *testing.T) {
+	testApp, h := setupTestApp(t)
+
+	alice := createTestUser(t, testApp, 1001, "alice")
+	bob := createTestUser(t, testApp, 1002, "bob")
+	room := createTestRoom(t, testApp, "general", alice)
+
+	if _, err := testApp.DB.CreateMessage(context.Background(), db.CreateMessageParams{
+		RoomID: room.ID, UserID: alice.ID, Body: "first post",
+	}); err != nil {
+		t.Fatalf("Failed to create message: %v", err)
+	}
+
+	t.Run("renders history for members", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/rooms/"+itoa(room.ID), nil)
+		req = withRoute(req, alice, map[string]string{"roomID": itoa(room.ID)})
+		w := httptest.NewRecorder()
+
+		h.Room(w, req)
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected status %d, got %d", http.StatusOK, w.Code)
+		}
+
+		body := w.Body.String()
+		if !strings.Contains(body, "general") {
+			t.Error("Expected room name in page")
+		}
+		if !strings.Contains(body, "first post") {
+			t.Error("Expected message history in page")
+		}
+	})
+
+	t.Run("forbids non-members", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/rooms/"+itoa(room.ID), nil)
+		req = withRoute(req, bob, map[string]string{"roomID": itoa(room.ID)})
+		w := httptest.NewRecorder()
+
+		h.Room(w, req)
+
+		if w.Code != http.StatusForbidden {
+			t.Errorf("Expected status %d, got %d", http.StatusForbidden, w.Code)
+		}
+	})
+
+	t.Run("rejects invalid room ID", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/rooms/abc", nil)
+		req = withRoute(req, alice, map[string]string{"roomID": "abc"})
+		w := httptest.NewRecorder()
+
+		h.Room(w, req)
+
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("Expected status %d, got %d", http.StatusBadRequest, w.Code)
+		}
+	})
+}
+
+func TestCreateRoom(t *testing.T) {
+	testApp, h := setupTestApp(t)
+
+	alice := createTestUser(t, testApp, 1001, "alice")
+
+	newRequest := func(name string, htmx bool) *http.Request {
+		form := url.Values{"name": {name}}
+		req := httptest.NewRequest("POST", "/rooms", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		if htmx {
+			req.Header.Set("HX-Request", "true")
+		}
+		return withRoute(req, alice, nil)
+	}
+
+	t.Run("creates room and seeds creator membership", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		h.CreateRoom(w, newRequest("  Launch plans  ", true))
+
+		if w.Code != http.StatusCreated {
+			t.Fatalf("Expected status %d, got %d: %s", http.StatusCreated, w.Code, w.Body.String())
+		}
+		if !strings.Contains(w.Body.String(), "Launch plans") {
+			t.Errorf("Expected room item in response, got: %s", w.Body.String())
+		}
+
+		rooms, err := testApp.DB.GetUserRooms(context.Background(), alice.ID)
+		if err != nil {
+			t.Fatalf("Failed to load rooms: %v", err)
+		}
+		if len(rooms) != 1 || rooms[0].Name != "Launch plans" || rooms[0].CreatorID != alice.ID {
+			t.Errorf("Expected creator to be seeded as member, got %+v", rooms)
+		}
+	})
+
+	t.Run("redirects plain form posts into the room", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		h.CreateRoom(w, newRequest("Standup", false))
+
+		if w.Code != http.StatusSeeOther {
+			t.Fatalf("Expected status %d, got %d", http.StatusSeeOther, w.Code)
+		}
+		if location := w.Header().Get("Location"); !strings.HasPrefix(location, "/rooms/") {
+			t.Errorf("Expected redirect to the new room, got %s", location)
+		}
+	})
+
+	t.Run("validates name", func(t *testing.T) {
+		for _, name := range []string{"", "   ", strings.Repeat("x", maxRoomNameLength+1), "bad\x00name"} {
+			w := httptest.NewRecorder()
+			h.CreateRoom(w, newRequest(name, true))
+
+			if w.Code != http.StatusUnprocessableEntity {
+				t.Errorf("Expected status %d for %q, got %d", http.StatusUnprocessableEntity, name, w.Code)
+			}
+		}
+	})
+}
@@ -0,0 +1,151 @@
+package handlers
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"blazing/internal/db"
+	"blazing/internal/session"
+)
+
+// savedMessageLimit bounds the saved messages page; older saves stay saved
+// but drop off the end.
+const savedMessageLimit = 200
+
+type SavedMessageView struct {
+	ID        int64
+	ThreadID  int64 // set when the message is a thread reply
+	RoomID    int64
+	RoomName  string
+	Login     string
+	AvatarURL string
+	Body      string
+	CreatedAt time.Time
+}
+
+type SavedData struct {
+	User     *session.User
+	Messages []SavedMessageView
+}
+
+// SavedMessages lists the messages the user has saved, newest save first,
+// from the rooms they still belong to.
+func (h *Handlers) SavedMessages(w http.ResponseWriter, r *http.Request) {
+	user, ok := GetUserFromContext(r)
+	if !ok {
+		slog.Error("User not found in context for saved messages")
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	rows, err := h.app.DB.GetSavedMessages(r.Context(), db.GetSavedMessagesParams{
+		UserID: user.ID,
+		Limit:  savedMessageLimit,
+	})
+	if err != nil {
+		slog.Error("Failed to load saved messages", "error", err, "user_id", user.ID)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	data := SavedData{User: user, Messages: make([]SavedMessageView, len(rows))}
+	for i, row := range rows {
+		data.Messages[i] = SavedMessageView{
+			ID:        row.ID,
+			ThreadID:  row.ParentMessageID.Int64,
+			RoomID:    row.RoomID,
+			RoomName:  row.RoomName,
+			Login:     row.Login,
+			AvatarURL: row.AvatarUrl.String,
+			Body:      row.Body,
+			CreatedAt: row.CreatedAt.Time,
+		}
+	}
+
+	if err := h.savedTemplate.ExecuteTemplate(w, "saved", data); err != nil {
+		slog.Error("Failed to render saved template", "error", err, "user_id", user.ID)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+	}
+}
+
+// SaveMessage adds a message to the user's saved messages and answers with
+// the button to unsave it.
+func (h *Handlers) SaveMessage(w http.ResponseWriter, r *http.Request) {
+	access, message, ok := h.requireRoomMessage(w, r)
+	if !ok {
+		return
+	}
+	if message.DeletedAt.Valid {
+		http.Error(w, "Message has been deleted", http.StatusGone)
+		return
+	}
+	if message.Kind == messageKindSystem {
+		http.Error(w, "System messages cannot be saved", http.StatusUnprocessableEntity)
+		return
+	}
+
+	user := access.User
+	err := h.app.DB.SaveMessage(r.Context(), db.SaveMessageParams{
+		UserID:    user.ID,
+		MessageID: message.ID,
+	})
+	if err != nil {
+		slog.Error("Failed to save message", "error", err, "message_id", message.ID, "user_id", user.ID)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	h.renderMessageFragment(w, "message_save", MessageView{ID: message.ID, RoomID: message.RoomID, Saved: true})
+}
+
+func (h *Handlers) UnsaveMessage(w http.ResponseWriter, r *http.Request) {
+	access, message, ok := h.requireRoomMessage(w, r)
+	if !ok {
+		return
+	}
+
+	user := access.User
+	err := h.app.DB.UnsaveMessage(r.Context(), db.UnsaveMessageParams{
+		UserID:    user.ID,
+		MessageID: message.ID,
+	})
+	if err != nil {
+		slog.Error("Failed to unsave message", "error", err, "message_id", message.ID, "user_id", user.ID)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	h.renderMessageFragment(w, "message_save", MessageView{ID: message.ID, RoomID: message.RoomID})
+}
+
+// attachSaved marks which of a batch of messages the viewer has saved.
+// Saves are private, so this only applies to pages rendered for one user;
+// messages pushed to the whole room always show as unsaved.
+func (h *Handlers) attachSaved(ctx context.Context, userID int64, messages []MessageView) error {
+	ids := make([]int64, 0, len(messages))
+	index := make(map[int64]int, len(messages))
+	for i, m := range messages {
+		if !m.Deleted && !m.System {
+			ids = append(ids, m.ID)
+			index[m.ID] = i
+		}
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+
+	saved, err := h.app.DB.GetSavedMessageIDs(ctx, db.GetSavedMessageIDsParams{
+		UserID:     userID,
+		MessageIds: ids,
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, id := range saved {
+		messages[index[id]].Saved = true
+	}
+	return nil
+}
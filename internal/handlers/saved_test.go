@@ -0,0 +1,112 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"blazing/internal/db"
+)
+
+func TestSavedMessages(t *testing.T) {
+	testApp, h := setupTestApp(t)
+	ctx := context.Background()
+
+	alice := createTestUser(t, testApp, 1001, "alice")
+	bob := createTestUser(t, testApp, 1002, "bob")
+	general := createTestRoom(t, testApp, "general", alice, bob)
+	ops := createTestRoom(t, testApp, "ops", bob, alice)
+
+	tip, err := testApp.DB.CreateMessage(ctx, db.CreateMessageParams{RoomID: general.ID, UserID: bob.ID, Body: "use `make dev`"})
+	if err != nil {
+		t.Fatalf("Failed to create message: %v", err)
+	}
+	alert, err := testApp.DB.CreateMessage(ctx, db.CreateMessageParams{RoomID: ops.ID, UserID: bob.ID, Body: "pager rota moved"})
+	if err != nil {
+		t.Fatalf("Failed to create message: %v", err)
+	}
+
+	save := func(method string, user db.User, room db.Room, messageID int64) *httptest.ResponseRecorder {
+		t.Helper()
+		req := httptest.NewRequest(method, "/rooms/"+itoa(room.ID)+"/messages/"+itoa(messageID)+"/saved", nil)
+		req = withRoute(req, user, map[string]string{"roomID": itoa(room.ID), "messageID": itoa(messageID)})
+		w := httptest.NewRecorder()
+		if method == "PUT" {
+			h.SaveMessage(w, req)
+		} else {
+			h.UnsaveMessage(w, req)
+		}
+		return w
+	}
+
+	savedPage := func(user db.User) string {
+		t.Helper()
+		req := withRoute(httptest.NewRequest("GET", "/saved", nil), user, nil)
+		w := httptest.NewRecorder()
+		h.SavedMessages(w, req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+		}
+		return w.Body.String()
+	}
+
+	t.Run("saves across rooms", func(t *testing.T) {
+		w := save("PUT", alice, general, tip.ID)
+		if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "hx-delete") {
+			t.Fatalf("Expected the unsave button, got %d: %s", w.Code, w.Body.String())
+		}
+		if w := save("PUT", alice, ops, alert.ID); w.Code != http.StatusOK {
+			t.Fatalf("Expected status %d, got %d", http.StatusOK, w.Code)
+		}
+
+		page := savedPage(alice)
+		if !strings.Contains(page, "<code>make dev</code>") || !strings.Contains(page, "pager rota moved") {
+			t.Errorf("Expected both saved messages, got: %s", page)
+		}
+		if strings.Index(page, "pager rota") > strings.Index(page, "make dev") {
+			t.Error("Expected the latest save first")
+		}
+		if page := savedPage(bob); strings.Contains(page, "make dev") {
+			t.Error("Expected saves to be private")
+		}
+	})
+
+	t.Run("room page shows the viewer's saves", func(t *testing.T) {
+		render := func(user db.User) string {
+			req := withRoute(httptest.NewRequest("GET", "/rooms/"+itoa(general.ID), nil), user, map[string]string{"roomID": itoa(general.ID)})
+			w := httptest.NewRecorder()
+			h.Room(w, req)
+			return w.Body.String()
+		}
+		if !strings.Contains(render(alice), "Remove from your saved messages") {
+			t.Error("Expected alice to see her save")
+		}
+		if strings.Contains(render(bob), "Remove from your saved messages") {
+			t.Error("Expected bob not to see alice's save")
+		}
+	})
+
+	t.Run("only from rooms still joined", func(t *testing.T) {
+		if err := testApp.DB.DeleteRoomMembership(ctx, db.DeleteRoomMembershipParams{RoomID: ops.ID, UserID: alice.ID}); err != nil {
+			t.Fatalf("Failed to remove membership: %v", err)
+		}
+		if page := savedPage(alice); strings.Contains(page, "pager rota moved") {
+			t.Errorf("Expected messages from a room left behind to be hidden, got: %s", page)
+		}
+		if w := save("PUT", alice, ops, alert.ID); w.Code != http.StatusForbidden {
+			t.Errorf("Expected status %d, got %d", http.StatusForbidden, w.Code)
+		}
+	})
+
+	t.Run("unsave", func(t *testing.T) {
+		w := save("DELETE", alice, general, tip.ID)
+		if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "hx-put") {
+			t.Fatalf("Expected the save button, got %d: %s", w.Code, w.Body.String())
+		}
+		if page := savedPage(alice); strings.Contains(page, "make dev") {
+			t.Errorf("Expected the message to be gone, got: %s", page)
+		}
+	})
+}
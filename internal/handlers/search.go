@@ -0,0 +1,210 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"html"
+	"html/template"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"blazing/internal/db"
+	"blazing/internal/session"
+)
+
+const (
+	searchResultLimit = 50
+	searchDateLayout  = "2006-01-02"
+)
+
+// snippet() wraps matches in these control characters so highlighting can
+// be applied after the message text has been escaped.
+const (
+	snippetMatchStart = "\x02"
+	snippetMatchEnd   = "\x03"
+)
+
+type SearchFilters struct {
+	Query  string
+	RoomID int64
+	Author string
+	From   string
+	To     string
+}
+
+type SearchResultView struct {
+	MessageID int64
+	ThreadID  int64 // set when the match is a thread reply
+	RoomID    int64
+	RoomName  string
+	Login     string
+	AvatarURL string
+	CreatedAt time.Time
+	Snippet   template.HTML
+}
+
+type SearchData struct {
+	User     *session.User
+	Rooms    []db.Room
+	Filters  SearchFilters
+	Results  []SearchResultView
+	Searched bool
+	Error    string
+}
+
+func (h *Handlers) Search(w http.ResponseWriter, r *http.Request) {
+	user, ok := GetUserFromContext(r)
+	if !ok {
+		slog.Error("User not found in context for search")
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	if !h.app.SearchEnabled {
+		http.Error(w, "Search is not available: this server was built without SQLite FTS5", http.StatusServiceUnavailable)
+		return
+	}
+
+	query := r.URL.Query()
+	data := SearchData{
+		User: user,
+		Filters: SearchFilters{
+			Query:  strings.TrimSpace(query.Get("q")),
+			Author: strings.TrimPrefix(strings.TrimSpace(query.Get("author")), "@"),
+			From:   query.Get("from"),
+			To:     query.Get("to"),
+		},
+	}
+
+	rooms, err := h.app.DB.GetUserRooms(r.Context(), user.ID)
+	if err != nil {
+		slog.Error("Failed to load user rooms", "error", err, "user_id", user.ID)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	data.Rooms = rooms
+
+	if data.Filters.Query != "" {
+		params, err := h.searchParams(r, user, &data.Filters)
+		if err != nil {
+			data.Error = err.Error()
+		} else {
+			data.Results, err = h.runSearch(r, params)
+			if err != nil {
+				slog.Error("Search failed", "error", err, "user_id", user.ID)
+				http.Error(w, "Internal server error", http.StatusInternalServerError)
+				return
+			}
+			data.Searched = true
+		}
+	}
+
+	name := "search"
+	if r.Header.Get("HX-Request") != "" {
+		name = "search_results"
+	}
+
+	if err := h.searchTemplate.ExecuteTemplate(w, name, data); err != nil {
+		slog.Error("Failed to render search template", "error", err, "user_id", user.ID)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+	}
+}
+
+// searchParams validates the filters. Membership scoping is enforced by the
+// query itself; room and author only narrow it further.
+func (h *Handlers) searchParams(r *http.Request, user *session.User, filters *SearchFilters) (db.SearchMessagesParams, error) {
+	params := db.SearchMessagesParams{
+		UserID: user.ID,
+		Query:  ftsQuery(filters.Query),
+		Limit:  searchResultLimit,
+	}
+	if params.Query == "" {
+		return params, errors.New("enter some words to search for")
+	}
+
+	if raw := r.URL.Query().Get("room"); raw != "" {
+		roomID, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			return params, errors.New("unknown room")
+		}
+		filters.RoomID = roomID
+		params.RoomID = sql.NullInt64{Int64: roomID, Valid: true}
+	}
+
+	if filters.Author != "" {
+		author, err := h.app.DB.GetUserByLogin(r.Context(), filters.Author)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return params, errors.New("no user named " + filters.Author)
+			}
+			return params, err
+		}
+		params.AuthorID = sql.NullInt64{Int64: author.ID, Valid: true}
+	}
+
+	if filters.From != "" {
+		from, err := time.Parse(searchDateLayout, filters.From)
+		if err != nil {
+			return params, errors.New("invalid from date")
+		}
+		params.Since = sql.NullTime{Time: from, Valid: true}
+	}
+
+	if filters.To != "" {
+		to, err := time.Parse(searchDateLayout, filters.To)
+		if err != nil {
+			return params, errors.New("invalid to date")
+		}
+		// The end date is inclusive, so search up to the following midnight
+		params.Until = sql.NullTime{Time: to.AddDate(0, 0, 1), Valid: true}
+	}
+
+	return params, nil
+}
+
+func (h *Handlers) runSearch(r *http.Request, params db.SearchMessagesParams) ([]SearchResultView, error) {
+	rows, err := h.app.DB.SearchMessages(r.Context(), params)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]SearchResultView, len(rows))
+	for i, row := range rows {
+		results[i] = SearchResultView{
+			MessageID: row.ID,
+			ThreadID:  row.ParentMessageID.Int64,
+			RoomID:    row.RoomID,
+			RoomName:  row.RoomName,
+			Login:     row.Login,
+			AvatarURL: row.AvatarUrl.String,
+			CreatedAt: row.CreatedAt.Time,
+			Snippet:   highlightSnippet(row.Snippet),
+		}
+	}
+	return results, nil
+}
+
+// ftsQuery turns free text into an FTS5 query that cannot be a syntax
+// error: every word is quoted, and the last one matches as a prefix so
+// results appear while the user is still typing.
+func ftsQuery(input string) string {
+	words := strings.Fields(strings.ReplaceAll(input, `"`, " "))
+	for i, word := range words {
+		words[i] = `"` + word + `"`
+	}
+	if len(words) > 0 {
+		words[len(words)-1] += "*"
+	}
+	return strings.Join(words, " ")
+}
+
+func highlightSnippet(snippet string) template.HTML {
+	escaped := html.EscapeString(snippet)
+	if strings.Count(escaped, snippetMatchStart) != strings.Count(escaped, snippetMatchEnd) {
+		return template.HTML(strings.NewReplacer(snippetMatchStart, "", snippetMatchEnd, "").Replace(escaped))
+	}
+	return template.HTML(strings.NewReplacer(snippetMatchStart, "<mark>", snippetMatchEnd, "</mark>").Replace(escaped))
+}
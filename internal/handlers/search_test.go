@@ -0,0 +1,135 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"blazing/internal/db"
+)
+
+func TestFTSQuery(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"deploy", `"deploy"*`},
+		{"  release   notes ", `"release" "notes"*`},
+		{`say "hi" OR NOT`, `"say" "hi" "OR" "NOT"*`},
+		{`"""`, ""},
+	}
+
+	for _, tt := range tests {
+		if got := ftsQuery(tt.input); got != tt.want {
+			t.Errorf("ftsQuery(%q) = %q, want %q", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestHighlightSnippet(t *testing.T) {
+	got := highlightSnippet("use <b>" + snippetMatchStart + "sqlite" + snippetMatchEnd + "</b>")
+	want := "use &lt;b&gt;<mark>sqlite</mark>&lt;/b&gt;"
+	if string(got) != want {
+		t.Errorf("highlightSnippet() = %q, want %q", got, want)
+	}
+
+	if got := highlightSnippet(snippetMatchStart + "unbalanced"); string(got) != "unbalanced" {
+		t.Errorf("Expected unbalanced markers to be dropped, got %q", got)
+	}
+}
+
+func TestSearch(t *testing.T) {
+	testApp, h := setupTestApp(t)
+
+	ctx := context.Background()
+	alice := createTestUser(t, testApp, 1001, "alice")
+	bob := createTestUser(t, testApp, 1002, "bob")
+
+	if !testApp.SearchEnabled {
+		req := withRoute(httptest.NewRequest("GET", "/search?q=deploy", nil), alice, nil)
+		w := httptest.NewRecorder()
+		h.Search(w, req)
+		if w.Code != http.StatusServiceUnavailable {
+			t.Fatalf("Expected status %d without FTS5, got %d", http.StatusServiceUnavailable, w.Code)
+		}
+		return
+	}
+
+	shared := createTestRoom(t, testApp, "shared", alice, bob)
+	private := createTestRoom(t, testApp, "bob-only", bob)
+
+	for _, m := range []struct {
+		room db.Room
+		user db.User
+		body string
+	}{
+		{shared, alice, "We decided to deploy on Fridays"},
+		{shared, bob, "Deployment checklist lives in the wiki"},
+		{private, bob, "secret deploy plan"},
+	} {
+		if _, err := testApp.DB.CreateMessage(ctx, db.CreateMessageParams{RoomID: m.room.ID, UserID: m.user.ID, Body: m.body}); err != nil {
+			t.Fatalf("Failed to create message: %v", err)
+		}
+	}
+
+	search := func(query string) string {
+		req := httptest.NewRequest("GET", "/search?"+query, nil)
+		req.Header.Set("HX-Request", "true")
+		req = withRoute(req, alice, nil)
+		w := httptest.NewRecorder()
+		h.Search(w, req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+		}
+		return w.Body.String()
+	}
+
+	t.Run("only searches rooms the user belongs to", func(t *testing.T) {
+		body := search("q=deploy")
+		if !strings.Contains(body, "<mark>deploy</mark>") {
+			t.Errorf("Expected highlighted match, got: %s", body)
+		}
+		if !strings.Contains(body, "<mark>Deployment</mark>") {
+			t.Errorf("Expected prefix match, got: %s", body)
+		}
+		if strings.Contains(body, "secret") {
+			t.Error("Search leaked a message from a room the user is not in")
+		}
+	})
+
+	t.Run("filters by author", func(t *testing.T) {
+		body := search("q=deploy&author=bob")
+		if strings.Contains(body, "Fridays") || !strings.Contains(body, "checklist") {
+			t.Errorf("Expected only bob's message, got: %s", body)
+		}
+	})
+
+	t.Run("filters by date", func(t *testing.T) {
+		body := search("q=deploy&to=2000-01-01")
+		if !strings.Contains(body, "No messages match") {
+			t.Errorf("Expected no results before 2000, got: %s", body)
+		}
+	})
+
+	t.Run("skips deleted messages", func(t *testing.T) {
+		deleted, err := testApp.DB.CreateMessage(ctx, db.CreateMessageParams{RoomID: shared.ID, UserID: alice.ID, Body: "deploy keys pasted by mistake"})
+		if err != nil {
+			t.Fatalf("Failed to create message: %v", err)
+		}
+		if err := testApp.DB.SoftDeleteMessage(ctx, deleted.ID); err != nil {
+			t.Fatalf("Failed to delete message: %v", err)
+		}
+		if body := search("q=keys"); strings.Contains(body, "mistake") {
+			t.Errorf("Expected deleted message to be hidden, got: %s", body)
+		}
+	})
+
+	t.Run("reports bad filters", func(t *testing.T) {
+		body := search("q=deploy&author=nobody")
+		if !strings.Contains(body, "no user named nobody") {
+			t.Errorf("Expected filter error, got: %s", body)
+		}
+	})
+}
@@ -0,0 +1,239 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"blazing/internal/db"
+	"blazing/internal/session"
+)
+
+type SessionView struct {
+	ID         int64
+	Device     string
+	IP         string
+	CreatedAt  time.Time
+	LastSeenAt time.Time
+	Current    bool
+}
+
+type SessionsData struct {
+	User       *session.User
+	ServerSide bool
+	IsAdmin    bool
+	Sessions   []SessionView
+}
+
+// RevokedData confirms an admin signed someone out everywhere.
+type RevokedData struct {
+	Login string
+	Count int64
+}
+
+// Sessions lists the devices the user is signed in on. Without server-side
+// sessions there is nothing to list, and the page says how to turn them on.
+func (h *Handlers) Sessions(w http.ResponseWriter, r *http.Request) {
+	user, ok := GetUserFromContext(r)
+	if !ok {
+		slog.Error("User not found in context for sessions")
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	data := SessionsData{
+		User:       user,
+		ServerSide: h.app.Session.ServerSide(),
+		IsAdmin:    h.isSiteAdmin(user),
+	}
+	if data.ServerSide {
+		var err error
+		data.Sessions, err = h.loadSessions(r.Context(), user)
+		if err != nil {
+			slog.Error("Failed to load sessions", "error", err, "user_id", user.ID)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	if err := h.sessionsTemplate.ExecuteTemplate(w, "sessions", data); err != nil {
+		slog.Error("Failed to render sessions template", "error", err, "user_id", user.ID)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+	}
+}
+
+// RevokeSession signs the user out on one device. Revoking the session in
+// use signs them out here too.
+func (h *Handlers) RevokeSession(w http.ResponseWriter, r *http.Request) {
+	user, ok := h.requireServerSessions(w, r)
+	if !ok {
+		return
+	}
+
+	sessionID, err := strconv.ParseInt(chi.URLParam(r, "sessionID"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid session ID", http.StatusBadRequest)
+		return
+	}
+
+	removed, err := h.app.DB.DeleteSession(r.Context(), db.DeleteSessionParams{ID: sessionID, UserID: user.ID})
+	if err != nil {
+		slog.Error("Failed to revoke session", "error", err, "session_id", sessionID, "user_id", user.ID)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if removed == 0 {
+		http.Error(w, "Session not found", http.StatusNotFound)
+		return
+	}
+	slog.Info("Session revoked", "session_id", sessionID, "user_id", user.ID)
+
+	// Live connections carry no session, so drop them all; the devices
+	// still signed in reconnect straight away
+	h.app.Hub.DisconnectUser(user.ID)
+
+	if sessionID == user.SessionID {
+		h.app.Session.Clear(w, r)
+		w.Header().Set("HX-Redirect", "/")
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// RevokeOtherSessions signs the user out everywhere but here.
+func (h *Handlers) RevokeOtherSessions(w http.ResponseWriter, r *http.Request) {
+	user, ok := h.requireServerSessions(w, r)
+	if !ok {
+		return
+	}
+
+	removed, err := h.app.DB.DeleteOtherSessions(r.Context(), db.DeleteOtherSessionsParams{UserID: user.ID, ID: user.SessionID})
+	if err != nil {
+		slog.Error("Failed to revoke sessions", "error", err, "user_id", user.ID)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	slog.Info("Other sessions revoked", "user_id", user.ID, "count", removed)
+
+	h.app.Hub.DisconnectUser(user.ID)
+	w.Header().Set("HX-Refresh", "true")
+	w.WriteHeader(http.StatusOK)
+}
+
+// RevokeUserSessions lets an admin sign someone out on every device, for
+// example when they leave the team.
+func (h *Handlers) RevokeUserSessions(w http.ResponseWriter, r *http.Request) {
+	admin, ok := h.requireServerSessions(w, r)
+	if !ok {
+		return
+	}
+	if !h.isSiteAdmin(admin) {
+		http.Error(w, "Only admins can sign other people out", http.StatusForbidden)
+		return
+	}
+
+	login := strings.TrimPrefix(strings.TrimSpace(r.FormValue("login")), "@")
+	target, err := h.app.DB.GetUserByLogin(r.Context(), login)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "User not found", http.StatusNotFound)
+			return
+		}
+		slog.Error("Failed to look up user", "error", err, "login", login)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	removed, err := h.app.DB.DeleteUserSessions(r.Context(), target.ID)
+	if err != nil {
+		slog.Error("Failed to revoke sessions", "error", err, "user_id", target.ID)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	slog.Info("User signed out everywhere", "user_id", target.ID, "count", removed, "revoked_by", admin.ID)
+
+	h.app.Hub.DisconnectUser(target.ID)
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	data := RevokedData{Login: target.Login, Count: removed}
+	if err := h.sessionsTemplate.ExecuteTemplate(w, "sessions_revoked", data); err != nil {
+		slog.Error("Failed to render revoked sessions", "error", err, "user_id", target.ID)
+	}
+}
+
+// requireServerSessions answers 404 when sessions live only in cookies,
+// since there is then nothing to revoke.
+func (h *Handlers) requireServerSessions(w http.ResponseWriter, r *http.Request) (*session.User, bool) {
+	user, ok := GetUserFromContext(r)
+	if !ok {
+		slog.Error("User not found in context for session management")
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return nil, false
+	}
+	if !h.app.Session.ServerSide() {
+		http.Error(w, "Server-side sessions are not enabled", http.StatusNotFound)
+		return nil, false
+	}
+	return user, true
+}
+
+func (h *Handlers) isSiteAdmin(user *session.User) bool {
+	return user.GitHubUID != 0 && h.app.Admins[user.GitHubUID]
+}
+
+func (h *Handlers) loadSessions(ctx context.Context, user *session.User) ([]SessionView, error) {
+	rows, err := h.app.DB.GetUserSessions(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	sessions := make([]SessionView, len(rows))
+	for i, row := range rows {
+		sessions[i] = SessionView{
+			ID:         row.ID,
+			Device:     deviceName(row.UserAgent),
+			IP:         row.IpAddress,
+			CreatedAt:  row.CreatedAt.Time,
+			LastSeenAt: row.LastSeenAt.Time,
+			Current:    row.ID == user.SessionID,
+		}
+	}
+	return sessions, nil
+}
+
+// deviceName turns a User-Agent header into something like "Firefox on
+// macOS". Order matters: Edge claims to be Chrome, and Chrome Safari.
+func deviceName(userAgent string) string {
+	browser := "Unknown browser"
+	for _, b := range []struct{ token, name string }{
+		{"Edg/", "Edge"},
+		{"Firefox/", "Firefox"},
+		{"Chrome/", "Chrome"},
+		{"Safari/", "Safari"},
+	} {
+		if strings.Contains(userAgent, b.token) {
+			browser = b.name
+			break
+		}
+	}
+
+	for _, platform := range []struct{ token, name string }{
+		{"Android", "Android"},
+		{"iPhone", "iOS"},
+		{"iPad", "iOS"},
+		{"Windows", "Windows"},
+		{"Mac OS X", "macOS"},
+		{"Linux", "Linux"},
+	} {
+		if strings.Contains(userAgent, platform.token) {
+			return browser + " on " + platform.name
+		}
+	}
+	return browser
+}
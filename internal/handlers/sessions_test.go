@@ -0,0 +1,207 @@
+package handlers
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+
+	"blazing/internal/db"
+	"blazing/internal/session"
+)
+
+const (
+	laptopAgent = "Mozilla/5.0 (Macintosh; Intel Mac OS X 14_5) Gecko/20100101 Firefox/130.0"
+	phoneAgent  = "Mozilla/5.0 (iPhone; CPU iPhone OS 17_5 like Mac OS X) Version/17.5 Mobile/15E148 Safari/604.1"
+)
+
+func TestSessions(t *testing.T) {
+	testApp, h := setupTestApp(t)
+	testApp.Session.UseStore(session.NewDBStore(testApp.DB, session.DefaultExpiry))
+	testApp.Admins = map[int64]bool{1001: true}
+	ctx := context.Background()
+
+	alice := createTestUser(t, testApp, 1001, "alice")
+	bob := createTestUser(t, testApp, 1002, "bob")
+
+	router := chi.NewRouter()
+	router.Use(h.RequireAuth)
+	router.Get("/sessions", h.Sessions)
+	router.Delete("/sessions", h.RevokeOtherSessions)
+	router.Delete("/sessions/{sessionID}", h.RevokeSession)
+	router.Post("/admin/sessions/revoke", h.RevokeUserSessions)
+
+	signIn := func(user db.User, agent string) []*http.Cookie {
+		t.Helper()
+		req := httptest.NewRequest("GET", "/auth/github/callback", nil)
+		req.Header.Set("User-Agent", agent)
+		w := httptest.NewRecorder()
+		err := testApp.Session.Set(w, req, &session.User{ID: user.ID, Login: user.Login})
+		if err != nil {
+			t.Fatalf("Failed to sign in: %v", err)
+		}
+		return w.Result().Cookies()
+	}
+
+	do := func(method, path string, cookies []*http.Cookie, body io.Reader) *httptest.ResponseRecorder {
+		t.Helper()
+		req := httptest.NewRequest(method, path, body)
+		if body != nil {
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		}
+		for _, c := range cookies {
+			req.AddCookie(c)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	sessionID := func(user db.User, agent string) int64 {
+		t.Helper()
+		sessions, err := testApp.DB.GetUserSessions(ctx, user.ID)
+		if err != nil {
+			t.Fatalf("Failed to load sessions: %v", err)
+		}
+		for _, s := range sessions {
+			if s.UserAgent == agent {
+				return s.ID
+			}
+		}
+		t.Fatalf("No session for %s on %q", user.Login, agent)
+		return 0
+	}
+
+	laptop := signIn(alice, laptopAgent)
+	phone := signIn(alice, phoneAgent)
+
+	t.Run("lists devices", func(t *testing.T) {
+		w := do("GET", "/sessions", laptop, nil)
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+		}
+		page := w.Body.String()
+		if !strings.Contains(page, "Firefox on macOS") || !strings.Contains(page, "Safari on iOS") {
+			t.Errorf("Expected both devices, got: %s", page)
+		}
+		if strings.Count(page, "This device") != 1 {
+			t.Errorf("Expected one session marked current, got: %s", page)
+		}
+	})
+
+	t.Run("signs out one device", func(t *testing.T) {
+		client := testApp.Hub.RegisterUser(alice.ID)
+
+		w := do("DELETE", "/sessions/"+itoa(sessionID(alice, phoneAgent)), laptop, nil)
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+		}
+		if w := do("GET", "/sessions", phone, nil); w.Code != http.StatusUnauthorized {
+			t.Errorf("Expected the phone to be signed out, got %d", w.Code)
+		}
+		if w := do("GET", "/sessions", laptop, nil); w.Code != http.StatusOK {
+			t.Errorf("Expected the laptop to stay signed in, got %d", w.Code)
+		}
+		select {
+		case <-client.Done():
+		default:
+			t.Error("Expected live connections to be dropped")
+		}
+	})
+
+	t.Run("cannot sign out someone else's device", func(t *testing.T) {
+		bobs := signIn(bob, laptopAgent)
+		if w := do("DELETE", "/sessions/"+itoa(sessionID(alice, laptopAgent)), bobs, nil); w.Code != http.StatusNotFound {
+			t.Errorf("Expected status %d, got %d", http.StatusNotFound, w.Code)
+		}
+	})
+
+	t.Run("signs out everywhere else", func(t *testing.T) {
+		phone = signIn(alice, phoneAgent)
+		if w := do("DELETE", "/sessions", laptop, nil); w.Code != http.StatusOK {
+			t.Fatalf("Expected status %d, got %d", http.StatusOK, w.Code)
+		}
+		if w := do("GET", "/sessions", phone, nil); w.Code != http.StatusUnauthorized {
+			t.Errorf("Expected the phone to be signed out, got %d", w.Code)
+		}
+		if w := do("GET", "/sessions", laptop, nil); w.Code != http.StatusOK {
+			t.Errorf("Expected the laptop to stay signed in, got %d", w.Code)
+		}
+	})
+
+	t.Run("signing out this device clears the cookie", func(t *testing.T) {
+		tablet := signIn(alice, "Tablet")
+		w := do("DELETE", "/sessions/"+itoa(sessionID(alice, "Tablet")), tablet, nil)
+		if w.Code != http.StatusOK || w.Header().Get("HX-Redirect") != "/" {
+			t.Errorf("Expected a redirect home, got %d %v", w.Code, w.Header())
+		}
+		if w := do("GET", "/sessions", tablet, nil); w.Code != http.StatusUnauthorized {
+			t.Errorf("Expected the tablet to be signed out, got %d", w.Code)
+		}
+	})
+
+	t.Run("admins sign people out everywhere", func(t *testing.T) {
+		bobLaptop := signIn(bob, laptopAgent)
+		signIn(bob, phoneAgent)
+
+		form := url.Values{"login": {"bob"}}
+		if w := do("POST", "/admin/sessions/revoke", bobLaptop, strings.NewReader(form.Encode())); w.Code != http.StatusForbidden {
+			t.Errorf("Expected status %d for a non-admin, got %d", http.StatusForbidden, w.Code)
+		}
+
+		w := do("POST", "/admin/sessions/revoke", laptop, strings.NewReader(form.Encode()))
+		if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "Signed bob out of 3 sessions") {
+			t.Fatalf("Expected bob's sessions to go, got %d: %s", w.Code, w.Body.String())
+		}
+		if w := do("GET", "/sessions", bobLaptop, nil); w.Code != http.StatusUnauthorized {
+			t.Errorf("Expected bob to be signed out, got %d", w.Code)
+		}
+
+		form = url.Values{"login": {"nobody"}}
+		if w := do("POST", "/admin/sessions/revoke", laptop, strings.NewReader(form.Encode())); w.Code != http.StatusNotFound {
+			t.Errorf("Expected status %d for an unknown user, got %d", http.StatusNotFound, w.Code)
+		}
+	})
+}
+
+func TestSessionsWithoutStore(t *testing.T) {
+	testApp, h := setupTestApp(t)
+	alice := createTestUser(t, testApp, 1001, "alice")
+
+	req := withRoute(httptest.NewRequest("GET", "/sessions", nil), alice, nil)
+	w := httptest.NewRecorder()
+	h.Sessions(w, req)
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "SESSION_STORE=database") {
+		t.Errorf("Expected a note on enabling sessions, got %d: %s", w.Code, w.Body.String())
+	}
+
+	req = withRoute(httptest.NewRequest("DELETE", "/sessions/1", nil), alice, map[string]string{"sessionID": "1"})
+	w = httptest.NewRecorder()
+	h.RevokeSession(w, req)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected status %d, got %d", http.StatusNotFound, w.Code)
+	}
+}
+
+func TestDeviceName(t *testing.T) {
+	tests := []struct {
+		agent, want string
+	}{
+		{laptopAgent, "Firefox on macOS"},
+		{phoneAgent, "Safari on iOS"},
+		{"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 Chrome/126.0 Safari/537.36 Edg/126.0", "Edge on Windows"},
+		{"Mozilla/5.0 (Linux; Android 14) AppleWebKit/537.36 Chrome/126.0 Mobile Safari/537.36", "Chrome on Android"},
+		{"curl/8.5.0", "Unknown browser"},
+	}
+
+	for _, tt := range tests {
+		if got := deviceName(tt.agent); got != tt.want {
+			t.Errorf("deviceName(%q) = %q, want %q", tt.agent, got, tt.want)
+		}
+	}
+}
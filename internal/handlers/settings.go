@@ -0,0 +1,251 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"blazing/internal/db"
+)
+
+const maxRoomTopicLength = 250
+
+// RenameRoom changes the room's name.
+func (h *Handlers) RenameRoom(w http.ResponseWriter, r *http.Request) {
+	access, ok := h.requireRoomOwner(w, r)
+	if !ok {
+		return
+	}
+	if !checkNotArchived(w, access.Room) {
+		return
+	}
+
+	name, err := validateRoomName(r.FormValue("name"))
+	if err != nil {
+		http.Error(w, "Invalid room name: "+err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+
+	room := access.Room
+	room.Name = name
+	h.updateRoom(w, r, access, room, "renamed the room to “"+name+"”", func(q *db.Queries) error {
+		return q.UpdateRoomName(r.Context(), db.UpdateRoomNameParams{Name: name, ID: room.ID})
+	})
+}
+
+// UpdateRoomTopic sets or clears the line shown under the room's name.
+func (h *Handlers) UpdateRoomTopic(w http.ResponseWriter, r *http.Request) {
+	access, ok := h.requireRoomOwner(w, r)
+	if !ok {
+		return
+	}
+	if !checkNotArchived(w, access.Room) {
+		return
+	}
+
+	topic, err := validateRoomTopic(r.FormValue("topic"))
+	if err != nil {
+		http.Error(w, "Invalid topic: "+err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+
+	note := "set the topic to “" + topic + "”"
+	if topic == "" {
+		note = "cleared the topic"
+	}
+
+	room := access.Room
+	room.Topic = topic
+	h.updateRoom(w, r, access, room, note, func(q *db.Queries) error {
+		return q.UpdateRoomTopic(r.Context(), db.UpdateRoomTopicParams{Topic: topic, ID: room.ID})
+	})
+}
+
+// ArchiveRoom freezes the room: members can still read it, but nobody
+// posts, reacts or invites, and it leaves the main dashboard list.
+func (h *Handlers) ArchiveRoom(w http.ResponseWriter, r *http.Request) {
+	access, ok := h.requireRoomOwner(w, r)
+	if !ok {
+		return
+	}
+	if access.Room.ArchivedAt.Valid {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	room := access.Room
+	room.ArchivedAt.Valid = true
+	h.updateRoom(w, r, access, room, "archived the room", func(q *db.Queries) error {
+		return q.ArchiveRoom(r.Context(), room.ID)
+	})
+}
+
+// UnarchiveRoom brings an archived room back into use.
+func (h *Handlers) UnarchiveRoom(w http.ResponseWriter, r *http.Request) {
+	access, ok := h.requireRoomOwner(w, r)
+	if !ok {
+		return
+	}
+	if !access.Room.ArchivedAt.Valid {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	room := access.Room
+	room.ArchivedAt.Valid = false
+	h.updateRoom(w, r, access, room, "restored the room", func(q *db.Queries) error {
+		return q.UnarchiveRoom(r.Context(), room.ID)
+	})
+}
+
+// DeleteRoom removes the room for good. Memberships, messages and
+// everything hanging off them go with it through ON DELETE CASCADE.
+func (h *Handlers) DeleteRoom(w http.ResponseWriter, r *http.Request) {
+	access, ok := h.requireRoomOwner(w, r)
+	if !ok {
+		return
+	}
+	room, user := access.Room, access.User
+
+	members, err := h.app.DB.GetRoomMembers(r.Context(), room.ID)
+	if err != nil {
+		slog.Error("Failed to load room members", "error", err, "room_id", room.ID)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	// Attachment rows go with the messages; their content is swept after
+	var stored []db.GetRoomAttachmentKeysRow
+	err = h.app.WithTx(r.Context(), func(q *db.Queries) error {
+		var err error
+		stored, err = q.GetRoomAttachmentKeys(r.Context(), room.ID)
+		if err != nil {
+			return err
+		}
+		return q.DeleteRoom(r.Context(), room.ID)
+	})
+	if err != nil {
+		slog.Error("Failed to delete room", "error", err, "room_id", room.ID, "user_id", user.ID)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	slog.Info("Room deleted", "room_id", room.ID, "deleted_by", user.ID)
+
+	var keys []string
+	for _, row := range stored {
+		keys = append(keys, storedKeys(row.StorageKey, row.ThumbnailKey)...)
+	}
+	h.sweepAttachments(context.WithoutCancel(r.Context()), keys)
+
+	event := roomEvent{ID: room.ID, Name: room.Name, Login: user.Login}
+	h.broadcast(room.ID, eventRoomDeleted, event)
+	for _, member := range members {
+		h.sendToUser(member.ID, eventRoomDeleted, event)
+		h.app.Hub.Disconnect(room.ID, member.ID)
+	}
+
+	w.Header().Set("HX-Redirect", "/")
+	w.WriteHeader(http.StatusOK)
+}
+
+// updateRoom applies a change and records it as a system message in one
+// transaction, then shows both to everyone in the room and refreshes
+// their dashboards. room is the room as it will be after the change.
+func (h *Handlers) updateRoom(w http.ResponseWriter, r *http.Request, access roomAccess, room db.Room, note string, update func(q *db.Queries) error) {
+	user := access.User
+
+	var message db.Message
+	err := h.app.WithTx(r.Context(), func(q *db.Queries) error {
+		if err := update(q); err != nil {
+			return err
+		}
+		var err error
+		message, err = q.CreateSystemMessage(r.Context(), db.CreateSystemMessageParams{
+			RoomID: room.ID,
+			UserID: user.ID,
+			Body:   note,
+		})
+		return err
+	})
+	if err != nil {
+		slog.Error("Failed to update room", "error", err, "room_id", room.ID, "user_id", user.ID)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	slog.Info("Room updated", "room_id", room.ID, "user_id", user.ID, "change", note)
+
+	html, err := h.renderFragment("message", MessageView{
+		ID:        message.ID,
+		RoomID:    room.ID,
+		UserID:    user.ID,
+		Login:     user.Login,
+		AvatarURL: user.AvatarURL,
+		Body:      message.Body,
+		CreatedAt: message.CreatedAt.Time,
+		System:    true,
+	})
+	if err != nil {
+		slog.Error("Failed to render system message", "error", err, "message_id", message.ID)
+	} else {
+		h.broadcast(room.ID, eventMessageCreated, messageEvent{ID: message.ID, HTML: html})
+	}
+
+	event := roomEvent{
+		ID:       room.ID,
+		Name:     room.Name,
+		Topic:    room.Topic,
+		Archived: room.ArchivedAt.Valid,
+		Login:    user.Login,
+	}
+	h.broadcast(room.ID, eventRoomUpdated, event)
+
+	members, err := h.app.DB.GetRoomMembers(r.Context(), room.ID)
+	if err != nil {
+		slog.Error("Failed to load members for room update", "error", err, "room_id", room.ID)
+	}
+	for _, member := range members {
+		h.sendToUser(member.ID, eventRoomUpdated, event)
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// requireRoomOwner is requireRoomMember for the settings only the owner
+// may change.
+func (h *Handlers) requireRoomOwner(w http.ResponseWriter, r *http.Request) (roomAccess, bool) {
+	access, ok := h.requireRoomMember(w, r)
+	if !ok {
+		return roomAccess{}, false
+	}
+	if !access.can(permManage) {
+		http.Error(w, "Only the owner can change this room", http.StatusForbidden)
+		return roomAccess{}, false
+	}
+	return access, true
+}
+
+func checkNotArchived(w http.ResponseWriter, room db.Room) bool {
+	if room.ArchivedAt.Valid {
+		http.Error(w, "Room is archived", http.StatusConflict)
+		return false
+	}
+	return true
+}
+
+func validateRoomTopic(raw string) (string, error) {
+	topic := strings.TrimSpace(raw)
+	if utf8.RuneCountInString(topic) > maxRoomTopicLength {
+		return "", fmt.Errorf("topic must be at most %d characters", maxRoomTopicLength)
+	}
+	for _, c := range topic {
+		if unicode.IsControl(c) {
+			return "", errors.New("topic cannot contain control characters")
+		}
+	}
+	return topic, nil
+}
@@ -0,0 +1,198 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"blazing/internal/db"
+)
+
+func TestRoomSettings(t *testing.T) {
+	testApp, h := setupTestApp(t)
+	ctx := context.Background()
+
+	alice := createTestUser(t, testApp, 1001, "alice")
+	bob := createTestUser(t, testApp, 1002, "bob")
+	room := createTestRoom(t, testApp, "general", alice, bob)
+
+	do := func(method, path string, user db.User, form url.Values, handler http.HandlerFunc, params map[string]string) *httptest.ResponseRecorder {
+		t.Helper()
+		req := httptest.NewRequest(method, path, strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		if params == nil {
+			params = map[string]string{}
+		}
+		params["roomID"] = itoa(room.ID)
+		req = withRoute(req, user, params)
+		w := httptest.NewRecorder()
+		handler(w, req)
+		return w
+	}
+	base := "/rooms/" + itoa(room.ID)
+
+	load := func() db.Room {
+		t.Helper()
+		room, err := testApp.DB.GetRoom(ctx, room.ID)
+		if err != nil {
+			t.Fatalf("Failed to load room: %v", err)
+		}
+		return room
+	}
+
+	t.Run("owner renames the room", func(t *testing.T) {
+		client := testApp.Hub.Register(room.ID, bob.ID)
+		defer testApp.Hub.Unregister(client)
+		dashboard := testApp.Hub.RegisterUser(bob.ID)
+		defer testApp.Hub.Unregister(dashboard)
+
+		w := do("PUT", base+"/name", alice, url.Values{"name": {"  engineering  "}}, h.RenameRoom, nil)
+		if w.Code != http.StatusNoContent {
+			t.Fatalf("Expected status %d, got %d: %s", http.StatusNoContent, w.Code, w.Body.String())
+		}
+		if name := load().Name; name != "engineering" {
+			t.Errorf("Expected the room to be renamed, got %q", name)
+		}
+
+		var created messageEvent
+		if typ := nextEvent(t, client, &created); typ != eventMessageCreated {
+			t.Fatalf("Expected a system message first, got %s", typ)
+		}
+		if !strings.Contains(created.HTML, "message-system") || !strings.Contains(created.HTML, "renamed the room to “engineering”") {
+			t.Errorf("Expected a system message about the rename, got: %s", created.HTML)
+		}
+
+		var updated roomEvent
+		if typ := nextEvent(t, client, &updated); typ != eventRoomUpdated || updated.Name != "engineering" || updated.Login != "alice" {
+			t.Errorf("Expected a room update, got %s %+v", typ, updated)
+		}
+		if typ := nextEvent(t, dashboard, nil); typ != eventRoomUpdated {
+			t.Errorf("Expected the dashboard to hear about the rename, got %s", typ)
+		}
+	})
+
+	t.Run("only the owner changes settings", func(t *testing.T) {
+		if w := do("PUT", base+"/name", bob, url.Values{"name": {"mine"}}, h.RenameRoom, nil); w.Code != http.StatusForbidden {
+			t.Errorf("Expected status %d, got %d", http.StatusForbidden, w.Code)
+		}
+		if w := do("DELETE", base, bob, nil, h.DeleteRoom, nil); w.Code != http.StatusForbidden {
+			t.Errorf("Expected status %d, got %d", http.StatusForbidden, w.Code)
+		}
+	})
+
+	t.Run("topic", func(t *testing.T) {
+		if w := do("PUT", base+"/topic", alice, url.Values{"topic": {"Ship it"}}, h.UpdateRoomTopic, nil); w.Code != http.StatusNoContent {
+			t.Fatalf("Expected status %d, got %d: %s", http.StatusNoContent, w.Code, w.Body.String())
+		}
+		if topic := load().Topic; topic != "Ship it" {
+			t.Errorf("Expected the topic to be set, got %q", topic)
+		}
+
+		if w := do("PUT", base+"/topic", alice, url.Values{"topic": {strings.Repeat("x", maxRoomTopicLength+1)}}, h.UpdateRoomTopic, nil); w.Code != http.StatusUnprocessableEntity {
+			t.Errorf("Expected status %d, got %d", http.StatusUnprocessableEntity, w.Code)
+		}
+
+		req := withRoute(httptest.NewRequest("GET", base, nil), bob, map[string]string{"roomID": itoa(room.ID)})
+		w := httptest.NewRecorder()
+		h.Room(w, req)
+		if !strings.Contains(w.Body.String(), `<p id="room-topic" class="room-topic">Ship it</p>`) {
+			t.Errorf("Expected the topic under the name, got: %s", w.Body.String())
+		}
+	})
+
+	t.Run("system messages cannot be changed", func(t *testing.T) {
+		page, err := h.loadMessagesBefore(ctx, room.ID, 0)
+		if err != nil {
+			t.Fatalf("Failed to load messages: %v", err)
+		}
+		last := page.Messages[len(page.Messages)-1]
+		if !last.System || last.Body != "set the topic to “Ship it”" {
+			t.Fatalf("Expected the topic change in the timeline, got %+v", last)
+		}
+
+		params := map[string]string{"messageID": itoa(last.ID)}
+		if w := do("DELETE", base+"/messages/"+itoa(last.ID), alice, nil, h.DeleteMessage, params); w.Code != http.StatusForbidden {
+			t.Errorf("Expected status %d, got %d", http.StatusForbidden, w.Code)
+		}
+		if w := do("POST", base+"/threads/"+itoa(last.ID), alice, url.Values{"body": {"hi"}}, h.CreateReply, params); w.Code != http.StatusConflict {
+			t.Errorf("Expected status %d, got %d", http.StatusConflict, w.Code)
+		}
+	})
+
+	t.Run("archived rooms are read-only", func(t *testing.T) {
+		if w := do("PUT", base+"/archive", alice, nil, h.ArchiveRoom, nil); w.Code != http.StatusNoContent {
+			t.Fatalf("Expected status %d, got %d: %s", http.StatusNoContent, w.Code, w.Body.String())
+		}
+		if !load().ArchivedAt.Valid {
+			t.Fatal("Expected the room to be archived")
+		}
+
+		if w := postMessage(t, h, bob, room.ID, "anyone?"); w.Code != http.StatusForbidden {
+			t.Errorf("Expected status %d, got %d", http.StatusForbidden, w.Code)
+		}
+		if w := do("PUT", base+"/name", alice, url.Values{"name": {"old"}}, h.RenameRoom, nil); w.Code != http.StatusConflict {
+			t.Errorf("Expected status %d, got %d", http.StatusConflict, w.Code)
+		}
+
+		req := httptest.NewRequest("GET", "/", nil)
+		for _, c := range sessionCookies(t, testApp, bob) {
+			req.AddCookie(c)
+		}
+		w := httptest.NewRecorder()
+		h.Dashboard(w, req)
+		body := w.Body.String()
+		rooms := body[strings.Index(body, `id="room-list"`):strings.Index(body, `id="dm-list"`)]
+		archived := body[strings.Index(body, `id="archived-list"`):]
+		if strings.Contains(rooms, "engineering") || !strings.Contains(archived, "engineering") {
+			t.Errorf("Expected the room under archived rooms only, got: %s", body)
+		}
+
+		if w := do("DELETE", base+"/archive", alice, nil, h.UnarchiveRoom, nil); w.Code != http.StatusNoContent {
+			t.Fatalf("Expected status %d, got %d", http.StatusNoContent, w.Code)
+		}
+		if w := postMessage(t, h, bob, room.ID, "we're back"); w.Code != http.StatusCreated {
+			t.Errorf("Expected posting to work again, got %d", w.Code)
+		}
+	})
+
+	t.Run("owner deletes the room", func(t *testing.T) {
+		page, err := h.loadMessagesBefore(ctx, room.ID, 0)
+		if err != nil {
+			t.Fatalf("Failed to load messages: %v", err)
+		}
+		messageID := page.Messages[len(page.Messages)-1].ID
+		if err := testApp.DB.CreateMessageReaction(ctx, db.CreateMessageReactionParams{MessageID: messageID, UserID: alice.ID, Emoji: "🎉"}); err != nil {
+			t.Fatalf("Failed to react: %v", err)
+		}
+		if w := postMessage(t, h, alice, room.ID, "thanks @bob"); w.Code != http.StatusCreated {
+			t.Fatalf("Expected status %d, got %d", http.StatusCreated, w.Code)
+		}
+
+		client := testApp.Hub.Register(room.ID, bob.ID)
+		w := do("DELETE", base, alice, nil, h.DeleteRoom, nil)
+		if w.Code != http.StatusOK || w.Header().Get("HX-Redirect") != "/" {
+			t.Fatalf("Expected a redirect home, got %d: %s", w.Code, w.Body.String())
+		}
+
+		if typ := nextEvent(t, client, nil); typ != eventRoomDeleted {
+			t.Errorf("Expected members to hear the room is gone, got %s", typ)
+		}
+		if _, err := testApp.DB.GetRoom(ctx, room.ID); !errors.Is(err, sql.ErrNoRows) {
+			t.Errorf("Expected the room to be gone, got %v", err)
+		}
+		if members, err := testApp.DB.GetRoomMembers(ctx, room.ID); err != nil || len(members) != 0 {
+			t.Errorf("Expected memberships to cascade, got %d (%v)", len(members), err)
+		}
+		if _, err := testApp.DB.GetRoomMessage(ctx, db.GetRoomMessageParams{ID: messageID, RoomID: room.ID}); !errors.Is(err, sql.ErrNoRows) {
+			t.Errorf("Expected messages to cascade, got %v", err)
+		}
+		if reactions, err := testApp.DB.GetMessageReactions(ctx, []int64{messageID}); err != nil || len(reactions) != 0 {
+			t.Errorf("Expected reactions to cascade, got %d (%v)", len(reactions), err)
+		}
+	})
+}
@@ -0,0 +1,218 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"blazing/internal/db"
+)
+
+// maxThreadReplies bounds a single thread load; threads are expected to be
+// far shorter than room history, so they are not paginated.
+const maxThreadReplies = 500
+
+type ThreadData struct {
+	Root    MessageView
+	Replies []MessageView
+	CanPost bool
+}
+
+// Thread renders a thread as a fragment for the room page, or the whole
+// room page with the thread open when loaded directly. With ?after= it
+// returns only newer replies, for reconnect catch-up.
+func (h *Handlers) Thread(w http.ResponseWriter, r *http.Request) {
+	access, root, ok := h.requireThreadRoot(w, r)
+	if !ok {
+		return
+	}
+
+	if after := r.URL.Query().Get("after"); after != "" {
+		afterID, err := strconv.ParseInt(after, 10, 64)
+		if err != nil {
+			http.Error(w, "Invalid after cursor", http.StatusBadRequest)
+			return
+		}
+
+		replies, err := h.loadReplies(r.Context(), root.ID, afterID)
+		if err == nil {
+			err = h.attachSaved(r.Context(), access.User.ID, replies)
+		}
+		if err != nil {
+			slog.Error("Failed to load newer replies", "error", err, "message_id", root.ID)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		h.renderMessageFragment(w, "thread_replies", replies)
+		return
+	}
+
+	thread, err := h.loadThread(r.Context(), root)
+	if err == nil {
+		err = h.attachSaved(r.Context(), access.User.ID, thread.Replies)
+	}
+	if err != nil {
+		slog.Error("Failed to load thread", "error", err, "message_id", root.ID)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	thread.CanPost = access.can(permPost)
+
+	if r.Header.Get("HX-Request") == "" {
+		h.renderRoom(w, r, access, &thread)
+		return
+	}
+
+	h.renderMessageFragment(w, "thread", thread)
+}
+
+func (h *Handlers) CreateReply(w http.ResponseWriter, r *http.Request) {
+	access, root, ok := h.requireThreadRoot(w, r)
+	if !ok {
+		return
+	}
+	if !access.can(permPost) {
+		http.Error(w, "You cannot post in this room", http.StatusForbidden)
+		return
+	}
+	if root.DeletedAt.Valid {
+		http.Error(w, "Message has been deleted", http.StatusGone)
+		return
+	}
+	if root.Kind == messageKindSystem {
+		http.Error(w, "System messages have no thread", http.StatusConflict)
+		return
+	}
+
+	body, ok := parseMessageBody(w, r)
+	if !ok {
+		return
+	}
+
+	room, user := access.Room, access.User
+	var reply db.Message
+	var mentioned []int64
+	err := h.app.WithTx(r.Context(), func(q *db.Queries) error {
+		var err error
+		reply, err = q.CreateReply(r.Context(), db.CreateReplyParams{
+			RoomID:          room.ID,
+			UserID:          user.ID,
+			Body:            body,
+			ParentMessageID: sql.NullInt64{Int64: root.ID, Valid: true},
+		})
+		if err != nil {
+			return err
+		}
+		mentioned, err = h.saveMentions(r.Context(), q, access, user.ID, reply.ID, body)
+		return err
+	})
+	if err != nil {
+		slog.Error("Failed to create reply", "error", err, "message_id", root.ID, "user_id", user.ID)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	view := MessageView{
+		ID:        reply.ID,
+		RoomID:    reply.RoomID,
+		UserID:    reply.UserID,
+		Login:     user.Login,
+		AvatarURL: user.AvatarURL,
+		Body:      reply.Body,
+		CreatedAt: reply.CreatedAt.Time,
+		ParentID:  root.ID,
+	}
+	views := []MessageView{view}
+	if err := h.attachMentions(r.Context(), views); err != nil {
+		slog.Error("Failed to load mentions", "error", err, "message_id", reply.ID)
+	}
+	view = views[0]
+
+	html, err := h.renderFragment("message", view)
+	if err != nil {
+		slog.Error("Failed to render reply", "error", err, "message_id", reply.ID)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	h.broadcast(room.ID, eventThreadReplyCreated, threadEvent{ThreadID: root.ID, ID: reply.ID, HTML: html})
+	h.refreshThreadSummary(r.Context(), room.ID, root.ID)
+	h.notifyMentions(r.Context(), room, user.Login, reply.ID, root.ID, body, mentioned)
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(http.StatusCreated)
+	w.Write([]byte(html))
+}
+
+// requireThreadRoot resolves the message in the URL to the top-level
+// message of its thread, so links to a reply open the whole thread.
+func (h *Handlers) requireThreadRoot(w http.ResponseWriter, r *http.Request) (roomAccess, db.GetRoomMessageRow, bool) {
+	access, message, ok := h.requireRoomMessage(w, r)
+	if !ok || !message.ParentMessageID.Valid {
+		return access, message, ok
+	}
+
+	root, err := h.app.DB.GetRoomMessage(r.Context(), db.GetRoomMessageParams{
+		ID:     message.ParentMessageID.Int64,
+		RoomID: access.Room.ID,
+	})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "Message not found", http.StatusNotFound)
+			return roomAccess{}, db.GetRoomMessageRow{}, false
+		}
+		slog.Error("Failed to load thread root", "error", err, "room_id", access.Room.ID, "message_id", message.ID)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return roomAccess{}, db.GetRoomMessageRow{}, false
+	}
+
+	return access, root, true
+}
+
+func (h *Handlers) loadThread(ctx context.Context, root db.GetRoomMessageRow) (ThreadData, error) {
+	rootView, err := h.storedMessageView(ctx, root)
+	if err != nil {
+		return ThreadData{}, err
+	}
+
+	replies, err := h.loadReplies(ctx, root.ID, 0)
+	if err != nil {
+		return ThreadData{}, err
+	}
+
+	return ThreadData{Root: rootView, Replies: replies}, nil
+}
+
+func (h *Handlers) loadReplies(ctx context.Context, rootID, afterID int64) ([]MessageView, error) {
+	rows, err := h.app.DB.GetThreadReplies(ctx, db.GetThreadRepliesParams{
+		ParentMessageID: sql.NullInt64{Int64: rootID, Valid: true},
+		AfterID:         afterID,
+		Limit:           maxThreadReplies,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	replies := make([]MessageView, len(rows))
+	for i, row := range rows {
+		replies[i] = messageViewFromRow(db.GetRoomMessagesBeforeRow(row))
+	}
+
+	if err := h.decorateMessages(ctx, replies); err != nil {
+		return nil, err
+	}
+
+	return replies, nil
+}
+
+// refreshThreadSummary re-renders a thread's top-level message so open
+// timelines pick up the new reply count.
+func (h *Handlers) refreshThreadSummary(ctx context.Context, roomID, rootID int64) {
+	if _, err := h.pushStoredMessage(ctx, roomID, rootID, eventMessageUpdated); err != nil {
+		slog.Error("Failed to refresh thread summary", "error", err, "room_id", roomID, "message_id", rootID)
+	}
+}
@@ -0,0 +1,185 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"blazing/internal/db"
+)
+
+func TestCreateReply(t *testing.T) {
+	testApp, h := setupTestApp(t)
+	ctx := context.Background()
+
+	alice := createTestUser(t, testApp, 1001, "alice")
+	bob := createTestUser(t, testApp, 1002, "bob")
+	room := createTestRoom(t, testApp, "general", alice, bob)
+
+	root, err := testApp.DB.CreateMessage(ctx, db.CreateMessageParams{RoomID: room.ID, UserID: alice.ID, Body: "Release on Thursday?"})
+	if err != nil {
+		t.Fatalf("Failed to create message: %v", err)
+	}
+
+	reply := func(user db.User, messageID int64, body string) *httptest.ResponseRecorder {
+		form := url.Values{"body": {body}}
+		req := httptest.NewRequest("POST", "/rooms/"+itoa(room.ID)+"/threads/"+itoa(messageID), strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req = withRoute(req, user, map[string]string{"roomID": itoa(room.ID), "messageID": itoa(messageID)})
+		w := httptest.NewRecorder()
+		h.CreateReply(w, req)
+		return w
+	}
+
+	loadRoot := func() db.GetRoomMessageRow {
+		row, err := testApp.DB.GetRoomMessage(ctx, db.GetRoomMessageParams{ID: root.ID, RoomID: room.ID})
+		if err != nil {
+			t.Fatalf("Failed to load root: %v", err)
+		}
+		return row
+	}
+
+	var replyID int64
+
+	t.Run("broadcasts the reply and the new summary", func(t *testing.T) {
+		client := testApp.Hub.Register(room.ID, alice.ID)
+		defer testApp.Hub.Unregister(client)
+
+		w := reply(bob, root.ID, "Works for me")
+		if w.Code != http.StatusCreated {
+			t.Fatalf("Expected status %d, got %d: %s", http.StatusCreated, w.Code, w.Body.String())
+		}
+
+		var created struct {
+			Type string      `json:"type"`
+			Data threadEvent `json:"data"`
+		}
+		if err := json.Unmarshal(<-client.Send(), &created); err != nil {
+			t.Fatalf("Failed to decode event: %v", err)
+		}
+		if created.Type != eventThreadReplyCreated || created.Data.ThreadID != root.ID {
+			t.Errorf("Expected %s for thread %d, got %s for %d", eventThreadReplyCreated, root.ID, created.Type, created.Data.ThreadID)
+		}
+		replyID = created.Data.ID
+
+		var updated struct {
+			Type string       `json:"type"`
+			Data messageEvent `json:"data"`
+		}
+		if err := json.Unmarshal(<-client.Send(), &updated); err != nil {
+			t.Fatalf("Failed to decode event: %v", err)
+		}
+		if updated.Type != eventMessageUpdated || !strings.Contains(updated.Data.HTML, "1 reply") {
+			t.Errorf("Expected updated thread summary, got %s: %s", updated.Type, updated.Data.HTML)
+		}
+
+		if row := loadRoot(); row.ReplyCount != 1 || !row.LastReplyAt.Valid {
+			t.Errorf("Expected one reply with a timestamp, got %+v", row)
+		}
+	})
+
+	t.Run("replying to a reply joins the same thread", func(t *testing.T) {
+		if w := reply(alice, replyID, "Great"); w.Code != http.StatusCreated {
+			t.Fatalf("Expected status %d, got %d", http.StatusCreated, w.Code)
+		}
+		if row := loadRoot(); row.ReplyCount != 2 {
+			t.Errorf("Expected 2 replies, got %d", row.ReplyCount)
+		}
+	})
+
+	t.Run("replies stay out of the timeline", func(t *testing.T) {
+		page, err := h.loadMessagesBefore(ctx, room.ID, 0)
+		if err != nil {
+			t.Fatalf("Failed to load messages: %v", err)
+		}
+		if len(page.Messages) != 1 || page.Messages[0].ReplyCount != 2 {
+			t.Errorf("Expected only the root with 2 replies, got %+v", page.Messages)
+		}
+	})
+
+	t.Run("deleting a reply updates the count", func(t *testing.T) {
+		if err := testApp.DB.SoftDeleteMessage(ctx, replyID); err != nil {
+			t.Fatalf("Failed to delete reply: %v", err)
+		}
+		if row := loadRoot(); row.ReplyCount != 1 {
+			t.Errorf("Expected 1 reply after deletion, got %d", row.ReplyCount)
+		}
+	})
+}
+
+func TestThread(t *testing.T) {
+	testApp, h := setupTestApp(t)
+	ctx := context.Background()
+
+	alice := createTestUser(t, testApp, 1001, "alice")
+	bob := createTestUser(t, testApp, 1002, "bob")
+	room := createTestRoom(t, testApp, "general", alice)
+
+	root, err := testApp.DB.CreateMessage(ctx, db.CreateMessageParams{RoomID: room.ID, UserID: alice.ID, Body: "Incident review"})
+	if err != nil {
+		t.Fatalf("Failed to create message: %v", err)
+	}
+	var replyIDs []int64
+	for _, body := range []string{"Timeline attached", "Action items below"} {
+		reply, err := testApp.DB.CreateReply(ctx, db.CreateReplyParams{
+			RoomID:          room.ID,
+			UserID:          alice.ID,
+			Body:            body,
+			ParentMessageID: sql.NullInt64{Int64: root.ID, Valid: true},
+		})
+		if err != nil {
+			t.Fatalf("Failed to create reply: %v", err)
+		}
+		replyIDs = append(replyIDs, reply.ID)
+	}
+
+	get := func(user db.User, messageID int64, query string, hx bool) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/rooms/"+itoa(room.ID)+"/threads/"+itoa(messageID)+query, nil)
+		if hx {
+			req.Header.Set("HX-Request", "true")
+		}
+		req = withRoute(req, user, map[string]string{"roomID": itoa(room.ID), "messageID": itoa(messageID)})
+		w := httptest.NewRecorder()
+		h.Thread(w, req)
+		return w
+	}
+
+	t.Run("renders the thread fragment", func(t *testing.T) {
+		w := get(alice, root.ID, "", true)
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+		}
+		body := w.Body.String()
+		if !strings.Contains(body, "Incident review") || !strings.Contains(body, "Action items below") {
+			t.Errorf("Expected root and replies, got: %s", body)
+		}
+		if strings.Contains(body, "<html") {
+			t.Error("Expected a fragment for htmx requests")
+		}
+	})
+
+	t.Run("direct loads open the thread in the room page", func(t *testing.T) {
+		body := get(alice, replyIDs[0], "", false).Body.String()
+		if !strings.Contains(body, "<html") || !strings.Contains(body, `data-thread-id="`+itoa(root.ID)+`"`) {
+			t.Errorf("Expected room page with the thread open, got: %s", body)
+		}
+	})
+
+	t.Run("catches up after a cursor", func(t *testing.T) {
+		body := get(alice, root.ID, "?after="+itoa(replyIDs[0]), true).Body.String()
+		if strings.Contains(body, "Timeline attached") || !strings.Contains(body, "Action items below") {
+			t.Errorf("Expected only newer replies, got: %s", body)
+		}
+	})
+
+	t.Run("rejects non-members", func(t *testing.T) {
+		if w := get(bob, root.ID, "", true); w.Code != http.StatusForbidden {
+			t.Errorf("Expected status %d, got %d", http.StatusForbidden, w.Code)
+		}
+	})
+}
@@ -0,0 +1,128 @@
+package handlers
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"blazing/internal/db"
+)
+
+// RoomListItem is a dashboard entry with the viewer's unread state.
+type RoomListItem struct {
+	ID       int64
+	Name     string
+	Unread   int64
+	Mentions int64
+	Online   int // other members currently connected
+	Direct   bool
+	Archived bool
+	Quiet    bool // muted or notifications off
+}
+
+// MarkRead moves the user's read marker forward to message_id. The marker
+// never moves backwards and never past the room's latest message.
+func (h *Handlers) MarkRead(w http.ResponseWriter, r *http.Request) {
+	access, ok := h.requireRoomMember(w, r)
+	if !ok {
+		return
+	}
+	room, user := access.Room, access.User
+
+	messageID, err := strconv.ParseInt(r.FormValue("message_id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid message ID", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.markRead(r.Context(), room.ID, user.ID, messageID); err != nil {
+		slog.Error("Failed to mark room read", "error", err, "room_id", room.ID, "user_id", user.ID)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *Handlers) markRead(ctx context.Context, roomID, userID, messageID int64) error {
+	err := h.app.DB.MarkRoomRead(ctx, db.MarkRoomReadParams{
+		MessageID: messageID,
+		RoomID:    roomID,
+		UserID:    userID,
+	})
+	if err != nil {
+		return err
+	}
+
+	// Other tabs showing the dashboard should clear their badges too
+	h.pushUnread(ctx, roomID, userID)
+	return nil
+}
+
+func (h *Handlers) loadRoomList(ctx context.Context, userID int64) ([]RoomListItem, error) {
+	rows, err := h.app.DB.GetUserRoomsWithUnread(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	rooms := make([]RoomListItem, len(rows))
+	for i, row := range rows {
+		settings := notificationSettings(row.ID, row.NotifyLevel, row.MutedUntil, now)
+		unread, mentions := settings.badges(row.UnreadCount, row.MentionCount)
+		rooms[i] = RoomListItem{
+			ID:       row.ID,
+			Name:     row.Name,
+			Unread:   unread,
+			Mentions: mentions,
+			Direct:   row.Kind == roomKindDirect,
+			Archived: row.ArchivedAt.Valid,
+			Quiet:    settings.Quiet(),
+		}
+		// Direct conversations are named after the other people in them
+		if rooms[i].Direct && row.OtherLogins != "" {
+			rooms[i].Name = row.OtherLogins
+		}
+	}
+
+	if err := h.attachPresence(ctx, userID, rooms); err != nil {
+		return nil, err
+	}
+	return rooms, nil
+}
+
+// notifyUnread pushes fresh counts for a room to every member who has a
+// dashboard open. Counts are per user, so each needs its own query.
+func (h *Handlers) notifyUnread(ctx context.Context, roomID int64) {
+	members, err := h.app.DB.GetRoomMembers(ctx, roomID)
+	if err != nil {
+		slog.Error("Failed to load members for unread counts", "error", err, "room_id", roomID)
+		return
+	}
+
+	for _, member := range members {
+		h.pushUnread(ctx, roomID, member.ID)
+	}
+}
+
+func (h *Handlers) pushUnread(ctx context.Context, roomID, userID int64) {
+	if h.app.Hub.UserClientCount(userID) == 0 {
+		return
+	}
+
+	counts, err := h.app.DB.GetRoomUnread(ctx, db.GetRoomUnreadParams{RoomID: roomID, UserID: userID})
+	if err != nil {
+		slog.Error("Failed to count unread messages", "error", err, "room_id", roomID, "user_id", userID)
+		return
+	}
+
+	settings := notificationSettings(roomID, counts.NotifyLevel, counts.MutedUntil, time.Now())
+	unread, mentions := settings.badges(counts.UnreadCount, counts.MentionCount)
+	h.sendToUser(userID, eventRoomUnread, unreadEvent{
+		RoomID:   roomID,
+		Unread:   unread,
+		Mentions: mentions,
+	})
+}
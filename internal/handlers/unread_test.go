@@ -0,0 +1,122 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"blazing/internal/db"
+)
+
+func TestUnreadCounts(t *testing.T) {
+	testApp, h := setupTestApp(t)
+	ctx := context.Background()
+
+	alice := createTestUser(t, testApp, 1001, "alice")
+	bob := createTestUser(t, testApp, 1002, "bob")
+	room := createTestRoom(t, testApp, "general", alice, bob)
+
+	roomFor := func(user db.User) RoomListItem {
+		t.Helper()
+		rooms, err := h.loadRoomList(ctx, user.ID)
+		if err != nil {
+			t.Fatalf("Failed to load rooms: %v", err)
+		}
+		if len(rooms) != 1 {
+			t.Fatalf("Expected one room, got %+v", rooms)
+		}
+		return rooms[0]
+	}
+
+	markRead := func(user db.User, messageID int64) *httptest.ResponseRecorder {
+		form := url.Values{"message_id": {itoa(messageID)}}
+		req := httptest.NewRequest("POST", "/rooms/"+itoa(room.ID)+"/read", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req = withRoute(req, user, map[string]string{"roomID": itoa(room.ID)})
+		w := httptest.NewRecorder()
+		h.MarkRead(w, req)
+		return w
+	}
+
+	t.Run("counts other people's messages and mentions", func(t *testing.T) {
+		client := testApp.Hub.RegisterUser(alice.ID)
+		defer testApp.Hub.Unregister(client)
+
+		postMessage(t, h, bob, room.ID, "Morning all")
+		postMessage(t, h, bob, room.ID, "@alice can you review?")
+		postMessage(t, h, alice, room.ID, "On it")
+
+		root := roomFor(alice)
+		if root.Unread != 2 || root.Mentions != 1 {
+			t.Errorf("Expected 2 unread and 1 mention, got %+v", root)
+		}
+
+		// The last push alice's dashboard saw is for her own message, which
+		// leaves the counts where bob's messages put them
+		var event struct {
+			Type string      `json:"type"`
+			Data unreadEvent `json:"data"`
+		}
+		for len(client.Send()) > 0 {
+			if err := json.Unmarshal(<-client.Send(), &event); err != nil {
+				t.Fatalf("Failed to decode event: %v", err)
+			}
+		}
+		if event.Type != eventRoomUnread || event.Data.RoomID != room.ID || event.Data.Unread != 2 || event.Data.Mentions != 1 {
+			t.Errorf("Expected live unread counts, got %+v", event)
+		}
+	})
+
+	t.Run("thread replies do not count", func(t *testing.T) {
+		page, err := h.loadMessagesBefore(ctx, room.ID, 0)
+		if err != nil {
+			t.Fatalf("Failed to load messages: %v", err)
+		}
+		if _, err := testApp.DB.CreateReply(ctx, db.CreateReplyParams{
+			RoomID:          room.ID,
+			UserID:          bob.ID,
+			Body:            "in a thread",
+			ParentMessageID: sql.NullInt64{Int64: page.Messages[0].ID, Valid: true},
+		}); err != nil {
+			t.Fatalf("Failed to create reply: %v", err)
+		}
+		if got := roomFor(alice).Unread; got != 2 {
+			t.Errorf("Expected 2 unread, got %d", got)
+		}
+	})
+
+	t.Run("marking read clears counts and clamps to the latest message", func(t *testing.T) {
+		if w := markRead(alice, 1<<40); w.Code != http.StatusNoContent {
+			t.Fatalf("Expected status %d, got %d", http.StatusNoContent, w.Code)
+		}
+		if got := roomFor(alice); got.Unread != 0 || got.Mentions != 0 {
+			t.Errorf("Expected no unread messages, got %+v", got)
+		}
+
+		postMessage(t, h, bob, room.ID, "One more thing")
+		if got := roomFor(alice).Unread; got != 1 {
+			t.Errorf("Expected a later message to be unread, got %d", got)
+		}
+	})
+
+	t.Run("marker never moves backwards", func(t *testing.T) {
+		markRead(bob, 1<<40)
+		markRead(bob, 1)
+		if got := roomFor(bob).Unread; got != 0 {
+			t.Errorf("Expected marker to stay at the latest message, got %d unread", got)
+		}
+	})
+
+	t.Run("viewing the room marks it read", func(t *testing.T) {
+		req := withRoute(httptest.NewRequest("GET", "/rooms/"+itoa(room.ID), nil), alice, map[string]string{"roomID": itoa(room.ID)})
+		h.Room(httptest.NewRecorder(), req)
+		if got := roomFor(alice).Unread; got != 0 {
+			t.Errorf("Expected room to be read after viewing, got %d unread", got)
+		}
+	})
+}
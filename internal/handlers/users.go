@@ -0,0 +1,199 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"blazing/internal/db"
+	"blazing/internal/session"
+)
+
+const (
+	// userCacheTTL bounds how stale a signed-in user can be: a rename, or
+	// an account disabled on another instance, applies within this long
+	userCacheTTL = 30 * time.Second
+
+	// maxCachedUsers is when the cache starts dropping expired entries
+	maxCachedUsers = 10000
+)
+
+var errAccountDisabled = errors.New("account disabled")
+
+// userCache keeps recently read users so that checking the account on
+// every request does not cost a query each time.
+type userCache struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	entries map[int64]cachedUser
+	now     func() time.Time
+}
+
+type cachedUser struct {
+	user    db.User
+	expires time.Time
+}
+
+func newUserCache(ttl time.Duration) *userCache {
+	return &userCache{
+		ttl:     ttl,
+		entries: make(map[int64]cachedUser),
+		now:     time.Now,
+	}
+}
+
+func (c *userCache) get(id int64) (db.User, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[id]
+	if !ok || c.now().After(entry.expires) {
+		return db.User{}, false
+	}
+	return entry.user, true
+}
+
+func (c *userCache) put(user db.User) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := c.now()
+	if len(c.entries) >= maxCachedUsers {
+		for id, entry := range c.entries {
+			if now.After(entry.expires) {
+				delete(c.entries, id)
+			}
+		}
+	}
+	c.entries[user.ID] = cachedUser{user: user, expires: now.Add(c.ttl)}
+}
+
+// forget drops a user whose account just changed here, so the change
+// applies on their next request.
+func (c *userCache) forget(id int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.entries, id)
+}
+
+// authenticate reads the session and checks its user against the database,
+// clearing the session if the account is gone or disabled.
+func (h *Handlers) authenticate(w http.ResponseWriter, r *http.Request) (*session.User, error) {
+	sessionUser, err := h.app.Session.Refresh(w, r)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := h.currentUser(r.Context(), sessionUser)
+	if errors.Is(err, session.ErrInvalidSession) || errors.Is(err, errAccountDisabled) {
+		slog.Info("Session rejected for account", "user_id", sessionUser.ID, "reason", err)
+		h.app.Session.Clear(w, r)
+	}
+	return user, err
+}
+
+// currentUser returns the session's user as the database has it now, so
+// that renames and new avatars show up without signing in again.
+func (h *Handlers) currentUser(ctx context.Context, sessionUser *session.User) (*session.User, error) {
+	user, ok := h.users.get(sessionUser.ID)
+	if !ok {
+		var err error
+		user, err = h.app.DB.GetUserByID(ctx, sessionUser.ID)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return nil, session.ErrInvalidSession
+			}
+			return nil, err
+		}
+		h.users.put(user)
+	}
+
+	if user.DisabledAt.Valid {
+		return nil, errAccountDisabled
+	}
+	return &session.User{
+		ID:        user.ID,
+		GitHubUID: user.GithubUid.Int64,
+		Login:     user.Login,
+		AvatarURL: user.AvatarUrl.String,
+		SessionID: sessionUser.SessionID,
+	}, nil
+}
+
+// AccountStatusData confirms an admin disabled or re-enabled an account.
+type AccountStatusData struct {
+	Login    string
+	Disabled bool
+}
+
+// DisableUser lets an admin lock someone out: they are signed out
+// everywhere and cannot sign in again until re-enabled.
+func (h *Handlers) DisableUser(w http.ResponseWriter, r *http.Request) {
+	h.setAccountDisabled(w, r, true)
+}
+
+// EnableUser lets a disabled user sign in again.
+func (h *Handlers) EnableUser(w http.ResponseWriter, r *http.Request) {
+	h.setAccountDisabled(w, r, false)
+}
+
+func (h *Handlers) setAccountDisabled(w http.ResponseWriter, r *http.Request, disabled bool) {
+	admin, ok := GetUserFromContext(r)
+	if !ok {
+		slog.Error("User not found in context for account admin")
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if !h.isSiteAdmin(admin) {
+		http.Error(w, "Only admins can disable accounts", http.StatusForbidden)
+		return
+	}
+
+	login := strings.TrimPrefix(strings.TrimSpace(r.FormValue("login")), "@")
+	target, err := h.app.DB.GetUserByLogin(r.Context(), login)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "User not found", http.StatusNotFound)
+			return
+		}
+		slog.Error("Failed to look up user", "error", err, "login", login)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if disabled && target.ID == admin.ID {
+		http.Error(w, "You cannot disable your own account", http.StatusBadRequest)
+		return
+	}
+
+	if disabled {
+		_, err = h.app.DB.DisableUser(r.Context(), target.ID)
+	} else {
+		_, err = h.app.DB.EnableUser(r.Context(), target.ID)
+	}
+	if err != nil {
+		slog.Error("Failed to update account", "error", err, "user_id", target.ID, "disabled", disabled)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	h.users.forget(target.ID)
+	slog.Info("Account status changed", "user_id", target.ID, "disabled", disabled, "changed_by", admin.ID)
+
+	if disabled {
+		// Cookie sessions end on their next request; stored ones can go now
+		if _, err := h.app.DB.DeleteUserSessions(r.Context(), target.ID); err != nil {
+			slog.Error("Failed to revoke sessions", "error", err, "user_id", target.ID)
+		}
+		h.app.Hub.DisconnectUser(target.ID)
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	data := AccountStatusData{Login: target.Login, Disabled: disabled}
+	if err := h.sessionsTemplate.ExecuteTemplate(w, "account_status", data); err != nil {
+		slog.Error("Failed to render account status", "error", err, "user_id", target.ID)
+	}
+}
@@ -0,0 +1,171 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"blazing/internal/db"
+	"blazing/internal/session"
+)
+
+func TestRequireAuthChecksAccount(t *testing.T) {
+	testApp, h := setupTestApp(t)
+	ctx := context.Background()
+
+	alice := createTestUser(t, testApp, 1001, "alice")
+
+	router := chi.NewRouter()
+	router.Use(h.RequireAuth)
+	router.Get("/whoami", func(w http.ResponseWriter, r *http.Request) {
+		user, _ := GetUserFromContext(r)
+		w.Write([]byte(user.Login + " " + user.AvatarURL))
+	})
+
+	signIn := func(user *session.User) []*http.Cookie {
+		t.Helper()
+		w := httptest.NewRecorder()
+		if err := testApp.Session.Set(w, httptest.NewRequest("GET", "/", nil), user); err != nil {
+			t.Fatalf("Failed to sign in: %v", err)
+		}
+		return w.Result().Cookies()
+	}
+
+	whoami := func(cookies []*http.Cookie) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/whoami", nil)
+		for _, c := range cookies {
+			req.AddCookie(c)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	cookies := signIn(&session.User{ID: alice.ID, Login: "alice"})
+
+	t.Run("uses the stored account", func(t *testing.T) {
+		err := testApp.DB.UpdateUser(ctx, db.UpdateUserParams{
+			Login:     "alice-renamed",
+			AvatarUrl: alice.AvatarUrl,
+			ID:        alice.ID,
+		})
+		if err != nil {
+			t.Fatalf("Failed to rename user: %v", err)
+		}
+		h.users.forget(alice.ID)
+
+		w := whoami(cookies)
+		if w.Code != http.StatusOK || w.Body.String() != "alice-renamed "+alice.AvatarUrl.String {
+			t.Errorf("Expected the renamed account, got %d: %s", w.Code, w.Body.String())
+		}
+	})
+
+	t.Run("rejects disabled accounts", func(t *testing.T) {
+		if _, err := testApp.DB.DisableUser(ctx, alice.ID); err != nil {
+			t.Fatalf("Failed to disable user: %v", err)
+		}
+		h.users.forget(alice.ID)
+		defer func() {
+			testApp.DB.EnableUser(ctx, alice.ID)
+			h.users.forget(alice.ID)
+		}()
+
+		w := whoami(cookies)
+		if w.Code != http.StatusForbidden {
+			t.Errorf("Expected status %d, got %d", http.StatusForbidden, w.Code)
+		}
+		cleared := w.Result().Cookies()
+		if len(cleared) == 0 || cleared[len(cleared)-1].MaxAge >= 0 {
+			t.Errorf("Expected the session cookie to be cleared, got %+v", cleared)
+		}
+	})
+
+	t.Run("rejects deleted accounts", func(t *testing.T) {
+		gone := signIn(&session.User{ID: alice.ID + 1000, Login: "ghost"})
+		if w := whoami(gone); w.Code != http.StatusUnauthorized {
+			t.Errorf("Expected status %d, got %d", http.StatusUnauthorized, w.Code)
+		}
+	})
+}
+
+func TestUserCache(t *testing.T) {
+	cache := newUserCache(time.Minute)
+	clock := time.Date(2026, 10, 17, 9, 0, 0, 0, time.UTC)
+	cache.now = func() time.Time { return clock }
+
+	cache.put(db.User{ID: 1, Login: "alice"})
+	if user, ok := cache.get(1); !ok || user.Login != "alice" {
+		t.Errorf("Expected a cached user, got %+v (%v)", user, ok)
+	}
+
+	clock = clock.Add(time.Minute + time.Second)
+	if _, ok := cache.get(1); ok {
+		t.Error("Expected the entry to expire")
+	}
+
+	cache.put(db.User{ID: 2, Login: "bob"})
+	cache.forget(2)
+	if _, ok := cache.get(2); ok {
+		t.Error("Expected a forgotten user to be dropped")
+	}
+}
+
+func TestDisableUser(t *testing.T) {
+	testApp, h := setupTestApp(t)
+	testApp.Admins = map[int64]bool{1001: true}
+	ctx := context.Background()
+
+	alice := createTestUser(t, testApp, 1001, "alice")
+	bob := createTestUser(t, testApp, 1002, "bob")
+
+	post := func(handler http.HandlerFunc, as db.User, login string) *httptest.ResponseRecorder {
+		form := url.Values{"login": {login}}
+		req := withRoute(httptest.NewRequest("POST", "/admin/users", strings.NewReader(form.Encode())), as, nil)
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		w := httptest.NewRecorder()
+		handler(w, req)
+		return w
+	}
+
+	if w := post(h.DisableUser, bob, "alice"); w.Code != http.StatusForbidden {
+		t.Errorf("Expected status %d for a non-admin, got %d", http.StatusForbidden, w.Code)
+	}
+	if w := post(h.DisableUser, alice, "alice"); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected admins not to disable themselves, got %d", w.Code)
+	}
+	if w := post(h.DisableUser, alice, "nobody"); w.Code != http.StatusNotFound {
+		t.Errorf("Expected status %d for an unknown user, got %d", http.StatusNotFound, w.Code)
+	}
+
+	client := testApp.Hub.RegisterUser(bob.ID)
+	if _, err := h.currentUser(ctx, &session.User{ID: bob.ID}); err != nil {
+		t.Fatalf("Expected bob to be active, got %v", err)
+	}
+
+	w := post(h.DisableUser, alice, "@bob")
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "Disabled bob") {
+		t.Fatalf("Expected bob to be disabled, got %d: %s", w.Code, w.Body.String())
+	}
+	if _, err := h.currentUser(ctx, &session.User{ID: bob.ID}); err != errAccountDisabled {
+		t.Errorf("Expected the cached account to be refreshed, got %v", err)
+	}
+	select {
+	case <-client.Done():
+	default:
+		t.Error("Expected live connections to be dropped")
+	}
+
+	w = post(h.EnableUser, alice, "bob")
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "Re-enabled bob") {
+		t.Fatalf("Expected bob to be re-enabled, got %d: %s", w.Code, w.Body.String())
+	}
+	if _, err := h.currentUser(ctx, &session.User{ID: bob.ID}); err != nil {
+		t.Errorf("Expected bob to be active again, got %v", err)
+	}
+}
@@ -0,0 +1,135 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"nhooyr.io/websocket"
+
+	"blazing/internal/hub"
+)
+
+const (
+	wsWriteTimeout = 10 * time.Second
+	wsPingInterval = 30 * time.Second
+	wsReadLimit    = 1024
+)
+
+func (h *Handlers) WebSocket(w http.ResponseWriter, r *http.Request) {
+	access, ok := h.requireRoomMember(w, r)
+	if !ok {
+		return
+	}
+
+	h.serveWebSocket(w, r, h.app.Hub.Register(access.Room.ID, access.User.ID))
+}
+
+// DashboardWebSocket streams events addressed to the signed-in user, such
+// as unread counts for the dashboard room list.
+func (h *Handlers) DashboardWebSocket(w http.ResponseWriter, r *http.Request) {
+	user, ok := GetUserFromContext(r)
+	if !ok {
+		slog.Error("User not found in context for dashboard WebSocket")
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	h.serveWebSocket(w, r, h.app.Hub.RegisterUser(user.ID))
+}
+
+// serveWebSocket upgrades the request and pumps the hub client's messages
+// to it until either side goes away. It owns the client and unregisters it.
+func (h *Handlers) serveWebSocket(w http.ResponseWriter, r *http.Request, client *hub.Client) {
+	defer h.app.Hub.Unregister(client)
+
+	user, ok := GetUserFromContext(r)
+	if !ok {
+		slog.Error("User not found in context for WebSocket")
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	conn, err := websocket.Accept(w, r, nil)
+	if err != nil {
+		slog.Error("Failed to accept WebSocket", "error", err, "room_id", client.RoomID, "user_id", client.UserID)
+		return
+	}
+	defer conn.CloseNow()
+	conn.SetReadLimit(wsReadLimit)
+
+	session := &socketSession{
+		h:        h,
+		client:   client,
+		user:     user,
+		presence: h.app.Presence.Connect(client.UserID),
+	}
+	defer session.presence.Close()
+
+	// The read side only carries small ephemeral events; it also keeps
+	// control frames (pong, close) flowing and ends the write side when
+	// the peer goes away.
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+	go func() {
+		defer cancel()
+		if err := readPump(ctx, conn, session.handle); err != nil {
+			slog.Debug("WebSocket read ended", "error", err, "room_id", client.RoomID, "user_id", client.UserID)
+		}
+	}()
+
+	if err := writePump(ctx, conn, client); err != nil {
+		slog.Debug("WebSocket closed", "error", err, "room_id", client.RoomID, "user_id", client.UserID)
+	}
+}
+
+// readPump decodes client events until the connection fails. Malformed
+// events are dropped rather than closing the socket.
+func readPump(ctx context.Context, conn *websocket.Conn, handle func(clientEvent)) error {
+	for {
+		_, data, err := conn.Read(ctx)
+		if err != nil {
+			return err
+		}
+
+		var event clientEvent
+		if err := json.Unmarshal(data, &event); err != nil {
+			continue
+		}
+		handle(event)
+	}
+}
+
+func writePump(ctx context.Context, conn *websocket.Conn, client *hub.Client) error {
+	ticker := time.NewTicker(wsPingInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case msg := <-client.Send():
+			if err := writeWithTimeout(ctx, conn, msg); err != nil {
+				return err
+			}
+		case <-ticker.C:
+			pingCtx, cancel := context.WithTimeout(ctx, wsWriteTimeout)
+			err := conn.Ping(pingCtx)
+			cancel()
+			if err != nil {
+				return err
+			}
+		case <-client.Done():
+			// Evicted by the hub: either too slow or no longer a member
+			return conn.Close(websocket.StatusPolicyViolation, "disconnected by server")
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+func writeWithTimeout(ctx context.Context, conn *websocket.Conn, msg []byte) error {
+	ctx, cancel := context.WithTimeout(ctx, wsWriteTimeout)
+	defer cancel()
+	return conn.Write(ctx, websocket.MessageText, msg)
+}
@@ -0,0 +1,121 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"nhooyr.io/websocket"
+)
+
+func TestWebSocket(t *testing.T) {
+	testApp, h := setupTestApp(t)
+
+	alice := createTestUser(t, testApp, 1001, "alice")
+	bob := createTestUser(t, testApp, 1002, "bob")
+	room := createTestRoom(t, testApp, "general", alice)
+
+	r := chi.NewRouter()
+	r.With(h.RequireAuth).Get("/ws/{roomID}", h.WebSocket)
+	srv := httptest.NewServer(r)
+	t.Cleanup(srv.Close)
+
+	wsURL := "ws" + strings.TrimPrefix(srv.URL, "http") + "/ws/"
+
+	t.Run("rejects non-members", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		_, resp, err := websocket.Dial(ctx, wsURL+itoa(room.ID), &websocket.DialOptions{
+			HTTPHeader: cookieHeader(sessionCookies(t, testApp, bob)),
+		})
+		if err == nil {
+			t.Fatal("Expected dial to fail for non-member")
+		}
+		if resp == nil || resp.StatusCode != http.StatusForbidden {
+			t.Errorf("Expected status %d, got %v", http.StatusForbidden, resp)
+		}
+	})
+
+	t.Run("rejects unauthenticated", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		_, resp, err := websocket.Dial(ctx, wsURL+itoa(room.ID), nil)
+		if err == nil {
+			t.Fatal("Expected dial to fail without a session")
+		}
+		if resp == nil || resp.StatusCode != http.StatusUnauthorized {
+			t.Errorf("Expected status %d, got %v", http.StatusUnauthorized, resp)
+		}
+	})
+
+	t.Run("receives room broadcasts", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		conn, _, err := websocket.Dial(ctx, wsURL+itoa(room.ID), &websocket.DialOptions{
+			HTTPHeader: cookieHeader(sessionCookies(t, testApp, alice)),
+		})
+		if err != nil {
+			t.Fatalf("Failed to dial: %v", err)
+		}
+		defer conn.CloseNow()
+
+		waitFor(t, func() bool { return testApp.Hub.ClientCount(room.ID) == 1 })
+
+		testApp.Hub.Broadcast(room.ID, []byte(`{"type":"test"}`))
+
+		_, msg, err := conn.Read(ctx)
+		if err != nil {
+			t.Fatalf("Failed to read: %v", err)
+		}
+		if string(msg) != `{"type":"test"}` {
+			t.Errorf("Unexpected message: %s", msg)
+		}
+
+		conn.Close(websocket.StatusNormalClosure, "")
+		waitFor(t, func() bool { return testApp.Hub.ClientCount(room.ID) == 0 })
+	})
+}
+
+func TestDashboardWebSocket(t *testing.T) {
+	testApp, h := setupTestApp(t)
+
+	alice := createTestUser(t, testApp, 1001, "alice")
+
+	r := chi.NewRouter()
+	r.With(h.RequireAuth).Get("/ws/", h.DashboardWebSocket)
+	srv := httptest.NewServer(r)
+	t.Cleanup(srv.Close)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	conn, _, err := websocket.Dial(ctx, "ws"+strings.TrimPrefix(srv.URL, "http")+"/ws/", &websocket.DialOptions{
+		HTTPHeader: cookieHeader(sessionCookies(t, testApp, alice)),
+	})
+	if err != nil {
+		t.Fatalf("Failed to dial: %v", err)
+	}
+	defer conn.CloseNow()
+
+	waitFor(t, func() bool { return testApp.Hub.UserClientCount(alice.ID) == 1 })
+
+	testApp.Hub.SendToUser(alice.ID, []byte(`{"type":"test"}`))
+
+	_, msg, err := conn.Read(ctx)
+	if err != nil {
+		t.Fatalf("Failed to read: %v", err)
+	}
+	if string(msg) != `{"type":"test"}` {
+		t.Errorf("Unexpected message: %s", msg)
+	}
+
+	conn.Close(websocket.StatusNormalClosure, "")
+	waitFor(t, func() bool { return testApp.Hub.UserClientCount(alice.ID) == 0 })
+}
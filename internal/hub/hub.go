@@ -0,0 +1,229 @@
+package hub
+
+import (
+	"encoding/json"
+	"log/slog"
+	"sync"
+)
+
+const defaultSendBuffer = 64
+
+// Event is the envelope for everything pushed to WebSocket clients.
+type Event struct {
+	Type string `json:"type"`
+	Data any    `json:"data,omitempty"`
+}
+
+// Client is a single connection subscribed to a room, or to the user's own
+// events when RoomID is zero. The transport reads from Send until Done is
+// closed.
+type Client struct {
+	RoomID int64
+	UserID int64
+
+	send      chan []byte
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+func (c *Client) Send() <-chan []byte {
+	return c.send
+}
+
+// Done is closed once the client has been unregistered or evicted.
+func (c *Client) Done() <-chan struct{} {
+	return c.done
+}
+
+func (c *Client) close() {
+	c.closeOnce.Do(func() { close(c.done) })
+}
+
+type Hub struct {
+	mu    sync.RWMutex
+	rooms map[int64]map[*Client]struct{}
+	users map[int64]map[*Client]struct{}
+	// conns indexes every connection, room or user-level, by its user
+	conns      map[int64]map[*Client]struct{}
+	sendBuffer int
+}
+
+func New() *Hub {
+	return &Hub{
+		rooms:      make(map[int64]map[*Client]struct{}),
+		users:      make(map[int64]map[*Client]struct{}),
+		conns:      make(map[int64]map[*Client]struct{}),
+		sendBuffer: defaultSendBuffer,
+	}
+}
+
+func (h *Hub) Register(roomID, userID int64) *Client {
+	return h.register(h.rooms, roomID, roomID, userID)
+}
+
+// RegisterUser subscribes a connection to events addressed to the user
+// rather than a room, such as dashboard badge updates.
+func (h *Hub) RegisterUser(userID int64) *Client {
+	return h.register(h.users, userID, 0, userID)
+}
+
+func (h *Hub) register(index map[int64]map[*Client]struct{}, key, roomID, userID int64) *Client {
+	c := &Client{
+		RoomID: roomID,
+		UserID: userID,
+		send:   make(chan []byte, h.sendBuffer),
+		done:   make(chan struct{}),
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	add(index, key, c)
+	add(h.conns, userID, c)
+
+	return c
+}
+
+func add(index map[int64]map[*Client]struct{}, key int64, c *Client) {
+	clients, ok := index[key]
+	if !ok {
+		clients = make(map[*Client]struct{})
+		index[key] = clients
+	}
+	clients[c] = struct{}{}
+}
+
+func drop(index map[int64]map[*Client]struct{}, key int64, c *Client) {
+	if clients, ok := index[key]; ok {
+		delete(clients, c)
+		if len(clients) == 0 {
+			delete(index, key)
+		}
+	}
+}
+
+func (h *Hub) Unregister(c *Client) {
+	h.mu.Lock()
+	h.remove(c)
+	h.mu.Unlock()
+}
+
+// remove must be called with h.mu held for writing.
+func (h *Hub) remove(c *Client) {
+	index, key := h.rooms, c.RoomID
+	if c.RoomID == 0 {
+		index, key = h.users, c.UserID
+	}
+
+	drop(index, key, c)
+	drop(h.conns, c.UserID, c)
+	c.close()
+}
+
+// Disconnect evicts every connection a user holds in a room, e.g. after
+// they have been removed from it.
+func (h *Hub) Disconnect(roomID, userID int64) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for c := range h.rooms[roomID] {
+		if c.UserID == userID {
+			h.remove(c)
+		}
+	}
+}
+
+// DisconnectUser evicts every connection a user holds, in rooms and on
+// the dashboard, e.g. after their sessions have been revoked.
+func (h *Hub) DisconnectUser(userID int64) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for c := range h.conns[userID] {
+		h.remove(c)
+	}
+}
+
+// Broadcast queues msg for every client in the room. Clients whose send
+// buffer is full are evicted rather than allowed to stall the room.
+func (h *Hub) Broadcast(roomID int64, msg []byte) {
+	h.deliver(h.rooms, roomID, msg)
+}
+
+func (h *Hub) BroadcastEvent(roomID int64, event Event) error {
+	msg, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	h.Broadcast(roomID, msg)
+	return nil
+}
+
+// SendToUser queues msg for every user-level connection the user holds,
+// with the same slow-client eviction as Broadcast.
+func (h *Hub) SendToUser(userID int64, msg []byte) {
+	h.deliver(h.users, userID, msg)
+}
+
+func (h *Hub) SendUserEvent(userID int64, event Event) error {
+	msg, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	h.SendToUser(userID, msg)
+	return nil
+}
+
+// Notify queues msg for every connection the user holds, whichever room it
+// is subscribed to, for events that should reach them anywhere.
+func (h *Hub) Notify(userID int64, msg []byte) {
+	h.deliver(h.conns, userID, msg)
+}
+
+func (h *Hub) NotifyEvent(userID int64, event Event) error {
+	msg, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	h.Notify(userID, msg)
+	return nil
+}
+
+func (h *Hub) deliver(index map[int64]map[*Client]struct{}, key int64, msg []byte) {
+	var slow []*Client
+
+	h.mu.RLock()
+	for c := range index[key] {
+		select {
+		case c.send <- msg:
+		default:
+			slow = append(slow, c)
+		}
+	}
+	h.mu.RUnlock()
+
+	if len(slow) == 0 {
+		return
+	}
+
+	h.mu.Lock()
+	for _, c := range slow {
+		slog.Warn("Evicting slow WebSocket client", "room_id", c.RoomID, "user_id", c.UserID)
+		h.remove(c)
+	}
+	h.mu.Unlock()
+}
+
+func (h *Hub) ClientCount(roomID int64) int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return len(h.rooms[roomID])
+}
+
+// UserClientCount reports how many user-level connections a user holds, so
+// callers can skip work for users with no dashboard open.
+func (h *Hub) UserClientCount(userID int64) int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return len(h.users[userID])
+}
@@ -0,0 +1,246 @@
+package hub
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestHubBroadcast(t *testing.T) {
+	h := New()
+
+	a := h.Register(1, 10)
+	b := h.Register(1, 11)
+	other := h.Register(2, 12)
+
+	h.Broadcast(1, []byte("hello"))
+
+	for _, c := range []*Client{a, b} {
+		select {
+		case msg := <-c.Send():
+			if string(msg) != "hello" {
+				t.Errorf("Expected 'hello', got '%s'", msg)
+			}
+		default:
+			t.Errorf("Expected message for user %d", c.UserID)
+		}
+	}
+
+	select {
+	case msg := <-other.Send():
+		t.Errorf("Client in another room received '%s'", msg)
+	default:
+	}
+}
+
+func TestHubUnregister(t *testing.T) {
+	h := New()
+
+	c := h.Register(1, 10)
+	if h.ClientCount(1) != 1 {
+		t.Fatalf("Expected 1 client, got %d", h.ClientCount(1))
+	}
+
+	h.Unregister(c)
+
+	if h.ClientCount(1) != 0 {
+		t.Errorf("Expected 0 clients, got %d", h.ClientCount(1))
+	}
+
+	select {
+	case <-c.Done():
+	default:
+		t.Error("Expected Done to be closed after unregister")
+	}
+
+	// Unregistering twice must not panic
+	h.Unregister(c)
+}
+
+func TestHubEvictsSlowConsumer(t *testing.T) {
+	h := New()
+	h.sendBuffer = 2
+
+	slow := h.Register(1, 10)
+	fast := h.Register(1, 11)
+
+	for i := 0; i < 3; i++ {
+		h.Broadcast(1, []byte("msg"))
+		<-fast.Send()
+	}
+
+	select {
+	case <-slow.Done():
+	default:
+		t.Fatal("Expected slow client to be evicted")
+	}
+
+	select {
+	case <-fast.Done():
+		t.Fatal("Fast client should not be evicted")
+	default:
+	}
+
+	if h.ClientCount(1) != 1 {
+		t.Errorf("Expected 1 remaining client, got %d", h.ClientCount(1))
+	}
+}
+
+func TestHubBroadcastEvent(t *testing.T) {
+	h := New()
+	c := h.Register(1, 10)
+
+	if err := h.BroadcastEvent(1, Event{Type: "ping", Data: map[string]int{"n": 1}}); err != nil {
+		t.Fatalf("Failed to broadcast event: %v", err)
+	}
+
+	var event struct {
+		Type string         `json:"type"`
+		Data map[string]int `json:"data"`
+	}
+	if err := json.Unmarshal(<-c.Send(), &event); err != nil {
+		t.Fatalf("Failed to decode event: %v", err)
+	}
+
+	if event.Type != "ping" || event.Data["n"] != 1 {
+		t.Errorf("Unexpected event: %+v", event)
+	}
+}
+
+func TestHubDisconnect(t *testing.T) {
+	h := New()
+
+	a1 := h.Register(1, 10)
+	a2 := h.Register(1, 10)
+	b := h.Register(1, 11)
+	elsewhere := h.Register(2, 10)
+
+	h.Disconnect(1, 10)
+
+	for _, c := range []*Client{a1, a2} {
+		select {
+		case <-c.Done():
+		default:
+			t.Error("Expected user's room connections to be closed")
+		}
+	}
+
+	for _, c := range []*Client{b, elsewhere} {
+		select {
+		case <-c.Done():
+			t.Error("Expected unrelated connections to stay open")
+		default:
+		}
+	}
+
+	if h.ClientCount(1) != 1 {
+		t.Errorf("Expected 1 remaining client, got %d", h.ClientCount(1))
+	}
+}
+
+func TestHubDisconnectUser(t *testing.T) {
+	h := New()
+
+	inRoom := h.Register(1, 10)
+	dashboard := h.RegisterUser(10)
+	other := h.Register(1, 11)
+
+	h.DisconnectUser(10)
+
+	for _, c := range []*Client{inRoom, dashboard} {
+		select {
+		case <-c.Done():
+		default:
+			t.Error("Expected every connection of the user to be closed")
+		}
+	}
+
+	select {
+	case <-other.Done():
+		t.Error("Expected other users to stay connected")
+	default:
+	}
+
+	if h.ClientCount(1) != 1 || h.UserClientCount(10) != 0 {
+		t.Errorf("Expected only the other user left, got %d in the room and %d on the dashboard", h.ClientCount(1), h.UserClientCount(10))
+	}
+}
+
+func TestHubUserClients(t *testing.T) {
+	h := New()
+
+	dashboard := h.RegisterUser(10)
+	room := h.Register(1, 10)
+	other := h.RegisterUser(11)
+
+	h.SendToUser(10, []byte("badge"))
+
+	select {
+	case msg := <-dashboard.Send():
+		if string(msg) != "badge" {
+			t.Errorf("Expected badge, got %q", msg)
+		}
+	default:
+		t.Error("Expected user-level client to receive the message")
+	}
+
+	for _, c := range []*Client{room, other} {
+		select {
+		case <-c.Send():
+			t.Error("Expected user events to skip room and other users' connections")
+		default:
+		}
+	}
+
+	h.Broadcast(1, []byte("room"))
+	select {
+	case <-dashboard.Send():
+		t.Error("Expected room broadcasts to skip user-level connections")
+	default:
+	}
+
+	h.Unregister(dashboard)
+	if h.UserClientCount(10) != 0 {
+		t.Errorf("Expected no user clients after unregister, got %d", h.UserClientCount(10))
+	}
+	if h.ClientCount(1) != 1 {
+		t.Errorf("Expected room client to be unaffected, got %d", h.ClientCount(1))
+	}
+}
+
+func TestHubNotify(t *testing.T) {
+	h := New()
+
+	dashboard := h.RegisterUser(10)
+	inRoom := h.Register(1, 10)
+	elsewhere := h.Register(2, 10)
+	other := h.Register(1, 11)
+
+	h.Notify(10, []byte("mention"))
+
+	for _, c := range []*Client{dashboard, inRoom, elsewhere} {
+		select {
+		case msg := <-c.Send():
+			if string(msg) != "mention" {
+				t.Errorf("Expected mention, got %q", msg)
+			}
+		default:
+			t.Errorf("Expected connection in room %d to be notified", c.RoomID)
+		}
+	}
+
+	select {
+	case <-other.Send():
+		t.Error("Expected other users' connections to be skipped")
+	default:
+	}
+
+	h.Unregister(inRoom)
+	h.Disconnect(2, 10)
+	h.Notify(10, []byte("again"))
+	if len(dashboard.Send()) != 1 {
+		t.Error("Expected the remaining connection to be notified")
+	}
+	if len(inRoom.Send()) != 0 || len(elsewhere.Send()) != 0 {
+		t.Error("Expected removed connections to stop receiving notifications")
+	}
+}
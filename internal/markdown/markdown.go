@@ -0,0 +1,426 @@
+// Package markdown renders the subset of Markdown that chat messages use:
+// bold, italics, links, inline code, fenced code blocks and block quotes.
+// Raw HTML in the source is always shown as text, and the output passes
+// through an allowlist sanitizer before it reaches a template.
+package markdown
+
+import (
+	"html"
+	"html/template"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// TextFunc turns a run of plain text into escaped HTML. It never sees text
+// inside code or link targets, so it can mark up things like mentions.
+type TextFunc func(text string) string
+
+// EscapeText is the TextFunc that only escapes.
+func EscapeText(text string) string {
+	return html.EscapeString(text)
+}
+
+// Render converts src to sanitized HTML, using text for plain text runs
+// (EscapeText if nil). Line breaks inside paragraphs are kept as newline
+// characters for the page to show with white-space: pre-wrap, and nothing
+// is written between blocks.
+func Render(src string, text TextFunc) template.HTML {
+	if text == nil {
+		text = EscapeText
+	}
+	r := &renderer{text: text}
+	src = strings.ReplaceAll(src, "\r\n", "\n")
+	r.blocks(strings.Split(src, "\n"))
+	return template.HTML(Sanitize(r.b.String()))
+}
+
+type renderer struct {
+	b    strings.Builder
+	text TextFunc
+}
+
+// blocks renders lines as paragraphs, fenced code blocks and block quotes.
+func (r *renderer) blocks(lines []string) {
+	var para []string
+	flush := func() {
+		if len(para) > 0 {
+			r.b.WriteString("<p>")
+			r.inline(strings.TrimSpace(strings.Join(para, "\n")), false)
+			r.b.WriteString("</p>")
+			para = nil
+		}
+	}
+
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		switch {
+		case strings.TrimSpace(line) == "":
+			flush()
+
+		case fenceOpening(line) != "":
+			flush()
+			fence := fenceOpening(line)
+			lang := language(strings.TrimSpace(strings.TrimLeft(line, " "))[len(fence):])
+			var code []string
+			for i++; i < len(lines) && !fenceClosing(lines[i], fence); i++ {
+				code = append(code, lines[i])
+			}
+			// An unclosed fence runs to the end of the message
+			r.code(strings.Join(code, "\n"), lang)
+
+		case quoted(line):
+			flush()
+			var inner []string
+			for ; i < len(lines) && quoted(lines[i]); i++ {
+				rest := strings.TrimLeft(lines[i], " ")[1:]
+				inner = append(inner, strings.TrimPrefix(rest, " "))
+			}
+			i--
+			r.b.WriteString("<blockquote>")
+			r.blocks(inner)
+			r.b.WriteString("</blockquote>")
+
+		default:
+			para = append(para, line)
+		}
+	}
+	flush()
+}
+
+func (r *renderer) code(code, lang string) {
+	r.b.WriteString("<pre><code")
+	if lang != "" {
+		r.b.WriteString(` class="language-` + lang + `"`)
+	}
+	r.b.WriteString(">")
+	r.b.WriteString(html.EscapeString(code))
+	r.b.WriteString("</code></pre>")
+}
+
+// fenceOpening returns the ``` or ~~~ run that opens a code block on line,
+// indented by at most three spaces, or "".
+func fenceOpening(line string) string {
+	trimmed := strings.TrimLeft(line, " ")
+	if len(line)-len(trimmed) > 3 || len(trimmed) < 3 {
+		return ""
+	}
+	c := trimmed[0]
+	if c != '`' && c != '~' {
+		return ""
+	}
+	n := 0
+	for n < len(trimmed) && trimmed[n] == c {
+		n++
+	}
+	// Backticks in the info string would make it an inline code span
+	if n < 3 || c == '`' && strings.Contains(trimmed[n:], "`") {
+		return ""
+	}
+	return trimmed[:n]
+}
+
+// fenceClosing reports whether line closes a block opened by fence: the
+// same character, at least as many times, and nothing else.
+func fenceClosing(line, fence string) bool {
+	trimmed := strings.TrimSpace(line)
+	if len(line)-len(strings.TrimLeft(line, " ")) > 3 || len(trimmed) < len(fence) {
+		return false
+	}
+	return strings.Trim(trimmed, fence[:1]) == ""
+}
+
+// language takes the first word of a fence's info string as the code's
+// language, if it looks like one.
+func language(info string) string {
+	lang, _, _ := strings.Cut(strings.TrimSpace(info), " ")
+	if len(lang) > 32 {
+		return ""
+	}
+	for _, c := range lang {
+		if !isAlnum(c) && !strings.ContainsRune("+-#._", c) {
+			return ""
+		}
+	}
+	return strings.ToLower(lang)
+}
+
+func quoted(line string) bool {
+	trimmed := strings.TrimLeft(line, " ")
+	return len(line)-len(trimmed) <= 3 && strings.HasPrefix(trimmed, ">")
+}
+
+// inline renders code spans, emphasis, links and bare URLs in s. Anything
+// that does not parse as one of them is text.
+func (r *renderer) inline(s string, inLink bool) {
+	last := 0
+	flush := func(end int) {
+		r.plain(s[last:end], inLink)
+	}
+
+	for i := 0; i < len(s); {
+		switch c := s[i]; {
+		case c == '\\' && i+1 < len(s) && isPunct(s[i+1]):
+			flush(i)
+			r.b.WriteString(html.EscapeString(s[i+1 : i+2]))
+			i += 2
+			last = i
+
+		case c == '`':
+			n := run(s, i)
+			end := closingBackticks(s, i+n, n)
+			if end < 0 {
+				i += n
+				continue
+			}
+			flush(i)
+			code := s[i+n : end]
+			if len(code) > 2 && code[0] == ' ' && code[len(code)-1] == ' ' && strings.Trim(code, " ") != "" {
+				code = code[1 : len(code)-1]
+			}
+			r.b.WriteString("<code>" + html.EscapeString(code) + "</code>")
+			i = end + n
+			last = i
+
+		case c == '*' || c == '_':
+			n := run(s, i)
+			size := min(n, 2)
+			end := -1
+			if opens(s, i, size) {
+				end = closingDelimiter(s, i+size, s[i:i+size])
+			}
+			if end < 0 {
+				i += n
+				continue
+			}
+			flush(i)
+			tag := "em"
+			if size == 2 {
+				tag = "strong"
+			}
+			r.b.WriteString("<" + tag + ">")
+			r.inline(s[i+size:end], inLink)
+			r.b.WriteString("</" + tag + ">")
+			i = end + size
+			last = i
+
+		case c == '[' && !inLink:
+			label, href, end := link(s, i)
+			if end < 0 {
+				i++
+				continue
+			}
+			flush(i)
+			r.anchor(href, func() { r.inline(label, true) })
+			i = end
+			last = i
+
+		default:
+			i++
+		}
+	}
+	flush(len(s))
+}
+
+// plain writes text, linking bare http and https URLs unless it is already
+// inside a link.
+func (r *renderer) plain(s string, inLink bool) {
+	for !inLink {
+		start := bareURL(s)
+		if start < 0 {
+			break
+		}
+		end := start
+		for end < len(s) && !unicode.IsSpace(rune(s[end])) && s[end] != '<' {
+			end++
+		}
+		url := trimURL(s[start:end])
+		if !SafeURL(url) {
+			r.b.WriteString(r.text(s[:end]))
+			s = s[end:]
+			continue
+		}
+		r.b.WriteString(r.text(s[:start]))
+		r.anchor(url, func() { r.b.WriteString(html.EscapeString(url)) })
+		s = s[start+len(url):]
+	}
+	r.b.WriteString(r.text(s))
+}
+
+func (r *renderer) anchor(href string, label func()) {
+	r.b.WriteString(`<a href="` + html.EscapeString(href) + `"` + linkAttrs + `>`)
+	label()
+	r.b.WriteString("</a>")
+}
+
+// run returns the length of the run of s[i] starting at i.
+func run(s string, i int) int {
+	n := 1
+	for i+n < len(s) && s[i+n] == s[i] {
+		n++
+	}
+	return n
+}
+
+// closingBackticks finds a run of exactly n backticks from i on.
+func closingBackticks(s string, i, n int) int {
+	for i < len(s) {
+		j := strings.IndexByte(s[i:], '`')
+		if j < 0 {
+			return -1
+		}
+		i += j
+		m := run(s, i)
+		if m == n {
+			return i
+		}
+		i += m
+	}
+	return -1
+}
+
+// opens reports whether the delimiter at s[i:i+size] can start emphasis:
+// it must be followed by text, and an underscore must not be inside a word,
+// so snake_case identifiers stay as they are.
+func opens(s string, i, size int) bool {
+	if i+size >= len(s) || unicode.IsSpace(rune(s[i+size])) {
+		return false
+	}
+	if s[i] == '_' && i > 0 {
+		prev, _ := utf8.DecodeLastRuneInString(s[:i])
+		return !isAlnum(prev)
+	}
+	return true
+}
+
+// closingDelimiter finds the delimiter that closes emphasis opened just
+// before i, skipping escapes and code spans. A single * or _ does not close
+// on one half of a double, nor a double on a single.
+func closingDelimiter(s string, i int, delim string) int {
+	c := delim[0]
+	for ; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+			continue
+		case '`':
+			n := run(s, i)
+			if end := closingBackticks(s, i+n, n); end >= 0 {
+				i = end + n - 1
+			} else {
+				i += n - 1
+			}
+			continue
+		case c:
+		default:
+			continue
+		}
+
+		// A run of three closes both emphasis and bold, the outer one last
+		n := run(s, i)
+		if n != len(delim) && n < 3 || unicode.IsSpace(rune(s[i-1])) {
+			i += n - 1
+			continue
+		}
+		if c == '_' && i+n < len(s) {
+			next, _ := utf8.DecodeRuneInString(s[i+n:])
+			if isAlnum(next) {
+				i += n - 1
+				continue
+			}
+		}
+		return i + n - len(delim)
+	}
+	return -1
+}
+
+// link parses [label](href) at s[i], returning where it ends or -1. Links
+// that are not http, https or mailto stay as text.
+func link(s string, i int) (label, href string, end int) {
+	depth := 0
+	j := i
+	for ; j < len(s); j++ {
+		switch s[j] {
+		case '\\':
+			j++
+			continue
+		case '[':
+			depth++
+		case ']':
+			depth--
+		}
+		if depth == 0 {
+			break
+		}
+	}
+	if j+1 >= len(s) || s[j+1] != '(' {
+		return "", "", -1
+	}
+	k := strings.IndexByte(s[j+2:], ')')
+	if k < 0 {
+		return "", "", -1
+	}
+	href = strings.TrimSpace(s[j+2 : j+2+k])
+	if strings.ContainsFunc(href, unicode.IsSpace) || !SafeURL(href) || j == i+1 {
+		return "", "", -1
+	}
+	return s[i+1 : j], href, j + 2 + k + 1
+}
+
+// SafeURL reports whether href is an absolute http, https or mailto URL,
+// the only kinds messages may link to.
+func SafeURL(href string) bool {
+	lower := strings.ToLower(href)
+	switch {
+	case strings.HasPrefix(lower, "http://"), strings.HasPrefix(lower, "https://"):
+		return len(href) > len("https://") && !strings.ContainsAny(href, "\"'<>`")
+	case strings.HasPrefix(lower, "mailto:"):
+		return len(href) > len("mailto:") && !strings.ContainsAny(href, "\"'<>`")
+	}
+	return false
+}
+
+// bareURL returns where the first http:// or https:// URL starting a word
+// in s begins, or -1.
+func bareURL(s string) int {
+	for i := 0; i < len(s); i++ {
+		if s[i] != 'h' || !strings.HasPrefix(s[i:], "http://") && !strings.HasPrefix(s[i:], "https://") {
+			continue
+		}
+		if i > 0 {
+			prev, _ := utf8.DecodeLastRuneInString(s[:i])
+			if isAlnum(prev) {
+				continue
+			}
+		}
+		scheme := strings.Index(s[i:], "//") + 2
+		if i+scheme < len(s) && !unicode.IsSpace(rune(s[i+scheme])) {
+			return i
+		}
+	}
+	return -1
+}
+
+// trimURL drops punctuation that ends the sentence around a URL rather
+// than the URL itself, keeping a closing parenthesis that has a match.
+func trimURL(url string) string {
+	for len(url) > 0 {
+		last := url[len(url)-1]
+		switch {
+		case strings.IndexByte(".,:;!?'\"*_", last) >= 0:
+		case last == ')' && strings.Count(url, "(") < strings.Count(url, ")"):
+		default:
+			return url
+		}
+		url = url[:len(url)-1]
+	}
+	return url
+}
+
+func isAlnum(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+func isPunct(c byte) bool {
+	return c < utf8.RuneSelf && unicode.IsPunct(rune(c)) || strings.IndexByte("`^$+<=>|~", c) >= 0
+}
@@ -0,0 +1,96 @@
+package markdown
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRender(t *testing.T) {
+	tests := []struct {
+		name, in, want string
+	}{
+		{"plain", "hello", "<p>hello</p>"},
+		{"line breaks", "one\ntwo", "<p>one\ntwo</p>"},
+		{"paragraphs", "one\n\n\ntwo", "<p>one</p><p>two</p>"},
+		{"bold", "**bold** and __bold__", "<p><strong>bold</strong> and <strong>bold</strong></p>"},
+		{"italics", "*it* and _it_", "<p><em>it</em> and <em>it</em></p>"},
+		{"nested", "**bold *and it***", "<p><strong>bold <em>and it</em></strong></p>"},
+		{"nested italics", "*it **and bold***", "<p><em>it <strong>and bold</strong></em></p>"},
+		{"snake case", "call do_the_thing_now", "<p>call do_the_thing_now</p>"},
+		{"spaced stars", "2 * 3 * 4", "<p>2 * 3 * 4</p>"},
+		{"unclosed", "**not bold", "<p>**not bold</p>"},
+		{"inline code", "run `go test ./...` now", "<p>run <code>go test ./...</code> now</p>"},
+		{"code keeps markup", "`**x** <b>`", "<p><code>**x** &lt;b&gt;</code></p>"},
+		{"double backticks", "``a ` b``", "<p><code>a ` b</code></p>"},
+		{"emphasis skips code", "*a `*` b*", "<p><em>a <code>*</code> b</em></p>"},
+		{"escapes", `\*not it\* \_`, "<p>*not it* _</p>"},
+		{
+			"fenced code",
+			"look:\n```go\nfunc main() {\n\tfmt.Println(\"<hi>\")\n}\n```\ndone",
+			"<p>look:</p><pre><code class=\"language-go\">func main() {\n\tfmt.Println(&#34;&lt;hi&gt;&#34;)\n}</code></pre><p>done</p>",
+		},
+		{"tilde fence", "~~~\n**x**\n~~~", "<pre><code>**x**</code></pre>"},
+		{"unclosed fence", "```\ncode", "<pre><code>code</code></pre>"},
+		{"bad language", "```go\"><script>\nx\n```", "<pre><code>x</code></pre>"},
+		{"block quote", "> quoted\n> **text**\nreply", "<blockquote><p>quoted\n<strong>text</strong></p></blockquote><p>reply</p>"},
+		{"nested quote", ">> deep", "<blockquote><blockquote><p>deep</p></blockquote></blockquote>"},
+		{
+			"link",
+			"see [the *docs*](https://example.com/a?b=1&c=2)",
+			`<p>see <a href="https://example.com/a?b=1&amp;c=2" rel="nofollow noopener noreferrer" target="_blank">the <em>docs</em></a></p>`,
+		},
+		{
+			"bare url",
+			"at https://example.com/x_(y). Thanks",
+			`<p>at <a href="https://example.com/x_(y)" rel="nofollow noopener noreferrer" target="_blank">https://example.com/x_(y)</a>. Thanks</p>`,
+		},
+		{
+			"bare url in parentheses",
+			"(https://example.com)",
+			`<p>(<a href="https://example.com" rel="nofollow noopener noreferrer" target="_blank">https://example.com</a>)</p>`,
+		},
+		{"javascript link", "[x](javascript:alert(1))", "<p>[x](javascript:alert(1))</p>"},
+		{"relative link", "[x](/rooms/1)", "<p>[x](/rooms/1)</p>"},
+		{"raw html", `<img src=x onerror="alert(1)">`, "<p>&lt;img src=x onerror=&#34;alert(1)&#34;&gt;</p>"},
+		{"entities stay text", "&amp; &lt;", "<p>&amp;amp; &amp;lt;</p>"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := string(Render(tt.in, nil)); got != tt.want {
+				t.Errorf("Render(%q) =\n%s\nwant\n%s", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRenderText(t *testing.T) {
+	var runs []string
+	text := func(s string) string {
+		runs = append(runs, s)
+		return strings.ReplaceAll(EscapeText(s), "@bob", `<span class="mention">@bob</span>`)
+	}
+
+	got := string(Render("hi @bob, `@bob` https://example.com/@bob", text))
+	want := `<p>hi <span class="mention">@bob</span>, <code>@bob</code> <a href="https://example.com/@bob" rel="nofollow noopener noreferrer" target="_blank">https://example.com/@bob</a></p>`
+	if got != want {
+		t.Errorf("Render() =\n%s\nwant\n%s", got, want)
+	}
+	for _, run := range runs {
+		if strings.Contains(run, "`") || strings.Contains(run, "example.com") {
+			t.Errorf("Expected text runs to exclude code and links, got %q", run)
+		}
+	}
+}
+
+func TestSafeURL(t *testing.T) {
+	for _, href := range []string{"https://example.com", "HTTP://example.com/a", "mailto:a@example.com"} {
+		if !SafeURL(href) {
+			t.Errorf("Expected %q to be safe", href)
+		}
+	}
+	for _, href := range []string{"javascript:alert(1)", "data:text/html,x", "//evil.com", "/rooms/1", "https://", `https://x.com/"onclick`} {
+		if SafeURL(href) {
+			t.Errorf("Expected %q to be refused", href)
+		}
+	}
+}
@@ -0,0 +1,126 @@
+package markdown
+
+import (
+	"html"
+	"regexp"
+	"strings"
+)
+
+// allowedTags lists the elements rendered messages may contain, with the
+// attributes each may carry and a check for their values. Everything else
+// is dropped, keeping any text inside it.
+var allowedTags = map[string]map[string]func(string) bool{
+	"p":          nil,
+	"strong":     nil,
+	"em":         nil,
+	"pre":        nil,
+	"blockquote": nil,
+	"code":       {"class": languageClass.MatchString},
+	"span":       {"class": func(v string) bool { return v == "mention" }},
+	"a":          {"href": SafeURL},
+}
+
+// linkAttrs are set on every link, whatever the input said.
+const linkAttrs = ` rel="nofollow noopener noreferrer" target="_blank"`
+
+var (
+	languageClass = regexp.MustCompile(`^language-[a-z0-9+#._-]{1,32}$`)
+	tagPattern    = regexp.MustCompile(`^<(/?)([a-z][a-z0-9]*)((?:\s+[a-z-]+="[^"<>]*")*)\s*/?>`)
+	attrPattern   = regexp.MustCompile(`([a-z-]+)="([^"<>]*)"`)
+	entityPattern = regexp.MustCompile(`^&(?:[a-zA-Z][a-zA-Z0-9]{0,31}|#[0-9]{1,7}|#[xX][0-9a-fA-F]{1,6});`)
+)
+
+// Sanitize reduces s to the allowlisted elements and attributes, escapes
+// any stray markup characters and balances the tags that remain. Links
+// lose their href unless it is safe, and are dropped without one; those
+// kept always open in a new tab without passing on the referrer.
+func Sanitize(s string) string {
+	type open struct {
+		name string
+		kept bool
+	}
+	var b strings.Builder
+	var stack []open
+
+	for i := 0; i < len(s); {
+		switch s[i] {
+		case '<':
+			m := tagPattern.FindStringSubmatch(s[i:])
+			if m == nil {
+				b.WriteString("&lt;")
+				i++
+				continue
+			}
+			i += len(m[0])
+			closing, name := m[1] == "/", m[2]
+			attrs, allowed := allowedTags[name]
+
+			if closing {
+				for j := len(stack) - 1; j >= 0; j-- {
+					if stack[j].name != name {
+						continue
+					}
+					for k := len(stack) - 1; k >= j; k-- {
+						if stack[k].kept {
+							b.WriteString("</" + stack[k].name + ">")
+						}
+					}
+					stack = stack[:j]
+					break
+				}
+				continue
+			}
+
+			kept := allowed
+			var out strings.Builder
+			if allowed {
+				out.WriteString("<" + name)
+				hasHref := false
+				for _, a := range attrPattern.FindAllStringSubmatch(m[3], -1) {
+					key, value := a[1], html.UnescapeString(a[2])
+					if valid, ok := attrs[key]; ok && valid(value) {
+						out.WriteString(" " + key + `="` + html.EscapeString(value) + `"`)
+						hasHref = hasHref || key == "href"
+					}
+				}
+				if name == "a" {
+					out.WriteString(linkAttrs)
+				}
+				out.WriteString(">")
+				kept = name != "a" || hasHref
+			}
+			if kept {
+				b.WriteString(out.String())
+			}
+			stack = append(stack, open{name, kept})
+
+		case '&':
+			if m := entityPattern.FindString(s[i:]); m != "" {
+				b.WriteString(m)
+				i += len(m)
+				continue
+			}
+			b.WriteString("&amp;")
+			i++
+
+		case '>':
+			b.WriteString("&gt;")
+			i++
+
+		case '"':
+			b.WriteString("&#34;")
+			i++
+
+		default:
+			b.WriteByte(s[i])
+			i++
+		}
+	}
+
+	for k := len(stack) - 1; k >= 0; k-- {
+		if stack[k].kept {
+			b.WriteString("</" + stack[k].name + ">")
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,34 @@
+package markdown
+
+import "testing"
+
+func TestSanitize(t *testing.T) {
+	tests := []struct {
+		name, in, want string
+	}{
+		{"allowed", "<p><strong>a</strong> <em>b</em></p>", "<p><strong>a</strong> <em>b</em></p>"},
+		{"unknown tags", "<script>alert(1)</script><p>x</p>", "alert(1)<p>x</p>"},
+		{"unknown attributes", `<p onclick="x" style="y">a</p>`, "<p>a</p>"},
+		{"mention", `<span class="mention">@a</span><span class="evil">b</span>`, `<span class="mention">@a</span><span>b</span>`},
+		{"language", `<code class="language-go">x</code><code class="language-a b">y</code>`, `<code class="language-go">x</code><code>y</code>`},
+		{
+			"safe link",
+			`<a href="https://example.com/?a=1&amp;b=2" rel="opener" target="_top">x</a>`,
+			`<a href="https://example.com/?a=1&amp;b=2" rel="nofollow noopener noreferrer" target="_blank">x</a>`,
+		},
+		{"unsafe link", `<a href="javascript:alert(1)">x</a>`, "x"},
+		{"encoded scheme", `<a href="&#106;avascript:alert(1)">x</a>`, "x"},
+		{"unbalanced", "<p><em>a</p>b</em>", "<p><em>a</em></p>b"},
+		{"unclosed", "<blockquote><p>a", "<blockquote><p>a</p></blockquote>"},
+		{"stray markup", `a < b > c "d" & e &amp; f`, "a &lt; b &gt; c &#34;d&#34; &amp; e &amp; f"},
+		{"broken tag", `<p x=1>a`, "&lt;p x=1&gt;a"},
+		{"uppercase tag", "<SCRIPT>x</SCRIPT>", "&lt;SCRIPT&gt;x&lt;/SCRIPT&gt;"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Sanitize(tt.in); got != tt.want {
+				t.Errorf("Sanitize(%q) =\n%s\nwant\n%s", tt.in, got, tt.want)
+			}
+		})
+	}
+}
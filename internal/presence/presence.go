@@ -0,0 +1,187 @@
+// Package presence tracks who is connected, in memory only. A user is
+// online while any of their WebSocket connections is active, idle while all
+// of them report idle, and offline once the last one has been gone for the
+// grace period, so page loads and reconnects do not flap their status.
+package presence
+
+import (
+	"sync"
+	"time"
+)
+
+// DefaultGrace covers a page navigation or a quick reconnect.
+const DefaultGrace = 15 * time.Second
+
+type Status string
+
+const (
+	Offline Status = "offline"
+	Idle    Status = "idle"
+	Online  Status = "online"
+)
+
+type Tracker struct {
+	mu       sync.Mutex
+	users    map[int64]*user
+	grace    time.Duration
+	onChange func(userID int64, status Status)
+}
+
+type user struct {
+	conns  map[*Conn]struct{}
+	status Status
+	// gen invalidates pending offline timers when the user reconnects
+	gen int
+}
+
+// Conn is one connection's contribution to its user's presence.
+type Conn struct {
+	tracker *Tracker
+	userID  int64
+	idle    bool
+	closed  bool
+}
+
+func New(grace time.Duration) *Tracker {
+	return &Tracker{
+		users: make(map[int64]*user),
+		grace: grace,
+	}
+}
+
+// OnChange registers fn to be called, outside the tracker's lock, whenever
+// a user's status changes. It must be set before connections arrive.
+func (t *Tracker) OnChange(fn func(userID int64, status Status)) {
+	t.onChange = fn
+}
+
+func (t *Tracker) Connect(userID int64) *Conn {
+	c := &Conn{tracker: t, userID: userID}
+
+	t.mu.Lock()
+	u, ok := t.users[userID]
+	if !ok {
+		u = &user{conns: make(map[*Conn]struct{}), status: Offline}
+		t.users[userID] = u
+	}
+	u.gen++
+	u.conns[c] = struct{}{}
+	status, changed := u.update()
+	t.mu.Unlock()
+
+	if changed {
+		t.notify(userID, status)
+	}
+	return c
+}
+
+// SetIdle records whether the client behind this connection is idle, e.g.
+// because its tab is hidden.
+func (c *Conn) SetIdle(idle bool) {
+	t := c.tracker
+
+	t.mu.Lock()
+	if c.closed || c.idle == idle {
+		t.mu.Unlock()
+		return
+	}
+	c.idle = idle
+	status, changed := t.users[c.userID].update()
+	t.mu.Unlock()
+
+	if changed {
+		t.notify(c.userID, status)
+	}
+}
+
+func (c *Conn) Close() {
+	t := c.tracker
+
+	t.mu.Lock()
+	if c.closed {
+		t.mu.Unlock()
+		return
+	}
+	c.closed = true
+
+	u := t.users[c.userID]
+	delete(u.conns, c)
+	if len(u.conns) == 0 {
+		u.gen++
+		gen := u.gen
+		time.AfterFunc(t.grace, func() { t.expire(c.userID, gen) })
+		t.mu.Unlock()
+		return
+	}
+	status, changed := u.update()
+	t.mu.Unlock()
+
+	if changed {
+		t.notify(c.userID, status)
+	}
+}
+
+func (t *Tracker) expire(userID int64, gen int) {
+	t.mu.Lock()
+	u, ok := t.users[userID]
+	if !ok || u.gen != gen || len(u.conns) > 0 {
+		t.mu.Unlock()
+		return
+	}
+	delete(t.users, userID)
+	t.mu.Unlock()
+
+	t.notify(userID, Offline)
+}
+
+func (t *Tracker) Status(userID int64) Status {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if u, ok := t.users[userID]; ok {
+		return u.status
+	}
+	return Offline
+}
+
+// CountPresent reports how many of userIDs are online or idle.
+func (t *Tracker) CountPresent(userIDs []int64) int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	n := 0
+	for _, id := range userIDs {
+		if u, ok := t.users[id]; ok && u.status != Offline {
+			n++
+		}
+	}
+	return n
+}
+
+func (t *Tracker) notify(userID int64, status Status) {
+	if t.onChange != nil {
+		t.onChange(userID, status)
+	}
+}
+
+// update recomputes the status from the open connections. While none are
+// open the status is left alone until the offline timer fires.
+func (u *user) update() (Status, bool) {
+	if len(u.conns) == 0 {
+		return u.status, false
+	}
+
+	status := Idle
+	for c := range u.conns {
+		if !c.idle {
+			status = Online
+			break
+		}
+	}
+
+	if status == u.status {
+		return status, false
+	}
+	u.status = status
+	return status, true
+}
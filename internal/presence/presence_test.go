@@ -0,0 +1,99 @@
+package presence
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+type recorder struct {
+	mu      sync.Mutex
+	changes []Status
+}
+
+func (r *recorder) record(userID int64, status Status) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.changes = append(r.changes, status)
+}
+
+func (r *recorder) get() []Status {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]Status(nil), r.changes...)
+}
+
+func TestTrackerLifecycle(t *testing.T) {
+	tracker := New(20 * time.Millisecond)
+	rec := &recorder{}
+	tracker.OnChange(rec.record)
+
+	tab1 := tracker.Connect(1)
+	tab2 := tracker.Connect(1)
+	if got := tracker.Status(1); got != Online {
+		t.Fatalf("Expected online, got %s", got)
+	}
+
+	tab1.SetIdle(true)
+	if got := tracker.Status(1); got != Online {
+		t.Errorf("Expected online while one tab is active, got %s", got)
+	}
+	tab2.SetIdle(true)
+	if got := tracker.Status(1); got != Idle {
+		t.Errorf("Expected idle once every tab is idle, got %s", got)
+	}
+
+	tab1.Close()
+	tab2.Close()
+	if got := tracker.Status(1); got != Idle {
+		t.Errorf("Expected status to hold during the grace period, got %s", got)
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for tracker.Status(1) != Offline {
+		if time.Now().After(deadline) {
+			t.Fatal("Expected user to go offline after the grace period")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	want := []Status{Online, Idle, Offline}
+	got := rec.get()
+	if len(got) != len(want) {
+		t.Fatalf("Expected changes %v, got %v", want, got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("Expected changes %v, got %v", want, got)
+		}
+	}
+}
+
+func TestTrackerReconnectWithinGrace(t *testing.T) {
+	tracker := New(50 * time.Millisecond)
+	rec := &recorder{}
+	tracker.OnChange(rec.record)
+
+	tracker.Connect(1).Close()
+	tracker.Connect(1)
+
+	time.Sleep(100 * time.Millisecond)
+
+	if got := tracker.Status(1); got != Online {
+		t.Errorf("Expected reconnect to keep the user online, got %s", got)
+	}
+	if got := rec.get(); len(got) != 1 {
+		t.Errorf("Expected a single online change, got %v", got)
+	}
+}
+
+func TestTrackerCountPresent(t *testing.T) {
+	tracker := New(time.Minute)
+
+	tracker.Connect(1)
+	tracker.Connect(2).SetIdle(true)
+
+	if got := tracker.CountPresent([]int64{1, 2, 3}); got != 2 {
+		t.Errorf("Expected 2 present, got %d", got)
+	}
+}
@@ -0,0 +1,119 @@
+package session
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Expiry bounds how long a session lasts. Lifetime counts from sign-in
+// and is never extended; IdleTimeout counts from the last time the cookie
+// was renewed, which happens on a request once half of it has passed.
+type Expiry struct {
+	Lifetime    time.Duration
+	IdleTimeout time.Duration
+}
+
+// DefaultExpiry signs people out after 30 days, or after a week without
+// using the app.
+var DefaultExpiry = Expiry{
+	Lifetime:    30 * 24 * time.Hour,
+	IdleTimeout: 7 * 24 * time.Hour,
+}
+
+// ParseExpiry reads durations such as "720h" from SESSION_LIFETIME and
+// SESSION_IDLE_TIMEOUT. An empty value keeps the default.
+func ParseExpiry(lifetime, idleTimeout string) (Expiry, error) {
+	e := DefaultExpiry
+	if lifetime != "" {
+		d, err := time.ParseDuration(lifetime)
+		if err != nil {
+			return Expiry{}, fmt.Errorf("invalid SESSION_LIFETIME: %w", err)
+		}
+		e.Lifetime = d
+	}
+	if idleTimeout != "" {
+		d, err := time.ParseDuration(idleTimeout)
+		if err != nil {
+			return Expiry{}, fmt.Errorf("invalid SESSION_IDLE_TIMEOUT: %w", err)
+		}
+		e.IdleTimeout = d
+	}
+	return e, e.validate()
+}
+
+func (e Expiry) validate() error {
+	if e.Lifetime < time.Minute || e.IdleTimeout < time.Minute {
+		return fmt.Errorf("session lifetime and idle timeout must be at least a minute")
+	}
+	if e.IdleTimeout > e.Lifetime {
+		return fmt.Errorf("session idle timeout (%s) cannot exceed its lifetime (%s)", e.IdleTimeout, e.Lifetime)
+	}
+	return nil
+}
+
+// legacyCookieAge is how long cookies lasted before they recorded when
+// they were issued. Such cookies cannot say how old they are, so they are
+// held to this from a fixed point rather than trusted indefinitely.
+const legacyCookieAge = 7 * 24 * time.Hour
+
+// ParseLegacyUntil reads SESSION_LEGACY_COOKIES_UNTIL, either a date such
+// as "2026-11-01", meaning midnight UTC, or an RFC 3339 time.
+func ParseLegacyUntil(raw string) (time.Time, error) {
+	if t, err := time.Parse(time.DateOnly, raw); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse(time.RFC3339, raw)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid SESSION_LEGACY_COOKIES_UNTIL: want a date like 2026-11-01 or an RFC 3339 time")
+	}
+	return t, nil
+}
+
+// claims is what a session cookie carries: the user, or with a Store the
+// token naming the session, and when it was issued and last renewed.
+type claims struct {
+	User     *User  `json:"user,omitempty"`
+	Token    string `json:"token,omitempty"`
+	IssuedAt int64  `json:"iat"`
+	LastSeen int64  `json:"seen"`
+}
+
+// decodeClaims reads a cookie payload. Cookies from before claims held
+// only the user or token; they count as issued and last seen at
+// legacyIssued, however often they are presented, and legacy is set so
+// that they get replaced.
+func decodeClaims(purpose string, payload []byte, legacyIssued time.Time) (c claims, legacy bool, err error) {
+	if json.Unmarshal(payload, &c) == nil && c.IssuedAt != 0 {
+		return c, false, nil
+	}
+
+	c = claims{IssuedAt: legacyIssued.Unix(), LastSeen: legacyIssued.Unix()}
+	if purpose == tokenPurpose {
+		c.Token = string(payload)
+		return c, true, nil
+	}
+	if c.User, err = decodeUser(payload); err != nil {
+		return claims{}, false, err
+	}
+	return c, true, nil
+}
+
+// expired reports whether the session has run past either limit.
+func (c claims) expired(e Expiry, now time.Time) bool {
+	return now.After(time.Unix(c.IssuedAt, 0).Add(e.Lifetime)) ||
+		now.After(time.Unix(c.LastSeen, 0).Add(e.IdleTimeout))
+}
+
+// due reports whether the cookie is past half its life and should be
+// renewed.
+func (c claims) due(e Expiry, now time.Time) bool {
+	return now.Sub(time.Unix(c.LastSeen, 0)) > e.IdleTimeout/2
+}
+
+// maxAge is how long the browser should keep the cookie: until the idle
+// timeout, or the end of the session's lifetime if that comes first.
+func (c claims) maxAge(e Expiry, now time.Time) time.Duration {
+	left := time.Unix(c.IssuedAt, 0).Add(e.Lifetime).Sub(now)
+	return min(left, e.IdleTimeout)
+}
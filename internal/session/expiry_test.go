@@ -0,0 +1,247 @@
+package session
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestSessionExpiry(t *testing.T) {
+	manager, err := NewManager("test-secret-key-that-is-long-enough-for-testing")
+	if err != nil {
+		t.Fatalf("Failed to create manager: %v", err)
+	}
+	if err := manager.UseExpiry(Expiry{Lifetime: 10 * time.Hour, IdleTimeout: 2 * time.Hour}); err != nil {
+		t.Fatalf("Failed to set expiry: %v", err)
+	}
+
+	start := time.Date(2026, 10, 17, 9, 0, 0, 0, time.UTC)
+	clock := start
+	manager.now = func() time.Time { return clock }
+
+	signIn := func() *http.Cookie {
+		t.Helper()
+		clock = start
+		w := httptest.NewRecorder()
+		if err := manager.Set(w, httptest.NewRequest("GET", "/", nil), &User{ID: 1, Login: "testuser"}); err != nil {
+			t.Fatalf("Failed to set session: %v", err)
+		}
+		return w.Result().Cookies()[0]
+	}
+
+	// visit makes a request at the given time since sign-in and returns
+	// the cookie to use afterwards
+	visit := func(cookie *http.Cookie, at time.Duration) (*http.Cookie, error) {
+		clock = start.Add(at)
+		req := httptest.NewRequest("GET", "/", nil)
+		req.AddCookie(cookie)
+		w := httptest.NewRecorder()
+		if _, err := manager.Refresh(w, req); err != nil {
+			return nil, err
+		}
+		if renewed := w.Result().Cookies(); len(renewed) == 1 {
+			return renewed[0], nil
+		}
+		return cookie, nil
+	}
+
+	t.Run("cookie lasts until the idle timeout", func(t *testing.T) {
+		if cookie := signIn(); cookie.MaxAge != int((2 * time.Hour).Seconds()) {
+			t.Errorf("Expected Max-Age of two hours, got %d", cookie.MaxAge)
+		}
+	})
+
+	t.Run("idle sessions expire", func(t *testing.T) {
+		if _, err := visit(signIn(), 2*time.Hour+time.Minute); err != ErrInvalidSession {
+			t.Errorf("Expected ErrInvalidSession, got %v", err)
+		}
+	})
+
+	t.Run("renews only past half its life", func(t *testing.T) {
+		cookie := signIn()
+		same, err := visit(cookie, 30*time.Minute)
+		if err != nil || same != cookie {
+			t.Fatalf("Expected no renewal yet, got %v", err)
+		}
+
+		renewed, err := visit(cookie, 90*time.Minute)
+		if err != nil || renewed == cookie {
+			t.Fatalf("Expected the cookie to be renewed, got %v", err)
+		}
+		if _, err := visit(renewed, 3*time.Hour); err != nil {
+			t.Errorf("Expected the renewed cookie to outlast the first, got %v", err)
+		}
+	})
+
+	t.Run("active sessions end at their lifetime", func(t *testing.T) {
+		cookie := signIn()
+		var err error
+		for at := time.Hour + time.Minute; at < 10*time.Hour; at += time.Hour + time.Minute {
+			if cookie, err = visit(cookie, at); err != nil {
+				t.Fatalf("Expected the session to stay alive at %s, got %v", at, err)
+			}
+		}
+		if cookie.MaxAge > int(time.Hour.Seconds()) {
+			t.Errorf("Expected Max-Age to stop at the lifetime, got %d", cookie.MaxAge)
+		}
+		if _, err := visit(cookie, 10*time.Hour+time.Minute); err != ErrInvalidSession {
+			t.Errorf("Expected ErrInvalidSession, got %v", err)
+		}
+	})
+
+	t.Run("Get does not renew", func(t *testing.T) {
+		cookie := signIn()
+		clock = start.Add(90 * time.Minute)
+		req := httptest.NewRequest("GET", "/", nil)
+		req.AddCookie(cookie)
+		if _, err := manager.Get(req); err != nil {
+			t.Fatalf("Expected the session to be valid, got %v", err)
+		}
+		if _, err := visit(cookie, 2*time.Hour+time.Minute); err != ErrInvalidSession {
+			t.Errorf("Expected ErrInvalidSession, got %v", err)
+		}
+	})
+
+	t.Run("expired server-side sessions are deleted", func(t *testing.T) {
+		store := &memoryStore{sessions: make(map[string]memorySession)}
+		manager.UseStore(store)
+		defer manager.UseStore(nil)
+
+		cookie := signIn()
+		for hash, session := range store.sessions {
+			session.started = start
+			store.sessions[hash] = session
+		}
+		if _, err := visit(cookie, 3*time.Hour); err != ErrInvalidSession {
+			t.Errorf("Expected ErrInvalidSession, got %v", err)
+		}
+		if len(store.sessions) != 0 {
+			t.Error("Expected the idle session to be deleted")
+		}
+	})
+}
+
+func TestLegacyCookieClaims(t *testing.T) {
+	issued := time.Date(2026, 10, 17, 9, 0, 0, 0, time.UTC)
+
+	c, legacy, err := decodeClaims("", []byte(`{"id":1,"login":"testuser"}`), issued)
+	if err != nil || !legacy || c.User.Login != "testuser" || c.IssuedAt != issued.Unix() || c.LastSeen != issued.Unix() {
+		t.Errorf("Expected a legacy user cookie to count as issued at the fixed time, got %+v %v (%v)", c, legacy, err)
+	}
+
+	c, legacy, err = decodeClaims(tokenPurpose, []byte("c29tZS10b2tlbg=="), issued)
+	if err != nil || !legacy || c.Token != "c29tZS10b2tlbg==" || c.IssuedAt != issued.Unix() {
+		t.Errorf("Expected a legacy token cookie to keep its token, got %+v %v (%v)", c, legacy, err)
+	}
+
+	if _, _, err := decodeClaims("", []byte("not json"), issued); err != ErrInvalidSession {
+		t.Errorf("Expected ErrInvalidSession, got %v", err)
+	}
+}
+
+func TestLegacyCookieCutoff(t *testing.T) {
+	start := time.Date(2026, 10, 17, 9, 0, 0, 0, time.UTC)
+	clock := start
+	manager, err := NewManager("test-secret-key-that-is-long-enough-for-testing")
+	if err != nil {
+		t.Fatalf("Failed to create manager: %v", err)
+	}
+	manager.now = func() time.Time { return clock }
+
+	data, _ := json.Marshal(&User{ID: 1, Login: "testuser"})
+	encoded := base64.URLEncoding.EncodeToString(data)
+	signed := &http.Cookie{Name: cookieName, Value: encoded + "." + manager.sign(encoded)}
+
+	get := func(cookie *http.Cookie, at time.Duration) error {
+		clock = start.Add(at)
+		req := httptest.NewRequest("GET", "/", nil)
+		req.AddCookie(cookie)
+		_, err := manager.Get(req)
+		return err
+	}
+
+	if err := get(signed, 0); err != ErrInvalidSession {
+		t.Errorf("Expected signed cookies to be refused until the upgrade time is known, got %v", err)
+	}
+
+	// A restart days later is given the same upgrade time, so the cutoff
+	// does not move with it
+	manager.AcceptLegacyFrom(start)
+	clock = start.Add(5 * 24 * time.Hour)
+	if err := manager.AcceptLegacyUntil(start.Add(legacyCookieAge + time.Hour)); err == nil {
+		t.Error("Expected a cutoff more than a week after the upgrade to be rejected")
+	}
+
+	if err := get(signed, 6*24*time.Hour); err != nil {
+		t.Errorf("Expected the signed cookie to work before the cutoff, got %v", err)
+	}
+	if err := get(signed, legacyCookieAge); err != ErrInvalidSession {
+		t.Errorf("Expected the signed cookie to stop at the cutoff, got %v", err)
+	}
+
+	// Renewing keeps the fixed issue time, so even a session in constant
+	// use ends a lifetime after it
+	refresh := func(cookie *http.Cookie, at time.Duration) (*http.Cookie, error) {
+		clock = start.Add(at)
+		req := httptest.NewRequest("GET", "/", nil)
+		req.AddCookie(cookie)
+		w := httptest.NewRecorder()
+		if _, err := manager.Refresh(w, req); err != nil {
+			return nil, err
+		}
+		if renewed := w.Result().Cookies(); len(renewed) == 1 {
+			return renewed[0], nil
+		}
+		return cookie, nil
+	}
+	cookie, err := refresh(signed, 6*24*time.Hour)
+	if err != nil || cookie == signed {
+		t.Fatalf("Expected the signed cookie to be sealed again, got %v", err)
+	}
+	for at := 10 * 24 * time.Hour; at < DefaultExpiry.Lifetime; at += 4 * 24 * time.Hour {
+		if cookie, err = refresh(cookie, at); err != nil {
+			t.Fatalf("Expected the resealed session to stay alive at %s, got %v", at, err)
+		}
+	}
+	if _, err := refresh(cookie, DefaultExpiry.Lifetime+time.Minute); err != ErrInvalidSession {
+		t.Errorf("Expected the session to end a lifetime after the fixed issue time, got %v", err)
+	}
+
+	clock = start
+	if err := manager.AcceptLegacyUntil(start.Add(-time.Second)); err != nil {
+		t.Fatalf("Failed to set the cutoff: %v", err)
+	}
+	if err := get(signed, 0); err != ErrInvalidSession {
+		t.Errorf("Expected a past cutoff to refuse signed cookies, got %v", err)
+	}
+}
+
+func TestParseExpiry(t *testing.T) {
+	tests := []struct {
+		name, lifetime, idle string
+		want                 Expiry
+		expectErr            bool
+	}{
+		{"defaults", "", "", DefaultExpiry, false},
+		{"both set", "48h", "90m", Expiry{Lifetime: 48 * time.Hour, IdleTimeout: 90 * time.Minute}, false},
+		{"idle only", "", "24h", Expiry{Lifetime: DefaultExpiry.Lifetime, IdleTimeout: 24 * time.Hour}, false},
+		{"not a duration", "30d", "", Expiry{}, true},
+		{"idle longer than lifetime", "1h", "2h", Expiry{}, true},
+		{"too short", "30s", "30s", Expiry{}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseExpiry(tt.lifetime, tt.idle)
+			if (err != nil) != tt.expectErr {
+				t.Fatalf("ParseExpiry() error = %v, expectErr %v", err, tt.expectErr)
+			}
+			if !tt.expectErr && got != tt.want {
+				t.Errorf("ParseExpiry() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,104 @@
+package session
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hkdf"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"unicode"
+)
+
+const minSecretLength = 32
+
+// key is one session secret and the short ID cookies name it by. The ID
+// is derived from the secret, so rotating needs no bookkeeping. Cookies
+// are encrypted with an AES-256-GCM key derived from the secret; the
+// secret itself signs invite links and the cookies written before
+// encryption.
+type key struct {
+	id     string
+	secret []byte
+	aead   cipher.AEAD
+}
+
+func newKey(secret string) (key, error) {
+	if len(secret) < minSecretLength {
+		return key{}, errors.New("session secret must be at least 32 characters")
+	}
+
+	encryptionKey, err := hkdf.Key(sha256.New, []byte(secret), nil, "blazing session cookie encryption", 32)
+	if err != nil {
+		return key{}, err
+	}
+	block, err := aes.NewCipher(encryptionKey)
+	if err != nil {
+		return key{}, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return key{}, err
+	}
+
+	return key{id: KeyID(secret), secret: []byte(secret), aead: aead}, nil
+}
+
+// encrypt seals plaintext under a fresh nonce, binding it to purpose, and
+// returns the nonce followed by the ciphertext.
+func (k key) encrypt(purpose string, plaintext []byte) ([]byte, error) {
+	nonce := make([]byte, k.aead.NonceSize(), k.aead.NonceSize()+len(plaintext)+k.aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return k.aead.Seal(nonce, nonce, plaintext, []byte(purpose)), nil
+}
+
+func (k key) decrypt(purpose string, sealed []byte) ([]byte, bool) {
+	if len(sealed) < k.aead.NonceSize() {
+		return nil, false
+	}
+	nonce, ciphertext := sealed[:k.aead.NonceSize()], sealed[k.aead.NonceSize():]
+	plaintext, err := k.aead.Open(nil, nonce, ciphertext, []byte(purpose))
+	if err != nil {
+		return nil, false
+	}
+	return plaintext, true
+}
+
+func (k key) sign(data string) string {
+	h := hmac.New(sha256.New, k.secret)
+	h.Write([]byte(data))
+	return base64.URLEncoding.EncodeToString(h.Sum(nil))
+}
+
+func (k key) verify(data, signature string) bool {
+	return hmac.Equal([]byte(k.sign(data)), []byte(signature))
+}
+
+// KeyID returns the ID that cookies signed with secret carry.
+func KeyID(secret string) string {
+	sum := sha256.Sum256([]byte("blazing session key id:" + secret))
+	return hex.EncodeToString(sum[:4])
+}
+
+// GenerateSecret returns a new random secret for SESSION_SECRET.
+func GenerateSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(b), nil
+}
+
+// ParseSecrets splits a list of secrets such as SESSION_PREVIOUS_SECRETS,
+// separated by commas or whitespace.
+func ParseSecrets(raw string) []string {
+	return strings.FieldsFunc(raw, func(r rune) bool {
+		return r == ',' || unicode.IsSpace(r)
+	})
+}
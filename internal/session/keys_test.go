@@ -0,0 +1,154 @@
+package session
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+const (
+	oldSecret = "old-secret-key-that-is-long-enough-for-testing"
+	newSecret = "new-secret-key-that-is-long-enough-for-testing"
+)
+
+// sealedWith is how a cookie sealed with secret starts.
+func sealedWith(secret string) string {
+	return encryptedVersion + "." + KeyID(secret) + "."
+}
+
+func TestKeyRotation(t *testing.T) {
+	before, err := NewManager(oldSecret)
+	if err != nil {
+		t.Fatalf("Failed to create manager: %v", err)
+	}
+	after, err := NewManager(newSecret, oldSecret)
+	if err != nil {
+		t.Fatalf("Failed to create manager: %v", err)
+	}
+
+	testUser := &User{ID: 1, Login: "testuser"}
+
+	sessionCookie := func(w *httptest.ResponseRecorder) *http.Cookie {
+		t.Helper()
+		for _, c := range w.Result().Cookies() {
+			if c.Name == cookieName {
+				return c
+			}
+		}
+		return nil
+	}
+
+	signIn := func(m *Manager) *http.Cookie {
+		t.Helper()
+		w := httptest.NewRecorder()
+		if err := m.Set(w, httptest.NewRequest("GET", "/", nil), testUser); err != nil {
+			t.Fatalf("Failed to set session: %v", err)
+		}
+		return sessionCookie(w)
+	}
+
+	refresh := func(m *Manager, cookie *http.Cookie) (*User, *httptest.ResponseRecorder, error) {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.AddCookie(cookie)
+		w := httptest.NewRecorder()
+		user, err := m.Refresh(w, req)
+		return user, w, err
+	}
+
+	t.Run("cookie names its key", func(t *testing.T) {
+		cookie := signIn(after)
+		if !strings.HasPrefix(cookie.Value, sealedWith(newSecret)) {
+			t.Errorf("Expected the cookie to start with %q, got %q", sealedWith(newSecret), cookie.Value)
+		}
+	})
+
+	t.Run("old cookies are sealed again with the new key", func(t *testing.T) {
+		user, w, err := refresh(after, signIn(before))
+		if err != nil || user.ID != testUser.ID {
+			t.Fatalf("Expected the old cookie to be accepted, got %+v (%v)", user, err)
+		}
+
+		resealed := sessionCookie(w)
+		if resealed == nil || !strings.HasPrefix(resealed.Value, sealedWith(newSecret)) {
+			t.Fatalf("Expected a cookie sealed with the new key, got %+v", resealed)
+		}
+		if _, w, err := refresh(after, resealed); err != nil || sessionCookie(w) != nil {
+			t.Errorf("Expected the resealed cookie to be left alone, got %v", err)
+		}
+	})
+
+	t.Run("cookies from before key IDs are accepted", func(t *testing.T) {
+		data, _ := json.Marshal(testUser)
+		encoded := base64.URLEncoding.EncodeToString(data)
+		legacy := &http.Cookie{Name: cookieName, Value: encoded + "." + before.keys[0].sign(encoded)}
+		after.AcceptLegacyFrom(time.Now())
+
+		user, w, err := refresh(after, legacy)
+		if err != nil || user.Login != testUser.Login {
+			t.Fatalf("Expected the legacy cookie to be accepted, got %+v (%v)", user, err)
+		}
+		if c := sessionCookie(w); c == nil || !strings.HasPrefix(c.Value, sealedWith(newSecret)) {
+			t.Errorf("Expected the legacy cookie to be sealed again, got %+v", c)
+		}
+	})
+
+	t.Run("dropped keys stop working", func(t *testing.T) {
+		dropped, err := NewManager(newSecret)
+		if err != nil {
+			t.Fatalf("Failed to create manager: %v", err)
+		}
+		if _, _, err := refresh(dropped, signIn(before)); err != ErrInvalidSession {
+			t.Errorf("Expected ErrInvalidSession, got %v", err)
+		}
+	})
+
+	t.Run("key IDs cannot be swapped", func(t *testing.T) {
+		cookie := signIn(before)
+		cookie.Value = sealedWith(newSecret) + strings.TrimPrefix(cookie.Value, sealedWith(oldSecret))
+		if _, _, err := refresh(after, cookie); err != ErrInvalidSession {
+			t.Errorf("Expected ErrInvalidSession, got %v", err)
+		}
+	})
+
+	t.Run("tokens signed with an old key verify", func(t *testing.T) {
+		token := before.SignToken("invite", "42")
+		if value, ok := after.VerifyToken("invite", token); !ok || value != "42" {
+			t.Errorf("Expected the token to verify as 42, got %q (%v)", value, ok)
+		}
+	})
+
+	t.Run("previous secrets are validated", func(t *testing.T) {
+		if _, err := NewManager(newSecret, "short"); err == nil {
+			t.Error("Expected a short previous secret to be rejected")
+		}
+	})
+}
+
+func TestParseSecrets(t *testing.T) {
+	got := ParseSecrets(" first,second\nthird ,, ")
+	want := []string{"first", "second", "third"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ParseSecrets() = %q, want %q", got, want)
+	}
+	if got := ParseSecrets(""); len(got) != 0 {
+		t.Errorf("Expected no secrets, got %q", got)
+	}
+}
+
+func TestGenerateSecret(t *testing.T) {
+	secret, err := GenerateSecret()
+	if err != nil {
+		t.Fatalf("Failed to generate secret: %v", err)
+	}
+	if _, err := NewManager(secret); err != nil {
+		t.Errorf("Expected a generated secret to be usable, got %v", err)
+	}
+	if KeyID(secret) == KeyID(oldSecret) || len(KeyID(secret)) != 8 {
+		t.Errorf("Expected a distinct 8-character key ID, got %q", KeyID(secret))
+	}
+}
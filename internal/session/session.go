@@ -1,9 +1,7 @@
 package session
 
 import (
-	"crypto/hmac"
 	"crypto/rand"
-	"crypto/sha256"
 	"encoding/base64"
 	"encoding/json"
 	"errors"
@@ -17,7 +15,16 @@ import (
 
 const (
 	cookieName = "blazing_session"
-	cookieAge  = 7 * 24 * time.Hour
+
+	// tokenPurpose marks the cookie of a server-side session, which holds
+	// only a token naming it
+	tokenPurpose = "session"
+
+	// encryptedVersion starts an encrypted cookie,
+	// "v2.keyID.base64(nonce+ciphertext)". Earlier cookies were only
+	// signed, as "keyID.value.signature" or, before key IDs,
+	// "value.signature", and anyone holding one could read the user in it.
+	encryptedVersion = "v2"
 )
 
 var (
@@ -30,13 +37,30 @@ type User struct {
 	GitHubUID int64  `json:"github_uid"`
 	Login     string `json:"login"`
 	AvatarURL string `json:"avatar_url"`
+
+	// SessionID names the server-side session the user signed in with;
+	// zero when sessions live only in the cookie
+	SessionID int64 `json:"-"`
 }
 
 type Manager struct {
-	key []byte
+	// keys[0] signs; every key verifies, so cookies and links signed
+	// before a rotation keep working until their key is dropped
+	keys   []key
+	store  Store
+	expiry Expiry
+	now    func() time.Time
+
+	// upgraded is when the server first recorded expiry in cookies, and
+	// legacyUntil when cookies from before then stop being accepted; both
+	// are zero, refusing such cookies, until AcceptLegacyFrom is called
+	upgraded    time.Time
+	legacyUntil time.Time
 }
 
-func NewManager(secretKey string) (*Manager, error) {
+// NewManager signs with secretKey, falling back to SESSION_SECRET, and
+// also accepts anything signed with one of the previous secrets.
+func NewManager(secretKey string, previous ...string) (*Manager, error) {
 	if secretKey == "" {
 		secretKey = os.Getenv("SESSION_SECRET")
 		if secretKey == "" {
@@ -44,80 +68,336 @@ func NewManager(secretKey string) (*Manager, error) {
 		}
 	}
 
-	if len(secretKey) < 32 {
-		return nil, errors.New("session secret must be at least 32 characters")
+	primary, err := newKey(secretKey)
+	if err != nil {
+		return nil, err
+	}
+
+	m := &Manager{keys: []key{primary}, expiry: DefaultExpiry, now: time.Now}
+	for _, secret := range previous {
+		k, err := newKey(secret)
+		if err != nil {
+			return nil, fmt.Errorf("previous %w", err)
+		}
+		if _, dup := m.key(k.id); !dup {
+			m.keys = append(m.keys, k)
+		}
+	}
+	return m, nil
+}
+
+// KeyIDs lists the IDs of the signing key and then the previous keys.
+func (m *Manager) KeyIDs() []string {
+	ids := make([]string, len(m.keys))
+	for i, k := range m.keys {
+		ids[i] = k.id
+	}
+	return ids
+}
+
+func (m *Manager) key(id string) (key, bool) {
+	for _, k := range m.keys {
+		if k.id == id {
+			return k, true
+		}
+	}
+	return key{}, false
+}
+
+// UseStore keeps sessions in store rather than in the cookie itself.
+// Cookies issued before the switch stop working.
+func (m *Manager) UseStore(store Store) {
+	m.store = store
+}
+
+// UseExpiry changes how long sessions last. Cookies already issued are
+// held to the new limits on their next request.
+func (m *Manager) UseExpiry(e Expiry) error {
+	if err := e.validate(); err != nil {
+		return err
+	}
+	m.expiry = e
+	return nil
+}
+
+// AcceptLegacyFrom accepts cookies from before expiry was recorded,
+// including every cookie that was only signed, for a week after upgraded,
+// when the server first recorded it. upgraded must be a fixed instant,
+// kept across restarts, or every restart would extend the week.
+func (m *Manager) AcceptLegacyFrom(upgraded time.Time) {
+	m.upgraded = upgraded
+	m.legacyUntil = upgraded.Add(legacyCookieAge)
+}
+
+// AcceptLegacyUntil brings forward when cookies from before expiry was
+// recorded stop working. Until then they count as issued a week before t,
+// the age such cookies had, so none outlives it. t can be no later than a
+// week after the time given to AcceptLegacyFrom; a time in the past
+// refuses them outright.
+func (m *Manager) AcceptLegacyUntil(t time.Time) error {
+	if limit := m.upgraded.Add(legacyCookieAge); t.After(limit) {
+		if m.upgraded.IsZero() {
+			return errors.New("legacy session cookies are not accepted")
+		}
+		return fmt.Errorf("legacy session cookies can be accepted for at most %s after the upgrade, until %s", legacyCookieAge, limit.Format(time.RFC3339))
 	}
+	m.legacyUntil = t
+	return nil
+}
 
-	return &Manager{
-		key: []byte(secretKey),
-	}, nil
+// acceptsLegacy reports whether cookies from before expiry was recorded
+// are still accepted.
+func (m *Manager) acceptsLegacy() bool {
+	return m.now().Before(m.legacyUntil)
+}
+
+// legacyIssued is when a cookie from before expiry was recorded counts as
+// issued.
+func (m *Manager) legacyIssued() time.Time {
+	return m.legacyUntil.Add(-legacyCookieAge)
+}
+
+// Expiry returns how long sessions last.
+func (m *Manager) Expiry() Expiry {
+	return m.expiry
+}
+
+// ServerSide reports whether sessions are kept in a Store, and so can be
+// listed and revoked.
+func (m *Manager) ServerSide() bool {
+	return m.store != nil
 }
 
 func (m *Manager) sign(data string) string {
-	h := hmac.New(sha256.New, m.key)
-	h.Write([]byte(data))
-	return base64.URLEncoding.EncodeToString(h.Sum(nil))
+	return m.keys[0].sign(data)
 }
 
 func (m *Manager) verify(data, signature string) bool {
-	expected := m.sign(data)
-	return hmac.Equal([]byte(expected), []byte(signature))
+	for _, k := range m.keys {
+		if k.verify(data, signature) {
+			return true
+		}
+	}
+	return false
+}
+
+// signedData is what a signed cookie's signature covers. The user cookie
+// predates purposes and signs its payload alone.
+func signedData(purpose, value string) string {
+	if purpose == "" {
+		return value
+	}
+	return purpose + ":" + value
 }
 
-func (m *Manager) Set(w http.ResponseWriter, user *User) error {
-	data, err := json.Marshal(user)
+// seal encrypts a cookie payload with the primary key, naming the key so
+// that the cookie can still be opened once another key has taken over.
+// purpose is authenticated too, so a cookie sealed for one use never
+// opens as another.
+func (m *Manager) seal(purpose string, payload []byte) (string, error) {
+	k := m.keys[0]
+	sealed, err := k.encrypt(purpose, payload)
 	if err != nil {
-		slog.Error("Failed to marshal user session data", "error", err, "user_id", user.ID)
-		return fmt.Errorf("failed to marshal user: %w", err)
+		return "", err
 	}
+	return encryptedVersion + "." + k.id + "." + base64.RawURLEncoding.EncodeToString(sealed), nil
+}
 
-	encoded := base64.URLEncoding.EncodeToString(data)
-	signature := m.sign(encoded)
-	value := fmt.Sprintf("%s.%s", encoded, signature)
+// unseal opens a cookie from seal and returns its payload, and whether it
+// should be sealed again: because it was sealed with a key other than the
+// primary, or because it is from before encryption and only signed.
+// Signed cookies are refused once legacy cookies are no longer accepted.
+func (m *Manager) unseal(purpose, cookie string) (payload []byte, stale, ok bool) {
+	parts := strings.Split(cookie, ".")
+	switch {
+	case len(parts) == 3 && parts[0] == encryptedVersion:
+		k, found := m.key(parts[1])
+		if !found {
+			return nil, false, false
+		}
+		sealed, err := base64.RawURLEncoding.DecodeString(parts[2])
+		if err != nil {
+			return nil, false, false
+		}
+		payload, ok := k.decrypt(purpose, sealed)
+		if !ok {
+			return nil, false, false
+		}
+		return payload, k.id != m.keys[0].id, true
+	case !m.acceptsLegacy():
+		return nil, false, false
+	case len(parts) == 3:
+		k, found := m.key(parts[0])
+		if !found || !k.verify(signedData(purpose, parts[1]), parts[2]) {
+			return nil, false, false
+		}
+		payload, ok := signedPayload(purpose, parts[1])
+		return payload, true, ok
+	case len(parts) == 2:
+		if !m.verify(signedData(purpose, parts[0]), parts[1]) {
+			return nil, false, false
+		}
+		payload, ok := signedPayload(purpose, parts[0])
+		return payload, true, ok
+	}
+	return nil, false, false
+}
 
-	isSecure := os.Getenv("GO_ENV") == "production"
-	maxAge := int(cookieAge.Seconds())
+// signedPayload recovers the payload of a signed cookie, in which the user
+// was base64-encoded and a token kept as it was.
+func signedPayload(purpose, value string) ([]byte, bool) {
+	if purpose != "" {
+		return []byte(value), true
+	}
+	payload, err := base64.URLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, false
+	}
+	return payload, true
+}
 
-	http.SetCookie(w, &http.Cookie{
-		Name:     cookieName,
-		Value:    value,
-		Path:     "/",
-		MaxAge:   maxAge,
-		HttpOnly: true,
-		Secure:   isSecure,
-		SameSite: http.SameSiteLaxMode,
-	})
+// SignToken signs value for use outside the session cookie, such as in a
+// link, and returns "value.signature". purpose is mixed into the signature
+// so a token minted for one use never verifies as another.
+func (m *Manager) SignToken(purpose, value string) string {
+	return value + "." + m.sign(purpose+":"+value)
+}
+
+// VerifyToken checks a token from SignToken made for the same purpose and
+// returns the value it carries.
+func (m *Manager) VerifyToken(purpose, token string) (string, bool) {
+	i := strings.LastIndex(token, ".")
+	if i < 0 {
+		return "", false
+	}
+	value, signature := token[:i], token[i+1:]
+	if !m.verify(purpose+":"+value, signature) {
+		return "", false
+	}
+	return value, true
+}
+
+// Set signs the user in on this response. With a Store the cookie carries
+// a token naming a new server-side session for the device r came from;
+// otherwise it carries the user.
+func (m *Manager) Set(w http.ResponseWriter, r *http.Request, user *User) error {
+	now := m.now()
+	c := claims{IssuedAt: now.Unix(), LastSeen: now.Unix()}
+	if m.store != nil {
+		token, err := GenerateState()
+		if err != nil {
+			return fmt.Errorf("failed to generate session token: %w", err)
+		}
+		if err := m.store.Create(r.Context(), hashToken(token), user.ID, clientOf(r)); err != nil {
+			slog.Error("Failed to store session", "error", err, "user_id", user.ID)
+			return fmt.Errorf("failed to store session: %w", err)
+		}
+		c.Token = token
+	} else {
+		c.User = user
+	}
 
+	if err := m.write(w, c, now); err != nil {
+		slog.Error("Failed to write session cookie", "error", err, "user_id", user.ID)
+		return err
+	}
 	return nil
 }
 
 func (m *Manager) Get(r *http.Request) (*User, error) {
+	user, _, _, err := m.load(r)
+	return user, err
+}
+
+// Refresh is Get for requests that can answer with a cookie, and renews
+// the cookie once it is past half its life so that people who keep using
+// the app stay signed in until the session's lifetime runs out. A cookie
+// sealed with a previous key, or from before encryption, is replaced
+// straight away, so rotating keys signs nobody out.
+func (m *Manager) Refresh(w http.ResponseWriter, r *http.Request) (*User, error) {
+	user, c, stale, err := m.load(r)
+	if err != nil {
+		return nil, err
+	}
+
+	now := m.now()
+	if stale || c.due(m.expiry, now) {
+		c.LastSeen = now.Unix()
+		if err := m.write(w, c, now); err != nil {
+			slog.Error("Failed to renew session cookie", "error", err, "user_id", user.ID)
+		}
+	}
+	return user, nil
+}
+
+// load reads the session cookie and returns who it is for, what it
+// claims, and whether it should be replaced regardless of its age.
+func (m *Manager) load(r *http.Request) (*User, claims, bool, error) {
 	cookie, err := r.Cookie(cookieName)
 	if err != nil {
 		if err == http.ErrNoCookie {
-			return nil, ErrNoSession
+			return nil, claims{}, false, ErrNoSession
 		}
-		return nil, err
+		return nil, claims{}, false, err
 	}
 
-	parts := strings.SplitN(cookie.Value, ".", 2)
-	if len(parts) != 2 {
-		return nil, ErrInvalidSession
+	purpose := m.purpose()
+	payload, stale, ok := m.unseal(purpose, cookie.Value)
+	if !ok {
+		return nil, claims{}, false, ErrInvalidSession
 	}
 
-	data, signature := parts[0], parts[1]
+	c, legacy, err := decodeClaims(purpose, payload, m.legacyIssued())
+	if err != nil {
+		return nil, claims{}, false, err
+	}
+	if legacy && !m.acceptsLegacy() {
+		return nil, claims{}, false, ErrInvalidSession
+	}
 
-	if !m.verify(data, signature) {
-		return nil, ErrInvalidSession
+	now := m.now()
+	if c.expired(m.expiry, now) {
+		if m.store != nil {
+			m.end(r, c.Token)
+		}
+		return nil, claims{}, false, ErrInvalidSession
+	}
+
+	user := c.User
+	if m.store != nil {
+		if user, err = m.lookup(r, c.Token); err != nil {
+			return nil, claims{}, false, err
+		}
 	}
 
-	decoded, err := base64.URLEncoding.DecodeString(data)
+	return user, c, stale || legacy, nil
+}
+
+// write seals c into the session cookie.
+func (m *Manager) write(w http.ResponseWriter, c claims, now time.Time) error {
+	payload, err := json.Marshal(c)
 	if err != nil {
-		return nil, ErrInvalidSession
+		return fmt.Errorf("failed to marshal session: %w", err)
+	}
+	value, err := m.seal(m.purpose(), payload)
+	if err != nil {
+		return fmt.Errorf("failed to seal session: %w", err)
+	}
+
+	m.setCookie(w, value, int(c.maxAge(m.expiry, now).Seconds()))
+	return nil
+}
+
+func (m *Manager) purpose() string {
+	if m.store != nil {
+		return tokenPurpose
 	}
+	return ""
+}
 
+func decodeUser(payload []byte) (*User, error) {
 	var user User
-	if err := json.Unmarshal(decoded, &user); err != nil {
+	if err := json.Unmarshal(payload, &user); err != nil {
 		slog.Error("Failed to unmarshal session user data", "error", err)
 		return nil, ErrInvalidSession
 	}
@@ -125,13 +405,56 @@ func (m *Manager) Get(r *http.Request) (*User, error) {
 	return &user, nil
 }
 
-func (m *Manager) Clear(w http.ResponseWriter) {
+func (m *Manager) lookup(r *http.Request, token string) (*User, error) {
+	user, started, err := m.store.Lookup(r.Context(), hashToken(token), clientOf(r))
+	if err != nil {
+		return nil, err
+	}
+
+	// The store knows when the session really started, even if the cookie
+	// is from before it recorded that itself
+	if m.now().Sub(started) > m.expiry.Lifetime {
+		m.end(r, token)
+		return nil, ErrInvalidSession
+	}
+
+	return user, nil
+}
+
+// end deletes an expired server-side session.
+func (m *Manager) end(r *http.Request, token string) {
+	if err := m.store.Delete(r.Context(), hashToken(token)); err != nil {
+		slog.Error("Failed to delete expired session", "error", err)
+	}
+}
+
+// Clear signs the user out on this device, ending its server-side session
+// if there is one.
+func (m *Manager) Clear(w http.ResponseWriter, r *http.Request) {
+	if m.store != nil {
+		if cookie, err := r.Cookie(cookieName); err == nil {
+			if payload, _, ok := m.unseal(tokenPurpose, cookie.Value); ok {
+				c, _, err := decodeClaims(tokenPurpose, payload, m.legacyIssued())
+				if err == nil {
+					err = m.store.Delete(r.Context(), hashToken(c.Token))
+				}
+				if err != nil {
+					slog.Error("Failed to delete session", "error", err)
+				}
+			}
+		}
+	}
+
+	m.setCookie(w, "", -1)
+}
+
+func (m *Manager) setCookie(w http.ResponseWriter, value string, maxAge int) {
 	isSecure := os.Getenv("GO_ENV") == "production"
 	http.SetCookie(w, &http.Cookie{
 		Name:     cookieName,
-		Value:    "",
+		Value:    value,
 		Path:     "/",
-		MaxAge:   -1,
+		MaxAge:   maxAge,
 		HttpOnly: true,
 		Secure:   isSecure,
 		SameSite: http.SameSiteLaxMode,
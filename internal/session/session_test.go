@@ -1,10 +1,13 @@
 package session
 
 import (
+	"encoding/base64"
+	"encoding/json"
 	"net/http"
 	"net/http/httptest"
 	"strings"
 	"testing"
+	"time"
 )
 
 func TestSessionManager(t *testing.T) {
@@ -24,7 +27,7 @@ func TestSessionManager(t *testing.T) {
 		w := httptest.NewRecorder()
 		req := httptest.NewRequest("GET", "/", nil)
 
-		if err := manager.Set(w, testUser); err != nil {
+		if err := manager.Set(w, req, testUser); err != nil {
 			t.Fatalf("Failed to set session: %v", err)
 		}
 
@@ -83,7 +86,7 @@ func TestSessionManager(t *testing.T) {
 	t.Run("Clear session", func(t *testing.T) {
 		w := httptest.NewRecorder()
 
-		manager.Clear(w)
+		manager.Clear(w, httptest.NewRequest("GET", "/logout", nil))
 
 		cookies := w.Result().Cookies()
 		for _, c := range cookies {
@@ -99,6 +102,123 @@ func TestSessionManager(t *testing.T) {
 	})
 }
 
+func TestCookieEncryption(t *testing.T) {
+	manager, err := NewManager("test-secret-key-that-is-long-enough-for-testing")
+	if err != nil {
+		t.Fatalf("Failed to create manager: %v", err)
+	}
+
+	testUser := &User{ID: 1, GitHubUID: 12345, Login: "testuser", AvatarURL: "https://example.com/avatar.jpg"}
+
+	get := func(value string) (*User, *httptest.ResponseRecorder, error) {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.AddCookie(&http.Cookie{Name: cookieName, Value: value})
+		w := httptest.NewRecorder()
+		user, err := manager.Refresh(w, req)
+		return user, w, err
+	}
+
+	w := httptest.NewRecorder()
+	if err := manager.Set(w, httptest.NewRequest("GET", "/", nil), testUser); err != nil {
+		t.Fatalf("Failed to set session: %v", err)
+	}
+	sealed := w.Result().Cookies()[0].Value
+
+	t.Run("payload cannot be read", func(t *testing.T) {
+		parts := strings.Split(sealed, ".")
+		if len(parts) != 3 || parts[0] != encryptedVersion {
+			t.Fatalf("Expected an encrypted cookie, got %q", sealed)
+		}
+		decoded, err := base64.RawURLEncoding.DecodeString(parts[2])
+		if err != nil {
+			t.Fatalf("Expected base64 ciphertext: %v", err)
+		}
+		for _, field := range []string{"testuser", "12345", "avatar"} {
+			if strings.Contains(sealed, field) || strings.Contains(string(decoded), field) {
+				t.Errorf("Expected %q to be hidden, got %q", field, sealed)
+			}
+		}
+	})
+
+	t.Run("sealing twice differs", func(t *testing.T) {
+		first, err := manager.seal("", []byte("same"))
+		if err != nil {
+			t.Fatalf("Failed to seal: %v", err)
+		}
+		second, _ := manager.seal("", []byte("same"))
+		if first == second {
+			t.Error("Expected a fresh nonce each time")
+		}
+	})
+
+	t.Run("tampering is detected", func(t *testing.T) {
+		i := len(sealed) - 5
+		flipped := byte('A')
+		if sealed[i] == 'A' {
+			flipped = 'B'
+		}
+		if _, _, err := get(sealed[:i] + string(flipped) + sealed[i+1:]); err != ErrInvalidSession {
+			t.Errorf("Expected ErrInvalidSession, got %v", err)
+		}
+	})
+
+	t.Run("purposes do not mix", func(t *testing.T) {
+		token, err := manager.seal(tokenPurpose, []byte(`{"id":2,"login":"intruder"}`))
+		if err != nil {
+			t.Fatalf("Failed to seal: %v", err)
+		}
+		if _, _, err := get(token); err != ErrInvalidSession {
+			t.Errorf("Expected ErrInvalidSession, got %v", err)
+		}
+	})
+
+	t.Run("signed cookies are accepted and encrypted", func(t *testing.T) {
+		data, _ := json.Marshal(testUser)
+		encoded := base64.URLEncoding.EncodeToString(data)
+		signed := KeyID("test-secret-key-that-is-long-enough-for-testing") + "." + encoded + "." + manager.sign(encoded)
+		manager.AcceptLegacyFrom(time.Now())
+
+		user, w, err := get(signed)
+		if err != nil || user.Login != testUser.Login || user.GitHubUID != testUser.GitHubUID {
+			t.Fatalf("Expected the signed cookie to be accepted, got %+v (%v)", user, err)
+		}
+		cookies := w.Result().Cookies()
+		if len(cookies) != 1 || !strings.HasPrefix(cookies[0].Value, encryptedVersion+".") {
+			t.Errorf("Expected the cookie to be encrypted, got %+v", cookies)
+		}
+
+		forged := KeyID("test-secret-key-that-is-long-enough-for-testing") + "." + encoded + ".forged"
+		if _, _, err := get(forged); err != ErrInvalidSession {
+			t.Errorf("Expected a forged signed cookie to fail, got %v", err)
+		}
+	})
+}
+
+func TestSignToken(t *testing.T) {
+	manager, err := NewManager("test-secret-key-that-is-long-enough-for-testing")
+	if err != nil {
+		t.Fatalf("Failed to create manager: %v", err)
+	}
+
+	token := manager.SignToken("invite", "42")
+	if value, ok := manager.VerifyToken("invite", token); !ok || value != "42" {
+		t.Errorf("Expected token to verify as 42, got %q (%v)", value, ok)
+	}
+
+	if _, ok := manager.VerifyToken("other", token); ok {
+		t.Error("Expected token to fail for another purpose")
+	}
+
+	tampered := "43" + strings.TrimPrefix(token, "42")
+	if _, ok := manager.VerifyToken("invite", tampered); ok {
+		t.Error("Expected tampered token to fail")
+	}
+
+	if _, ok := manager.VerifyToken("invite", "42"); ok {
+		t.Error("Expected unsigned token to fail")
+	}
+}
+
 func TestGenerateState(t *testing.T) {
 	state1, err := GenerateState()
 	if err != nil {
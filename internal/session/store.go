@@ -0,0 +1,118 @@
+package session
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"net"
+	"net/http"
+	"time"
+
+	"blazing/internal/db"
+)
+
+// touchInterval limits how often a session's last-seen time is written
+// back, so busy clients do not turn every request into a write.
+const touchInterval = time.Minute
+
+const maxUserAgentLength = 255
+
+// Client describes the device a session was used from.
+type Client struct {
+	UserAgent string
+	IP        string
+}
+
+func clientOf(r *http.Request) Client {
+	ip := r.RemoteAddr
+	if host, _, err := net.SplitHostPort(ip); err == nil {
+		ip = host
+	}
+	agent := r.UserAgent()
+	if len(agent) > maxUserAgentLength {
+		agent = agent[:maxUserAgentLength]
+	}
+	return Client{UserAgent: agent, IP: ip}
+}
+
+// Store keeps sessions server-side, so they can be listed and revoked
+// before their cookie runs out. Tokens reach the store already hashed.
+type Store interface {
+	// Create records a new session for the user.
+	Create(ctx context.Context, tokenHash string, userID int64, client Client) error
+	// Lookup returns the user a session belongs to and when it started,
+	// noting that it was just used from client. It returns
+	// ErrInvalidSession if there is no such session.
+	Lookup(ctx context.Context, tokenHash string, client Client) (*User, time.Time, error)
+	// Delete ends a session. Deleting one that is already gone is not an
+	// error.
+	Delete(ctx context.Context, tokenHash string) error
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// DBStore keeps sessions in the sessions table, pruning those past expiry
+// as people sign in.
+type DBStore struct {
+	q      *db.Queries
+	expiry Expiry
+}
+
+func NewDBStore(q *db.Queries, expiry Expiry) *DBStore {
+	return &DBStore{q: q, expiry: expiry}
+}
+
+func (s *DBStore) Create(ctx context.Context, tokenHash string, userID int64, client Client) error {
+	// Sessions nobody signed out of pile up otherwise
+	now := time.Now().UTC()
+	err := s.q.DeleteExpiredSessions(ctx, db.DeleteExpiredSessionsParams{
+		UserID:     userID,
+		CreatedAt:  sql.NullTime{Time: now.Add(-s.expiry.Lifetime), Valid: true},
+		LastSeenAt: sql.NullTime{Time: now.Add(-s.expiry.IdleTimeout), Valid: true},
+	})
+	if err != nil {
+		return err
+	}
+
+	return s.q.CreateSession(ctx, db.CreateSessionParams{
+		TokenHash: tokenHash,
+		UserID:    userID,
+		UserAgent: client.UserAgent,
+		IpAddress: client.IP,
+	})
+}
+
+func (s *DBStore) Lookup(ctx context.Context, tokenHash string, client Client) (*User, time.Time, error) {
+	row, err := s.q.GetSessionByToken(ctx, tokenHash)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, time.Time{}, ErrInvalidSession
+		}
+		return nil, time.Time{}, err
+	}
+
+	if time.Since(row.LastSeenAt.Time) >= touchInterval {
+		err := s.q.TouchSession(ctx, db.TouchSessionParams{IpAddress: client.IP, ID: row.ID})
+		if err != nil {
+			return nil, time.Time{}, err
+		}
+	}
+
+	user := &User{
+		ID:        row.UserID,
+		GitHubUID: row.GithubUid.Int64,
+		Login:     row.Login,
+		AvatarURL: row.AvatarUrl.String,
+		SessionID: row.ID,
+	}
+	return user, row.CreatedAt.Time, nil
+}
+
+func (s *DBStore) Delete(ctx context.Context, tokenHash string) error {
+	return s.q.DeleteSessionByToken(ctx, tokenHash)
+}
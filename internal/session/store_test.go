@@ -0,0 +1,145 @@
+package session
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+type memoryStore struct {
+	sessions map[string]memorySession
+}
+
+type memorySession struct {
+	user    User
+	started time.Time
+	client  Client
+}
+
+func (s *memoryStore) Create(ctx context.Context, tokenHash string, userID int64, client Client) error {
+	s.sessions[tokenHash] = memorySession{user: User{ID: userID, Login: "testuser"}, started: time.Now(), client: client}
+	return nil
+}
+
+func (s *memoryStore) Lookup(ctx context.Context, tokenHash string, client Client) (*User, time.Time, error) {
+	session, ok := s.sessions[tokenHash]
+	if !ok {
+		return nil, time.Time{}, ErrInvalidSession
+	}
+	return &session.user, session.started, nil
+}
+
+func (s *memoryStore) Delete(ctx context.Context, tokenHash string) error {
+	delete(s.sessions, tokenHash)
+	return nil
+}
+
+func TestServerSideSessions(t *testing.T) {
+	manager, err := NewManager("test-secret-key-that-is-long-enough-for-testing")
+	if err != nil {
+		t.Fatalf("Failed to create manager: %v", err)
+	}
+	store := &memoryStore{sessions: make(map[string]memorySession)}
+	manager.UseStore(store)
+
+	signIn := func() *http.Cookie {
+		t.Helper()
+		req := httptest.NewRequest("GET", "/auth/github/callback", nil)
+		req.RemoteAddr = "203.0.113.7:51234"
+		req.Header.Set("User-Agent", "Mozilla/5.0 (X11; Linux x86_64) Firefox/130.0")
+		w := httptest.NewRecorder()
+		if err := manager.Set(w, req, &User{ID: 1, Login: "testuser"}); err != nil {
+			t.Fatalf("Failed to set session: %v", err)
+		}
+		return w.Result().Cookies()[0]
+	}
+
+	get := func(cookie *http.Cookie) (*User, error) {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.AddCookie(cookie)
+		return manager.Get(req)
+	}
+
+	t.Run("cookie names a stored session", func(t *testing.T) {
+		cookie := signIn()
+		if strings.Contains(cookie.Value, "testuser") {
+			t.Errorf("Expected the cookie to hold only a token, got %q", cookie.Value)
+		}
+
+		user, err := get(cookie)
+		if err != nil || user.ID != 1 {
+			t.Fatalf("Expected the stored user, got %+v (%v)", user, err)
+		}
+
+		for _, session := range store.sessions {
+			if session.client.IP != "203.0.113.7" || !strings.Contains(session.client.UserAgent, "Firefox") {
+				t.Errorf("Expected the device to be recorded, got %+v", session.client)
+			}
+		}
+	})
+
+	t.Run("revoked sessions stop working", func(t *testing.T) {
+		cookie := signIn()
+		for hash := range store.sessions {
+			delete(store.sessions, hash)
+		}
+		if _, err := get(cookie); err != ErrInvalidSession {
+			t.Errorf("Expected ErrInvalidSession, got %v", err)
+		}
+	})
+
+	t.Run("sessions expire with their cookie", func(t *testing.T) {
+		cookie := signIn()
+		for hash, session := range store.sessions {
+			session.started = time.Now().Add(-DefaultExpiry.Lifetime - time.Minute)
+			store.sessions[hash] = session
+		}
+		if _, err := get(cookie); err != ErrInvalidSession {
+			t.Errorf("Expected ErrInvalidSession, got %v", err)
+		}
+		if len(store.sessions) != 0 {
+			t.Error("Expected the expired session to be deleted")
+		}
+	})
+
+	t.Run("clear deletes the session", func(t *testing.T) {
+		cookie := signIn()
+		req := httptest.NewRequest("GET", "/logout", nil)
+		req.AddCookie(cookie)
+		manager.Clear(httptest.NewRecorder(), req)
+		if len(store.sessions) != 0 {
+			t.Error("Expected signing out to delete the session")
+		}
+	})
+
+	t.Run("cookie-only sessions are not accepted", func(t *testing.T) {
+		plain, err := NewManager("test-secret-key-that-is-long-enough-for-testing")
+		if err != nil {
+			t.Fatalf("Failed to create manager: %v", err)
+		}
+		w := httptest.NewRecorder()
+		if err := plain.Set(w, httptest.NewRequest("GET", "/", nil), &User{ID: 1, Login: "testuser"}); err != nil {
+			t.Fatalf("Failed to set session: %v", err)
+		}
+		if _, err := get(w.Result().Cookies()[0]); err != ErrInvalidSession {
+			t.Errorf("Expected ErrInvalidSession, got %v", err)
+		}
+	})
+}
+
+func TestClientOf(t *testing.T) {
+	req := httptest.NewRequest("GET", "/", nil)
+	req.RemoteAddr = "[2001:db8::1]:443"
+	req.Header.Set("User-Agent", strings.Repeat("x", 1000))
+
+	client := clientOf(req)
+	if client.IP != "2001:db8::1" {
+		t.Errorf("Expected the port to be dropped, got %q", client.IP)
+	}
+	if len(client.UserAgent) != maxUserAgentLength {
+		t.Errorf("Expected the user agent to be truncated, got %d bytes", len(client.UserAgent))
+	}
+}
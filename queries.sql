@@ -20,8 +20,387 @@ RETURNING *;
 UPDATE users SET login = ?, avatar_url = ?, updated_at = CURRENT_TIMESTAMP
 WHERE id = ?;
 
+-- name: DisableUser :execrows
+UPDATE users SET disabled_at = CURRENT_TIMESTAMP WHERE id = ? AND disabled_at IS NULL;
+
+-- name: EnableUser :execrows
+UPDATE users SET disabled_at = NULL WHERE id = ? AND disabled_at IS NOT NULL;
+
 -- name: GetUserRooms :many
 SELECT r.* FROM rooms r
 JOIN room_memberships rm ON r.id = rm.room_id
 WHERE rm.user_id = ?
-ORDER BY r.created_at DESC;
\ No newline at end of file
+ORDER BY r.created_at DESC;
+
+-- name: GetRoomMembership :one
+SELECT * FROM room_memberships WHERE room_id = ? AND user_id = ? LIMIT 1;
+
+-- name: CreateRoom :one
+INSERT INTO rooms (name, creator_id) VALUES (?, ?)
+RETURNING *;
+
+-- name: CreateRoomMembership :exec
+INSERT INTO room_memberships (room_id, user_id, role) VALUES (?, ?, ?);
+
+-- name: GetRoom :one
+SELECT * FROM rooms WHERE id = ? LIMIT 1;
+
+-- name: GetDirectRoom :one
+SELECT * FROM rooms WHERE dm_key = ? LIMIT 1;
+
+-- name: CreateDirectRoom :one
+INSERT INTO rooms (name, creator_id, kind, dm_key) VALUES (?, ?, 'dm', ?)
+ON CONFLICT (dm_key) WHERE dm_key IS NOT NULL DO NOTHING
+RETURNING *;
+
+-- name: CreateMessage :one
+INSERT INTO messages (room_id, user_id, body) VALUES (?, ?, ?)
+RETURNING *;
+
+-- name: CreateSystemMessage :one
+INSERT INTO messages (room_id, user_id, body, kind) VALUES (?, ?, ?, 'system')
+RETURNING *;
+
+-- name: GetRoomMessagesBefore :many
+SELECT m.id, m.room_id, m.user_id, m.body, m.created_at, m.edited_at, m.deleted_at,
+    m.parent_message_id, m.reply_count, m.last_reply_at, m.kind, u.login, u.avatar_url
+FROM messages m
+JOIN users u ON u.id = m.user_id
+WHERE m.room_id = sqlc.arg(room_id) AND m.id < sqlc.arg(before_id)
+    AND m.parent_message_id IS NULL
+ORDER BY m.id DESC
+LIMIT sqlc.arg(limit);
+
+-- name: GetRoomMessagesAfter :many
+SELECT m.id, m.room_id, m.user_id, m.body, m.created_at, m.edited_at, m.deleted_at,
+    m.parent_message_id, m.reply_count, m.last_reply_at, m.kind, u.login, u.avatar_url
+FROM messages m
+JOIN users u ON u.id = m.user_id
+WHERE m.room_id = sqlc.arg(room_id) AND m.id > sqlc.arg(after_id)
+    AND m.parent_message_id IS NULL
+ORDER BY m.id ASC
+LIMIT sqlc.arg(limit);
+
+-- name: GetUserByLogin :one
+SELECT * FROM users WHERE login = ? LIMIT 1;
+
+-- name: CreatePendingUser :one
+INSERT INTO users (login) VALUES (?)
+RETURNING *;
+
+-- name: ClaimPendingUser :one
+UPDATE users SET github_uid = ?, login = ?, avatar_url = ?, updated_at = CURRENT_TIMESTAMP
+WHERE id = ? AND github_uid IS NULL
+RETURNING *;
+
+-- name: GetRoomMembers :many
+SELECT u.id, u.github_uid, u.login, u.avatar_url, rm.joined_at, rm.role
+FROM room_memberships rm
+JOIN users u ON u.id = rm.user_id
+WHERE rm.room_id = ?
+ORDER BY u.login;
+
+-- name: UpdateMembershipRole :exec
+UPDATE room_memberships SET role = ? WHERE room_id = ? AND user_id = ?;
+
+-- name: UpdateRoomName :exec
+UPDATE rooms SET name = ? WHERE id = ?;
+
+-- name: UpdateRoomTopic :exec
+UPDATE rooms SET topic = ? WHERE id = ?;
+
+-- name: ArchiveRoom :exec
+UPDATE rooms SET archived_at = CURRENT_TIMESTAMP WHERE id = ? AND archived_at IS NULL;
+
+-- name: UnarchiveRoom :exec
+UPDATE rooms SET archived_at = NULL WHERE id = ?;
+
+-- name: DeleteRoom :exec
+DELETE FROM rooms WHERE id = ?;
+
+-- name: UpdateRoomPolicies :exec
+UPDATE rooms SET post_policy = ?, invite_policy = ?, updated_at = CURRENT_TIMESTAMP
+WHERE id = ?;
+
+-- name: DeleteRoomMembership :exec
+DELETE FROM room_memberships WHERE room_id = ? AND user_id = ?;
+
+-- name: SearchMessages :many
+SELECT m.id, m.room_id, m.user_id, m.parent_message_id, m.created_at, r.name AS room_name, u.login, u.avatar_url,
+    CAST(snippet(messages_fts, 0, char(2), char(3), '…', 16) AS TEXT) AS snippet
+FROM messages_fts
+JOIN messages m ON m.id = messages_fts.rowid
+JOIN rooms r ON r.id = m.room_id
+JOIN users u ON u.id = m.user_id
+JOIN room_memberships rm ON rm.room_id = m.room_id AND rm.user_id = sqlc.arg(user_id)
+WHERE messages_fts MATCH sqlc.arg(query)
+    AND m.deleted_at IS NULL AND m.kind = 'message'
+    AND (sqlc.narg(room_id) IS NULL OR m.room_id = sqlc.narg(room_id))
+    AND (sqlc.narg(author_id) IS NULL OR m.user_id = sqlc.narg(author_id))
+    AND (sqlc.narg(since) IS NULL OR m.created_at >= sqlc.narg(since))
+    AND (sqlc.narg(until) IS NULL OR m.created_at < sqlc.narg(until))
+ORDER BY rank
+LIMIT sqlc.arg(limit);
+
+-- name: GetRoomMessage :one
+SELECT m.id, m.room_id, m.user_id, m.body, m.created_at, m.edited_at, m.deleted_at,
+    m.parent_message_id, m.reply_count, m.last_reply_at, m.kind, u.login, u.avatar_url
+FROM messages m
+JOIN users u ON u.id = m.user_id
+WHERE m.id = ? AND m.room_id = ?
+LIMIT 1;
+
+-- name: UpdateMessageBody :exec
+UPDATE messages SET body = ?, edited_at = CURRENT_TIMESTAMP
+WHERE id = ? AND deleted_at IS NULL;
+
+-- name: SoftDeleteMessage :exec
+UPDATE messages SET deleted_at = CURRENT_TIMESTAMP
+WHERE id = ? AND deleted_at IS NULL;
+
+-- name: CreateMessageEdit :exec
+INSERT INTO message_edits (message_id, editor_id, body) VALUES (?, ?, ?);
+
+-- name: GetMessageEdits :many
+SELECT e.id, e.body, e.edited_at, u.login
+FROM message_edits e
+JOIN users u ON u.id = e.editor_id
+WHERE e.message_id = ?
+ORDER BY e.id DESC;
+
+-- name: CreateMessageReaction :exec
+INSERT OR IGNORE INTO message_reactions (message_id, user_id, emoji) VALUES (?, ?, ?);
+
+-- name: DeleteMessageReaction :execrows
+DELETE FROM message_reactions WHERE message_id = ? AND user_id = ? AND emoji = ?;
+
+-- name: GetMessageReactions :many
+SELECT r.message_id, r.emoji, COUNT(*) AS count,
+    CAST(group_concat(r.user_id, ' ') AS TEXT) AS user_ids,
+    CAST(group_concat(u.login, ', ') AS TEXT) AS logins
+FROM message_reactions r
+JOIN users u ON u.id = r.user_id
+WHERE r.message_id IN (sqlc.slice('message_ids'))
+GROUP BY r.message_id, r.emoji
+ORDER BY r.message_id, MIN(r.created_at), r.emoji;
+
+-- name: CreateReply :one
+INSERT INTO messages (room_id, user_id, body, parent_message_id) VALUES (?, ?, ?, ?)
+RETURNING *;
+
+-- name: GetThreadReplies :many
+SELECT m.id, m.room_id, m.user_id, m.body, m.created_at, m.edited_at, m.deleted_at,
+    m.parent_message_id, m.reply_count, m.last_reply_at, m.kind, u.login, u.avatar_url
+FROM messages m
+JOIN users u ON u.id = m.user_id
+WHERE m.parent_message_id = sqlc.arg(parent_message_id) AND m.id > sqlc.arg(after_id)
+ORDER BY m.id ASC
+LIMIT sqlc.arg(limit);
+
+-- name: GetUserRoomsWithUnread :many
+SELECT r.id, r.name, r.kind, r.archived_at,
+    CAST(CASE WHEN r.kind = 'dm' THEN COALESCE(
+        (SELECT group_concat(u.login, ', ') FROM room_memberships o
+         JOIN users u ON u.id = o.user_id
+         WHERE o.room_id = r.id AND o.user_id != rm.user_id), '')
+    ELSE '' END AS TEXT) AS other_logins,
+    (SELECT COUNT(*) FROM messages m
+     WHERE m.room_id = r.id AND m.id > rm.last_read_message_id AND m.user_id != rm.user_id
+        AND m.parent_message_id IS NULL AND m.deleted_at IS NULL) AS unread_count,
+    (SELECT COUNT(DISTINCT m.id) FROM messages m
+     JOIN message_mentions mm ON mm.message_id = m.id AND mm.user_id = rm.user_id
+     WHERE m.room_id = r.id AND m.id > rm.last_read_message_id
+        AND m.parent_message_id IS NULL AND m.deleted_at IS NULL) AS mention_count,
+    rm.notify_level, rm.muted_until
+FROM rooms r
+JOIN room_memberships rm ON rm.room_id = r.id
+WHERE rm.user_id = ?
+ORDER BY r.created_at DESC;
+
+-- name: GetRoomUnread :one
+SELECT
+    (SELECT COUNT(*) FROM messages m
+     WHERE m.room_id = rm.room_id AND m.id > rm.last_read_message_id AND m.user_id != rm.user_id
+        AND m.parent_message_id IS NULL AND m.deleted_at IS NULL) AS unread_count,
+    (SELECT COUNT(DISTINCT m.id) FROM messages m
+     JOIN message_mentions mm ON mm.message_id = m.id AND mm.user_id = rm.user_id
+     WHERE m.room_id = rm.room_id AND m.id > rm.last_read_message_id
+        AND m.parent_message_id IS NULL AND m.deleted_at IS NULL) AS mention_count,
+    rm.notify_level, rm.muted_until
+FROM room_memberships rm
+WHERE rm.room_id = ? AND rm.user_id = ?;
+
+-- name: MarkRoomRead :exec
+UPDATE room_memberships
+SET last_read_message_id = MAX(last_read_message_id, MIN(sqlc.arg(message_id),
+    (SELECT COALESCE(MAX(id), 0) FROM messages WHERE messages.room_id = sqlc.arg(room_id))))
+WHERE room_id = sqlc.arg(room_id) AND user_id = sqlc.arg(user_id);
+
+-- name: GetUserRoomMates :many
+SELECT rm.room_id, rm.user_id
+FROM room_memberships rm
+JOIN room_memberships mine ON mine.room_id = rm.room_id
+WHERE mine.user_id = sqlc.arg(user_id) AND rm.user_id != sqlc.arg(user_id);
+
+-- name: GetRoomMembersByLogin :many
+SELECT u.id, u.login
+FROM room_memberships rm
+JOIN users u ON u.id = rm.user_id
+WHERE rm.room_id = sqlc.arg(room_id) AND lower(u.login) IN (sqlc.slice('logins'));
+
+-- name: CreateMessageMention :exec
+INSERT OR IGNORE INTO message_mentions (message_id, user_id, kind) VALUES (?, ?, ?);
+
+-- name: DeleteMessageMentions :exec
+DELETE FROM message_mentions WHERE message_id = ?;
+
+-- name: GetMessageMentions :many
+SELECT mm.message_id, mm.kind,
+    CAST(group_concat(mm.user_id, ' ') AS TEXT) AS user_ids,
+    CAST(group_concat(u.login, ' ') AS TEXT) AS logins
+FROM message_mentions mm
+JOIN users u ON u.id = mm.user_id
+WHERE mm.message_id IN (sqlc.slice('message_ids'))
+GROUP BY mm.message_id, mm.kind;
+
+-- name: CreateRoomInvite :one
+INSERT INTO room_invites (room_id, creator_id, expires_at, max_uses) VALUES (?, ?, ?, ?)
+RETURNING *;
+
+-- name: GetRoomInvite :one
+SELECT * FROM room_invites WHERE id = ?;
+
+-- name: GetRoomInvites :many
+SELECT i.*, u.login AS creator_login
+FROM room_invites i
+JOIN users u ON u.id = i.creator_id
+WHERE i.room_id = ? AND i.revoked_at IS NULL
+ORDER BY i.id DESC;
+
+-- name: UseRoomInvite :execrows
+UPDATE room_invites SET use_count = use_count + 1
+WHERE id = ? AND revoked_at IS NULL AND (max_uses IS NULL OR use_count < max_uses);
+
+-- name: RevokeRoomInvite :exec
+UPDATE room_invites SET revoked_at = CURRENT_TIMESTAMP WHERE id = ? AND revoked_at IS NULL;
+
+-- name: CreateAttachment :one
+INSERT INTO attachments (message_id, user_id, storage_key, thumbnail_key, filename, content_type, size, width, height)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+RETURNING *;
+
+-- name: GetMessageAttachments :many
+SELECT * FROM attachments
+WHERE message_id IN (sqlc.slice('message_ids'))
+ORDER BY id;
+
+-- name: GetRoomAttachment :one
+SELECT a.*
+FROM attachments a
+JOIN messages m ON m.id = a.message_id
+WHERE a.id = ? AND m.room_id = ? AND m.deleted_at IS NULL;
+
+-- name: DeleteMessageAttachments :many
+DELETE FROM attachments WHERE message_id = ?
+RETURNING *;
+
+-- name: GetRoomAttachmentKeys :many
+SELECT a.storage_key, a.thumbnail_key
+FROM attachments a
+JOIN messages m ON m.id = a.message_id
+WHERE m.room_id = ?;
+
+-- name: AttachmentKeyInUse :one
+SELECT EXISTS (
+    SELECT 1 FROM attachments
+    WHERE storage_key = sqlc.arg(key) OR thumbnail_key = sqlc.arg(key)
+) AS in_use;
+
+-- name: PinMessage :exec
+INSERT INTO pinned_messages (room_id, message_id, pinned_by)
+VALUES (?, ?, ?)
+ON CONFLICT (room_id, message_id) DO NOTHING;
+
+-- name: UnpinMessage :execrows
+DELETE FROM pinned_messages WHERE room_id = ? AND message_id = ?;
+
+-- name: GetPinnedMessages :many
+SELECT m.id, m.user_id, m.body, m.created_at, u.login, u.avatar_url,
+    p.created_at AS pinned_at, pu.login AS pinned_by_login
+FROM pinned_messages p
+JOIN messages m ON m.id = p.message_id
+JOIN users u ON u.id = m.user_id
+JOIN users pu ON pu.id = p.pinned_by
+WHERE p.room_id = ? AND m.deleted_at IS NULL
+ORDER BY p.created_at DESC, p.message_id DESC;
+
+-- name: GetPinnedMessageIDs :many
+SELECT message_id FROM pinned_messages
+WHERE message_id IN (sqlc.slice('message_ids'));
+
+-- name: SaveMessage :exec
+INSERT INTO saved_messages (user_id, message_id)
+VALUES (?, ?)
+ON CONFLICT (user_id, message_id) DO NOTHING;
+
+-- name: UnsaveMessage :exec
+DELETE FROM saved_messages WHERE user_id = ? AND message_id = ?;
+
+-- name: GetSavedMessages :many
+SELECT m.id, m.room_id, m.user_id, m.body, m.parent_message_id, m.created_at,
+    r.name AS room_name, u.login, u.avatar_url, s.created_at AS saved_at
+FROM saved_messages s
+JOIN messages m ON m.id = s.message_id
+JOIN rooms r ON r.id = m.room_id
+JOIN room_memberships rm ON rm.room_id = m.room_id AND rm.user_id = s.user_id
+JOIN users u ON u.id = m.user_id
+WHERE s.user_id = ? AND m.deleted_at IS NULL
+ORDER BY s.created_at DESC, s.message_id DESC
+LIMIT ?;
+
+-- name: GetSavedMessageIDs :many
+SELECT message_id FROM saved_messages
+WHERE user_id = ? AND message_id IN (sqlc.slice('message_ids'));
+
+-- name: UpdateRoomNotifications :exec
+UPDATE room_memberships SET notify_level = ?, muted_until = ?
+WHERE room_id = ? AND user_id = ?;
+
+-- name: GetMemberNotificationSettings :many
+SELECT user_id, notify_level, muted_until FROM room_memberships
+WHERE room_id = sqlc.arg(room_id) AND user_id IN (sqlc.slice('user_ids'));
+
+-- name: CreateSession :exec
+INSERT INTO sessions (token_hash, user_id, user_agent, ip_address) VALUES (?, ?, ?, ?);
+
+-- name: GetSessionByToken :one
+SELECT s.id, s.user_id, s.created_at, s.last_seen_at, u.github_uid, u.login, u.avatar_url
+FROM sessions s
+JOIN users u ON u.id = s.user_id
+WHERE s.token_hash = ?;
+
+-- name: TouchSession :exec
+UPDATE sessions SET last_seen_at = CURRENT_TIMESTAMP, ip_address = ? WHERE id = ?;
+
+-- name: GetUserSessions :many
+SELECT * FROM sessions
+WHERE user_id = ?
+ORDER BY last_seen_at DESC, id DESC;
+
+-- name: DeleteSession :execrows
+DELETE FROM sessions WHERE id = ? AND user_id = ?;
+
+-- name: DeleteSessionByToken :exec
+DELETE FROM sessions WHERE token_hash = ?;
+
+-- name: DeleteOtherSessions :execrows
+DELETE FROM sessions WHERE user_id = ? AND id != ?;
+
+-- name: DeleteUserSessions :execrows
+DELETE FROM sessions WHERE user_id = ?;
+
+-- name: GetSessionUpgrade :one
+SELECT upgraded_at FROM session_upgrade WHERE id = 1;
+
+-- name: DeleteExpiredSessions :exec
+DELETE FROM sessions WHERE user_id = ? AND (created_at < ? OR last_seen_at < ?);